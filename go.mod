@@ -1,3 +1,12 @@
 module github.com/tpfeiffer67/ObsidianToQuartz
 
 go 1.21.1
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require (
+	github.com/fsnotify/fsnotify v1.7.0
+	golang.org/x/text v0.14.0
+)
+
+require golang.org/x/sys v0.5.0 // indirect
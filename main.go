@@ -2,262 +2,108 @@
 ObsidianToQuartz - A tool to copy content from Obsidian to Quartz
 
 Features:
-- Copies content to a "content" folder in the Quartz directory
+- Copies content to a "content" folder in the Quartz directory, or to another folder (--content-dir)
+- Reads the vault from a folder, or from a zip archive without unpacking it
 - Only copies .svg files from Excalidraw folders
 - Transforms Excalidraw links:
   - Wiki-style: [[drawing.excalidraw]] → [[drawing.excalidraw.svg|drawing]]
   - Markdown-style: [text](drawing.excalidraw.md) → [text](drawing.excalidraw.svg)
+- Shows drawings exported as a light and a dark SVG according to the theme (--excalidraw-theme=dual)
+- Lists drawings linked without an SVG export (--fail-on-missing-drawings)
+- Warns about note names used in several folders and the links to them by name only (--fail-on-ambiguous-links)
 - Skips all directories starting with . (like .obsidian, .trash)
-- Supports exclusion patterns via .obsidian-to-quartz-ignore file
-
-Usage: ObsidianToQuartz <Obsidian_Folder> <Quartz_Folder>
+- Reports symbolic links to folders, or follows them (--follow-symlinks)
+- Supports exclusion patterns following the rules of .gitignore via .obsidian-to-quartz-ignore file, another ignore file (--ignore-file) and --exclude
+- Reads the .obsidian-to-quartz-ignore files of the vault folders, whose patterns apply inside their folder
+- Leaves out the template and script folders configured in Obsidian, Templater and Excalidraw (--no-auto-exclude to disable)
+- Overrides the exclusion patterns for a single run (--override)
+- Leaves out the folders holding a vault of their own (--skip-nested-vaults); the files of the tool are never published
+- Leaves out the conflict copies of sync services, iCloud placeholders and editor temporary files (--no-skip-conflicts, --conflict-pattern)
+- Also leaves out the paths ignored by the .gitignore files of the vault (--respect-gitignore)
+- Leaves out large files and files by extension, and reports the notes embedding them (--max-file-size, --exclude-ext, --include-ext)
+- Selects the published notes with a filter expression on path, tags, frontmatter, date and size (--filter)
+- Optionally strips Dataview and query blocks (--strip-dataview)
+- Optionally strips HTML comments and lists leftover template syntax (--strip-html-comments, --warn-template-syntax)
+- Skips .canvas files or publishes them as generated markdown pages (--canvas)
+- Routes .html files to the Quartz static folder or wraps them in an iframe page (--html)
+- Rewrites obsidian://open links into wikilinks and reports app:// and file:// links (--obsidian-uris, --local-urls)
+- Rewrites PDF, audio and video embeds into links or HTML tags (--media-embeds)
+- Rewrites sized image embeds into markdown embeds or <img> tags (--image-size)
+- Strips ^blockid markers and rewrites block reference links (--block-refs)
+- Rewrites heading links into the anchors Quartz gives headings (--heading-links)
+- Rewrites custom callout types into types Quartz styles, and strips fold markers (--callout-map, --callout-default, --callout-folds)
+- Replaces embeds of excluded or missing notes with a placeholder, or removes them (--missing-embeds)
+- Warns about links to notes that are not published, and turns them into plain text or removes them (--unpublished-links)
+- Shows custom task statuses as plain checkboxes and strips the metadata of the Tasks plugin (--normalize-tasks, --strip-task-metadata)
+- Treats absolute links to the published site as internal links (--site-base-url)
+- Migrates from Obsidian Publish using publish: true and permalink frontmatter (--from-obsidian-publish)
+- Leaves out notes marked publish: false or draft: true (--skip-unpublished)
+- Publishes a note to the path set by its quartz-path frontmatter and rewrites links to it
+- Controls output with --quiet and --verbose; warnings and errors always go to stderr
+- Validates frontmatter against rules from the config file (--strict-frontmatter-rules)
+- Targets the syntax of a given version of Quartz (--quartz-compat)
+- Adds a title from the first H1 or the file name to notes without one (--add-title, --strip-h1)
+- Adds a description from the first paragraph to notes without one, for RSS and link previews (--add-description)
+- Adds a banner or a footer to every published note (--prepend-file, --append-file, --no-snippet)
+- Merges inline #tags into the tags frontmatter list (--collect-inline-tags, --strip-inline-tags)
+- Drops, renames and adds frontmatter keys of the published notes (--fm-drop, --fm-rename, --fm-set)
+- Locates invalid YAML frontmatter with a hint, and publishes the note without it (--strict-frontmatter, --fail-on-frontmatter-errors)
+- Warns about frontmatter dates that are not ISO dates and tags written as a map (--no-validate to skip)
+- Warns about markdown Quartz parses differently than Obsidian, with safe fixes (check, --fix)
+- Exports a single note and the files it needs to a folder or a zip (export-note)
+- Exports a self-contained HTML preview that opens in a browser without Quartz (export --standalone)
+- Merges several vaults or folders into subfolders of one site (--source)
+- Publishes vault folders under another name and rewrites links to them (--map)
+- Gathers attachments into a single folder and rewrites links to them (--attachments-to)
+- Publishes date-named daily notes in folders built from their date (--date-folders)
+- Renames files whose names break on Windows or web hosts, or warns about them (--sanitize-names)
+- Publishes file names and link targets in one Unicode form, NFC by default (--normalize-unicode)
+- Warns about, excludes or splits notes too large for Quartz (--max-note-size, --oversize-notes)
+- Copies notes too large to transform, and binary files named .md, as they are (--max-transform-size)
+- Generates a static page per tag and a tags overview page (--emit-tag-pages)
+- Generates an index page listing the notes of each folder without an index.md (--generate-indexes, --index-sort)
+- Shows progress for large vaults (--progress)
+- Optionally wipes the content folder before copying, with safety checks (--clean)
+- Optionally deletes the published files whose note is gone from the vault (--prune)
+- Never writes or deletes outside the content folder, and refuses a content folder inside the vault
+- Optionally keeps existing or hand-edited destination files (--no-clobber, --update-only)
+- Keeps running and syncs on an interval, with optional jitter (--every, --jitter)
+- Keeps running and publishes the files of the vault again as they change (--watch)
+- Lists what a run would write, overwrite, skip and delete without writing anything (--dry-run)
+- Turns transform steps off by folder with the rules of the config file
+- Locks the Quartz folder during a sync so overlapping runs are refused or wait (--wait, --force-unlock)
+- Only publishes the files changed in the vault's git repository since a commit, and records the commit published (--since-git, --write-ref)
+- Only publishes the files changed since the last run, tracked in a state file of the Quartz folder (--incremental)
+- Redirects the old URLs of the notes whose published path changed (--redirects)
+- Runs a command on each written file and after a successful run (--hook-file, --hook-post)
+- Writes files atomically so Quartz's watcher never sees half-written files
+- Hard-links or reflinks files published as they are instead of copying them (--link-mode)
+- Checks the destination has room for the run before writing (--skip-space-check to disable)
+- Shows vault-relative and content-relative paths in messages and reports (--absolute-paths to disable)
+- Prints an end-of-run summary and optionally writes a JSON report (--report-json)
+- Exits with distinct codes for success, usage errors, file errors, refused safety checks and nothing to do
+- Reads settings from an obsidian-to-quartz.yaml config file (--config)
+- Runs from other Go programs through the pkg/o2q package, which holds the conversion
+
+Usage: ObsidianToQuartz [options] <Obsidian_Folder> <Quartz_Folder>
+       ObsidianToQuartz [options] --source <Folder>:<Subfolder> [--source ...] <Quartz_Folder>
+       ObsidianToQuartz [options] --config <Config_File>
+       ObsidianToQuartz [options] check [options] <Obsidian_Folder>
+       ObsidianToQuartz [options] export [--standalone] [options] <Obsidian_Folder> <Output_Folder>
+       ObsidianToQuartz [options] export-note [options] <Obsidian_Folder> <Note> <Output_Folder_or_Zip>
 */
 
 package main
 
 import (
-	"bufio"
-	"fmt"
-	"io"
 	"os"
-	"path/filepath"
-	"regexp"
-	"strings"
-)
-
-func main() {
-	if len(os.Args) != 3 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <Obsidian_Folder> <Quartz_Folder>\n", os.Args[0])
-		os.Exit(1)
-	}
-
-	obsidianFolder := os.Args[1]
-	quartzFolder := os.Args[2]
-
-	// Read exclusion patterns from .obsidian-to-quartz-ignore file
-	excludePatterns := readExcludePatterns(obsidianFolder)
-	if len(excludePatterns) > 0 {
-		fmt.Printf("Loaded %d exclusion patterns\n", len(excludePatterns))
-	}
-
-	// Ensure Quartz content folder exists
-	contentFolder := filepath.Join(quartzFolder, "content")
-	if err := os.MkdirAll(contentFolder, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating content folder: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Walk through Obsidian folder
-	err := filepath.Walk(obsidianFolder, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip the root folder itself
-		if path == obsidianFolder {
-			return nil
-		}
-
-		// Get relative path from obsidian folder
-		relPath, err := filepath.Rel(obsidianFolder, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %v", err)
-		}
-
-		// Skip any directory starting with . (hidden folders like .obsidian, .trash, etc.)
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
-			return filepath.SkipDir
-		}
-
-		// Check if path matches any exclusion pattern
-		if shouldExclude(relPath, excludePatterns, info.IsDir()) {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Determine destination path
-		destPath := filepath.Join(contentFolder, relPath)
-
-		// Handle directories
-		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
-		}
-
-		// Check if file is in Excalidraw folder and not an SVG
-		if isInExcalidrawFolder(relPath) && !strings.HasSuffix(path, ".svg") {
-			// Skip non-SVG files in Excalidraw folders
-			return nil
-		}
-
-		// Process the file
-		if strings.HasSuffix(path, ".md") {
-			// Process markdown files (transform excalidraw links)
-			return processMarkdownFile(path, destPath)
-		} else {
-			// Copy other files as-is
-			return copyFile(path, destPath)
-		}
-	})
-
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error walking through folder: %v\n", err)
-		os.Exit(1)
-	}
-
-	fmt.Println("Conversion completed successfully!")
-}
-
-// isInExcalidrawFolder checks if a file path contains "Excalidraw" folder
-func isInExcalidrawFolder(path string) bool {
-	parts := strings.Split(filepath.ToSlash(path), "/")
-	for _, part := range parts {
-		if strings.EqualFold(part, "Excalidraw") {
-			return true
-		}
-	}
-	return false
-}
-
-// processMarkdownFile reads a markdown file, transforms excalidraw links, and writes to destination
-// Transforms:
-//   - [[drawing.excalidraw]] → [[drawing.excalidraw.svg|drawing]]
-//   - [text](drawing.excalidraw.md) → [text](drawing.excalidraw.svg)
-func processMarkdownFile(src, dest string) error {
-	// Read the source file
-	content, err := os.ReadFile(src)
-	if err != nil {
-		return fmt.Errorf("failed to read markdown file: %v", err)
-	}
-
-	// Replace .excalidraw]] with .excalidraw.svg|name]]
-	// This regex captures the filename before .excalidraw
-	re := regexp.MustCompile(`\[\[([^|\]]+?)\.excalidraw\]\]`)
-	modifiedContent := re.ReplaceAll(content, []byte("[[$1.excalidraw.svg|$1]]"))
 
-	// Also replace markdown-style links: .excalidraw.md) with .excalidraw.svg)
-	re2 := regexp.MustCompile(`\.excalidraw\.md\)`)
-	modifiedContent = re2.ReplaceAll(modifiedContent, []byte(".excalidraw.svg)"))
-
-	// Ensure destination directory exists
-	destDir := filepath.Dir(dest)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %v", err)
-	}
-
-	// Write the modified content
-	if err := os.WriteFile(dest, modifiedContent, 0644); err != nil {
-		return fmt.Errorf("failed to write markdown file: %v", err)
-	}
-
-	fmt.Printf("Processed: %s -> %s\n", src, dest)
-	return nil
-}
-
-// copyFile copies a file from src to dest
-func copyFile(src, dest string) error {
-	// Open source file
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %v", err)
-	}
-	defer srcFile.Close()
-
-	// Get file info for permissions
-	info, err := srcFile.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat source file: %v", err)
-	}
-
-	// Ensure destination directory exists
-	destDir := filepath.Dir(dest)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %v", err)
-	}
-
-	// Create destination file
-	destFile, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %v", err)
-	}
-	defer destFile.Close()
-
-	// Copy content
-	if _, err := io.Copy(destFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy file content: %v", err)
-	}
-
-	fmt.Printf("Copied: %s -> %s\n", src, dest)
-	return nil
-}
-
-// readExcludePatterns reads exclusion patterns from .obsidian-to-quartz-ignore file
-func readExcludePatterns(obsidianFolder string) []string {
-	ignoreFile := filepath.Join(obsidianFolder, ".obsidian-to-quartz-ignore")
-	file, err := os.Open(ignoreFile)
-	if err != nil {
-		// File doesn't exist, return empty patterns
-		return []string{}
-	}
-	defer file.Close()
-
-	var patterns []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Skip empty lines and comments
-		if line != "" && !strings.HasPrefix(line, "#") {
-			patterns = append(patterns, line)
-		}
-	}
-	return patterns
-}
-
-// shouldExclude checks if a path matches any exclusion pattern
-func shouldExclude(relPath string, patterns []string, isDir bool) bool {
-	// Normalize path separators for consistent matching
-	relPath = filepath.ToSlash(relPath)
-
-	for _, pattern := range patterns {
-		pattern = filepath.ToSlash(pattern)
-
-		// Check if pattern is for directories only (ends with /)
-		if strings.HasSuffix(pattern, "/") {
-			if !isDir {
-				continue
-			}
-			pattern = strings.TrimSuffix(pattern, "/")
-		}
-
-		// Check for exact match
-		if relPath == pattern {
-			return true
-		}
-
-		// Check if path starts with pattern (for directory exclusion)
-		if isDir && strings.HasPrefix(relPath+"/", pattern+"/") {
-			return true
-		}
-
-		// Check if any parent directory matches (for file exclusion)
-		if !isDir {
-			dir := filepath.Dir(relPath)
-			if dir != "." && strings.HasPrefix(dir+"/", pattern+"/") {
-				return true
-			}
-		}
+	"github.com/tpfeiffer67/ObsidianToQuartz/pkg/o2q"
+)
 
-		// Simple glob matching for * wildcard
-		if strings.Contains(pattern, "*") {
-			// Convert simple glob to regex
-			regexPattern := strings.ReplaceAll(pattern, "*", ".*")
-			regexPattern = "^" + regexPattern + "$"
-			if matched, _ := regexp.MatchString(regexPattern, relPath); matched {
-				return true
-			}
-		}
-	}
+// version is set when building a release: go build -ldflags "-X main.version=v1.2.0"
+var version = ""
 
-	return false
+func main() {
+	os.Exit(o2q.Main(version))
 }
@@ -0,0 +1,108 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ambiguousName is a note name shared by several published notes, with the notes linking to it by name only
+// Obsidian tells them apart by folder, but Quartz resolves such links to any of them
+type ambiguousName struct {
+	Name   string   `json:"name"`
+	Notes  []string `json:"notes"`            // Vault-relative paths of the notes with this name
+	Linked []string `json:"linked,omitempty"` // Vault-relative paths of the notes linking to the name without a folder
+}
+
+// ambiguousKey is the lowercased NFC form of a note name without .md, under which links and notes are compared
+func ambiguousKey(name string) string {
+	name = path.Base(filepath.ToSlash(name))
+	if hasExt(name, ".md") {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	return norm.NFC.String(strings.ToLower(name))
+}
+
+// checkAmbiguousNames lists the names shared by several notes of the plan, and the notes linking to them
+// by name only, such as [[Ideas]] when Projects/Ideas.md and Journal/Ideas.md are both published
+// It warns before anything is written; links with a folder, such as [[Projects/Ideas]], are not ambiguous
+func (c *converter) checkAmbiguousNames() error {
+	names := make(map[string]*ambiguousName)
+	for name, notes := range c.plan.notesByName {
+		if len(notes) < 2 {
+			continue
+		}
+		key := ambiguousKey(name)
+		if names[key] == nil {
+			names[key] = &ambiguousName{Name: path.Base(strings.TrimSuffix(notes[0], path.Ext(notes[0])))}
+		}
+		names[key].Notes = append(names[key].Notes, notes...)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	for _, f := range c.plan.files {
+		if !hasExt(f.relPath, ".md") || (f.action != planPublish && f.action != planUnchangedGit && f.action != planUnchangedState) {
+			continue
+		}
+		content, err := c.vault.ReadFile(f.src)
+		if err != nil {
+			return fmt.Errorf("failed to read markdown file: %v", err)
+		}
+		linked := make(map[string]bool)
+		check := func(target string) {
+			target = strings.TrimSpace(target)
+			if target == "" || strings.Contains(filepath.ToSlash(target), "/") {
+				return
+			}
+			if n, ok := names[ambiguousKey(target)]; ok && !linked[n.Name] {
+				linked[n.Name] = true
+				n.Linked = append(n.Linked, filepath.ToSlash(f.relPath))
+			}
+		}
+		mapOutsideCode(content, func(text string) string {
+			for _, parts := range noteWikiLinkRe.FindAllStringSubmatch(text, -1) {
+				check(parts[2])
+			}
+			for _, parts := range noteMarkdownLinkRe.FindAllStringSubmatch(text, -1) {
+				check(fileLink{target: parts[3]}.decodedTarget())
+			}
+			return text
+		})
+	}
+
+	for _, n := range names {
+		sort.Strings(n.Notes)
+		// Names differing in case or Unicode form are one group; it is named after its first note, whatever the map order
+		n.Name = path.Base(strings.TrimSuffix(n.Notes[0], path.Ext(n.Notes[0])))
+		sort.Strings(n.Linked)
+		c.report.AmbiguousNames = append(c.report.AmbiguousNames, *n)
+	}
+	sort.Slice(c.report.AmbiguousNames, func(i, j int) bool {
+		return strings.ToLower(c.report.AmbiguousNames[i].Name) < strings.ToLower(c.report.AmbiguousNames[j].Name)
+	})
+	for _, n := range c.report.AmbiguousNames {
+		c.console.warnf("%d notes are named %s; links to it without a folder may lead to any of them on the site:", len(n.Notes), n.Name)
+		for _, note := range n.Notes {
+			c.console.detailf("%s", note)
+		}
+		for _, note := range n.Linked {
+			c.console.detailf("linked by name from %s", note)
+		}
+	}
+	return nil
+}
+
+// ambiguousLinks counts the notes linking to a shared name without a folder, for --fail-on-ambiguous-links
+func (r *runReport) ambiguousLinks() int {
+	n := 0
+	for _, a := range r.AmbiguousNames {
+		n += len(a.Linked)
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package o2q
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Heading link handling modes
+const (
+	headingLinksSlug = "slug" // Rewrite heading fragments into the ids Quartz gives headings
+	headingLinksKeep = "keep" // Leave heading fragments as written
+)
+
+// attachmentExtRe matches the extension of a file other than a note, such as .pdf, whose fragment is not a heading
+// Extensions of digits only are taken as part of a note name, as in Meeting 2024.01.05
+var attachmentExtRe = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// isNoteTarget checks if a link target, without its fragment, points at a note: the note itself, a .md file,
+// or a name without the extension of an attachment
+func isNoteTarget(target string) bool {
+	ext := path.Ext(target)
+	return ext == "" || strings.EqualFold(ext, ".md") || !attachmentExtRe.MatchString(ext) ||
+		strings.Trim(ext[1:], "0123456789") == ""
+}
+
+// rewriteHeadingLinks rewrites the heading fragments of links to notes into the ids Quartz gives headings,
+// so they land on the section instead of the top of the page
+//   - [[Architecture#Data Flow & Storage]] → [[Architecture#data-flow--storage|Architecture > Data Flow & Storage]]
+//   - [[#Data Flow|the flow]] → [[#data-flow|the flow]]
+//   - [text](Architecture.md#Data%20Flow) → [text](Architecture.md#data-flow)
+//
+// Links without an alias get the text Obsidian shows for them; aliases and link texts are kept
+// Block links (#^blockid) are left to --block-refs, and code blocks and inline code are left untouched; a heading
+// holding inline code is slugged whole, so [[Guide#`go test` flags]] → [[Guide#go-test-flags|Guide > go test flags]]
+func (c *converter) rewriteHeadingLinks(content []byte) []byte {
+	if c.opts.headingLinks == headingLinksKeep {
+		return content
+	}
+
+	return mapOutsideCodeKeepingLinks(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			target, fragment, alias := parts[2], parts[3], parts[4]
+			if fragment == "" || strings.HasPrefix(fragment, "#^") || !isNoteTarget(target) {
+				return match
+			}
+			// Obsidian links to nested headings as #Heading#Subheading, and Quartz to the last one
+			headings := strings.Split(strings.TrimPrefix(fragment, "#"), "#")
+			id := headingID(headings[len(headings)-1])
+			if id == "" || "#"+id == fragment {
+				return match
+			}
+			if alias == "" && parts[1] == "" {
+				alias = strings.ReplaceAll(strings.Join(headings, " > "), "`", "")
+				if target != "" {
+					alias = target + " > " + alias
+				}
+			}
+			if alias != "" {
+				alias = "|" + alias
+			}
+			return parts[1] + "[[" + target + "#" + id + alias + "]]"
+		})
+
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			angle := strings.HasPrefix(parts[3], "<")
+			target, fragment, ok := strings.Cut(strings.Trim(parts[3], "<>"), "#")
+			if !ok || strings.HasPrefix(fragment, "^") || isExternalURL(target) {
+				return match
+			}
+			if decoded, err := url.PathUnescape(target); err == nil && !isNoteTarget(decoded) {
+				return match
+			}
+			if decoded, err := url.PathUnescape(fragment); err == nil {
+				fragment = decoded
+			}
+			id := headingID(fragment)
+			if id == "" || id == fragment {
+				return match
+			}
+			link := target + "#" + id
+			if angle {
+				link = "<" + link + ">"
+			}
+			start := len(parts[1] + "[" + parts[2] + "](")
+			return match[:start] + link + match[start+len(parts[3]):]
+		})
+	}, noteWikiLinkRe, markdownLinkRe)
+}
@@ -0,0 +1,54 @@
+package o2q
+
+import "testing"
+
+func TestHeadingID(t *testing.T) {
+	tests := []struct {
+		heading string
+		want    string
+	}{
+		{"Data Flow & Storage", "data-flow--storage"},
+		{"🚀 Déploiement", "-déploiement"},
+		{"Überblick", "überblick"},
+		{"`go test` flags", "go-test-flags"},
+		{"Step 2: Build", "step-2-build"},
+	}
+	for _, tt := range tests {
+		if got := headingID(tt.heading); got != tt.want {
+			t.Errorf("headingID(%q) = %q, want %q", tt.heading, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteHeadingLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"wikilink", "[[Architecture#Data Flow & Storage]]", "[[Architecture#data-flow--storage|Architecture > Data Flow & Storage]]"},
+		{"alias kept", "[[#Data Flow|the flow]]", "[[#data-flow|the flow]]"},
+		{"nested headings", "[[Guide#Setup#Linux]]", "[[Guide#linux|Guide > Setup > Linux]]"},
+		{"emoji", "[[#🚀 Déploiement]]", "[[#-déploiement|🚀 Déploiement]]"},
+		{"accents", "[[Notes#Été à Zürich]]", "[[Notes#été-à-zürich|Notes > Été à Zürich]]"},
+		{"inline code in heading", "see [[Guide#`go test` flags]] and `code`", "see [[Guide#go-test-flags|Guide > go test flags]] and `code`"},
+		{"inline code in heading, with alias", "[[Guide#`go test` flags|flags]]", "[[Guide#go-test-flags|flags]]"},
+		{"embed", "![[Guide#`go test` flags]]", "![[Guide#go-test-flags]]"},
+		{"link inside inline code", "`[[Guide#Data Flow]]`", "`[[Guide#Data Flow]]`"},
+		{"link ending inside inline code", "[[Guide#a `b]]` c`", "[[Guide#a `b]]` c`"},
+		{"block link", "[[Guide#^abc123]]", "[[Guide#^abc123]]"},
+		{"attachment", "[[report.pdf#page=2]]", "[[report.pdf#page=2]]"},
+		{"markdown link", "[flow](Architecture.md#Data%20Flow)", "[flow](Architecture.md#data-flow)"},
+		{"markdown link with inline code", "[`go test` flags](Guide.md#Go%20Test%20Flags)", "[`go test` flags](Guide.md#go-test-flags)"},
+		{"external link", "[site](https://example.com/#Top)", "[site](https://example.com/#Top)"},
+		{"code block", "```\n[[Guide#Data Flow]]\n```\n", "```\n[[Guide#Data Flow]]\n```\n"},
+	}
+	c := &converter{console: console.fork(), opts: options{headingLinks: headingLinksSlug}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(c.rewriteHeadingLinks([]byte(tt.content))); got != tt.want {
+				t.Errorf("rewriteHeadingLinks(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// skippedAsset is a file left out by --max-file-size, --exclude-ext or --include-ext
+type skippedAsset struct {
+	size     int64
+	tooLarge bool   // Left out by --max-file-size rather than by its extension
+	reason   string // Why it is left out, for messages
+}
+
+// parseExtensions reads a comma-separated list of extensions, with or without their dot, as a set of lowercase extensions
+func parseExtensions(list string) map[string]bool {
+	exts := make(map[string]bool)
+	for _, ext := range strings.Split(list, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			exts[ext] = true
+		}
+	}
+	return exts
+}
+
+// planSkippedAssets finds the files other than notes left out by the size and extension filters, before anything is written,
+// so links to them can be reported from any note, whichever comes first in the walk
+// Notes are never left out by these filters
+func (c *converter) planSkippedAssets() error {
+	if c.opts.maxFileSize == 0 && c.opts.excludeExt == "" && c.opts.includeExt == "" {
+		return nil
+	}
+	excluded, included := parseExtensions(c.opts.excludeExt), parseExtensions(c.opts.includeExt)
+
+	skipped := make(map[string]*skippedAsset)
+	err := c.walkEligible(func(relPath string) error {
+		if hasExt(relPath, ".md") {
+			return nil
+		}
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(relPath), "."))
+		switch {
+		case excluded[ext]:
+			skipped[filepath.ToSlash(relPath)] = &skippedAsset{reason: "extension excluded by --exclude-ext"}
+			return nil
+		case len(included) > 0 && !included[ext]:
+			skipped[filepath.ToSlash(relPath)] = &skippedAsset{reason: "extension not in --include-ext"}
+			return nil
+		case c.opts.maxFileSize == 0:
+			return nil
+		}
+		info, err := c.vault.Stat(filepath.Join(c.obsidianFolder, relPath))
+		if err != nil {
+			return nil
+		}
+		if info.Size() > c.opts.maxFileSize {
+			skipped[filepath.ToSlash(relPath)] = &skippedAsset{
+				size:     info.Size(),
+				tooLarge: true,
+				reason:   fmt.Sprintf("%s, larger than --max-file-size %s", formatSize(info.Size()), formatSize(c.opts.maxFileSize)),
+			}
+		}
+		return nil
+	})
+	c.skippedAssets = skipped
+	return err
+}
+
+// assetSkipped returns the filter leaving a file out, or nil if it is published
+func (c *converter) assetSkipped(relPath string) *skippedAsset {
+	return c.skippedAssets[filepath.ToSlash(relPath)]
+}
+
+// skipAsset records a file left out by the size and extension filters; files too large are listed in the summary
+func (c *converter) skipAsset(src string, asset *skippedAsset) {
+	if !asset.tooLarge {
+		c.addReportNote(src, asset.reason)
+		c.record(reportEntry{Source: src, Action: actionSkippedType}, 0)
+		return
+	}
+	c.console.warnf("%s: %s; not published", src, asset.reason)
+	c.report.SkippedLarge = append(c.report.SkippedLarge, largeFile{Source: c.paths.source(src), Size: asset.size})
+	c.record(reportEntry{Source: src, Action: actionSkippedFileSize}, 0)
+}
+
+// largeFile is a file left out by --max-file-size, listed in the summary
+type largeFile struct {
+	Source string `json:"source"`
+	Size   int64  `json:"size"`
+}
+
+// rewriteSkippedEmbeds reports the links of a note to files left out by the size and extension filters,
+// which would be broken on the published site; embeds are replaced with a line saying the file was omitted:
+//   - ![[demo.mp4]] → *(demo.mp4 omitted)*
+func (c *converter) rewriteSkippedEmbeds(src string, content []byte) []byte {
+	if len(c.skippedAssets) == 0 {
+		return content
+	}
+
+	files := make([]string, 0, len(c.skippedAssets))
+	for file := range c.skippedAssets {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	noteDir := c.noteDir(src)
+	replace := func(match string, embed bool, target string) string {
+		file, ok := resolveFile(files, noteDir, target)
+		if !ok {
+			return match
+		}
+		c.console.warnf("%s: links to %s, which is not published (%s)", src, file, c.skippedAssets[file].reason)
+		if !embed {
+			return match
+		}
+		return "*(" + path.Base(file) + " omitted)*"
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			return replace(match, parts[1] != "", parts[2])
+		})
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			link := fileLink{target: strings.Trim(parts[3], "<>"), angle: strings.HasPrefix(parts[3], "<")}
+			if isExternalURL(link.target) {
+				return match
+			}
+			target, _, _ := strings.Cut(link.decodedTarget(), "#")
+			return replace(match, parts[1] != "", target)
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package o2q
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// tempFileRe matches the temporary files created by writeFileAtomic: .name.tmpXXXX
+var tempFileRe = regexp.MustCompile(`^\..+\.tmp[0-9]+$`)
+
+// syncFile flushes a file to disk, where a full file system may only be reported; a variable so tests can fake it
+var syncFile = (*os.File).Sync
+
+// writeFileAtomic writes a file through a temporary file in the same folder, renamed over dest once complete
+// Quartz's watcher thus only ever sees the previous file or the complete new one
+// write fills the temporary file and returns the number of bytes written
+func writeFileAtomic(dest string, perm os.FileMode, write func(w io.Writer) (int64, error)) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	tmpPath := tmp.Name()
+
+	written, err := write(tmp)
+	if err == nil {
+		err = syncFile(tmp)
+	}
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dest)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	return written, nil
+}
+
+// removeTempFiles deletes the temporary files left in a folder by an interrupted run
+func removeTempFiles(log *consoleLogger, folder string) error {
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && tempFileRe.MatchString(info.Name()) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			log.progressf("Removed: %s (leftover temporary file)", path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove temporary files: %v", err)
+	}
+	return nil
+}
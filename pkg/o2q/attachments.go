@@ -0,0 +1,104 @@
+package o2q
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// obsidianAppConfig is the subset of .obsidian/app.json read by the tool
+type obsidianAppConfig struct {
+	AttachmentFolderPath string `json:"attachmentFolderPath"`
+}
+
+// readAttachmentFolder reads the attachment folder setting of the vault
+// ok is false if the vault has no such setting
+func readAttachmentFolder(vault *vaultFS) (folder string, ok bool) {
+	data, err := vault.ReadFile(filepath.Join(vault.root, ".obsidian", "app.json"))
+	if err != nil {
+		return "", false
+	}
+	var app obsidianAppConfig
+	if err := json.Unmarshal(data, &app); err != nil || app.AttachmentFolderPath == "" {
+		return "", false
+	}
+	return app.AttachmentFolderPath, true
+}
+
+// isAttachment checks if a vault file is an attachment according to the attachment folder setting
+// The setting is "/" for the vault root, "folder" for a folder of the vault, "./" for the folder of the note
+// and "./folder" for a subfolder of the folder of the note; without it every file that is not a note is an attachment
+func isAttachment(relPath, setting string, hasSetting bool) bool {
+	if hasExt(relPath, ".md") || hasExt(relPath, ".canvas") || hasExt(relPath, ".html") || isInExcalidrawFolder(relPath) {
+		return false
+	}
+	if !hasSetting {
+		return true
+	}
+
+	dir := path.Dir(relPath)
+	switch {
+	case setting == "/":
+		return dir == "."
+	case setting == "./" || setting == ".":
+		return true
+	case strings.HasPrefix(setting, "./"):
+		return path.Base(dir) == path.Clean(strings.TrimPrefix(setting, "./"))
+	}
+	folder := path.Clean(strings.Trim(setting, "/"))
+	return dir == folder || strings.HasPrefix(dir, folder+"/")
+}
+
+// planAttachments gives every attachment a destination in the --attachments-to folder
+// Attachments from different folders with the same name are numbered, in alphabetical order of their vault path
+func (c *converter) planAttachments() error {
+	if c.opts.attachmentsTo == "" {
+		return nil
+	}
+	setting, hasSetting := readAttachmentFolder(c.vault)
+	if hasSetting {
+		c.console.infof("Attachment folder from Obsidian settings: %s", setting)
+	}
+
+	var files []string
+	err := c.walkEligible(func(relPath string) error {
+		relPath = filepath.ToSlash(relPath)
+		if isAttachment(relPath, setting, hasSetting) {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	if c.renames == nil {
+		c.renames = make(map[string]string)
+	}
+	c.attachments = make(map[string]bool)
+	folder := filepath.ToSlash(filepath.Clean(c.opts.attachmentsTo))
+	taken := make(map[string]bool)
+	for _, file := range files {
+		name := path.Base(filepath.ToSlash(c.destRel(file)))
+		dest := path.Join(folder, name)
+		ext := path.Ext(dest)
+		base := strings.TrimSuffix(dest, ext)
+		for n := 2; taken[strings.ToLower(dest)]; n++ {
+			dest = fmt.Sprintf("%s %d%s", base, n, ext)
+		}
+		if n := path.Base(dest); n != name {
+			c.console.infof("Attachment %s renamed to %s, as another attachment is named %s", file, n, name)
+		}
+		taken[strings.ToLower(dest)] = true
+		c.renames[file] = dest
+		c.attachments[file] = true
+	}
+	if len(files) > 0 {
+		c.console.infof("Moving %d attachments to %s", len(files), folder)
+	}
+	return nil
+}
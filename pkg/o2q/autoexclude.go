@@ -0,0 +1,93 @@
+package o2q
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// folderSetting is a setting of Obsidian or of a plugin naming a folder whose files are never published
+type folderSetting struct {
+	file string // Settings file, relative to the .obsidian folder
+	key  string // Key holding the folder
+	what string // What the folder holds, for messages
+}
+
+// autoExcludeSettings are the settings read for the folders left out without --no-auto-exclude
+var autoExcludeSettings = []folderSetting{
+	{"templates.json", "folder", "template folder of the Templates core plugin"},
+	{"plugins/templater-obsidian/data.json", "templates_folder", "template folder of Templater"},
+	{"plugins/templater-obsidian/data.json", "user_scripts_folder", "script folder of Templater"},
+	{"plugins/obsidian-excalidraw-plugin/data.json", "scriptFolderPath", "script folder of Excalidraw"},
+}
+
+// autoExcludedFolder is a vault folder the settings of the vault reserve for templates or scripts
+type autoExcludedFolder struct {
+	folder string // Vault-relative path, with forward slashes
+	what   string
+}
+
+// readAutoExcludedFolders reads the template and script folders configured in the vault
+// Missing or unreadable settings files are skipped, as are settings naming the vault root
+func readAutoExcludedFolders(vault *vaultFS) []autoExcludedFolder {
+	var folders []autoExcludedFolder
+	seen := make(map[string]bool)
+	for _, setting := range autoExcludeSettings {
+		data, err := vault.ReadFile(filepath.Join(vault.root, ".obsidian", filepath.FromSlash(setting.file)))
+		if err != nil {
+			continue
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(data, &values); err != nil {
+			continue
+		}
+		folder, _ := values[setting.key].(string)
+		folder = strings.Trim(path.Clean("/"+filepath.ToSlash(strings.TrimSpace(folder))), "/")
+		if folder == "" || seen[folder] {
+			continue
+		}
+		seen[folder] = true
+		folders = append(folders, autoExcludedFolder{folder: folder, what: setting.what})
+	}
+	return folders
+}
+
+// addAutoExcludes leaves out the template and script folders configured in the vault, unless an ignore file line
+// such as !Templates/ keeps them; patterns are the lines of the ignore file
+func (c *converter) addAutoExcludes(patterns []ignorePattern) {
+	var negations []ignorePattern
+	used := make(map[string]bool) // ! lines keeping a folder, or following a pattern whose paths they may publish again
+	excluding := false
+	for _, p := range patterns {
+		if !p.negate {
+			excluding = true
+			continue
+		}
+		negations = append(negations, p)
+		used[p.text] = used[p.text] || excluding
+	}
+	if !c.opts.noAutoExclude {
+		for _, f := range readAutoExcludedFolders(c.vault) {
+			kept := false
+			for _, n := range negations {
+				if n.matches(f.folder, true) {
+					kept, used[n.text] = true, true
+				}
+			}
+			if kept {
+				c.console.infof("Publishing %s/, the %s, as the ignore file keeps it", f.folder, f.what)
+				continue
+			}
+			// The folder is matched as written, even if its name holds wildcard characters
+			c.excludePatterns = append(c.excludePatterns, ignorePattern{text: f.folder + "/", path: f.folder, dirOnly: true, anchored: true})
+			c.console.infof("Auto-excluded %s/, the %s", f.folder, f.what)
+		}
+	}
+	for _, n := range negations {
+		// With --respect-gitignore, a ! line may publish a path git ignores instead
+		if !used[n.text] && !c.opts.respectGitignore {
+			c.console.warnf("ignore file: !%s follows no pattern and matches no folder excluded automatically, so it publishes nothing again", n.text)
+		}
+	}
+}
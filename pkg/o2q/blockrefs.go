@@ -0,0 +1,94 @@
+package o2q
+
+import (
+	"regexp"
+)
+
+// Block reference handling modes
+const (
+	blockRefsKeep     = "keep"      // Keep ^blockid markers and #^blockid links
+	blockRefsStrip    = "strip"     // Remove ^blockid markers and point block links at the note
+	blockRefsLinkNote = "link-note" // Keep ^blockid markers but point block links at the note
+)
+
+var (
+	// blockMarkerRe matches a ^blockid marker at the end of a line
+	blockMarkerRe = regexp.MustCompile(`[ \t]+\^[A-Za-z0-9-]+[ \t]*(\r?\n|$)`)
+	// blockMarkerLineRe matches a line holding only a ^blockid marker (used after tables and lists)
+	blockMarkerLineRe = regexp.MustCompile(`^[ \t]*\^[A-Za-z0-9-]+[ \t]*\r?\n?$`)
+	// blockWikiLinkRe matches [[Note#^blockid]], [[Note#^blockid|alias]] and their embeds
+	blockWikiLinkRe = regexp.MustCompile(`(!?)\[\[([^\]|#]*)#\^[A-Za-z0-9-]+(?:\|([^\]]*))?\]\]`)
+	// blockMarkdownLinkRe matches [text](Note.md#^blockid)
+	blockMarkdownLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s#]*)#\^[A-Za-z0-9-]+\)`)
+)
+
+// rewriteBlockRefs strips block markers and rewrites block links according to the block refs mode
+//   - strip: "text ^3fa2b1" → "text", [[Note#^3fa2b1]] → [[Note]]
+//   - link-note: [[Note#^3fa2b1]] → [[Note]]
+//
+// Block transclusions ![[Note#^3fa2b1]] are degraded to [[Note]] and the note is recorded for a warning
+// Code blocks and inline code are left untouched
+func (c *converter) rewriteBlockRefs(src string, content []byte) []byte {
+	if c.opts.blockRefs == blockRefsKeep {
+		return content
+	}
+
+	degraded := false
+	content = mapOutsideCode(content, func(text string) string {
+		if c.opts.blockRefs == blockRefsStrip {
+			if blockMarkerLineRe.MatchString(text) {
+				return ""
+			}
+			text = blockMarkerRe.ReplaceAllString(text, "$1")
+		}
+
+		text = blockWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := blockWikiLinkRe.FindStringSubmatch(match)
+			note, alias := parts[2], parts[3]
+			if note == "" {
+				// Link to a block of the same note: the anchor only survives with its marker
+				if c.opts.blockRefs == blockRefsLinkNote || alias == "" {
+					return match
+				}
+				return alias
+			}
+			if parts[1] != "" {
+				degraded = true
+			}
+			if alias != "" {
+				return "[[" + note + "|" + alias + "]]"
+			}
+			return "[[" + note + "]]"
+		})
+
+		return blockMarkdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := blockMarkdownLinkRe.FindStringSubmatch(match)
+			if parts[3] == "" {
+				if c.opts.blockRefs == blockRefsLinkNote {
+					return match
+				}
+				return parts[2]
+			}
+			if parts[1] != "" {
+				degraded = true
+			}
+			return "[" + parts[2] + "](" + parts[3] + ")"
+		})
+	})
+
+	if degraded {
+		c.degradedBlockEmbeds = append(c.degradedBlockEmbeds, src)
+	}
+	return content
+}
+
+// reportDegradedBlockEmbeds warns about the notes whose block transclusions were turned into links
+func (c *converter) reportDegradedBlockEmbeds() {
+	if len(c.degradedBlockEmbeds) == 0 {
+		return
+	}
+	c.console.warnf("block transclusions were replaced with links to the note in %d files:", len(c.degradedBlockEmbeds))
+	for _, src := range c.degradedBlockEmbeds {
+		c.console.detailf("%s", src)
+	}
+}
@@ -0,0 +1,139 @@
+package o2q
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fold marker policies of --callout-folds
+const (
+	calloutFoldsKeep  = "keep"  // Keep [!note]- and [!note]+ as written
+	calloutFoldsStrip = "strip" // Drop the - and +, so the callout is shown open
+)
+
+// quartzCallouts are the callout types Quartz styles, with their aliases
+var quartzCallouts = map[string]bool{
+	"note": true, "abstract": true, "summary": true, "tldr": true, "info": true, "todo": true,
+	"tip": true, "hint": true, "important": true, "success": true, "check": true, "done": true,
+	"question": true, "help": true, "faq": true, "warning": true, "attention": true, "caution": true,
+	"failure": true, "missing": true, "fail": true, "danger": true, "error": true, "bug": true,
+	"example": true, "quote": true, "cite": true,
+}
+
+// calloutStartRe matches a line starting a callout: its quote markers, type, fold marker and title
+var calloutStartRe = regexp.MustCompile(`^((?:[ \t]*>[ \t]?)+)\[!([^\]\s]+)\]([-+]?)(.*)$`)
+
+// calloutQuoteRe matches the quote markers at the start of a line, to measure how deep the line is quoted
+var calloutQuoteRe = regexp.MustCompile(`^(?:[ \t]*>[ \t]?)+`)
+
+// parseCalloutMap reads --callout-map entries of the form "custom=supported", such as theorem=important
+// Types are compared without case, like Obsidian does; the mapped type must be one Quartz styles
+func parseCalloutMap(entries []string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, entry := range entries {
+		from, to, ok := strings.Cut(entry, "=")
+		from, to = strings.ToLower(strings.TrimSpace(from)), strings.ToLower(strings.TrimSpace(to))
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("%q is not of the form custom=supported", entry)
+		}
+		if !quartzCallouts[to] {
+			return nil, fmt.Errorf("%q: Quartz has no %s callout, use one of %s", entry, to, calloutTypes())
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
+
+// calloutTypes lists the callout types Quartz styles, for messages
+func calloutTypes() string {
+	types := make([]string, 0, len(quartzCallouts))
+	for t := range quartzCallouts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// quoteDepth returns how many quote markers start a line
+func quoteDepth(line string) int {
+	return strings.Count(calloutQuoteRe.FindString(line), ">")
+}
+
+// rewriteCallouts rewrites the callout types Quartz does not style, and the fold markers with --callout-folds=strip
+//   - > [!theorem] Pythagoras → > [!important] Pythagoras
+//   - > [!recipe]- → > [!example]- Recipe
+//
+// A custom type without a title keeps its name as the title. Only the first line of a blockquote,
+// or of a blockquote nested in one, starts a callout; [! further down a quote and code blocks are left alone
+func (c *converter) rewriteCallouts(src string, content []byte) []byte {
+	if len(c.calloutMap) == 0 && c.opts.calloutDefault == "" && c.opts.calloutFolds == calloutFoldsKeep {
+		return content
+	}
+
+	var out strings.Builder
+	unknown := make(map[string]bool)
+	fence := ""
+	depth := 0 // Quote depth of the previous line
+	for _, line := range splitLines(content) {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			out.WriteString(line)
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			fence = marker
+			depth = 0
+			out.WriteString(line)
+			continue
+		}
+
+		text := strings.TrimRight(line, "\r\n")
+		eol := line[len(text):]
+		previous := depth
+		depth = quoteDepth(text)
+		m := calloutStartRe.FindStringSubmatch(text)
+		if m == nil || depth <= previous {
+			out.WriteString(line)
+			continue
+		}
+
+		quote, kind, fold, title := m[1], m[2], m[3], m[4]
+		lower := strings.ToLower(kind)
+		if !quartzCallouts[lower] {
+			to, ok := c.calloutMap[lower]
+			if !ok {
+				to = c.opts.calloutDefault
+			}
+			if to == "" {
+				unknown[kind] = true
+			} else {
+				if strings.TrimSpace(title) == "" {
+					r, size := utf8.DecodeRuneInString(kind)
+					title = " " + string(unicode.ToUpper(r)) + kind[size:]
+				}
+				kind = to
+			}
+		}
+		if c.opts.calloutFolds == calloutFoldsStrip {
+			fold = ""
+		}
+		out.WriteString(quote + "[!" + kind + "]" + fold + title + eol)
+	}
+
+	if len(unknown) > 0 {
+		kinds := make([]string, 0, len(unknown))
+		for kind := range unknown {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		c.console.warnf("%s: Quartz does not style the callout types %s; map them with --callout-map or set --callout-default",
+			src, strings.Join(kinds, ", "))
+	}
+	return []byte(out.String())
+}
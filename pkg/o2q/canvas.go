@@ -0,0 +1,121 @@
+package o2q
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Canvas handling modes
+const (
+	canvasSkip = "skip" // Skip .canvas files and turn links to them into plain text
+	canvasList = "list" // Generate a markdown page listing the canvas nodes
+)
+
+// canvasFile is the subset of the Obsidian Canvas JSON format used to generate pages
+type canvasFile struct {
+	Nodes []canvasNode `json:"nodes"`
+}
+
+// canvasNode is a single card of a canvas
+type canvasNode struct {
+	Type    string  `json:"type"`
+	Text    string  `json:"text"`
+	File    string  `json:"file"`
+	Subpath string  `json:"subpath"`
+	URL     string  `json:"url"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+}
+
+// canvasLinks matches links to canvas files: [[Board.canvas]], ![[Board.canvas]] and [text](Board.canvas)
+var canvasLinks = newLinkPattern("canvas")
+
+// processCanvasFile converts a canvas file into a markdown page listing its nodes
+func (c *converter) processCanvasFile(src, dest string) error {
+	data, err := c.vault.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read canvas file: %v", err)
+	}
+
+	var canvas canvasFile
+	if err := json.Unmarshal(data, &canvas); err != nil {
+		return fmt.Errorf("failed to parse canvas file %s: %v", src, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	content := renderCanvasPage(name, canvas)
+	return c.writeMarkdownFile(src, dest, c.transformMarkdown(src, content), actionGenerated)
+}
+
+// renderCanvasPage builds the markdown page for a canvas
+// Text nodes become paragraphs, file nodes become wikilinks and link nodes become markdown links
+// Edges are ignored
+func renderCanvasPage(name string, canvas canvasFile) []byte {
+	// Order nodes top to bottom, then left to right, as they appear on the canvas
+	nodes := canvas.Nodes
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Y != nodes[j].Y {
+			return nodes[i].Y < nodes[j].Y
+		}
+		return nodes[i].X < nodes[j].X
+	})
+
+	var texts, files, links []string
+	for _, node := range nodes {
+		switch node.Type {
+		case "text":
+			if text := strings.TrimSpace(node.Text); text != "" {
+				texts = append(texts, text)
+			}
+		case "file":
+			target := node.File
+			if strings.EqualFold(filepath.Ext(target), ".md") {
+				target = strings.TrimSuffix(target, filepath.Ext(target))
+			}
+			files = append(files, "- [["+target+node.Subpath+"]]")
+		case "link":
+			links = append(links, "- ["+node.URL+"]("+node.URL+")")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\n---\n", strconv.Quote(name))
+	if len(texts) > 0 {
+		b.WriteString("\n## Cards\n\n")
+		b.WriteString(strings.Join(texts, "\n\n---\n\n"))
+		b.WriteString("\n")
+	}
+	if len(files) > 0 {
+		b.WriteString("\n## Files\n\n")
+		b.WriteString(strings.Join(files, "\n"))
+		b.WriteString("\n")
+	}
+	if len(links) > 0 {
+		b.WriteString("\n## Links\n\n")
+		b.WriteString(strings.Join(links, "\n"))
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+// rewriteCanvasLinks rewrites links to canvas files according to the canvas mode
+//   - skip: [[Board.canvas]] → Board, [text](Board.canvas) → text (with a warning)
+//   - list: [[Board.canvas]] → [[Board.canvas|Board]], [text](Board.canvas) → [text](Board.canvas.md)
+func rewriteCanvasLinks(log *consoleLogger, src string, content []byte, mode string) []byte {
+	return canvasLinks.rewrite(content, func(link fileLink) string {
+		if mode == canvasList {
+			if link.wiki {
+				link.text = link.displayText()
+			} else {
+				link.target += ".md"
+			}
+			return link.String()
+		}
+		log.warnf("%s: link to canvas %q rewritten as plain text", src, link.target)
+		return link.displayText()
+	})
+}
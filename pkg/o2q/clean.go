@@ -0,0 +1,162 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// quartzMarkers are the files at the root of a Quartz folder, one of which must exist for --clean without --yes
+var quartzMarkers = []string{"quartz.config.ts", "package.json"}
+
+// checkClean refuses to clean a content folder that could hold anything else than published files
+func (c *converter) checkClean() error {
+	content, err := resolvedPath(c.contentFolder)
+	if err != nil {
+		return err
+	}
+	vault, err := resolvedPath(c.obsidianFolder)
+	if err != nil {
+		return err
+	}
+	quartz, err := resolvedPath(c.quartzFolder)
+	if err != nil {
+		return err
+	}
+
+	if content == vault || isWithin(vault, content) {
+		return refusedf("refusing to clean %s: it contains the Obsidian folder", c.contentFolder)
+	}
+	if content == quartz && !c.export && !c.opts.noContentSubdir {
+		return refusedf("refusing to clean %s: it is the Quartz folder itself", c.contentFolder)
+	}
+	if c.opts.yes {
+		return nil
+	}
+	if c.opts.noContentSubdir {
+		return refusedf("refusing to clean %s: with --no-content-subdir nothing shows it only holds published files, pass --yes to clean anyway", c.contentFolder)
+	}
+	for _, marker := range quartzMarkers {
+		if _, err := os.Stat(filepath.Join(c.quartzFolder, marker)); err == nil {
+			return nil
+		}
+	}
+	return refusedf("refusing to clean %s: %s does not look like a Quartz folder (no %s), pass --yes to clean anyway",
+		c.contentFolder, c.quartzFolder, strings.Join(quartzMarkers, " or "))
+}
+
+// resolvedPath returns the absolute path of a folder with symbolic links resolved
+func resolvedPath(folder string) (string, error) {
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", folder, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
+// isWithin checks if path is inside folder; both must be absolute and clean
+func isWithin(path, folder string) bool {
+	rel, err := filepath.Rel(folder, path)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+// cleanContent deletes the contents of the content folder, keeping the files matching --clean-keep
+func (c *converter) cleanContent() error {
+	if err := c.checkClean(); err != nil {
+		return err
+	}
+	keep := c.keepPatterns()
+	if _, err := os.Stat(c.contentFolder); c.opts.dryRun && os.IsNotExist(err) {
+		return nil
+	}
+	deleted, _, err := c.cleanFolder(c.contentFolder, ".", keep)
+	if err != nil {
+		return fmt.Errorf("failed to clean content folder: %w", err)
+	}
+	if c.opts.dryRun {
+		c.console.infof("--clean would delete %d files from the content folder", deleted)
+		return nil
+	}
+	c.console.infof("Cleaned %d files from the content folder", deleted)
+	return nil
+}
+
+// keepPatterns returns the patterns of the files of the content folder --clean and --prune keep
+func (c *converter) keepPatterns() []string {
+	keep := splitList(c.opts.cleanKeep)
+	if c.opts.noContentSubdir {
+		// The files the tool keeps next to the content folder are in it with --no-content-subdir
+		keep = append(keep, stateFileName, redirectsStateFileName, pinsFileName, tombstonesFileName, lockFileName, "_redirects")
+	}
+	return keep
+}
+
+// cleanFolder deletes the contents of a folder, except the entries matching keep
+// Folders are removed unless they contain a kept entry; returns the number of files deleted
+func (c *converter) cleanFolder(folder, relDir string, keep []string) (deleted int, kept bool, err error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, entry := range entries {
+		relPath := path.Join(relDir, entry.Name())
+		entryPath := filepath.Join(folder, entry.Name())
+		if matchesAny(relPath, entry.Name(), keep) {
+			c.console.progressf("Kept: %s (clean-keep pattern)", entryPath)
+			kept = true
+			continue
+		}
+		if c.overrideKept[relPath] {
+			c.console.progressf("Kept: %s (source excluded by --override for this run only)", entryPath)
+			c.overrideKeptCount++
+			kept = true
+			continue
+		}
+
+		if entry.IsDir() {
+			n, keptInside, err := c.cleanFolder(entryPath, relPath, keep)
+			deleted += n
+			if err != nil {
+				return deleted, kept, err
+			}
+			if keptInside {
+				kept = true
+				continue
+			}
+		} else {
+			deleted++
+		}
+		if c.opts.dryRun {
+			if !entry.IsDir() {
+				c.wouldDelete(entryPath, "--clean")
+			}
+			continue
+		}
+		if err := c.checkContained(entryPath); err != nil {
+			return deleted, kept, fmt.Errorf("%s %w", entryPath, err)
+		}
+		if err := os.Remove(entryPath); err != nil {
+			return deleted, kept, err
+		}
+		c.console.progressf("Deleted: %s", entryPath)
+	}
+	return deleted, kept, nil
+}
+
+// matchesAny checks if a content-relative path or its file name matches one of the glob patterns
+func matchesAny(relPath, name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, relPath); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package o2q
+
+import (
+	"os"
+)
+
+// keepExisting checks if a destination file must be left as is because of --no-clobber or --update-only
+// Kept files are recorded and listed in a warning at the end of the run
+func (c *converter) keepExisting(src, dest string) bool {
+	if !c.opts.noClobber && !c.opts.updateOnly {
+		return false
+	}
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		return false
+	}
+
+	if c.opts.updateOnly {
+		// Transformed files are compared by the time their source was modified, not the time of the transform
+		srcInfo, err := c.vault.Stat(src)
+		if err != nil || !destInfo.ModTime().After(srcInfo.ModTime()) {
+			return false
+		}
+	}
+
+	c.record(reportEntry{Source: src, Destination: dest, Action: actionSkippedExisting}, 0)
+	c.keptFiles = append(c.keptFiles, dest)
+	return true
+}
+
+// reportKeptFiles warns about the destination files --no-clobber or --update-only did not overwrite
+func (c *converter) reportKeptFiles() {
+	if len(c.keptFiles) == 0 {
+		return
+	}
+	reason := "they already exist (--no-clobber)"
+	if c.opts.updateOnly {
+		reason = "they are newer than their source (--update-only)"
+	}
+	c.console.warnf("%d files were not overwritten because %s:", len(c.keptFiles), reason)
+	for _, dest := range c.keptFiles {
+		c.console.detailf("%s", c.paths.dest(dest))
+	}
+}
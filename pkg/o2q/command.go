@@ -0,0 +1,402 @@
+package o2q
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Main runs the ObsidianToQuartz command with the arguments of the process, and returns its exit code
+// release is the version stamped on a release build, or "" to report the module version
+func Main(release string) int {
+	version = release
+	var opts options
+	registry := newOptionRegistry(&opts)
+	// Invalid flags exit with exitFailure, like every other usage error, rather than the 2 of the flag package
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	registerFlags(flag.CommandLine, registry)
+	flag.Usage = func() {
+		printHelp(os.Stderr, os.Args[0], registry, helpWidth())
+	}
+
+	if err := flag.CommandLine.Parse(os.Args[1:]); err == flag.ErrHelp {
+		return exitSuccess
+	} else if err != nil {
+		return exitFailure
+	}
+
+	if flag.NArg() > 0 && flag.Arg(0) == "help" {
+		if err := runHelp(os.Stdout, os.Args[0], registry, flag.Args()[1:]); err != nil {
+			console.errorf("%v", err)
+			return exitFailure
+		}
+		return exitSuccess
+	}
+
+	// The check command lints the vault instead of converting it, the export command
+	// converts it to a folder outside of Quartz, and export-note a single note; flags may follow the command
+	command := ""
+	if flag.NArg() > 0 && (flag.Arg(0) == "check" || flag.Arg(0) == "export" || flag.Arg(0) == commandExportNote) {
+		command = flag.Arg(0)
+		if err := flag.CommandLine.Parse(flag.Args()[1:]); err == flag.ErrHelp {
+			return exitSuccess
+		} else if err != nil {
+			return exitFailure
+		}
+	}
+	checking := command == "check"
+
+	if opts.version {
+		fmt.Println("ObsidianToQuartz " + buildVersion())
+		return exitSuccess
+	}
+
+	// Command-line flags override environment variables, which override the config file
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if err := applyEnv(registry, set); err != nil {
+		console.errorf("%v", err)
+		return exitFailure
+	}
+	for name := range envOptions(registry) {
+		set[name] = true
+	}
+
+	// Look for a config file at the root of the Obsidian folder unless one is given
+	// With --source, the only folder argument is the Quartz folder
+	configPath := opts.configPath
+	if configPath == "" && flag.NArg() > 0 && len(opts.sources) == 0 {
+		candidate := filepath.Join(flag.Arg(0), configFileName)
+		if _, err := os.Stat(candidate); err == nil {
+			configPath = candidate
+		}
+	}
+	var cfg config
+	if configPath != "" {
+		var err error
+		if cfg, err = loadConfig(console, configPath, registry, set, opts.configPath != ""); err != nil {
+			console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+
+	if opts.quiet && opts.verbose {
+		console.errorf("--quiet and --verbose cannot be used together")
+		return exitFailure
+	}
+	if opts.quiet {
+		console.verbosity = verbosityQuiet
+	} else if opts.verbose {
+		console.verbosity = verbosityVerbose
+	}
+
+	if opts.printConfig {
+		printConfig(os.Stdout, registry)
+		return exitSuccess
+	}
+
+	// The folders come from the command line, or from the config file
+	// Several sources are given with --source, or as a list in the config file, and only take the Quartz folder as argument
+	obsidianFolder, quartzFolder := cfg.source, cfg.destination
+	specs := append(cfg.sources, opts.sources...)
+	if command == commandExportNote {
+		// export-note takes the vault, the note and the output folder or archive
+		if flag.NArg() != 3 || len(opts.sources) > 0 {
+			flag.Usage()
+			return exitFailure
+		}
+		obsidianFolder, opts.exportNote, quartzFolder = flag.Arg(0), flag.Arg(1), flag.Arg(2)
+		specs = nil
+	} else if len(specs) > 0 {
+		obsidianFolder = ""
+		if !checking && flag.NArg() == 1 {
+			quartzFolder = flag.Arg(0)
+		} else if flag.NArg() != 0 {
+			flag.Usage()
+			return exitFailure
+		}
+	} else if checking && flag.NArg() == 1 {
+		obsidianFolder = flag.Arg(0)
+	} else if checking && flag.NArg() == 0 {
+		// Only the vault is needed to check it
+	} else if !checking && flag.NArg() == 2 {
+		obsidianFolder, quartzFolder = flag.Arg(0), flag.Arg(1)
+	} else if flag.NArg() != 0 {
+		flag.Usage()
+		return exitFailure
+	}
+	if (obsidianFolder == "" && len(specs) == 0) || (quartzFolder == "" && !checking) {
+		flag.Usage()
+		return exitFailure
+	}
+	sources := []vaultSource{{folder: obsidianFolder, sub: "."}}
+	if len(specs) > 0 {
+		var err error
+		pruning := ""
+		if opts.emitTagPages != "" {
+			pruning = "--emit-tag-pages"
+		} else if opts.generateIndexes {
+			pruning = "--generate-indexes"
+		} else if opts.prune {
+			pruning = "--prune"
+		}
+		if sources, err = parseSources(specs, opts.clean, pruning); err != nil {
+			console.errorf("invalid --source: %v", err)
+			return exitFailure
+		}
+	}
+	if err := checkOptions(console, &opts, cfg, sources, command); err != nil {
+		console.errorf("%v", err)
+		return exitFailure
+	}
+
+	if command == commandExportNote {
+		return exitStatus(runExportNote(console, opts, cfg, obsidianFolder, quartzFolder))
+	}
+
+	if opts.dryRun {
+		console.infof("Dry run: nothing is written to %s", quartzFolder)
+	}
+
+	// A sync holds the lock of the Quartz folder while it runs, so a cron job and a manual run never overlap
+	runSync := func() int {
+		// --migrate-state and --reset-state only apply to the first sync of --watch and --every
+		defer func() { opts.migrateState, opts.resetState = false, false }()
+		if checking || opts.dryRun {
+			return runSources(console, opts, cfg, sources, quartzFolder, command)
+		}
+		return withLock(console, opts, quartzFolder, func() int {
+			return runSources(console, opts, cfg, sources, quartzFolder, command)
+		})
+	}
+
+	// With --watch, the process keeps running and syncs when the vault changes
+	if opts.watch {
+		w, err := newWatcher(console, sources[0].folder, quartzFolder, func() bool {
+			code := runSync()
+			return code == exitSuccess || code == exitNothingToDo
+		})
+		if err != nil {
+			console.errorf("%v", err)
+			return exitFailure
+		}
+		sources[0].listing = w.listing
+		w.run()
+		return exitSuccess
+	}
+
+	// With --every, the process keeps running and syncs on an interval
+	if opts.every > 0 {
+		s := newScheduler(console, opts.every, opts.jitter, func() bool {
+			code := runSync()
+			return code == exitSuccess || code == exitNothingToDo
+		})
+		s.run()
+		return exitSuccess
+	}
+	releaseLockOnSignal(console)
+	return exitStatus(runSync())
+}
+
+// checkOptions checks the options of a run before anything is read or written, and completes those implied by others
+func checkOptions(log *consoleLogger, opts *options, cfg config, sources []vaultSource, command string) error {
+	// A vault read from a zip archive has no git repository and no file to link to
+	for _, source := range sources {
+		if !isVaultArchive(source.folder) {
+			continue
+		}
+		if opts.sinceGit != "" || opts.writeRef != "" {
+			return fmt.Errorf("--since-git and --write-ref need the vault folder, not an archive: %s", source.folder)
+		}
+		if opts.watch {
+			return fmt.Errorf("--watch needs the vault folder, not an archive: %s", source.folder)
+		}
+		if opts.linkMode != linkModeCopy {
+			return fmt.Errorf("--link-mode=%s needs the vault folder, the files of an archive can only be copied: %s", opts.linkMode, source.folder)
+		}
+	}
+	// With --no-content-subdir, the destination is the content folder itself
+	if opts.noContentSubdir {
+		if opts.contentDir != "content" {
+			return errors.New("--no-content-subdir publishes to the destination folder itself and cannot be used with --content-dir")
+		}
+		if opts.html == htmlStatic || opts.html == htmlIframe {
+			return fmt.Errorf("--html=%s writes to the static folder of Quartz, which --no-content-subdir has no place for", opts.html)
+		}
+		opts.contentDir = "."
+	}
+	if !filepath.IsLocal(opts.contentDir) {
+		return fmt.Errorf("--content-dir must be a relative path inside the Quartz folder: %q", opts.contentDir)
+	}
+	if opts.emitTagPages != "" && !filepath.IsLocal(opts.emitTagPages) {
+		return fmt.Errorf("--emit-tag-pages must be a relative path inside the content folder: %q", opts.emitTagPages)
+	}
+	if opts.attachmentsTo != "" && !filepath.IsLocal(opts.attachmentsTo) {
+		return fmt.Errorf("--attachments-to must be a relative path inside the content folder: %q", opts.attachmentsTo)
+	}
+	if strings.ContainsAny(opts.sanitizeReplacement, unsafeNameChars+"/") {
+		return fmt.Errorf("--sanitize-replacement cannot contain / or any of %s", unsafeNameChars)
+	}
+	if _, ok := lookupQuartzCompat(opts.quartzCompat); !ok {
+		log.warnf("unknown Quartz version %q for --quartz-compat, using the rules of Quartz %s; known versions are %s",
+			opts.quartzCompat, latestQuartz().version, quartzCompatVersions())
+	}
+	if err := checkConflictPatterns(opts.conflictPatterns); err != nil {
+		return fmt.Errorf("%v", err)
+	}
+	if _, err := compileIgnorePatterns(opts.exclude, "--exclude"); err != nil {
+		return fmt.Errorf("%v", err)
+	}
+	if _, err := parseOverrides(opts.overrides); err != nil {
+		return fmt.Errorf("invalid value for --override: %v", err)
+	}
+	for _, pattern := range opts.noSnippet {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern for --no-snippet: %q", pattern)
+		}
+	}
+	if opts.stripH1 && !opts.addTitle {
+		return errors.New("--strip-h1 can only be used with --add-title")
+	}
+	if opts.descriptionLength < 20 {
+		return errors.New("--description-length must be at least 20")
+	}
+	if opts.excludeExt != "" && opts.includeExt != "" {
+		return errors.New("--exclude-ext and --include-ext cannot be used together")
+	}
+	if opts.stripInlineTags && !opts.collectInlineTags {
+		return errors.New("--strip-inline-tags can only be used with --collect-inline-tags")
+	}
+	if opts.noClobber && opts.updateOnly {
+		return errors.New("--no-clobber and --update-only cannot be used together")
+	}
+	if opts.standalone && command != "export" && command != commandExportNote {
+		return errors.New("--standalone can only be used with the export and export-note commands")
+	}
+	if (opts.noteDepth > 0 || opts.outsideLinks != outsideLinksUnlink) && command != commandExportNote {
+		return errors.New("--note-depth and --outside-links can only be used with the export-note command")
+	}
+	if opts.every > 0 && command == commandExportNote {
+		return errors.New("--every cannot be used with the export-note command")
+	}
+	if opts.every > 0 && command == "check" {
+		return errors.New("--every cannot be used with the check command")
+	}
+	if (opts.sinceGit != "" || opts.writeRef != "") && (command == "check" || command == commandExportNote) {
+		return fmt.Errorf("--since-git and --write-ref cannot be used with the %s command", command)
+	}
+	if (opts.sinceGit != "" || opts.writeRef != "") && len(sources) > 1 {
+		return errors.New("--since-git and --write-ref can only be used with a single vault")
+	}
+	if opts.sinceGit != "" && opts.clean {
+		return errors.New("--since-git cannot be used with --clean, which needs every file to be published")
+	}
+	if opts.watch && (command != "" || opts.every > 0) {
+		return errors.New("--watch can only be used when syncing to a Quartz folder, without --every")
+	}
+	if opts.watch && (len(sources) > 1 || opts.clean || opts.sinceGit != "") {
+		return errors.New("--watch can only be used with a single vault, without --clean or --since-git")
+	}
+	// Each sync of --watch only publishes the files that changed
+	if opts.watch {
+		opts.incremental = true
+	}
+	if opts.incremental && command != "" {
+		return fmt.Errorf("--incremental can only be used when syncing to a Quartz folder, not with the %s command", command)
+	}
+	if opts.redirects != "" && command != "" {
+		return fmt.Errorf("--redirects can only be used when syncing to a Quartz folder, not with the %s command", command)
+	}
+	if opts.tombstones > 0 && command != "" {
+		return fmt.Errorf("--tombstones can only be used when syncing to a Quartz folder, not with the %s command", command)
+	}
+	if opts.tombstones > 0 && !opts.prune && !opts.incremental && opts.sinceGit == "" {
+		return errors.New("--tombstones only applies to the notes --prune, --incremental or --since-git delete")
+	}
+	if opts.pinAssets != "" && command != "" {
+		return fmt.Errorf("--pin-assets can only be used when syncing to a Quartz folder, not with the %s command", command)
+	}
+	if opts.breakPins && (opts.pinAssets == "" || opts.redirects == "" || opts.redirects == redirectsAliases) {
+		return errors.New("--break-pins needs --pin-assets, and --redirects=netlify or --redirects=json to keep the old asset URLs working")
+	}
+	if opts.incremental && len(sources) > 1 {
+		return errors.New("--incremental can only be used with a single vault")
+	}
+	if (opts.migrateState || opts.resetState) && !opts.incremental {
+		return errors.New("--migrate-state and --reset-state can only be used with --incremental")
+	}
+	if opts.migrateState && opts.resetState {
+		return errors.New("--migrate-state and --reset-state cannot be used together")
+	}
+	if opts.incremental && (opts.clean || opts.sinceGit != "") {
+		return errors.New("--incremental cannot be used with --clean or --since-git")
+	}
+	for _, hook := range []struct{ name, command string }{{"--hook-file", opts.hookFile}, {"--hook-post", opts.hookPost}} {
+		if hook.command == "" {
+			continue
+		}
+		if command == "check" || command == commandExportNote {
+			return fmt.Errorf("%s cannot be used with the %s command", hook.name, command)
+		}
+		if _, err := splitCommand(hook.command); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", hook.name, err)
+		}
+	}
+	if opts.jitter > 0 && opts.jitter >= opts.every {
+		return errors.New("--jitter must be shorter than the --every interval")
+	}
+	if opts.dryRun && (command == "check" || command == commandExportNote || opts.every > 0 || opts.watch) {
+		return errors.New("--dry-run cannot be used with --every, --watch or the check and export-note commands")
+	}
+	if opts.prune && (command == "check" || command == commandExportNote || opts.clean || opts.sinceGit != "") {
+		return errors.New("--prune cannot be used with --clean, which deletes every file, --since-git, or the check and export-note commands")
+	}
+	if opts.prune && opts.noContentSubdir && !opts.yes {
+		return errors.New("--prune with --no-content-subdir deletes the files of the destination folder no note was published to, which may be your Quartz setup; pass --yes to prune anyway")
+	}
+	if opts.generateIndexes && command == commandExportNote {
+		return fmt.Errorf("--generate-indexes cannot be used with the %s command", command)
+	}
+	if (opts.lockWait > 0 || opts.forceUnlock) && (command == "check" || command == commandExportNote) {
+		return fmt.Errorf("--wait and --force-unlock cannot be used with the %s command, which takes no lock", command)
+	}
+
+	if _, err := parseLintRules(opts.lintDisable); err != nil {
+		return fmt.Errorf("invalid value for --lint-disable: %v", err)
+	}
+	if _, err := parseFolderMap(append(cfg.folderMap, opts.folderMap...)); err != nil {
+		return fmt.Errorf("invalid folder map: %v", err)
+	}
+	if _, err := parseDateFolders(opts.dateFolders); err != nil {
+		return fmt.Errorf("invalid value for --date-folders: %v", err)
+	}
+	if _, err := parseHiddenIncludes(opts.includeHidden); err != nil {
+		return fmt.Errorf("invalid value for --include-hidden: %v", err)
+	}
+	if _, err := parseTaskStatuses(opts.taskStatuses); err != nil {
+		return fmt.Errorf("invalid value for --task-status: %v", err)
+	}
+	if _, err := parseCalloutMap(opts.calloutMap); err != nil {
+		return fmt.Errorf("invalid value for --callout-map: %v", err)
+	}
+	if opts.calloutDefault != "" && !quartzCallouts[strings.ToLower(opts.calloutDefault)] {
+		return fmt.Errorf("invalid value for --callout-default: Quartz has no %s callout, use one of %s", opts.calloutDefault, calloutTypes())
+	}
+	if _, err := parseFrontmatterEdits(opts.fmDrop, opts.fmRename, opts.fmSet); err != nil {
+		return fmt.Errorf("invalid frontmatter edit: %v", err)
+	}
+	if opts.filter != "" {
+		if _, err := parseFilter(opts.filter); err != nil {
+			return fmt.Errorf("invalid value for --filter: %v", err)
+		}
+	} else if opts.explainFilter != "" {
+		return errors.New("--explain-filter needs a --filter expression")
+	}
+	return nil
+}
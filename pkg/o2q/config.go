@@ -0,0 +1,210 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the config file looked up at the root of the Obsidian folder
+const configFileName = "obsidian-to-quartz.yaml"
+
+// config holds the settings read from a config file that are not options
+// Option values are applied directly to the option registry
+type config struct {
+	source      string
+	destination string
+	exclude     []ignorePattern
+	rules       []frontmatterRule
+	folderMap   []string // Folder map entries, in the "src=>dst" form of --map
+	sources     []string // Sources, in the "folder:subfolder" form of --source, when source is a list
+	pipeline    []transformRule
+	order       []string // Body steps of the pipeline to run in another order, checked by orderBodySteps
+}
+
+// loadConfig reads a config file and applies its option values to the registry
+// Options listed in skip (set on the command line or in the environment) keep their value
+// Hooks run commands, so they are only read from a file given with --config (explicit), not from one found in a
+// vault, which may come from someone else
+// Unknown keys produce a warning so typos are caught
+func loadConfig(log *consoleLogger, path string, registry []option, skip map[string]bool, explicit bool) (config, error) {
+	var cfg config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	// Apply keys in a stable order so errors and warnings are reproducible
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+		switch key {
+		case "source":
+			if list, ok := value.([]interface{}); ok {
+				cfg.sources = configList(list)
+			} else {
+				cfg.source = configString(value)
+			}
+			continue
+		case "destination":
+			cfg.destination = configString(value)
+			continue
+		case "exclude":
+			if cfg.exclude, err = compileIgnorePatterns(configList(value), "exclude"); err != nil {
+				return cfg, fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		case "map":
+			if cfg.folderMap, err = configFolderMap(value); err != nil {
+				return cfg, fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		case "frontmatter-rules":
+			if cfg.rules, err = parseFrontmatterRules(value); err != nil {
+				return cfg, fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		case "rules":
+			if cfg.pipeline, err = parseTransformRules(value); err != nil {
+				return cfg, fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		case "order":
+			cfg.order = configList(value)
+			if _, err = orderBodySteps(cfg.order); err != nil {
+				return cfg, fmt.Errorf("%s: %v", path, err)
+			}
+			continue
+		}
+
+		o, ok := findOption(registry, key)
+		if !ok {
+			log.warnf("%s: unknown key %q", path, key)
+			continue
+		}
+		if skip[o.name] {
+			continue
+		}
+		if !explicit && (o.name == "hook-file" || o.name == "hook-post") {
+			log.warnf("%s: ignoring %s, hooks are only read from a config file given with --config", path, key)
+			continue
+		}
+		// A hook may be given as a list of arguments, which need no quoting
+		if list, ok := value.([]interface{}); ok && (o.name == "hook-file" || o.name == "hook-post") {
+			value = quoteCommand(configList(list))
+		}
+		// Each item of a list sets a repeatable option once, so items may hold commas
+		if list, ok := value.([]interface{}); ok {
+			if _, repeatable := o.value.(*listValue); repeatable {
+				for _, item := range configList(list) {
+					if err := o.value.Set(item); err != nil {
+						return cfg, fmt.Errorf("%s: invalid value for %s: %v", path, key, err)
+					}
+				}
+				continue
+			}
+		}
+		if err := o.value.Set(configString(value)); err != nil {
+			return cfg, fmt.Errorf("%s: invalid value for %s: %v", path, key, err)
+		}
+	}
+
+	// The config file lives at the root of the Obsidian folder unless told otherwise
+	// Relative folders are relative to the config file
+	dir := filepath.Dir(path)
+	for i, spec := range cfg.sources {
+		if !filepath.IsAbs(spec) {
+			cfg.sources[i] = filepath.Join(dir, spec)
+		}
+	}
+	if cfg.source == "" && len(cfg.sources) == 0 {
+		cfg.source = dir
+	} else if !filepath.IsAbs(cfg.source) {
+		cfg.source = filepath.Join(dir, cfg.source)
+	}
+	if cfg.destination != "" && !filepath.IsAbs(cfg.destination) {
+		cfg.destination = filepath.Join(dir, cfg.destination)
+	}
+
+	return cfg, nil
+}
+
+// findOption returns the option with the given config key
+func findOption(registry []option, key string) (option, bool) {
+	for _, o := range registry {
+		if o.configKey() == key {
+			return o, true
+		}
+	}
+	return option{}, false
+}
+
+// configString converts a config value to the text form used by flags
+// Lists are joined with commas
+func configString(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		return strings.Join(configList(list), ",")
+	}
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// configFolderMap reads the map key of the config file, a mapping of vault folders to their published names:
+//
+//	map: {"03 - Projects": projects, "07 - Archive": archive}
+//
+// A list or a comma-separated string of "src=>dst" entries, as printed by --print-config, is accepted too
+func configFolderMap(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		sources := make([]string, 0, len(v))
+		for source := range v {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		var entries []string
+		for _, source := range sources {
+			entries = append(entries, source+folderMapSeparator+configString(v[source]))
+		}
+		return entries, nil
+	case string:
+		return strings.Split(v, ","), nil
+	case []interface{}, nil:
+		return configList(v), nil
+	}
+	return nil, fmt.Errorf("map must be a mapping of vault folders to published folders")
+}
+
+// configList converts a config value to a list of strings
+// A single value is treated as a list of one element
+func configList(value interface{}) []string {
+	list, ok := value.([]interface{})
+	if !ok {
+		if value == nil {
+			return nil
+		}
+		return []string{fmt.Sprint(value)}
+	}
+	var result []string
+	for _, item := range list {
+		result = append(result, fmt.Sprint(item))
+	}
+	return result
+}
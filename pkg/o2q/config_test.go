@@ -0,0 +1,36 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes a config file to a temporary folder and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), configFileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigHooks(t *testing.T) {
+	path := writeConfig(t, "hook-file: optipng {dest}\nhook-post: npx quartz build\nstrip-dataview: true\n")
+	for _, explicit := range []bool{false, true} {
+		var opts options
+		if _, err := loadConfig(console, path, newOptionRegistry(&opts), nil, explicit); err != nil {
+			t.Fatalf("loadConfig(explicit=%v) error = %v", explicit, err)
+		}
+		if !opts.stripDataview {
+			t.Errorf("loadConfig(explicit=%v) did not set strip-dataview", explicit)
+		}
+		if explicit && (opts.hookFile != `optipng {dest}` || opts.hookPost != "npx quartz build") {
+			t.Errorf("loadConfig() of a --config file: hooks = %q, %q, want them read", opts.hookFile, opts.hookPost)
+		}
+		if !explicit && (opts.hookFile != "" || opts.hookPost != "") {
+			t.Errorf("loadConfig() of a config file found in the vault: hooks = %q, %q, want them ignored", opts.hookFile, opts.hookPost)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Kinds of files left by sync services and editors, which are never published without --no-skip-conflicts
+const (
+	conflictCopy        = "conflict copy"      // Version of a note kept by a sync service when two devices changed it
+	conflictPlaceholder = "iCloud placeholder" // Stand-in for a file iCloud has not downloaded
+	conflictTemporary   = "temporary file"     // Swap, lock or backup file of an editor, or a partial download
+	conflictPattern     = "matching --conflict-pattern"
+)
+
+// conflictRule recognizes the files of a sync service or editor by their name
+type conflictRule struct {
+	re   *regexp.Regexp
+	kind string
+}
+
+// conflictRules are the built-in names of conflict copies and temporary files
+var conflictRules = []conflictRule{
+	// Syncthing: Note.sync-conflict-20240311-143502-ABCDEF7.md, and its partial downloads
+	{regexp.MustCompile(`\.sync-conflict-\d{8}-\d{6}(-[[:alnum:]]+)?`), conflictCopy},
+	{regexp.MustCompile(`^(\.syncthing\..+|~syncthing~.+)\.tmp$`), conflictTemporary},
+	// Dropbox and Nextcloud: Note (Conflicted copy).md, Note (Jane's conflicted copy 2024-03-11).md,
+	// Note (conflicted copy 2024-03-11 143502).md
+	{regexp.MustCompile(`(?i)\([^()]*conflicted copy[^()]*\)`), conflictCopy},
+	// ownCloud and older Nextcloud: Note_conflict-20240311-143502.md
+	{regexp.MustCompile(`_conflict-\d{8}-\d{6}`), conflictCopy},
+	// iCloud: .Note.md.icloud
+	{regexp.MustCompile(`^\..+\.icloud$`), conflictPlaceholder},
+	// Emacs: .#Note.md lock files and #Note.md# auto-saves
+	{regexp.MustCompile(`^\.#|^#.+#$`), conflictTemporary},
+	// Backups of Emacs, Vim and other editors: Note.md~
+	{regexp.MustCompile(`~$`), conflictTemporary},
+	// Vim swap files: .Note.md.swp
+	{regexp.MustCompile(`^\..+\.sw[a-p]$`), conflictTemporary},
+}
+
+// conflictFile is a file left out as a conflict copy or a temporary file, listed in the summary
+type conflictFile struct {
+	Source string `json:"source"`
+	Kind   string `json:"kind"`
+}
+
+// checkConflictPatterns checks the patterns of --conflict-pattern, which are glob patterns on file names
+func checkConflictPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if strings.Contains(p, "/") {
+			return fmt.Errorf("--conflict-pattern %q: patterns match file names, not paths", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("--conflict-pattern %q: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// conflictKind returns what a file is if it is a conflict copy or a temporary file, or "" for a file to publish
+func (c *converter) conflictKind(name string) string {
+	if c.opts.noSkipConflicts {
+		return ""
+	}
+	for _, r := range conflictRules {
+		if r.re.MatchString(name) {
+			return r.kind
+		}
+	}
+	for _, p := range c.opts.conflictPatterns {
+		if ok, _ := path.Match(p, name); ok {
+			return conflictPattern
+		}
+	}
+	return ""
+}
+
+// skipConflict records a file left out as a conflict copy or a temporary file
+// An iCloud placeholder means the file it stands for is not on this computer, so its content is missing from the site
+func (c *converter) skipConflict(src, kind string) {
+	if kind == conflictPlaceholder {
+		real := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(src), "."), ".icloud")
+		c.console.warnf("%s: iCloud has not downloaded %s, which is missing from the site; download it in Finder and run again", src, real)
+	}
+	c.addReportNote(src, kind)
+	c.report.Conflicts = append(c.report.Conflicts, conflictFile{Source: c.paths.source(src), Kind: kind})
+	c.record(reportEntry{Source: src, Action: actionSkippedConflict}, 0)
+}
@@ -0,0 +1,83 @@
+package o2q
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Verbosity levels
+const (
+	verbosityQuiet   = iota // Only errors and the summary
+	verbosityNormal         // Summary, warnings and status lines
+	verbosityVerbose        // Also per-file progress and skip reasons
+)
+
+// consoleLogger writes messages according to the verbosity level
+// Progress and status go to stdout, warnings and errors always go to stderr
+type consoleLogger struct {
+	verbosity int
+	out       io.Writer
+	err       io.Writer
+	meter     *progressMeter           // Progress line to clear before printing a message, if any
+	scrub     func(text string) string // Shortens the paths of a message, if set
+}
+
+// console is the logger used for all user-facing messages
+var console = &consoleLogger{verbosity: verbosityNormal, out: os.Stdout, err: os.Stderr}
+
+// fork returns a logger printing where l prints, at its verbosity, with its own progress line and path display,
+// so that a run setting them does not change the messages of another
+func (l *consoleLogger) fork() *consoleLogger {
+	return &consoleLogger{verbosity: l.verbosity, out: l.out, err: l.err}
+}
+
+// progressf prints a per-file progress line, only in verbose mode
+func (l *consoleLogger) progressf(format string, args ...interface{}) {
+	if l.verbosity >= verbosityVerbose {
+		l.print(l.out, "", format, args...)
+	}
+}
+
+// infof prints a status line, unless in quiet mode
+func (l *consoleLogger) infof(format string, args ...interface{}) {
+	if l.verbosity >= verbosityNormal {
+		l.print(l.out, "", format, args...)
+	}
+}
+
+// warnf prints a warning, unless in quiet mode
+func (l *consoleLogger) warnf(format string, args ...interface{}) {
+	if l.verbosity >= verbosityNormal {
+		l.print(l.err, "Warning: ", format, args...)
+	}
+}
+
+// detailf prints an indented continuation line of a warning, unless in quiet mode
+func (l *consoleLogger) detailf(format string, args ...interface{}) {
+	if l.verbosity >= verbosityNormal {
+		l.print(l.err, "  ", format, args...)
+	}
+}
+
+// errorf prints an error, whatever the verbosity
+func (l *consoleLogger) errorf(format string, args ...interface{}) {
+	l.print(l.err, "Error: ", format, args...)
+}
+
+// verbatimf prints an indented continuation line of an error, whatever the verbosity, without shortening its paths,
+// for a command line whose paths must be shown as they were given to it
+func (l *consoleLogger) verbatimf(format string, args ...interface{}) {
+	l.meter.clear()
+	fmt.Fprintln(l.err, "  "+fmt.Sprintf(format, args...))
+}
+
+// print writes a message on its own line, after clearing the progress line
+func (l *consoleLogger) print(w io.Writer, prefix, format string, args ...interface{}) {
+	l.meter.clear()
+	message := fmt.Sprintf(format, args...)
+	if l.scrub != nil {
+		message = l.scrub(message)
+	}
+	fmt.Fprintln(w, prefix+message)
+}
@@ -0,0 +1,83 @@
+package o2q
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// resolveExisting returns the absolute form of a path with the symbolic links of its deepest existing folder resolved,
+// keeping the part that does not exist yet as is
+func resolveExisting(p string) string {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	rest := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(p, rest)
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
+
+// checkNesting refuses a content folder inside the vault, which the next run would publish into itself,
+// and a vault inside the content folder, which the run would write into
+// A content folder the walk never enters, being in a hidden or ignored folder of the vault, is accepted,
+// and so is a vault elsewhere in the Quartz folder, such as next to the content folder
+func (c *converter) checkNesting() error {
+	vault := resolveExisting(c.obsidianFolder)
+	content := resolveExisting(c.contentFolder)
+	if vault == content || isWithin(vault, content) {
+		return refusedf("the Obsidian folder %s is inside the output folder %s; the run would write into the vault",
+			c.obsidianFolder, c.contentFolder)
+	}
+	if !isWithin(content, vault) {
+		return nil
+	}
+	rel, _ := filepath.Rel(vault, content)
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ".") {
+			return nil
+		}
+		if ignored, _ := c.ignored(filepath.FromSlash(strings.Join(segments[:i+1], "/")), true); ignored {
+			return nil
+		}
+	}
+	return refusedf("the output folder %s is inside the Obsidian folder %s, so the next run would publish it into itself; "+
+		"move the Quartz folder out of the vault, or add %s/ to the ignore file", c.contentFolder, c.obsidianFolder, filepath.ToSlash(rel))
+}
+
+// destRoots returns the resolved folders a run writes to and deletes from: the output folder,
+// and the static folder of Quartz with --html=static or --html=iframe
+func (c *converter) destRoots() []string {
+	if c.resolvedRoots == nil {
+		c.resolvedRoots = []string{resolveExisting(c.contentFolder)}
+		if c.opts.html == htmlStatic || c.opts.html == htmlIframe {
+			c.resolvedRoots = append(c.resolvedRoots, resolveExisting(filepath.Join(c.quartzFolder, "quartz", "static")))
+		}
+	}
+	return c.resolvedRoots
+}
+
+// checkContained refuses to write or delete a file outside the folders of the run, as a misconfigured --map or
+// permalink, or a symbolic link in the content folder, could lead it to
+// The folder of the file is resolved, so a link leading elsewhere is caught even if the file does not exist yet
+func (c *converter) checkContained(dest string) error {
+	resolved := filepath.Join(resolveExisting(filepath.Dir(dest)), filepath.Base(dest))
+	for _, root := range c.destRoots() {
+		if resolved == root || isWithin(resolved, root) {
+			return nil
+		}
+	}
+	if abs, err := filepath.Abs(dest); err == nil && abs != resolved {
+		return refusedf("resolves to %s, outside the output folder; refusing to touch it", resolved)
+	}
+	return refusedf("is outside the output folder; refusing to touch it")
+}
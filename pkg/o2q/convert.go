@@ -0,0 +1,804 @@
+package o2q
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// converter holds the state of a conversion run
+type converter struct {
+	opts               options
+	console            *consoleLogger // Messages of the run, with its own progress line and path display
+	obsidianFolder     string
+	vault              *vaultFS // Files of the vault, from obsidianFolder or the archive it names
+	quartzFolder       string
+	contentFolder      string
+	export             bool            // Writing to an export folder instead of a Quartz folder
+	paths              *pathDisplay    // Shortens the paths shown in messages and reports
+	keptFiles          []string        // Destination files not overwritten because of --no-clobber or --update-only
+	symlinkNotices     map[string]bool // Symbolic links already reported, as the vault is walked several times
+	excludePatterns    []ignorePattern
+	gitignore          *gitignore               // Rules of the .gitignore files of the vault, with --respect-gitignore
+	gitignoreKeeps     []ignorePattern          // ! lines of the ignore file, which publish paths the .gitignore files exclude
+	vaultFiles         []string                 // Vault-relative paths of published files, used to resolve links
+	svgFiles           []string                 // Vault-relative paths of published SVGs, with a lowercase extension, listed on first use
+	svgFilesErr        error                    // Error listing svgFiles
+	skippedAssets      map[string]*skippedAsset // Files left out by --max-file-size, --exclude-ext and --include-ext, by vault path
+	linkFallbackWarned bool                     // A file could not be linked with --link-mode and was copied
+	mediaLinks         linkPattern
+	resolvedRoots      []string          // Resolved folders the run may write to and delete from, listed on first use
+	refusedFiles       int               // Files and folders refused as they resolve outside the output folder
+	unpublishedNotes   map[string]bool   // Notes passing the walk rules but left out, by vault path, for --missing-embeds
+	excludedNotes      map[string]bool   // Notes of the vault the plan does not publish, by vault path, for --unpublished-links
+	vaultNotes         map[string]string // Vault paths of every note, by noteKey, to resolve links to unpublished notes
+	vaultNotesByName   map[string][]string
+	publishedNotes     *noteIndex        // Notes the plan publishes, indexed on first use by --resolve-links
+	siteBaseURL        *url.URL          // Absolute links to this site are treated as internal
+	siteSlugs          map[string]string // Lowercased Quartz URL paths of published files, to their vault paths
+
+	report              *runReport
+	degradedBlockEmbeds []string   // Notes whose block transclusions were replaced with links
+	lostPublishedNotes  []lostNote // Notes marked publish: true that other rules exclude
+	lintDisabled        map[string]bool
+	lintFindings        int                     // Lint findings and frontmatter rule violations
+	checking            bool                    // Run of the check command, which reports lint findings
+	frontmatterRules    []frontmatterRule       // Validation rules from the config file
+	transformRules      []transformRule         // Steps of the pipeline turned off by path, from the config file
+	taggedNotes         map[string][]taggedNote // Published notes by normalized tag, for --emit-tag-pages
+	splitNotes          map[string]*splitNote   // Notes split by --oversize-notes=split, by vault-relative path
+	destOwners          map[string]string       // Vault file published to each destination, to catch Unicode name collisions
+	renames             map[string]string       // Destinations of the files renamed by --sanitize-names or moved by --attachments-to
+	attachments         map[string]bool         // Vault-relative paths of the attachments moved by --attachments-to
+	frontmatterReported map[string]bool         // Notes whose invalid frontmatter was already reported
+	reportNotes         map[string][]string     // Steps skipped for a note, added to its report entries
+	filter              filterExpr              // Parsed --filter expression; nil publishes every note
+	filterResults       map[string]bool         // Whether each note evaluated so far matches --filter, by vault-relative path
+	folderMap           []folderMapping         // Vault folders published under another name, deepest first
+	calloutMap          map[string]string       // Supported callout type of each custom one, by lowercased custom type
+	taskStatuses        map[string]bool         // Whether each custom task status is shown checked with --normalize-tasks
+	mapped              map[string]bool         // Vault-relative paths of the files moved by the folder map
+	dateFolders         []dateFolder            // Folders whose date-named notes are published in nested folders
+	dated               map[string]bool         // Vault-relative paths of the notes moved by --date-folders
+	hiddenIncludes      []*hiddenInclude        // Files of hidden folders published anyway, with --include-hidden
+	relocations         map[string]*relocation  // Notes setting their published path with quartz-path or permalink, by vault path
+	relocatedDests      map[string]string       // Content-relative paths of the relocated notes, to their vault paths
+	overrides           []ignoreOverride        // --override rules, applied after the ignore patterns
+	overrideKept        map[string]bool         // Content-relative paths --clean and --prune keep as --override only excludes their source for this run
+	overrideKeptCount   int                     // Files kept by --clean and --prune because of overrideKept
+	compat              quartzCompat            // How the targeted version of Quartz handles version-dependent syntax
+	exportFiles         map[string]bool         // Vault-relative paths of the files written by export-note; nil writes every file
+	frontmatterEdits    *frontmatterEdits       // Keys dropped, renamed and set by --fm-drop, --fm-rename and --fm-set; nil if none
+	gitChanges          *gitChanges             // Files changed since the --since-git revision; nil publishes every file
+	state               *incrementalState       // What the last --incremental run published; nil publishes every file
+	listing             *vaultListing           // Vault folders read by the last syncs of --watch; nil reads every folder
+	redirects           *redirectsState         // URLs of the notes and redirects of earlier runs, with --redirects
+	pins                *pinsState              // Published paths of the assets pinned by --pin-assets
+	pinned              map[string]bool         // Vault-relative paths of the assets kept at their pinned path
+	brokenPins          []redirect              // Old and new paths of the pinned assets moved by --break-pins
+	selfCheck           *selfCheck              // How the plan was carried out, with --self-check
+	tombstones          *tombstonesState        // When the stubs of --tombstones were written, with --tombstones
+	written             map[string][]string     // Files written for each source being processed, for --incremental and --hook-file
+	outputs             map[string]bool         // Files of the content folder written or kept by this run, for --prune
+	plan                *filePlan               // What the run does with each file of the vault, decided before anything is written
+	bodySteps           []transformStep         // Body steps of the pipeline, in the order of the config file
+	snippets            snippets                // Contents of --prepend-file and --append-file
+}
+
+// runConversion performs a single conversion, check or export run of a source; errors are printed as they occur
+// run is shared by the sources of a run publishing several of them, and nil otherwise
+// Messages are printed where log prints them, at its verbosity
+// Returns the exit code of the run
+func runConversion(log *consoleLogger, opts options, cfg config, source vaultSource, quartzFolder, command string, run *sourceRun) int {
+	lintDisabled, _ := parseLintRules(opts.lintDisable)                         // Checked before the first run
+	folderMap, _ := parseFolderMap(append(cfg.folderMap, opts.folderMap...))    // Checked before the first run
+	fmEdits, _ := parseFrontmatterEdits(opts.fmDrop, opts.fmRename, opts.fmSet) // Checked before the first run
+	overrides, _ := parseOverrides(opts.overrides)                              // Checked before the first run
+	calloutMap, _ := parseCalloutMap(opts.calloutMap)                           // Checked before the first run
+	dateFolders, _ := parseDateFolders(opts.dateFolders)                        // Checked before the first run
+	taskStatuses, _ := parseTaskStatuses(opts.taskStatuses)                     // Checked before the first run
+	hiddenIncludes, _ := parseHiddenIncludes(opts.includeHidden)                // Checked before the first run
+	compat, _ := lookupQuartzCompat(opts.quartzCompat)                          // Unknown versions are warned about before the first run
+	steps, _ := orderBodySteps(cfg.order)                                       // Checked when the config file was read
+	var filter filterExpr
+	if opts.filter != "" {
+		filter, _ = parseFilter(opts.filter) // Checked before the first run
+	}
+
+	c := &converter{
+		opts:           opts,
+		console:        log.fork(),
+		obsidianFolder: source.folder,
+		listing:        source.listing,
+		quartzFolder:   quartzFolder,
+		mediaLinks:     newLinkPattern(parseMediaExtensions(opts.mediaExtensions)...),
+		report:         newRunReport(),
+		lintDisabled:   lintDisabled,
+		checking:       command == "check",
+		filter:         filter,
+		folderMap:      folderMap,
+		calloutMap:     calloutMap,
+		dateFolders:    dateFolders,
+		taskStatuses:   taskStatuses,
+		hiddenIncludes: hiddenIncludes,
+
+		frontmatterEdits: fmEdits,
+		compat:           compat,
+		overrides:        overrides,
+		frontmatterRules: cfg.rules,
+		transformRules:   cfg.pipeline,
+		bodySteps:        steps,
+	}
+	if opts.selfCheck && !opts.dryRun {
+		c.selfCheck = newSelfCheck()
+	}
+
+	// Read the vault from its folder, or from its archive without unpacking it
+	var vaultErr error
+	if c.vault, vaultErr = openVault(c.obsidianFolder); vaultErr != nil {
+		c.console.errorf("%v", vaultErr)
+		return exitFailure
+	}
+	defer c.vault.Close()
+
+	// Read exclusion patterns from the ignore files, the config file and --exclude
+	ignoreFile := filepath.Join(c.obsidianFolder, ignoreFileName)
+	if opts.ignoreFile != "" {
+		ignoreFile = opts.ignoreFile
+	}
+	patterns, readErr := readExcludePatterns(c.vault, ignoreFile, opts.ignoreFile != "")
+	if readErr != nil {
+		c.console.errorf("%v", readErr)
+		return exitFailure
+	}
+	nested, nestedErr := readNestedIgnoreFiles(c.console, c.vault, patterns)
+	if nestedErr != nil {
+		c.console.errorf("failed to read the ignore files of the vault folders: %v", nestedErr)
+		return exitFailure
+	}
+	patterns = append(patterns, nested...)
+	var keeps []ignorePattern // Paths the ! lines publish, which --respect-gitignore keeps too
+	for _, p := range patterns {
+		if p.negate {
+			keep := p
+			keep.negate = false
+			keeps = append(keeps, keep)
+		}
+	}
+	excludes, _ := compileIgnorePatterns(opts.exclude, "--exclude") // Checked before the first run
+	c.excludePatterns = append(append(patterns, cfg.exclude...), excludes...)
+	if len(c.excludePatterns) > 0 {
+		c.console.infof("Loaded %d exclusion patterns", len(c.excludePatterns))
+	}
+	c.addAutoExcludes(patterns)
+	if opts.respectGitignore {
+		if err := c.loadGitignore(keeps); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+	c.reportOverrides()
+
+	// Read the snippets added to every note
+	var snippetErr error
+	if c.snippets, snippetErr = readSnippets(opts.prependFile, opts.appendFile); snippetErr != nil {
+		c.console.errorf("%v", snippetErr)
+		return exitFailure
+	}
+
+	// Find the commit to record and the files changed since --since-git, before anything is written
+	head := ""
+	if opts.writeRef != "" {
+		var err error
+		if head, err = gitHead(c.obsidianFolder); err != nil {
+			c.console.errorf("--write-ref: %v", err)
+			return exitFailure
+		}
+	}
+	if opts.sinceGit != "" {
+		var err error
+		if c.gitChanges, err = readGitChanges(c.obsidianFolder, opts.sinceGit); err != nil {
+			c.console.errorf("--since-git: %v", err)
+			return exitFailure
+		}
+		c.console.infof("%d files changed and %d deleted since %s", len(c.gitChanges.changed), len(c.gitChanges.deleted), opts.sinceGit)
+	}
+
+	// Ensure Quartz content folder exists; an export is written to the output folder itself
+	// Each source of a run publishing several of them has its own subfolder
+	c.opts.contentDir = filepath.Join(opts.contentDir, filepath.FromSlash(source.sub))
+	c.contentFolder = filepath.Join(c.quartzFolder, c.opts.contentDir)
+	if command == "export" || command == commandExportNote {
+		c.contentFolder = filepath.Join(c.quartzFolder, filepath.FromSlash(source.sub))
+		c.export = true
+	}
+	if run != nil {
+		c.destOwners = run.destOwners
+	}
+	if command != "check" {
+		if err := c.checkNesting(); err != nil {
+			c.console.errorf("%v", err)
+			return exitRefused
+		}
+	}
+
+	// From now on, paths are shown relative to the vault and content folders
+	c.paths = newPathDisplay(c.obsidianFolder, c.contentFolder, opts.absolutePaths)
+	c.console.scrub = c.paths.scrub
+	c.report.Base = reportBase{Source: c.paths.base(c.obsidianFolder), Destination: c.paths.base(c.contentFolder)}
+	if c.opts.linkMode != linkModeCopy {
+		c.report.LinkMode = c.opts.linkMode
+	}
+	if command != "check" && !opts.dryRun {
+		if err := os.MkdirAll(c.contentFolder, 0755); err != nil {
+			c.console.errorf("creating content folder: %v", err)
+			return exitFailure
+		}
+
+		// Remove temporary files left behind by an interrupted run
+		for _, folder := range []string{c.contentFolder, filepath.Join(c.quartzFolder, "quartz", "static")} {
+			if err := removeTempFiles(c.console, folder); err != nil {
+				c.console.errorf("%v", err)
+				return exitFailure
+			}
+		}
+	}
+
+	if opts.siteBaseURL != "" {
+		var err error
+		if c.siteBaseURL, err = parseSiteBaseURL(opts.siteBaseURL); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+
+	// Find the files left out by their size or extension, so links to them can be reported from any note
+	if err := c.planSkippedAssets(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Find the notes whose frontmatter sets the path they are published to
+	if err := c.findRelocations(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Index vault files so links to them can be resolved
+	splitting := opts.maxNoteSize > 0 && opts.oversizeNotes == oversizeSplit
+	if opts.html == htmlStatic || opts.html == htmlIframe || opts.mediaEmbeds != mediaKeep || opts.imageSize != imageSizeKeep || opts.missingEmbeds != missingEmbedsKeep || c.siteBaseURL != nil || splitting ||
+		opts.sanitizeNames || opts.attachmentsTo != "" || len(c.folderMap) > 0 || len(c.dateFolders) > 0 || len(c.relocations) > 0 || command == commandExportNote || opts.obsidianURIs || opts.resolveLinks || opts.pinAssets != "" {
+		if err := c.indexFiles(); err != nil {
+			c.console.errorf("walking through folder: %v", err)
+			return exitFailure
+		}
+		c.planUnpublishedNotes()
+	}
+
+	// Only export a note and the files it needs with export-note
+	if command == commandExportNote {
+		if err := c.selectExport(opts.exportNote); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+
+	// Find names that break on Windows or web hosts, and rename them with --sanitize-names
+	if err := c.planRenames(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Publish mapped folders under their new name
+	if err := c.planFolderMap(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Publish date-named notes in nested folders built from their date
+	if err := c.planDateFolders(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Gather attachments into a single folder
+	if err := c.planAttachments(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Publish relocated notes to the path set in their frontmatter
+	if err := c.planRelocations(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Keep the assets pinned by an earlier run at their published path, whatever the renames above
+	if err := c.planPins(); err != nil {
+		c.console.errorf("walking through folder: %v", err)
+		return exitFailure
+	}
+
+	// Index the URLs of the published files on the site, now that their destinations are known
+	if c.siteBaseURL != nil {
+		c.indexSiteSlugs()
+	}
+
+	// Work out how oversized notes are split, so links to them can be rewritten in every note
+	if splitting && command != "check" {
+		if err := c.planSplits(); err != nil {
+			c.console.errorf("walking through folder: %v", err)
+			return exitFailure
+		}
+	}
+
+	// Stop before writing anything if the destination cannot hold the run
+	// The check comes before --clean, so a failed run leaves the content folder as it was
+	if command != "check" {
+		if err := c.checkFreeSpace(); err != nil {
+			c.console.errorf("%v", err)
+			return exitCodeFor(err)
+		}
+
+		// Start from an empty content folder if requested; sources sharing a subfolder clean it once
+		if opts.clean && (run == nil || !run.cleaned[source.sub]) {
+			if run != nil {
+				run.cleaned[source.sub] = true
+			}
+			if err := c.planOverrideKeeps(); err != nil {
+				c.console.errorf("walking through folder: %v", err)
+				return exitFailure
+			}
+			if err := c.cleanContent(); err != nil {
+				c.console.errorf("%v", err)
+				return exitCodeFor(err)
+			}
+		}
+	}
+
+	// Find notes published on Obsidian Publish that would silently disappear
+	if opts.fromObsidianPublish {
+		if err := c.findLostPublishedNotes(); err != nil {
+			c.console.errorf("walking through folder: %v", err)
+			return exitFailure
+		}
+	}
+
+	if command == "check" {
+		notes, err := c.checkVault()
+		if err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+		if c.lintFindings > 0 {
+			c.console.errorf("found %d problems in %d notes", c.lintFindings, notes)
+			return exitFileErrors
+		}
+		c.console.infof("No problems found")
+		return exitSuccess
+	}
+
+	// Compare with the state file of the last --incremental run; a full sync leaves no state file, as it may not match anymore
+	if opts.incremental {
+		state, err := loadState(c.console, c.quartzFolder, c.stateIdentityOf(), c.settingsHash(), opts.migrateState, opts.resetState)
+		if err != nil {
+			c.console.errorf("%v", err)
+			return exitCodeFor(err)
+		}
+		c.state = state
+	} else if command == "" && !opts.dryRun {
+		if err := os.Remove(filepath.Join(c.quartzFolder, stateFileName)); err == nil {
+			c.console.progressf("Deleted the state file of --incremental, as this run publishes every file")
+		}
+	}
+
+	// Read the URLs of the notes published by the last runs, to redirect those that moved
+	if opts.redirects != "" {
+		c.redirects = loadRedirects(c.console, c.quartzFolder)
+	}
+
+	// Read when the stubs of deleted notes were written, to delete those past their retention
+	if opts.tombstones > 0 {
+		c.tombstones = loadTombstones(c.console, c.quartzFolder)
+	}
+
+	// Delete the published files whose source is gone since --since-git
+	if c.gitChanges != nil {
+		if err := c.removeDeletedSinceGit(); err != nil {
+			c.console.errorf("%v", err)
+			return exitCodeFor(err)
+		}
+	}
+
+	// Walk through Obsidian folder to decide what happens to every file, then carry out the plan
+	// Progress counts the files the plan publishes
+	c.plan = newFilePlan()
+	err := c.walkVault(c.planVisit)
+	if err == nil {
+		c.planStaleDependents()
+		c.warnUnusedHiddenIncludes()
+		err = c.checkAmbiguousNames()
+	}
+	if err == nil {
+		err = c.planUnpublishedLinks()
+	}
+	if err == nil {
+		c.planRedirects()
+		c.redirectBrokenPins()
+	}
+	// With --dry-run, the plan is listed instead of carried out
+	if opts.dryRun {
+		if err != nil {
+			c.console.errorf("walking through folder: %v", err)
+			return exitFailure
+		}
+		return c.printPlan()
+	}
+	if err == nil {
+		if opts.progress != progressNever {
+			c.console.meter = newProgressMeter(c.console, opts.progress, c.plan.eligible())
+		}
+		err = c.executePlan()
+	}
+	c.console.meter.clear()
+	c.console.meter = nil
+	if err != nil && isDiskFull(err) {
+		c.reportDiskFull(err)
+	} else if errors.Is(err, errFailFast) {
+		c.console.errorf("%v", err)
+	} else if err != nil {
+		c.console.errorf("walking through folder: %v", err)
+	}
+
+	// Generate a page per tag once all notes are known
+	if err == nil {
+		if err = c.writeTagPages(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	// Generate an index page for the folders without an index.md, once every note is published
+	if err == nil {
+		if err = c.writeFolderIndexes(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	// Delete the published files whose source is gone since the last --incremental run
+	if err == nil && c.state != nil {
+		if err = c.removeVanishedSources(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	// Delete the stubs of --tombstones past their retention
+	if err == nil {
+		if err = c.expireTombstones(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	// Delete the files of the content folder published from no vault file
+	if err == nil {
+		if err = c.pruneContent(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	// Remove the folders that hold no published file
+	if err == nil {
+		if err = c.removeEmptyDirs(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	// Render the exported notes to HTML pages that can be opened without Quartz
+	if err == nil && opts.standalone {
+		if err = c.renderStandalone(); err != nil {
+			c.console.errorf("%v", err)
+		}
+	}
+
+	c.reportDegradedBlockEmbeds()
+	c.reportLostPublishedNotes()
+	c.reportKeptFiles()
+	c.reportRenames()
+	c.reportOverrideEffects()
+
+	// The summary and report are produced even when the run failed
+	c.report.finish()
+	c.report.print(c.console.out)
+	if run != nil {
+		run.reports = append(run.reports, c.report)
+	} else if opts.reportJSON != "" {
+		if reportErr := c.report.writeJSON(opts.reportJSON); reportErr != nil {
+			c.console.errorf("%v", reportErr)
+			err = reportErr
+		}
+	}
+	if err != nil {
+		return exitCodeFor(err)
+	}
+	if c.report.Errors > 0 {
+		if c.refusedFiles > 0 {
+			return exitRefused
+		}
+		return exitFileErrors
+	}
+	if n := len(c.report.MissingDrawings); n > 0 && opts.failOnMissingDrawings {
+		c.console.errorf("%d links to Excalidraw drawings without an SVG export", n)
+		return exitFileErrors
+	}
+	if n := c.report.frontmatterErrors(); n > 0 && opts.failOnFrontmatter {
+		c.console.errorf("%d notes with invalid frontmatter", n)
+		return exitFileErrors
+	}
+	if n := c.report.ambiguousLinks(); n > 0 && opts.failOnAmbiguousLinks {
+		c.console.errorf("%d links to note names used by several notes", n)
+		return exitFileErrors
+	}
+
+	if c.state != nil {
+		if err := c.writeState(); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+	if c.redirects != nil {
+		if err := c.writeRedirects(); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+	if c.pins != nil {
+		if err := c.writePins(); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+	if c.tombstones != nil {
+		if err := c.writeTombstones(); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+	if c.selfCheck != nil {
+		problems := c.verifyRun()
+		if len(problems) == 0 {
+			problems = c.replan(opts, cfg, source, command)
+		}
+		if len(problems) > 0 {
+			c.reportSelfCheck(problems)
+			return exitFailure
+		}
+	}
+	if opts.writeRef != "" {
+		if err := writeGitRef(opts.writeRef, head); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+
+	if c.report.Transformed+c.report.Generated+c.report.Copied+c.report.Deleted+c.report.Tombstoned == 0 {
+		c.console.infof("Nothing to publish: no file was written or deleted")
+		return exitNothingToDo
+	}
+	c.console.infof("Conversion completed successfully!")
+	return exitSuccess
+}
+
+// processFile publishes a single file according to its type
+func (c *converter) processFile(path, relPath, destPath string) error {
+	// Drawings without an SVG export are rendered to one with --render-drawings
+	if c.rendersDrawing(path) {
+		return c.renderDrawing(path, destPath)
+	}
+
+	// Handle canvas files according to the canvas mode
+	if hasExt(path, ".canvas") {
+		if c.opts.canvas == canvasList {
+			return c.processCanvasFile(path, destPath+".md")
+		}
+		c.record(reportEntry{Source: path, Action: actionSkippedType}, 0)
+		return nil
+	}
+
+	// Handle HTML files according to the html mode
+	if hasExt(path, ".html") {
+		return c.processHTMLFile(path, relPath, destPath)
+	}
+
+	// Process the file
+	if hasExt(path, ".md") {
+		// Quartz only picks up notes with a lowercase .md extension
+		destPath = strings.TrimSuffix(destPath, filepath.Ext(destPath)) + ".md"
+
+		// With Obsidian Publish metadata, only notes marked publish: true are published, and with --skip-unpublished
+		// notes marked publish: false or draft: true are not
+		if c.opts.fromObsidianPublish || c.opts.skipUnpublished {
+			reason, err := c.unpublished(path)
+			if err != nil {
+				if c.rejectFrontmatter(path, destPath, err) {
+					return nil
+				}
+				if !c.frontmatterFallback(path, err, "publish flags") {
+					return err
+				}
+				// Read as a note without frontmatter, which is not marked publish: true
+				reason = c.unpublishedReason(nil)
+			}
+			if reason != "" {
+				c.addReportNote(path, reason)
+				c.record(reportEntry{Source: path, Action: actionSkippedUnpublished}, 0)
+				return nil
+			}
+		}
+
+		// Notes too large for Quartz are reported, excluded or split
+		if exclude, err := c.checkNoteSize(path); err != nil || exclude {
+			return err
+		}
+
+		// Binary files named .md and notes larger than --max-transform-size are copied without being read whole
+		if copied, err := c.copyUntransformed(path, destPath); err != nil || copied {
+			return err
+		}
+
+		// Process markdown files (transform excalidraw links)
+		return c.processMarkdownFile(path, destPath)
+	} else {
+		// Copy other files as-is
+		return c.copyFile(path, destPath)
+	}
+}
+
+// hasExt checks if a path has an extension (with its dot), ignoring case, so Note.MD is a note
+func hasExt(path, ext string) bool {
+	return strings.EqualFold(filepath.Ext(path), ext)
+}
+
+// isInExcalidrawFolder checks if a file path contains "Excalidraw" folder
+func isInExcalidrawFolder(path string) bool {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for _, part := range parts {
+		if strings.EqualFold(part, "Excalidraw") {
+			return true
+		}
+	}
+	return false
+}
+
+// processMarkdownFile reads a markdown file, transforms excalidraw links, and writes to destination
+func (c *converter) processMarkdownFile(src, dest string) error {
+	// Read the source file
+	content, err := c.vault.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read markdown file: %v", err)
+	}
+
+	// Notes with invalid frontmatter are published without it, as it would break the Quartz build
+	invalid := false
+	if _, err := parseFrontmatter(content); err != nil {
+		if c.rejectFrontmatter(src, dest, err) {
+			return nil
+		}
+		invalid = c.frontmatterFallback(src, err, "")
+	} else {
+		c.checkFrontmatterPitfalls(src, content)
+	}
+
+	// Notes violating strict frontmatter rules are not published, but the run carries on so all of them are listed
+	if err := c.checkFrontmatterRules(src, content); err != nil {
+		c.console.errorf("%s: %v", src, err)
+		c.record(reportEntry{Source: src, Destination: dest, Action: actionError, Error: err.Error()}, 0)
+		return nil
+	}
+
+	c.checkTemplateSyntax(src, content)
+	c.printSteps(src)
+
+	relPath, _ := filepath.Rel(c.obsidianFolder, src)
+	if note, ok := c.splitNotes[filepath.ToSlash(relPath)]; ok {
+		if invalid {
+			note.frontmatter = ""
+		}
+		note.frontmatter = string(c.editNoteFrontmatter(src, []byte(note.frontmatter)))
+		return c.writeSplitNote(src, dest, content, note)
+	}
+
+	c.collectTags(src, dest, content)
+	if invalid {
+		_, content, _ = splitFrontmatter(content)
+	}
+	content = c.editNoteFrontmatter(src, content)
+	if c.stepEnabled(src, snippetsStep.name) {
+		content = snippetsStep.run(c, src, content)
+	}
+	return c.writeMarkdownFile(src, dest, c.transformMarkdown(src, content), actionTransformed)
+}
+
+// editNoteFrontmatter applies the frontmatter edits and the frontmatter transforms to a note
+func (c *converter) editNoteFrontmatter(src string, content []byte) []byte {
+	return c.runSteps(src, content, frontmatterSteps)
+}
+
+// transformMarkdown applies the body steps of the pipeline the rules leave on to the content of a markdown file
+func (c *converter) transformMarkdown(src string, content []byte) []byte {
+	return c.runSteps(src, content, c.bodySteps)
+}
+
+// writeMarkdownFile writes transformed or generated markdown content to destination
+func (c *converter) writeMarkdownFile(src, dest string, content []byte, action string) error {
+	if err := c.checkContained(dest); err != nil {
+		return err
+	}
+	if c.keepExisting(src, dest) {
+		return nil
+	}
+
+	// Ensure destination directory exists
+	if err := c.makeDir(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("failed to create destination directory: %v", err)
+	}
+
+	// Write the modified content
+	_, err := writeFileAtomic(dest, 0644, func(w io.Writer) (int64, error) {
+		n, err := w.Write(content)
+		return int64(n), err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write markdown file: %v", err)
+	}
+
+	c.record(reportEntry{Source: src, Destination: dest, Action: action}, int64(len(content)))
+	return nil
+}
+
+// copyFile copies a file from src to dest
+func (c *converter) copyFile(src, dest string) error {
+	if err := c.checkContained(dest); err != nil {
+		return err
+	}
+	if c.keepExisting(src, dest) {
+		return nil
+	}
+
+	// Open source file
+	srcFile, err := c.vault.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer srcFile.Close()
+
+	// Get file info for permissions
+	info, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %v", err)
+	}
+
+	// Ensure destination directory exists
+	if err := c.makeDir(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("failed to create destination directory: %v", err)
+	}
+
+	// With --link-mode, the file is linked rather than copied when the file system allows it
+	if c.opts.linkMode != linkModeCopy && c.linkFile(src, dest, info) {
+		c.record(reportEntry{Source: src, Destination: dest, Action: actionCopied, Mode: c.opts.linkMode}, 0)
+		return nil
+	}
+
+	// Copy content through a temporary file, keeping the permissions of the source
+	written, err := writeFileAtomic(dest, info.Mode().Perm(), func(w io.Writer) (int64, error) {
+		return io.Copy(w, srcFile)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy file content: %v", err)
+	}
+
+	c.record(reportEntry{Source: src, Destination: dest, Action: actionCopied}, written)
+	return nil
+}
@@ -0,0 +1,134 @@
+package o2q
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOptions returns the options of a run given these command-line flags
+func testOptions(t *testing.T, args ...string) options {
+	t.Helper()
+	var opts options
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, newOptionRegistry(&opts))
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("invalid flags %q: %v", args, err)
+	}
+	return opts
+}
+
+// writeVault writes the files of a test vault, by slash-separated path, and returns its folder
+func writeVault(t *testing.T, files map[string]string) string {
+	t.Helper()
+	vault := t.TempDir()
+	for file, content := range files {
+		p := filepath.Join(vault, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vault
+}
+
+// runTestSync syncs a vault to a Quartz folder with these flags and --self-check, and returns the exit code
+func runTestSync(t *testing.T, vault, quartz string, args ...string) int {
+	t.Helper()
+	opts := testOptions(t, append([]string{"-quiet", "-self-check"}, args...)...)
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatalf("checkOptions(%q) error = %v", args, err)
+	}
+	return runSources(console, opts, config{}, sources, quartz, "")
+}
+
+// readContent returns a file of the content folder of a Quartz folder, by slash-separated path
+func readContent(t *testing.T, quartz, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(quartz, "content", filepath.FromSlash(file)))
+	if err != nil {
+		t.Fatalf("reading the published %s: %v", file, err)
+	}
+	return string(data)
+}
+
+func TestIsInExcalidrawFolder(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"Excalidraw/Diagram.svg", true},
+		{"Projects/Excalidraw/Board.png", true},
+		{"excalidraw/Diagram.svg", true},
+		{`Projects\Excalidraw\Board.png`, filepath.Separator == '\\'},
+		{"Excalidraw", true},
+		{"Excalidraw Drawings/Diagram.svg", false},
+		{"Projects/My Excalidraw.md", false},
+		{"Diagram.excalidraw.md", false},
+	}
+	for _, tt := range tests {
+		if got := isInExcalidrawFolder(tt.path); got != tt.want {
+			t.Errorf("isInExcalidrawFolder(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHasExt(t *testing.T) {
+	tests := []struct {
+		path string
+		ext  string
+		want bool
+	}{
+		{"Note.md", ".md", true},
+		{"Note.MD", ".md", true},
+		{"Notes/Note.Md", ".md", true},
+		{"Flow.excalidraw.SVG", ".svg", true},
+		{"Note.md.bak", ".md", false},
+		{"md", ".md", false},
+		{"Notes.md/image.png", ".md", false},
+	}
+	for _, tt := range tests {
+		if got := hasExt(tt.path, tt.ext); got != tt.want {
+			t.Errorf("hasExt(%q, %q) = %v, want %v", tt.path, tt.ext, got, tt.want)
+		}
+	}
+}
+
+func TestMixedCaseExtensions(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Note.MD":                        "See ![[Flow.excalidraw]] and [[Other]].\n",
+		"Other.Md":                       "Back to [[Note]]: [[Flow.excalidraw]]\n",
+		"Excalidraw/Flow.excalidraw.md":  "drawing",
+		"Excalidraw/Flow.excalidraw.SVG": "<svg/>",
+		"Excalidraw/Flow.PNG":            "png",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+
+	// Notes are transformed and published with a lowercase .md, and SVG exports as .svg, as links point to them
+	for file, want := range map[string]string{
+		"Note.md":  "See ![[Flow.excalidraw.svg|Flow]] and [[Other]].\n",
+		"Other.md": "Back to [[Note]]: [[Flow.excalidraw.svg|Flow]]\n",
+	} {
+		if got := readContent(t, quartz, file); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+	for file, published := range map[string]bool{
+		"Note.MD":                        false,
+		"Excalidraw/Flow.excalidraw.svg": true,
+		"Excalidraw/Flow.excalidraw.SVG": false,
+		"Excalidraw/Flow.PNG":            false,
+		"Excalidraw/Flow.excalidraw.md":  false,
+	} {
+		if _, err := os.Stat(filepath.Join(quartz, "content", filepath.FromSlash(file))); (err == nil) != published {
+			t.Errorf("%s published: %v, want %v", file, err == nil, published)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package o2q
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dataviewLanguages lists the fenced block info strings that are removed by --strip-dataview
+var dataviewLanguages = map[string]bool{
+	"dataview":   true,
+	"dataviewjs": true,
+	"query":      true,
+}
+
+// inlineDataviewRe matches inline Dataview expressions like `= this.file.name`
+var inlineDataviewRe = regexp.MustCompile("`=[^`\n]*`")
+
+// stripDataview removes Dataview and query blocks as well as inline Dataview expressions
+// If placeholder is not empty, each removed block is replaced with that line
+// Regular code blocks are left untouched
+func stripDataview(content []byte, placeholder string) []byte {
+	var out strings.Builder
+	lines := splitLines(content)
+	lastBlank := true
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		marker, info, ok := parseFence(line)
+		if !ok {
+			line = inlineDataviewRe.ReplaceAllString(line, "")
+			out.WriteString(line)
+			lastBlank = isBlankLine(line)
+			continue
+		}
+
+		// Find the end of the fenced block
+		end := i + 1
+		for end < len(lines) && !closesFence(lines[end], marker) {
+			end++
+		}
+
+		if !dataviewLanguages[fenceLanguage(info)] {
+			// Copy regular code blocks as-is
+			for j := i; j <= end && j < len(lines); j++ {
+				out.WriteString(lines[j])
+			}
+			i = end
+			lastBlank = false
+			continue
+		}
+
+		if placeholder != "" {
+			out.WriteString(placeholder + "\n")
+			lastBlank = false
+		}
+		i = end
+
+		// Avoid leaving a run of blank lines where the block used to be
+		if lastBlank {
+			for i+1 < len(lines) && isBlankLine(lines[i+1]) {
+				i++
+			}
+		}
+	}
+
+	return []byte(out.String())
+}
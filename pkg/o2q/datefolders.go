@@ -0,0 +1,216 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDateName is the file name format of daily notes, the default of the Daily notes core plugin
+const defaultDateName = "YYYY-MM-DD"
+
+// dateTokens are the tokens of --date-folders formats: the four-digit year, two-digit month and two-digit day
+// They are spelled out rather than taken from Go's reference time, as users write them in the config file
+var dateTokens = []string{"YYYY", "MM", "DD"}
+
+// dateFolder publishes the date-named notes of a vault folder in nested folders built from their date
+//
+//	Daily:YYYY/MM → Daily/2024-03-17.md is published as Daily/2024/03/2024-03-17.md
+type dateFolder struct {
+	folder string         // Vault-relative folder, with forward slashes
+	layout string         // Folders the notes go in, such as YYYY/MM
+	name   string         // File name format of the notes, without .md, such as YYYY-MM-DD
+	nameRe *regexp.Regexp // Matches a file name in the name format, capturing the tokens in the order of order
+	order  []string       // Tokens of the name format, in the order they appear
+}
+
+// splitDateFormat splits a format into its tokens and the literal text between them
+//   - YYYY/MM → YYYY, /, MM
+//   - DD.MM.YYYY → DD, ., MM, ., YYYY
+func splitDateFormat(format string) []string {
+	var parts []string
+	literal := ""
+	for i := 0; i < len(format); {
+		token := ""
+		for _, t := range dateTokens {
+			if strings.HasPrefix(format[i:], t) {
+				token = t
+				break
+			}
+		}
+		if token == "" {
+			literal += format[i : i+1]
+			i++
+			continue
+		}
+		if literal != "" {
+			parts = append(parts, literal)
+			literal = ""
+		}
+		parts = append(parts, token)
+		i += len(token)
+	}
+	if literal != "" {
+		parts = append(parts, literal)
+	}
+	return parts
+}
+
+// isDateToken checks if a part of a split format is a token
+func isDateToken(part string) bool {
+	for _, t := range dateTokens {
+		if part == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDateFolders reads --date-folders entries of the form "folder:layout", or "folder:layout:name format"
+// when the notes are not named YYYY-MM-DD, such as "Journal:YYYY:DD.MM.YYYY"
+// The name format must hold YYYY, MM and DD once each; the layout may use any of them
+func parseDateFolders(entries []string) ([]dateFolder, error) {
+	var folders []dateFolder
+	for _, entry := range entries {
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%q is not of the form folder:layout or folder:layout:name format", entry)
+		}
+		d := dateFolder{
+			folder: path.Clean(filepath.ToSlash(strings.TrimSpace(fields[0]))),
+			layout: strings.Trim(strings.TrimSpace(fields[1]), "/"),
+			name:   defaultDateName,
+		}
+		if len(fields) == 3 {
+			d.name = strings.TrimSpace(fields[2])
+		}
+		if d.folder == "." || !filepath.IsLocal(d.folder) {
+			return nil, fmt.Errorf("%q: the folder must be a relative path inside the vault", entry)
+		}
+
+		hasToken := false
+		for _, part := range splitDateFormat(d.layout) {
+			hasToken = hasToken || isDateToken(part)
+		}
+		if !hasToken {
+			return nil, fmt.Errorf("%q: the layout %q uses none of %s", entry, d.layout, strings.Join(dateTokens, ", "))
+		}
+		for _, segment := range strings.Split(d.layout, "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return nil, fmt.Errorf("%q: the layout %q must be folder names separated by /", entry, d.layout)
+			}
+		}
+
+		var pattern strings.Builder
+		pattern.WriteString("^")
+		seen := make(map[string]bool)
+		for _, part := range splitDateFormat(d.name) {
+			if !isDateToken(part) {
+				if strings.Contains(part, "/") {
+					return nil, fmt.Errorf("%q: the name format %q cannot hold a /", entry, d.name)
+				}
+				pattern.WriteString(regexp.QuoteMeta(part))
+				continue
+			}
+			if seen[part] {
+				return nil, fmt.Errorf("%q: the name format %q holds %s twice", entry, d.name, part)
+			}
+			seen[part] = true
+			d.order = append(d.order, part)
+			pattern.WriteString(`(\d{` + strconv.Itoa(len(part)) + `})`)
+		}
+		for _, t := range dateTokens {
+			if !seen[t] {
+				return nil, fmt.Errorf("%q: the name format %q must hold %s", entry, d.name, strings.Join(dateTokens, ", "))
+			}
+		}
+		pattern.WriteString("$")
+		d.nameRe = regexp.MustCompile(pattern.String())
+		folders = append(folders, d)
+	}
+	return folders, nil
+}
+
+// date reads the date of a note name, without .md, in the name format; ok is false for other names and invalid dates
+func (d dateFolder) date(name string) (values map[string]string, ok bool) {
+	m := d.nameRe.FindStringSubmatch(name)
+	if m == nil {
+		return nil, false
+	}
+	values = make(map[string]string)
+	for i, token := range d.order {
+		values[token] = m[i+1]
+	}
+	year, _ := strconv.Atoi(values["YYYY"])
+	month, _ := strconv.Atoi(values["MM"])
+	day, _ := strconv.Atoi(values["DD"])
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return nil, false
+	}
+	return values, true
+}
+
+// folderOf returns the folders, relative to the date folder, a note of the given date is published in
+func (d dateFolder) folderOf(values map[string]string) string {
+	var b strings.Builder
+	for _, part := range splitDateFormat(d.layout) {
+		if isDateToken(part) {
+			b.WriteString(values[part])
+		} else {
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// planDateFolders records the nested destination of every date-named note of a --date-folders folder,
+// so links to it, such as [[2024-03-17]], are rewritten
+// Only the notes directly in the folder are moved; notes with other names and those in its subfolders stay where they are
+func (c *converter) planDateFolders() error {
+	if len(c.dateFolders) == 0 {
+		return nil
+	}
+	if c.renames == nil {
+		c.renames = make(map[string]string)
+	}
+	c.dated = make(map[string]bool)
+	counts := make(map[string]int)
+	err := c.walkEligible(func(relPath string) error {
+		file := filepath.ToSlash(relPath)
+		if !hasExt(file, ".md") || isInExcalidrawFolder(file) {
+			return nil
+		}
+		dir := path.Dir(c.normalizeName(file))
+		for _, d := range c.dateFolders {
+			if dir != c.normalizeName(d.folder) {
+				continue
+			}
+			values, ok := d.date(strings.TrimSuffix(path.Base(file), path.Ext(file)))
+			if !ok {
+				return nil
+			}
+			dest := filepath.ToSlash(c.destRel(relPath))
+			c.renames[file] = path.Join(path.Dir(dest), d.folderOf(values), path.Base(dest))
+			c.dated[file] = true
+			counts[d.folder]++
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, d := range c.dateFolders {
+		if counts[d.folder] == 0 {
+			c.console.warnf("--date-folders: no note of %s is named %s", d.folder, d.name)
+			continue
+		}
+		c.console.infof("Publishing %d notes of %s in %s folders", counts[d.folder], d.folder, d.layout)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package o2q
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSplitDateFormat(t *testing.T) {
+	tests := map[string]string{
+		"YYYY":       "YYYY",
+		"YYYY/MM":    "YYYY|/|MM",
+		"YYYY/MM/DD": "YYYY|/|MM|/|DD",
+		"DD.MM.YYYY": "DD|.|MM|.|YYYY",
+		"Week of DD": "Week of |DD",
+		"YYYYMMDD":   "YYYY|MM|DD",
+		"Y-M-D":      "Y-M-D",
+	}
+	for format, want := range tests {
+		if got := strings.Join(splitDateFormat(format), "|"); got != want {
+			t.Errorf("splitDateFormat(%q) = %s, want %s", format, got, want)
+		}
+	}
+}
+
+func TestParseDateFolders(t *testing.T) {
+	folders, err := parseDateFolders([]string{"Daily:YYYY/MM", " Journal/ : /YYYY/ : DD.MM.YYYY"})
+	if err != nil {
+		t.Fatalf("parseDateFolders() error = %v", err)
+	}
+	if d := folders[0]; d.folder != "Daily" || d.layout != "YYYY/MM" || d.name != defaultDateName {
+		t.Errorf("parseDateFolders()[0] = %s, %s, %s", d.folder, d.layout, d.name)
+	}
+	if d := folders[1]; d.folder != "Journal" || d.layout != "YYYY" || d.name != "DD.MM.YYYY" || strings.Join(d.order, ",") != "DD,MM,YYYY" {
+		t.Errorf("parseDateFolders()[1] = %s, %s, %s, %s", d.folder, d.layout, d.name, d.order)
+	}
+
+	for _, entry := range []string{
+		"Daily",                    // No layout
+		"Daily:YYYY:YYYY-MM-DD:x",  // Too many fields
+		".:YYYY",                   // Vault root
+		"../Daily:YYYY",            // Outside the vault
+		"Daily:archive",            // No token in the layout
+		"Daily:YYYY//MM",           // Empty folder name
+		"Daily:YYYY/../MM",         // Folder leading out
+		"Daily:YYYY:YYYY-MM",       // No DD in the name
+		"Daily:YYYY:YYYY-MM-DD-DD", // DD twice
+		"Daily:YYYY:YYYY/MM/DD",    // Folders in the name
+	} {
+		if _, err := parseDateFolders([]string{entry}); err == nil {
+			t.Errorf("parseDateFolders(%q) error = nil", entry)
+		}
+	}
+}
+
+func TestDateFolderDate(t *testing.T) {
+	folders, err := parseDateFolders([]string{"Daily:YYYY", "Journal:YYYY:DD.MM.YYYY", "Log:YYYY:YYYYMMDD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily, journal, log := folders[0], folders[1], folders[2]
+	tests := []struct {
+		d    dateFolder
+		name string
+		want string // YYYY-MM-DD read from the name, or "" when it is not a date
+	}{
+		{daily, "2024-03-17", "2024-03-17"},
+		{daily, "2024-02-29", "2024-02-29"}, // Leap year
+		{journal, "17.03.2024", "2024-03-17"},
+		{log, "20240317", "2024-03-17"},
+		// Names without a date keep their place
+		{daily, "Notes", ""},
+		{daily, "2024-03-17 Meeting", ""},
+		{daily, "2024-3-17", ""},
+		{daily, "24-03-17", ""},
+		{journal, "2024-03-17", ""},
+		// So do dates that do not exist
+		{daily, "2024-02-30", ""},
+		{daily, "2023-02-29", ""},
+		{daily, "2024-13-01", ""},
+		{daily, "2024-00-10", ""},
+		{daily, "2024-04-31", ""},
+		{journal, "00.01.2024", ""},
+	}
+	for _, tt := range tests {
+		values, ok := tt.d.date(tt.name)
+		got := ""
+		if ok {
+			got = values["YYYY"] + "-" + values["MM"] + "-" + values["DD"]
+		}
+		if got != tt.want {
+			t.Errorf("date(%q) in the %s format = %q, want %q", tt.name, tt.d.name, got, tt.want)
+		}
+	}
+}
+
+func TestDateFolderLayout(t *testing.T) {
+	values := map[string]string{"YYYY": "2024", "MM": "03", "DD": "07"}
+	for layout, want := range map[string]string{
+		"YYYY":       "2024",
+		"MM":         "03",
+		"DD":         "07",
+		"YYYY/MM":    "2024/03",
+		"YYYY/MM/DD": "2024/03/07",
+		"YYYY-MM":    "2024-03",
+		"Year YYYY":  "Year 2024",
+		"YYYY/DD":    "2024/07",
+	} {
+		folders, err := parseDateFolders([]string{"Daily:" + layout})
+		if err != nil {
+			t.Fatalf("parseDateFolders(%q) error = %v", layout, err)
+		}
+		if got := folders[0].folderOf(values); got != want {
+			t.Errorf("folderOf() with layout %q = %q, want %q", layout, got, want)
+		}
+	}
+}
+
+func TestDateFolders(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Daily/2024-03-17.md":     "Sunday\n",
+		"Daily/2024-02-30.md":     "Not a date\n",
+		"Daily/Notes.md":          "Notes\n",
+		"Daily/Old/2023-01-05.md": "In a subfolder\n",
+		"Home.md":                 "See [[2024-03-17]] and [[2024-02-30]].\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-date-folders", "Daily:YYYY/MM"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	want := "Daily/2024-02-30.md, Daily/2024/03/2024-03-17.md, Daily/Notes.md, Daily/Old/2023-01-05.md, Home.md"
+	if got := contentFiles(t, quartz); got != want {
+		t.Errorf("published %s, want %s", got, want)
+	}
+	if got := readContent(t, quartz, "Home.md"); !strings.Contains(got, "[[Daily/2024/03/2024-03-17|2024-03-17]]") || !strings.Contains(got, "[[2024-02-30]]") {
+		t.Errorf("Home.md = %q, want the link to the moved note rewritten only", got)
+	}
+}
+
+func TestDateFoldersCollision(t *testing.T) {
+	// A note already in the folder a dated note moves to is not overwritten: the second one is an error
+	vault := writeVault(t, map[string]string{
+		"Daily/2024-03-17.md":         "Flat\n",
+		"Daily/2024/03/2024-03-17.md": "Nested\n",
+	})
+	opts := testOptions(t, "-date-folders", "Daily:YYYY/MM")
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var messages bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &messages}
+	quartz := t.TempDir()
+	if code := runSources(log, opts, config{}, sources, quartz, ""); code != exitFileErrors {
+		t.Fatalf("run exited with %d, want %d", code, exitFileErrors)
+	}
+	if !strings.Contains(messages.String(), "is published to the same path as") || !strings.Contains(messages.String(), "by --date-folders") {
+		t.Errorf("messages do not report the collision:\n%s", messages.String())
+	}
+	if got := contentFiles(t, quartz); got != "Daily/2024/03/2024-03-17.md" {
+		t.Errorf("published %s, want the single path", got)
+	}
+}
@@ -0,0 +1,137 @@
+package o2q
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// minDescriptionWords is the number of words below which a paragraph is too short to describe a note
+const minDescriptionWords = 4
+
+// Patterns used to find and flatten the first paragraph of a note for --add-description
+var (
+	descHeadingRe   = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]|$)`)
+	descCalloutRe   = regexp.MustCompile(`^(?:[ \t]*>[ \t]*)+\[![^\]]*\]`)
+	descQuoteRe     = regexp.MustCompile(`^(?:[ \t]*>[ \t]?)+`)
+	descListRe      = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])[ \t]+(?:\[[ xX]\][ \t]+)?`)
+	descEmbedRe     = regexp.MustCompile(`!\[\[[^\]]*\]\]|!\[[^\]]*\]\([^)]*\)`)
+	descFootnoteRe  = regexp.MustCompile(`\[\^[^\]]+\]`)
+	descCommentRe   = regexp.MustCompile(`%%.*?%%|<!--.*?-->`)
+	descBlockTextRe = regexp.MustCompile(`^ {0,3}(?:\||<|%%|\$\$|\^[A-Za-z0-9-]+[ \t]*$)`)
+)
+
+// firstParagraph returns the first paragraph of body text of a note, as plain text on one line
+// Headings, code blocks, callout titles, embeds and images are skipped; so are tables, HTML and math blocks,
+// which read poorly as a summary
+func firstParagraph(body []byte) string {
+	var words []string
+	fence := ""
+	for _, line := range splitLines(body) {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			if len(words) > 0 {
+				break
+			}
+			fence = marker
+			continue
+		}
+
+		text := strings.TrimRight(line, "\r\n")
+		if descHeadingRe.MatchString(text) || mdRuleRe.MatchString(text) || descCalloutRe.MatchString(text) {
+			if len(words) > 0 {
+				break
+			}
+			continue
+		}
+		text = descQuoteRe.ReplaceAllString(text, "")
+		text = descListRe.ReplaceAllString(text, "")
+		if descBlockTextRe.MatchString(text) {
+			if len(words) > 0 {
+				break
+			}
+			continue
+		}
+		text = descEmbedRe.ReplaceAllString(text, "")
+		text = descCommentRe.ReplaceAllString(text, "")
+		text = descFootnoteRe.ReplaceAllString(text, "")
+		text = plainHeading(text)
+		if text == "" {
+			// A blank line ends the paragraph; a line holding only embeds is skipped
+			if len(words) > 0 && isBlankLine(line) {
+				break
+			}
+			continue
+		}
+		words = append(words, strings.Fields(text)...)
+	}
+	return strings.Join(words, " ")
+}
+
+// truncateDescription shortens a description to at most max characters, cutting at a word boundary
+// and ending with an ellipsis, which counts in the length
+func truncateDescription(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:max-1])
+	if i := strings.LastIndex(cut, " "); i > 0 && runes[max-1] != ' ' {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-–—") + "…"
+}
+
+// addDescription gives a note without a description key one, from the first paragraph of its body, for RSS and link previews
+// Notes that have a description key are left untouched, even if it is empty; notes whose first paragraph is
+// shorter than a sentence, or that only hold embeds, get none
+func (c *converter) addDescription(src string, content []byte) []byte {
+	if !c.opts.addDescription {
+		return content
+	}
+	values, err := parseFrontmatter(content)
+	if err != nil {
+		c.frontmatterFallback(src, err, "--add-description")
+		return content
+	}
+	if _, ok := values["description"]; ok {
+		return content
+	}
+
+	frontmatter, body, hasFrontmatter := splitFrontmatter(content)
+	text := firstParagraph(c.describedBody(src, body))
+	if len(strings.Fields(text)) < minDescriptionWords {
+		return content
+	}
+	field := "description: " + yamlString(truncateDescription(text, c.opts.descriptionLength)) + "\n"
+	if !hasFrontmatter {
+		return []byte("---\n" + field + "---\n" + string(body))
+	}
+	if _, _, ok := frontmatterKeys(frontmatter); !ok {
+		c.console.warnf("%s: frontmatter is not a block of keys, no description added", src)
+		return content
+	}
+	// The description goes last, and the rest of the frontmatter is kept as written
+	fm := string(frontmatter)
+	if fm != "" && !strings.HasSuffix(fm, "\n") {
+		fm += "\n"
+	}
+	return replaceFrontmatter(content, fm+field)
+}
+
+// describedBody removes from the body of a note what its body steps strip later, before the first paragraph is read,
+// so a description never quotes the Dataview expressions of --strip-dataview or the comments of --strip-html-comments
+func (c *converter) describedBody(src string, body []byte) []byte {
+	if c.opts.stripDataview && c.stepEnabled(src, "strip-dataview") {
+		body = stripDataview(body, "")
+	}
+	if c.opts.stripHTMLComments && c.stepEnabled(src, "strip-comments") {
+		body, _ = stripHTMLComments(body, false)
+	}
+	return body
+}
@@ -0,0 +1,41 @@
+package o2q
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirstParagraph(t *testing.T) {
+	tests := map[string]string{
+		"# Title\n\nFirst words of the note.\nSecond line.\n\nNext paragraph.\n": "First words of the note. Second line.",
+		"![[banner.png]]\n> [!note] Title\n> Quoted **text** here.\n":            "Quoted text here.",
+		"```js\ncode();\n```\n- [ ] A task to do\n":                              "A task to do",
+		"| a | b |\n\n<div>html</div>\n\nText <!-- hidden --> shown.\n":          "Text shown.",
+	}
+	for body, want := range tests {
+		if got := firstParagraph([]byte(body)); got != want {
+			t.Errorf("firstParagraph(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestDescriptionAfterStrippedText(t *testing.T) {
+	// The description is read from the body as published, without what --strip-dataview and --strip-html-comments remove
+	vault := writeVault(t, map[string]string{
+		"Dataview.md": "Written by `= this.file.author` for the team, on every release.\n",
+		"Comment.md":  "<!--\nTODO: rewrite this whole intro\nbefore publishing it\n-->\n\nA guide to the release process of the team.\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-add-description", "-strip-dataview", "-strip-html-comments"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	tests := map[string]string{
+		"Dataview.md": "description: Written by for the team, on every release.",
+		"Comment.md":  "description: A guide to the release process of the team.",
+	}
+	for file, want := range tests {
+		if got := readContent(t, quartz, file); !strings.Contains(got, want+"\n") {
+			t.Errorf("%s = %q, want %s", file, got, want)
+		}
+	}
+}
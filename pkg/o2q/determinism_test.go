@@ -0,0 +1,87 @@
+package o2q
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// elapsedLineRe matches the line of the summary giving the time a run took
+var elapsedLineRe = regexp.MustCompile(`(?m)^  Elapsed: .*\n`)
+
+// orderedVault writes the files of a test vault one by one in this order, and returns its folder
+func orderedVault(t *testing.T, files [][2]string) string {
+	t.Helper()
+	vault := t.TempDir()
+	for _, file := range files {
+		p := filepath.Join(vault, filepath.FromSlash(file[0]))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(file[1]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vault
+}
+
+// The plan, the messages and the report of a run do not depend on the order the files of the vault were created in
+func TestDeterministicRun(t *testing.T) {
+	files := [][2]string{
+		{"index.md", "See [[Note]], [[Zeta]], ![[big.png]] and [[Missing]].\n"},
+		{"A/Note.md", "Note in A\n"},
+		{"B/Note.md", "Note in B\n"},
+		{"Zeta.md", "Zeta\n"},
+		{menageNFD + ".md", "Decomposed\n"},
+		{"Mf.md", "Between\n"},
+		{"assets/big.png", "0123456789"},
+		{"assets/small.png", "png"},
+		{"Drafts/Idea.md", "Idea\n"},
+	}
+	reversed := make([][2]string, len(files))
+	for i, file := range files {
+		reversed[len(files)-1-i] = file
+	}
+
+	var runs [2]struct{ messages, report string }
+	for i, order := range [][][2]string{files, reversed} {
+		vault := orderedVault(t, order)
+		reportPath := filepath.Join(t.TempDir(), "report.json")
+		opts := testOptions(t, "-exclude", "Drafts/", "-max-file-size", "5", "-skip-space-check", "-report-json", reportPath)
+		sources := []vaultSource{{folder: vault, sub: "."}}
+		if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+			t.Fatal(err)
+		}
+		var messages bytes.Buffer
+		log := &consoleLogger{verbosity: verbosityVerbose, out: &messages, err: &messages}
+		runSources(log, opts, config{}, sources, t.TempDir(), "")
+
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the base folders and the times differ between runs
+		var report map[string]interface{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []string{"base", "started_at", "elapsed_seconds"} {
+			delete(report, key)
+		}
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		// Nor does the time in the summary
+		runs[i].messages, runs[i].report = elapsedLineRe.ReplaceAllString(messages.String(), ""), string(data)
+	}
+
+	if runs[0].messages != runs[1].messages {
+		t.Errorf("messages differ with the creation order:\n%s\nand\n%s", runs[0].messages, runs[1].messages)
+	}
+	if runs[0].report != runs[1].report {
+		t.Errorf("reports differ with the creation order:\n%s\nand\n%s", runs[0].report, runs[1].report)
+	}
+}
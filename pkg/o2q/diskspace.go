@@ -0,0 +1,95 @@
+package o2q
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// freeSpaceMargin is left free on the destination, for other programs and the Quartz build itself
+const freeSpaceMargin = 64 << 20
+
+// freeSpace returns the bytes available on the file system holding a folder; a variable so tests can fake it
+var freeSpace = platformFreeSpace
+
+// estimateWrites estimates how much the run adds to the destination: the size of every published file,
+// less the size of the files it replaces, plus the largest of those, which stays on disk until its new version is complete
+// Transformed notes are counted 10% larger than their source, for rewritten links
+func (c *converter) estimateWrites() (int64, error) {
+	var total, largest int64
+	err := c.walkEligible(func(relPath string) error {
+		if c.unchangedSinceGit(relPath) {
+			return nil
+		}
+		info, err := c.vault.Stat(filepath.Join(c.obsidianFolder, relPath))
+		if err != nil {
+			return nil
+		}
+		size := info.Size()
+		if hasExt(relPath, ".md") {
+			size += size / 10
+		} else if c.opts.linkMode != linkModeCopy {
+			// Linked files take no room, and those falling back to a copy are left to the disk full handling
+			size = 0
+		}
+		total += size
+		if existing, err := os.Stat(filepath.Join(c.contentFolder, c.destRel(relPath))); err == nil && !existing.IsDir() {
+			total -= existing.Size()
+			largest = max(largest, existing.Size())
+		}
+		return nil
+	})
+	return max(total, 0) + largest, err
+}
+
+// checkFreeSpace fails before anything is written if the destination does not have room for the run
+func (c *converter) checkFreeSpace() error {
+	if c.opts.skipSpaceCheck {
+		return nil
+	}
+	needed, err := c.estimateWrites()
+	if err != nil {
+		return err
+	}
+	// A dry run does not create the content folder, so the space is read where it would be created
+	folder := c.contentFolder
+	for c.opts.dryRun && filepath.Dir(folder) != folder {
+		if _, err := os.Stat(folder); err == nil {
+			break
+		}
+		folder = filepath.Dir(folder)
+	}
+	available, err := freeSpace(folder)
+	if err != nil {
+		c.console.warnf("could not read the free space of %s, not checked: %v", c.contentFolder, err)
+		return nil
+	}
+	c.console.progressf("Estimated %s to write, %s free on the destination", formatSize(needed), formatSize(int64(available)))
+	if uint64(needed)+freeSpaceMargin > available {
+		return refusedf("not enough free space on the destination: about %s to write, %s free, and %s must stay free; "+
+			"free some space, or use --skip-space-check if the estimate is wrong",
+			formatSize(needed), formatSize(int64(available)), formatSize(freeSpaceMargin))
+	}
+	return nil
+}
+
+// isDiskFull checks if an error comes from a full file system
+// Errors are wrapped as text, so their message is compared too
+func isDiskFull(err error) bool {
+	for _, full := range diskFullErrors {
+		if errors.Is(err, full) || strings.Contains(err.Error(), full.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportDiskFull explains where a run stopped by a full destination left the content folder
+func (c *converter) reportDiskFull(err error) {
+	written := c.report.Transformed + c.report.Generated + c.report.Copied
+	c.console.errorf("the destination is full, the conversion stopped after writing %d files: %v", written, err)
+	c.console.detailf("No file is half-written: each file is written to a temporary file and renamed into place once complete")
+	c.console.detailf("The content folder holds the files written so far, listed by --report-json, and the other files from before the run")
+	c.console.detailf("Free some space and run again to publish the rest")
+}
@@ -0,0 +1,135 @@
+package o2q
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFreeSpace makes the destination look like it has this much room, for the rest of the test
+func fakeFreeSpace(t *testing.T, available uint64, err error) {
+	old := freeSpace
+	freeSpace = func(string) (uint64, error) {
+		return available, err
+	}
+	t.Cleanup(func() {
+		freeSpace = old
+	})
+}
+
+func TestEstimateWrites(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Note.md":   strings.Repeat("a", 1000),
+		"image.png": strings.Repeat("b", 500),
+	})
+	// The note grows by 10%, and the image replaces one of 300 bytes, which stays on disk until then: 1100+500-300+300
+	const estimate = 1600
+	for available, want := range map[uint64]int{freeSpaceMargin + estimate: exitSuccess, freeSpaceMargin + estimate - 1: exitRefused} {
+		quartz := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(quartz, "content"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(quartz, "content", "image.png"), make([]byte, 300), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fakeFreeSpace(t, available, nil)
+		if code := runTestSync(t, vault, quartz); code != want {
+			t.Errorf("with %d bytes free, run exited with %d, want %d", available, code, want)
+		}
+	}
+}
+
+func TestFreeSpaceCheck(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Note\n"})
+	tests := []struct {
+		name      string
+		available uint64
+		err       error
+		args      []string
+		want      int
+	}{
+		{"enough room", freeSpaceMargin + 1<<20, nil, nil, exitSuccess},
+		{"within the margin", freeSpaceMargin, nil, nil, exitRefused},
+		{"check skipped", 0, nil, []string{"-skip-space-check"}, exitSuccess},
+		{"unknown free space", 0, errors.New("not supported"), nil, exitSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeFreeSpace(t, tt.available, tt.err)
+			quartz := t.TempDir()
+			if code := runTestSync(t, vault, quartz, tt.args...); code != tt.want {
+				t.Fatalf("run exited with %d, want %d", code, tt.want)
+			}
+			// A refused run writes nothing
+			_, err := os.Stat(filepath.Join(quartz, "content", "Note.md"))
+			if (err == nil) != (tt.want == exitSuccess) {
+				t.Errorf("note published: %v", err == nil)
+			}
+		})
+	}
+}
+
+func TestDiskFull(t *testing.T) {
+	vault := writeVault(t, map[string]string{"A.md": "A\n", "B.md": "B\n", "C.md": "C\n"})
+	fakeFreeSpace(t, 1<<40, nil)
+	full := &os.PathError{Op: "sync", Path: "B.md", Err: diskFullErrors[0]}
+	synced := 0
+	old := syncFile
+	syncFile = func(f *os.File) error {
+		if synced++; synced == 2 {
+			return full
+		}
+		return old(f)
+	}
+	t.Cleanup(func() {
+		syncFile = old
+	})
+
+	opts := testOptions(t)
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var messages bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &messages}
+	quartz := t.TempDir()
+	if code := runSources(log, opts, config{}, sources, quartz, ""); code != exitFailure {
+		t.Errorf("run exited with %d, want %d", code, exitFailure)
+	}
+
+	// The run stops at the first full write, leaving the files written before it and no temporary file
+	if !strings.Contains(messages.String(), "the destination is full, the conversion stopped after writing 1 files") {
+		t.Errorf("messages do not report the full destination:\n%s", messages.String())
+	}
+	entries, err := os.ReadDir(filepath.Join(quartz, "content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := strings.Join(names, ","); got != "A.md" {
+		t.Errorf("content folder holds %s, want only A.md", got)
+	}
+}
+
+func TestIsDiskFull(t *testing.T) {
+	full := &os.PathError{Op: "write", Path: "note.md", Err: diskFullErrors[0]}
+	for err, want := range map[error]bool{
+		full:                              true,
+		fmt.Errorf("copying: %w", full):   true,
+		fmt.Errorf("copying: %v", full):   true,
+		errors.New("permission denied"):   false,
+		fmt.Errorf("copying: %w", io.EOF): false,
+	} {
+		if got := isDiskFull(err); got != want {
+			t.Errorf("isDiskFull(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
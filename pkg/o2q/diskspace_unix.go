@@ -0,0 +1,17 @@
+//go:build !windows
+
+package o2q
+
+import "syscall"
+
+// diskFullErrors are the errors a write fails with when the file system is full
+var diskFullErrors = []error{syscall.ENOSPC}
+
+// platformFreeSpace returns the bytes available to the current user on the file system holding folder
+func platformFreeSpace(folder string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(folder, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
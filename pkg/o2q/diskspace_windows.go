@@ -0,0 +1,24 @@
+package o2q
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// diskFullErrors are the errors a write fails with when the disk is full: ERROR_HANDLE_DISK_FULL and ERROR_DISK_FULL
+var diskFullErrors = []error{syscall.Errno(39), syscall.Errno(112)}
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// platformFreeSpace returns the bytes available to the current user on the volume holding folder
+func platformFreeSpace(folder string) (uint64, error) {
+	name, err := syscall.UTF16PtrFromString(folder)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if ok, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&available)), 0, 0); ok == 0 {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,459 @@
+package o2q
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// drawingJSONRe finds the scene of a drawing in an .excalidraw.md file, in a json or compressed-json code block
+var drawingJSONRe = regexp.MustCompile("(?s)```(json|compressed-json)[ \\t]*\\r?\\n(.*?)\\r?\\n```")
+
+// drawingPadding is the margin around the elements of a rendered drawing, as in the exports of Excalidraw
+const drawingPadding = 10
+
+// drawingFonts are the font stacks of the font families of Excalidraw text, by their number in the scene
+var drawingFonts = map[int]string{
+	1: "Virgil, Segoe UI Emoji",
+	2: "Helvetica, Segoe UI Emoji",
+	3: "Cascadia, Segoe UI Emoji",
+	5: "Excalifont, Xiaolai, Segoe UI Emoji",
+	6: "Nunito, Segoe UI Emoji",
+	7: "Lilita One, Segoe UI Emoji",
+	8: "Comic Shanns, Segoe UI Emoji",
+}
+
+// drawingScene is the part of an Excalidraw scene that is rendered
+type drawingScene struct {
+	Elements []drawingElement `json:"elements"`
+	AppState struct {
+		ViewBackgroundColor string `json:"viewBackgroundColor"`
+	} `json:"appState"`
+	Files map[string]struct {
+		DataURL string `json:"dataURL"`
+	} `json:"files"`
+}
+
+// drawingElement is a shape, line, text or image of a drawing, with coordinates in scene units
+type drawingElement struct {
+	Type            string       `json:"type"`
+	X               float64      `json:"x"`
+	Y               float64      `json:"y"`
+	Width           float64      `json:"width"`
+	Height          float64      `json:"height"`
+	Angle           float64      `json:"angle"` // Radians, around the center of the element
+	StrokeColor     string       `json:"strokeColor"`
+	BackgroundColor string       `json:"backgroundColor"`
+	StrokeWidth     float64      `json:"strokeWidth"`
+	StrokeStyle     string       `json:"strokeStyle"` // solid, dashed or dotted
+	Opacity         *float64     `json:"opacity"`     // 0 to 100; 100 when missing
+	Roundness       *struct{}    `json:"roundness"`   // Rounded corners when set
+	Points          [][2]float64 `json:"points"`      // Points of a line, arrow or freedraw, relative to X and Y
+	StartArrowhead  *string      `json:"startArrowhead"`
+	EndArrowhead    *string      `json:"endArrowhead"`
+	Text            string       `json:"text"`
+	FontSize        float64      `json:"fontSize"`
+	FontFamily      int          `json:"fontFamily"`
+	TextAlign       string       `json:"textAlign"`
+	LineHeight      float64      `json:"lineHeight"` // Multiple of the font size; 1.25 when missing
+	FileID          string       `json:"fileId"`
+	IsDeleted       bool         `json:"isDeleted"`
+}
+
+// isDrawingFile checks if a vault file is a drawing of the Excalidraw plugin, a note holding the scene as JSON
+func isDrawingFile(relPath string) bool {
+	return strings.HasSuffix(strings.ToLower(relPath), ".excalidraw.md")
+}
+
+// rendersDrawing checks if a drawing is rendered to SVG by --render-drawings, as it has no SVG export next to it
+func (c *converter) rendersDrawing(src string) bool {
+	if !c.opts.renderDrawings || !isDrawingFile(src) {
+		return false
+	}
+	base := strings.TrimSuffix(src, filepath.Ext(src))
+	for _, ext := range []string{".svg", ".SVG", ".light.svg", ".dark.svg"} {
+		if _, err := c.vault.Stat(base + ext); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// renderDrawing publishes a drawing as the SVG its links are rewritten to, rendered from the scene it holds
+// Shapes, lines, arrows, text and pasted images are drawn with plain strokes rather than the hand-drawn look of
+// Excalidraw; images linked from the vault and embedded web pages are left out, which the report notes
+func (c *converter) renderDrawing(src, dest string) error {
+	content, err := c.vault.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read drawing: %v", err)
+	}
+	scene, err := parseDrawing(content)
+	if err != nil {
+		return fmt.Errorf("failed to render drawing: %v", err)
+	}
+	svg, skipped := scene.svg()
+	if skipped > 0 {
+		c.addReportNote(src, fmt.Sprintf("%d elements not rendered", skipped))
+	}
+	return c.writeMarkdownFile(src, dest, svg, actionGenerated)
+}
+
+// parseDrawing reads the scene of an .excalidraw.md file, stored as JSON or compressed with lz-string
+func parseDrawing(content []byte) (*drawingScene, error) {
+	m := drawingJSONRe.FindSubmatch(content)
+	if m == nil {
+		return nil, errors.New("no json or compressed-json block holding the drawing")
+	}
+	data := m[2]
+	if string(m[1]) == "compressed-json" {
+		// The plugin breaks the compressed scene into lines
+		text, err := decompressLZBase64(strings.Join(strings.Fields(string(data)), ""))
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(text)
+	}
+	var scene drawingScene
+	if err := json.Unmarshal(data, &scene); err != nil {
+		return nil, fmt.Errorf("invalid drawing: %v", err)
+	}
+	return &scene, nil
+}
+
+// svg renders the scene, returning the SVG and the number of elements left out
+func (s *drawingScene) svg() ([]byte, int) {
+	var elements []drawingElement
+	for _, e := range s.Elements {
+		if !e.IsDeleted {
+			elements = append(elements, e)
+		}
+	}
+
+	// The view box holds every element with a margin, like the exports of Excalidraw
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, e := range elements {
+		x1, y1, x2, y2 := e.bounds()
+		minX, minY = math.Min(minX, x1), math.Min(minY, y1)
+		maxX, maxY = math.Max(maxX, x2), math.Max(maxY, y2)
+	}
+	if len(elements) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	minX, minY = minX-drawingPadding, minY-drawingPadding
+	width, height := maxX+drawingPadding-minX, maxY+drawingPadding-minY
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s %s %s %s" width="%s" height="%s">`+"\n",
+		svgNum(minX), svgNum(minY), svgNum(width), svgNum(height), svgNum(width), svgNum(height))
+	if bg := s.AppState.ViewBackgroundColor; bg != "" && bg != "transparent" {
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+			svgNum(minX), svgNum(minY), svgNum(width), svgNum(height), html.EscapeString(bg))
+	}
+	skipped := 0
+	for _, e := range elements {
+		shape := s.renderElement(e)
+		if shape == "" {
+			skipped++
+			continue
+		}
+		b.WriteString(shape)
+		b.WriteString("\n")
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String()), skipped
+}
+
+// bounds returns the box holding an element, leaving its rotation aside
+func (e drawingElement) bounds() (x1, y1, x2, y2 float64) {
+	if len(e.Points) == 0 {
+		return e.X, e.Y, e.X + e.Width, e.Y + e.Height
+	}
+	x1, y1, x2, y2 = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range e.Points {
+		x1, y1 = math.Min(x1, e.X+p[0]), math.Min(y1, e.Y+p[1])
+		x2, y2 = math.Max(x2, e.X+p[0]), math.Max(y2, e.Y+p[1])
+	}
+	return x1, y1, x2, y2
+}
+
+// renderElement returns the SVG of an element, or "" for the kinds that are not rendered
+func (s *drawingScene) renderElement(e drawingElement) string {
+	var shape string
+	switch e.Type {
+	case "rectangle", "frame", "magicframe":
+		radius := ""
+		if e.Roundness != nil {
+			radius = fmt.Sprintf(` rx="%s"`, svgNum(math.Min(e.Width, e.Height)*0.25))
+		}
+		shape = fmt.Sprintf(`<rect x="%s" y="%s" width="%s" height="%s"%s%s/>`,
+			svgNum(e.X), svgNum(e.Y), svgNum(e.Width), svgNum(e.Height), radius, e.paint(e.Type == "rectangle"))
+	case "ellipse":
+		shape = fmt.Sprintf(`<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s/>`,
+			svgNum(e.X+e.Width/2), svgNum(e.Y+e.Height/2), svgNum(e.Width/2), svgNum(e.Height/2), e.paint(true))
+	case "diamond":
+		shape = fmt.Sprintf(`<polygon points="%s,%s %s,%s %s,%s %s,%s"%s/>`,
+			svgNum(e.X+e.Width/2), svgNum(e.Y), svgNum(e.X+e.Width), svgNum(e.Y+e.Height/2),
+			svgNum(e.X+e.Width/2), svgNum(e.Y+e.Height), svgNum(e.X), svgNum(e.Y+e.Height/2), e.paint(true))
+	case "line", "arrow", "freedraw":
+		if len(e.Points) == 0 {
+			return ""
+		}
+		var points []string
+		for _, p := range e.Points {
+			points = append(points, svgNum(e.X+p[0])+","+svgNum(e.Y+p[1]))
+		}
+		// A line whose ends meet is a closed shape, which may be filled
+		closed := e.Type == "line" && len(e.Points) > 2 && e.Points[0] == e.Points[len(e.Points)-1]
+		shape = fmt.Sprintf(`<polyline points="%s"%s stroke-linecap="round" stroke-linejoin="round"/>`,
+			strings.Join(points, " "), e.paint(closed))
+		if e.Type == "arrow" {
+			shape += e.arrowheads()
+		}
+	case "text":
+		shape = e.renderText()
+	case "image":
+		file, ok := s.Files[e.FileID]
+		if !ok || !strings.HasPrefix(file.DataURL, "data:") {
+			return ""
+		}
+		shape = fmt.Sprintf(`<image x="%s" y="%s" width="%s" height="%s" href="%s" preserveAspectRatio="none"/>`,
+			svgNum(e.X), svgNum(e.Y), svgNum(e.Width), svgNum(e.Height), html.EscapeString(file.DataURL))
+	default:
+		// Embedded web pages and elements of newer versions of Excalidraw
+		return ""
+	}
+
+	attrs := ""
+	if e.Opacity != nil && *e.Opacity < 100 {
+		attrs += fmt.Sprintf(` opacity="%s"`, svgNum(*e.Opacity/100))
+	}
+	if e.Angle != 0 && len(e.Points) == 0 {
+		attrs += fmt.Sprintf(` transform="rotate(%s %s %s)"`,
+			svgNum(e.Angle*180/math.Pi), svgNum(e.X+e.Width/2), svgNum(e.Y+e.Height/2))
+	}
+	if attrs == "" {
+		return shape
+	}
+	return "<g" + attrs + ">" + shape + "</g>"
+}
+
+// paint returns the stroke and fill attributes of an element; hachure and cross-hatch fills are drawn solid
+func (e drawingElement) paint(filled bool) string {
+	stroke := e.StrokeColor
+	if stroke == "" {
+		stroke = "#1e1e1e"
+	}
+	fill := "none"
+	if filled && e.BackgroundColor != "" && e.BackgroundColor != "transparent" {
+		fill = e.BackgroundColor
+	}
+	width := e.StrokeWidth
+	if width == 0 {
+		width = 1
+	}
+	attrs := fmt.Sprintf(` stroke="%s" stroke-width="%s" fill="%s"`, html.EscapeString(stroke), svgNum(width), html.EscapeString(fill))
+	switch e.StrokeStyle {
+	case "dashed":
+		attrs += fmt.Sprintf(` stroke-dasharray="%s %s"`, svgNum(8+width*2), svgNum(8+width))
+	case "dotted":
+		attrs += fmt.Sprintf(` stroke-dasharray="%s %s"`, svgNum(1.5), svgNum(6+width*2))
+	}
+	return attrs
+}
+
+// arrowheads returns the heads of an arrow at its ends, drawn along its first and last segments
+func (e drawingElement) arrowheads() string {
+	n := len(e.Points)
+	if n < 2 {
+		return ""
+	}
+	var b strings.Builder
+	head := func(kind *string, tip, from [2]float64) {
+		if kind == nil {
+			return
+		}
+		dx, dy := tip[0]-from[0], tip[1]-from[1]
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			return
+		}
+		size := math.Min(15+e.StrokeWidth*2, length/2)
+		ux, uy := dx/length, dy/length
+		x, y := e.X+tip[0], e.Y+tip[1]
+		side := func(a float64) (float64, float64) {
+			return x - size*(ux*math.Cos(a)-uy*math.Sin(a)), y - size*(uy*math.Cos(a)+ux*math.Sin(a))
+		}
+		lx, ly := side(math.Pi / 7)
+		rx, ry := side(-math.Pi / 7)
+		switch *kind {
+		case "dot", "circle", "circle_outline":
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="%s"%s/>`, svgNum(x), svgNum(y), svgNum(size/3), e.headPaint(*kind == "dot" || *kind == "circle"))
+		case "bar":
+			bx, by := x-uy*size/2, y+ux*size/2
+			fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s"%s/>`, svgNum(bx), svgNum(by), svgNum(x+uy*size/2), svgNum(y-ux*size/2), e.headPaint(false))
+		case "triangle", "triangle_outline":
+			fmt.Fprintf(&b, `<polygon points="%s,%s %s,%s %s,%s"%s/>`, svgNum(x), svgNum(y), svgNum(lx), svgNum(ly), svgNum(rx), svgNum(ry), e.headPaint(*kind == "triangle"))
+		default:
+			fmt.Fprintf(&b, `<polyline points="%s,%s %s,%s %s,%s"%s stroke-linecap="round" stroke-linejoin="round"/>`,
+				svgNum(lx), svgNum(ly), svgNum(x), svgNum(y), svgNum(rx), svgNum(ry), e.headPaint(false))
+		}
+	}
+	head(e.StartArrowhead, e.Points[0], e.Points[1])
+	head(e.EndArrowhead, e.Points[n-1], e.Points[n-2])
+	return b.String()
+}
+
+// headPaint returns the stroke and fill of an arrowhead, solid and in the color of its arrow
+func (e drawingElement) headPaint(filled bool) string {
+	head := e
+	head.StrokeStyle, head.BackgroundColor = "", ""
+	if filled {
+		head.BackgroundColor = e.StrokeColor
+	}
+	return head.paint(filled)
+}
+
+// renderText returns the lines of a text element, placed as Excalidraw places them in its box
+func (e drawingElement) renderText() string {
+	size := e.FontSize
+	if size == 0 {
+		size = 20
+	}
+	lineHeight := e.LineHeight
+	if lineHeight == 0 {
+		lineHeight = 1.25
+	}
+	font, ok := drawingFonts[e.FontFamily]
+	if !ok {
+		font = drawingFonts[1]
+	}
+	x, anchor := e.X, "start"
+	switch e.TextAlign {
+	case "center":
+		x, anchor = e.X+e.Width/2, "middle"
+	case "right":
+		x, anchor = e.X+e.Width, "end"
+	}
+	color := e.StrokeColor
+	if color == "" {
+		color = "#1e1e1e"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<text font-family="%s" font-size="%s" fill="%s" text-anchor="%s" dominant-baseline="central">`,
+		html.EscapeString(font), svgNum(size), html.EscapeString(color), anchor)
+	for i, line := range strings.Split(e.Text, "\n") {
+		y := e.Y + size*lineHeight*(float64(i)+0.5)
+		fmt.Fprintf(&b, `<tspan x="%s" y="%s">%s</tspan>`, svgNum(x), svgNum(y), html.EscapeString(line))
+	}
+	b.WriteString("</text>")
+	return b.String()
+}
+
+// svgNum formats a coordinate with at most two decimals
+func svgNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// lzBase64Alphabet is the alphabet of lz-string's compressToBase64, used by Excalidraw for compressed-json
+const lzBase64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+// decompressLZBase64 decodes text compressed with lz-string's compressToBase64
+func decompressLZBase64(input string) (string, error) {
+	if input == "" {
+		return "", nil
+	}
+	errInvalid := errors.New("invalid compressed drawing")
+	values := make([]int, len(input))
+	for i := 0; i < len(input); i++ {
+		v := strings.IndexByte(lzBase64Alphabet, input[i])
+		if v < 0 {
+			return "", errInvalid
+		}
+		values[i] = v
+	}
+
+	// Bits are read 6 at a time from the characters, most significant first
+	val, position, index := values[0], 32, 1
+	readBits := func(n int) int {
+		bits := 0
+		for power := 1; power < 1<<n; power <<= 1 {
+			if val&position > 0 {
+				bits |= power
+			}
+			position >>= 1
+			if position == 0 {
+				position = 32
+				if index < len(values) {
+					val = values[index]
+				} else {
+					val = 0
+				}
+				index++
+			}
+		}
+		return bits
+	}
+
+	// The first three entries of the dictionary are the codes for a literal of 8 or 16 bits and the end
+	dictionary := [][]uint16{nil, nil, nil}
+	var w []uint16
+	switch readBits(2) {
+	case 0:
+		w = []uint16{uint16(readBits(8))}
+	case 1:
+		w = []uint16{uint16(readBits(16))}
+	default:
+		return "", nil
+	}
+	dictionary = append(dictionary, w)
+	result := append([]uint16{}, w...)
+	enlargeIn, numBits := 4, 3
+	for {
+		if index > len(values)+1 {
+			return "", errInvalid
+		}
+		code := readBits(numBits)
+		switch code {
+		case 0, 1:
+			bits := 8
+			if code == 1 {
+				bits = 16
+			}
+			dictionary = append(dictionary, []uint16{uint16(readBits(bits))})
+			code = len(dictionary) - 1
+			enlargeIn--
+		case 2:
+			return string(utf16.Decode(result)), nil
+		}
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+
+		var entry []uint16
+		switch {
+		case code < len(dictionary):
+			entry = dictionary[code]
+		case code == len(dictionary):
+			entry = append(append([]uint16{}, w...), w[0])
+		default:
+			return "", errInvalid
+		}
+		result = append(result, entry...)
+		dictionary = append(dictionary, append(append([]uint16{}, w...), entry[0]))
+		enlargeIn--
+		w = entry
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package o2q
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecompressLZBase64(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"Q===", ""},
+		{"IZA=", "a"},
+		{"BIUwNmD2A0AEDukBOYAmQ===", "Hello, world"},
+		// A code for the entry being added, as in a run of the same character
+		{"IY1o", "aaaaaaaaaa"},
+		// Literals of 16 bits, and the surrogate pairs of characters outside the BMP
+		{"LIS4dghg5gpgBAA7gFwE4HsCWBnIA===", "Ménage à trois"},
+		{"qemhpzR5UYdgyGMMi1DInQyAmGIA", "日本語のテキスト"},
+		{"jwbgr9gEAmBOCGDuBLAdgczKIA==", "🎨 drawing 🎨"},
+		// The codes grow to 7 bits as the dictionary fills
+		{"IYIwxqHpPXUNo+TUvczase33BOR+xhJ5Zlp1F1QA", strings.Repeat("abc", 100)},
+		{"N4IgLgngDgpiBcIYA8DGBDANgSwCYCd0B3EAGiUxgFsYA7MAZwQG1RJYER8ZUx1aA5pTIhkCAIwAGchAQAmSQF9SbaHETde/IXHJj4AZmkhZ8ACxKAuoqA==",
+			`{"type":"excalidraw","elements":[{"type":"rectangle","x":10,"y":20},{"type":"rectangle","x":30,"y":40}]}`},
+	}
+	for _, tt := range tests {
+		if got, err := decompressLZBase64(tt.input); err != nil || got != tt.want {
+			t.Errorf("decompressLZBase64(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	// A character outside the alphabet, and compressed texts cut short
+	for _, input := range []string{"IZA!", "N4IgLgngDgpiBcIYA8DG", "LIS4dghg5gpgBAA7"} {
+		if got, err := decompressLZBase64(input); err == nil {
+			t.Errorf("decompressLZBase64(%q) = %q, want an error", input, got)
+		}
+	}
+}
+
+func TestParseDrawing(t *testing.T) {
+	// The plugin breaks the compressed scene into lines, with blank lines between them
+	content := "# Drawing\n```compressed-json\nN4IgLgngDgpiBcIYA8DGBDANgSwCYCd0B3EAGiUxgFsYA7MAZwQG1RJYER8ZUx1a\n\nA5pTIhkCAIwAGchAQAmSQF9SbaHETde/IXHJj4AZmkhZ8ACxKAuoqA==\n```\n%%\n"
+	scene, err := parseDrawing([]byte(content))
+	if err != nil {
+		t.Fatalf("parseDrawing() error = %v", err)
+	}
+	if len(scene.Elements) != 2 || scene.Elements[1].X != 30 || scene.Elements[1].Type != "rectangle" {
+		t.Errorf("parseDrawing() elements = %+v", scene.Elements)
+	}
+
+	scene, err = parseDrawing([]byte("```json\n{\"elements\":[{\"type\":\"text\",\"text\":\"Hi\"}]}\n```\n"))
+	if err != nil || len(scene.Elements) != 1 || scene.Elements[0].Text != "Hi" {
+		t.Errorf("parseDrawing() of a json block = %+v, %v", scene, err)
+	}
+	for _, content := range []string{"# No scene\n", "```compressed-json\nIZA!\n```\n", "```json\n{\"elements\": [\n```\n"} {
+		if _, err := parseDrawing([]byte(content)); err == nil {
+			t.Errorf("parseDrawing(%q) error = nil", content)
+		}
+	}
+}
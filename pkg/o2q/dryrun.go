@@ -0,0 +1,133 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printPlan lists what a --dry-run would do with every file of the vault, in the order of the walk, without writing anything
+//
+//	transform  Projects/Roadmap.md → Projects/Roadmap.md (overwrites)
+//	copy       Attachments/diagram.png → Attachments/diagram.png
+//	skip       Private/ (ignore pattern)
+//
+// Returns the exit code of the run, which fails if a file would fail
+func (c *converter) printPlan() int {
+	writes, overwrites, skips, unchanged, errs := 0, 0, 0, 0, 0
+	for _, f := range c.plan.files {
+		src := c.paths.source(f.src)
+		if f.info != nil && f.info.IsDir() {
+			src += "/"
+		}
+		switch f.action {
+		case planFolder:
+			continue
+		case planUnchangedGit:
+			unchanged++
+			c.console.progressf("  unchanged  %s (since --since-git)", src)
+			continue
+		case planUnchangedState:
+			unchanged++
+			c.console.progressf("  unchanged  %s (since the last run)", src)
+			continue
+		case actionError:
+			errs++
+			c.console.infof("  error      %s: %v", src, f.err)
+			continue
+		case planPublish:
+		default:
+			skips++
+			reason := f.reason
+			if reason == "" {
+				reason = strings.TrimPrefix(f.action, "skipped-")
+			}
+			c.console.progressf("  skip       %s (%s)", src, reason)
+			continue
+		}
+
+		verb, dest := c.plannedWrite(f)
+		if verb == "skip" {
+			skips++
+			c.console.progressf("  skip       %s (file type not published)", src)
+			continue
+		}
+		line := "  " + verb + strings.Repeat(" ", max(1, 11-len(verb))) + src + " → " + c.paths.dest(dest)
+		if _, err := os.Stat(dest); err == nil && !c.opts.clean {
+			if c.opts.noClobber {
+				skips++
+				c.console.infof("  keep       %s (exists, --no-clobber)", c.paths.dest(dest))
+				continue
+			}
+			overwrites++
+			line += " (overwrites)"
+		}
+		writes++
+		c.console.infof("%s", line)
+	}
+
+	// Deletions of --incremental, once every file of the plan is known
+	if c.state != nil {
+		for _, f := range c.plan.files {
+			if f.action == planPublish {
+				c.state.next[filepath.ToSlash(f.relPath)] = stateEntry{}
+			}
+		}
+		if err := c.removeVanishedSources(); err != nil {
+			c.console.errorf("%v", err)
+			return exitFailure
+		}
+	}
+
+	if err := c.expireTombstones(); err != nil {
+		c.console.errorf("%v", err)
+		return exitFailure
+	}
+	if err := c.pruneContent(); err != nil {
+		c.console.errorf("%v", err)
+		return exitFailure
+	}
+
+	c.console.infof("Dry run: %d files to write (%d overwriting a file), %d unchanged, %d skipped, %d errors",
+		writes, overwrites, unchanged, skips, errs)
+	if errs > 0 {
+		return exitFileErrors
+	}
+	return exitSuccess
+}
+
+// plannedWrite returns how a file the plan publishes would be written, and where
+func (c *converter) plannedWrite(f plannedFile) (verb, dest string) {
+	switch {
+	case c.rendersDrawing(f.src):
+		return "render", f.dest
+	case hasExt(f.src, ".md") && !isInExcalidrawFolder(f.relPath):
+		dest = strings.TrimSuffix(f.dest, filepath.Ext(f.dest)) + ".md"
+		if c.tooLargeToTransform(f.info.Size()) {
+			return "copy", dest
+		}
+		return "transform", dest
+	case hasExt(f.src, ".canvas"):
+		if c.opts.canvas != canvasList {
+			return "skip", ""
+		}
+		return "generate", f.dest + ".md"
+	case hasExt(f.src, ".html"):
+		switch c.opts.html {
+		case htmlSkip:
+			return "skip", ""
+		case htmlStatic, htmlIframe:
+			return "copy", filepath.Join(c.quartzFolder, "quartz", "static", f.relPath)
+		}
+	}
+	return "copy", f.dest
+}
+
+// wouldDelete lists a file a --dry-run would delete, and checks if it must be left as it is
+func (c *converter) wouldDelete(file, why string) bool {
+	if !c.opts.dryRun {
+		return false
+	}
+	c.console.infof("  delete     %s (%s)", c.paths.dest(file), why)
+	return true
+}
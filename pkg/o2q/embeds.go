@@ -0,0 +1,123 @@
+package o2q
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Handling of embeds of notes that are not published
+const (
+	missingEmbedsPlaceholder = "placeholder" // Replace the embed with an italic line saying the note is omitted
+	missingEmbedsRemove      = "remove"      // Remove the embed
+	missingEmbedsKeep        = "keep"        // Leave the embed, which Quartz shows as a broken block
+)
+
+// missingEmbed is an embed of a note that is excluded or missing, listed in the summary
+type missingEmbed struct {
+	Source string `json:"source"`
+	Target string `json:"target"` // Note and section embedded, as written
+}
+
+// planUnpublishedNotes finds the notes that pass the folder, ignore and filter rules but are still left out,
+// as not marked publish: true with --from-obsidian-publish, marked publish: false or draft: true with --skip-unpublished,
+// or too large with --oversize-notes=exclude,
+// so embeds of them are caught whichever note comes first in the walk
+func (c *converter) planUnpublishedNotes() {
+	oversizeExcluded := c.opts.maxNoteSize > 0 && c.opts.oversizeNotes == oversizeExclude
+	if !c.opts.fromObsidianPublish && !c.opts.skipUnpublished && !oversizeExcluded {
+		return
+	}
+	c.unpublishedNotes = make(map[string]bool)
+	for _, file := range c.vaultFiles {
+		if !hasExt(file, ".md") {
+			continue
+		}
+		src := filepath.Join(c.obsidianFolder, filepath.FromSlash(file))
+		if c.opts.fromObsidianPublish || c.opts.skipUnpublished {
+			if reason, _ := c.unpublished(src); reason != "" {
+				c.unpublishedNotes[file] = true
+				continue
+			}
+		}
+		if info, err := c.vault.Stat(src); oversizeExcluded && err == nil && info.Size() > c.opts.maxNoteSize {
+			c.unpublishedNotes[file] = true
+		}
+	}
+}
+
+// notePublished checks if the note an embed points to is published by the run
+// A name matching several notes is taken as published, as Quartz picks one of them
+func (c *converter) notePublished(noteDir, target string) bool {
+	if file, ok := c.resolveReference(noteDir, target); ok {
+		return !c.unpublishedNotes[file]
+	}
+	name := path.Base(target)
+	if !hasExt(name, ".md") {
+		name += ".md"
+	}
+	for _, file := range c.vaultFiles {
+		if strings.EqualFold(path.Base(file), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteMissingEmbeds replaces the embeds of notes that are excluded or missing, which Quartz shows as broken blocks
+//   - placeholder: ![[Project Plan#Milestones]] → *(Project Plan > Milestones omitted)*
+//   - remove: the embed is removed
+//
+// Embeds of published notes, of other files and of sections of the note itself are left untouched, and so are
+// code blocks and inline code; the embeds replaced are listed in the summary
+func (c *converter) rewriteMissingEmbeds(src string, content []byte) []byte {
+	if c.opts.missingEmbeds == missingEmbedsKeep || c.exportFiles != nil {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	replace := func(match, target, fragment string) string {
+		if target == "" || !isNoteTarget(target) || c.notePublished(noteDir, target) {
+			return match
+		}
+		written := target + fragment
+		c.report.MissingEmbeds = append(c.report.MissingEmbeds, missingEmbed{Source: c.paths.source(src), Target: written})
+		c.console.progressf("%s: embeds %s, which is not published", src, written)
+		if c.opts.missingEmbeds == missingEmbedsRemove {
+			return ""
+		}
+		name := strings.TrimSuffix(target, path.Ext(target))
+		if !strings.EqualFold(path.Ext(target), ".md") {
+			name = target
+		}
+		if fragment != "" {
+			name += " > " + strings.Join(strings.Split(strings.TrimPrefix(fragment, "#"), "#"), " > ")
+		}
+		return "*(" + name + " omitted)*"
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			if parts[1] == "" {
+				return match
+			}
+			return replace(match, parts[2], parts[3])
+		})
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			link := fileLink{target: strings.Trim(parts[3], "<>"), angle: strings.HasPrefix(parts[3], "<")}
+			if parts[1] == "" || isExternalURL(link.target) {
+				return match
+			}
+			target, fragment, _ := strings.Cut(link.decodedTarget(), "#")
+			if !hasExt(target, ".md") {
+				return match
+			}
+			if fragment != "" {
+				fragment = "#" + fragment
+			}
+			return replace(match, target, fragment)
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package o2q
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// keepMarkers are the files that keep a vault folder published when nothing else in it is, as in git
+var keepMarkers = []string{".gitkeep", ".keep"}
+
+// hasKeepMarker checks if a vault folder holds a marker file asking to keep it even when empty
+func hasKeepMarker(dir string) bool {
+	for _, name := range keepMarkers {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// makeDir creates a folder of the content folder and the missing folders above it, counting them in the report
+// Folders are only created when a file is written to them, so a vault folder whose files are all left out
+// does not show up on the site as an empty folder page
+func (c *converter) makeDir(dir string) error {
+	created := 0
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		created++
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	c.report.DirectoriesCreated += created
+	return nil
+}
+
+// removeEmptyDirs removes the folders left empty in the content folder, deepest first
+// They hold no published file, such as a folder whose notes are all excluded, or one emptied since an earlier run
+// Only the folders mirroring a vault folder and the folders inside them are removed; a folder whose vault folder
+// holds a .gitkeep or .keep file is kept
+func (c *converter) removeEmptyDirs() error {
+	kept := make(map[string]bool)
+	var roots []string
+	for _, f := range c.plan.files {
+		if f.action != planFolder {
+			continue
+		}
+		if hasKeepMarker(f.src) {
+			kept[f.dest] = true
+		}
+		roots = append(roots, f.dest)
+	}
+
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, root := range roots {
+		if seen[root] {
+			continue
+		}
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) && p == root {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && !seen[p] {
+				seen[p] = true
+				dirs = append(dirs, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list folder %s: %v", root, err)
+		}
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+
+	removed := 0
+	for _, dir := range dirs {
+		if kept[dir] {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read folder %s: %v", dir, err)
+		}
+		if len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("failed to remove empty folder %s: %v", dir, err)
+		}
+		c.console.progressf("Removed empty folder: %s", c.paths.dest(dir))
+		removed++
+	}
+	if removed > 0 {
+		c.console.infof("Removed %d folders left empty in the content folder", removed)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package o2q
+
+import (
+	"html"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Modes of --excalidraw-theme
+const (
+	excalidrawThemeSingle = "single" // Drawings are exported as one .excalidraw.svg
+	excalidrawThemeDual   = "dual"   // Drawings may be exported as .excalidraw.light.svg and .excalidraw.dark.svg
+)
+
+// Modes of --excalidraw-links
+const (
+	excalidrawLinksLink   = "link"   // Links to a drawing stay links, to its SVG export
+	excalidrawLinksEmbed  = "embed"  // Links to a drawing become embeds of its SVG export
+	excalidrawLinksFigure = "figure" // Links to a drawing become a figure of its SVG export, captioned with the link text
+)
+
+// Patterns of the links to Excalidraw drawings that are rewritten to their SVG export
+var (
+	drawingWikiLinkRe  = regexp.MustCompile(`\[\[([^|\]]+?)\.excalidraw\]\]`)
+	themedWikiLinkRe   = regexp.MustCompile(`(!?)\[\[([^|\]]+?)\.excalidraw\]\]`)
+	aliasedDrawingLink = regexp.MustCompile(`(!?)\[\[([^|\]]+?)\.excalidraw(?:\|([^\]]*))?\]\]`)
+)
+
+// drawingTarget returns the target of a markdown link to an Excalidraw drawing without its .excalidraw.md
+// or .excalidraw extension, whatever its case, and still encoded as written
+func drawingTarget(target string) (string, bool) {
+	if isExternalURL(target) {
+		return "", false
+	}
+	for _, ext := range []string{".excalidraw.md", ".excalidraw"} {
+		if len(target) > len(ext) && strings.EqualFold(target[len(target)-len(ext):], ext) {
+			return target[:len(target)-len(ext)], true
+		}
+	}
+	return "", false
+}
+
+// rewriteDrawings points the links and embeds of a note to Excalidraw drawings at their SVG export
+//   - [[drawing.excalidraw]] → [[drawing.excalidraw.svg|drawing]]
+//   - [text](drawing.excalidraw.md) → [text](drawing.excalidraw.svg)
+//
+// Links to drawings exported for the light and the dark theme are rewritten first, and missing exports reported
+func (c *converter) rewriteDrawings(src string, content []byte) []byte {
+	content = c.rewriteThemedDrawings(src, content)
+
+	// Report drawings whose SVG export is missing, before their links are rewritten to it
+	c.checkDrawings(src, content)
+
+	// Show the drawings notes link to as images or figures, with --excalidraw-links
+	content = c.rewriteDrawingLinkModes(src, content)
+
+	content = drawingWikiLinkRe.ReplaceAll(content, []byte("[[$1.excalidraw.svg|$1]]"))
+	return rewriteDrawingLinks(content)
+}
+
+// rewriteDrawingLinks points the markdown links and embeds to a drawing at its SVG export
+// Only the target changes, keeping its encoding, angle brackets and title; code and prose are left alone:
+//   - [arch](My%20Drawings/system%20design.excalidraw.md "Overview") → [arch](My%20Drawings/system%20design.excalidraw.svg "Overview")
+func rewriteDrawingLinks(content []byte) []byte {
+	return mapOutsideCode(content, func(text string) string {
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			angle := strings.HasPrefix(parts[3], "<")
+			drawing, ok := drawingTarget(strings.Trim(parts[3], "<>"))
+			if !ok {
+				return match
+			}
+			target := drawing + ".excalidraw.svg"
+			if angle {
+				target = "<" + target + ">"
+			}
+			start := len(parts[1] + "[" + parts[2] + "](")
+			return match[:start] + target + match[start+len(parts[3]):]
+		})
+	})
+}
+
+// missingDrawing is a link to an Excalidraw drawing whose SVG export is not in the vault, listed in the summary
+type missingDrawing struct {
+	Source  string `json:"source"`
+	Drawing string `json:"drawing"`
+}
+
+// checkDrawings looks for the SVG export of each drawing a note links to, as the link is rewritten to it
+// SVGs are looked up in the vault rather than in the content folder, which only holds those copied so far;
+// a drawing is found like Obsidian finds it: next to the note, from the vault root, or by its name anywhere
+// Missing exports, usually because auto-export is off in the Excalidraw plugin, are listed in the summary
+func (c *converter) checkDrawings(src string, content []byte) {
+	noteDir := c.noteDir(src)
+	check := func(drawing string) {
+		if c.drawingExported(noteDir, drawing+".excalidraw.svg") {
+			return
+		}
+		c.report.MissingDrawings = append(c.report.MissingDrawings, missingDrawing{
+			Source:  c.paths.source(src),
+			Drawing: drawing,
+		})
+	}
+	mapOutsideCode(content, func(text string) string {
+		for _, m := range drawingWikiLinkRe.FindAllStringSubmatch(text, -1) {
+			check(m[1])
+		}
+		for _, m := range markdownLinkRe.FindAllStringSubmatch(text, -1) {
+			if drawing, ok := drawingTarget(strings.Trim(m[3], "<>")); ok {
+				check(fileLink{target: drawing}.decodedTarget())
+			}
+		}
+		return text
+	})
+}
+
+// drawingExported checks if the SVG export of a drawing is published
+func (c *converter) drawingExported(noteDir, svg string) bool {
+	_, ok := c.findDrawingSVG(noteDir, svg)
+	return ok || c.svgFilesErr != nil
+}
+
+// findDrawingSVG returns the vault path of a published SVG export, whatever the case of its extension
+func (c *converter) findDrawingSVG(noteDir, svg string) (string, bool) {
+	if c.svgFiles == nil {
+		c.svgFiles = []string{}
+		c.svgFilesErr = c.walkEligible(func(relPath string) error {
+			// Drawings rendered by --render-drawings are published as the SVG export they lack
+			if hasExt(relPath, ".svg") || c.rendersDrawing(filepath.Join(c.obsidianFolder, relPath)) {
+				relPath = filepath.ToSlash(relPath)
+				c.svgFiles = append(c.svgFiles, strings.TrimSuffix(relPath, path.Ext(relPath))+".svg")
+			}
+			return nil
+		})
+		if c.svgFilesErr != nil {
+			c.console.warnf("failed to list SVG exports, links to drawings not checked: %v", c.svgFilesErr)
+		}
+	}
+	if file, ok := resolveFile(c.svgFiles, noteDir, svg); ok {
+		return file, true
+	}
+	// Obsidian picks one of several drawings with the same name, so any of them is a match
+	for _, file := range c.svgFiles {
+		if path.Base(file) == path.Base(svg) {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// rewriteThemedDrawings points links to drawings exported as a light and a dark SVG at these exports, with --excalidraw-theme=dual
+// An embed becomes two <img> tags, for the light and the dark theme of Quartz, which the CSS of the site shows one at a time:
+//   - ![[Flow.excalidraw]] → <img src="Flow.excalidraw.light.svg" alt="Flow" class="excalidraw-light"><img ... class="excalidraw-dark">
+//
+// A link opens the light export. A drawing with a single variant is shown with it in both themes, with a warning;
+// links to drawings with neither are left for the usual rewrite to .excalidraw.svg
+func (c *converter) rewriteThemedDrawings(src string, content []byte) []byte {
+	if c.opts.excalidrawTheme != excalidrawThemeDual {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	// variants returns the vault paths of the light and dark exports of a drawing, "" for a missing one
+	variants := func(drawing string) (light, dark string, ok bool) {
+		light, _ = c.findDrawingSVG(noteDir, drawing+".excalidraw.light.svg")
+		dark, _ = c.findDrawingSVG(noteDir, drawing+".excalidraw.dark.svg")
+		switch {
+		case light == "" && dark == "":
+			return "", "", false
+		case light == "":
+			c.console.warnf("%s: drawing %s only has a dark SVG export, shown in both themes", src, drawing)
+			light = dark
+		case dark == "":
+			c.console.warnf("%s: drawing %s only has a light SVG export, shown in both themes", src, drawing)
+			dark = light
+		}
+		return light, dark, true
+	}
+	images := func(name, light, dark string) string {
+		name = html.EscapeString(name)
+		if light == dark {
+			return `<img src="` + relativeURL(noteDir, light) + `" alt="` + name + `">`
+		}
+		return `<img src="` + relativeURL(noteDir, light) + `" alt="` + name + `" class="excalidraw-light">` +
+			`<img src="` + relativeURL(noteDir, dark) + `" alt="` + name + `" class="excalidraw-dark">`
+	}
+	// linked shows a link to a drawing as --excalidraw-links asks, or returns "" to keep it a link
+	linked := func(name, light, dark string) string {
+		switch c.opts.excalidrawLinks {
+		case excalidrawLinksEmbed:
+			return images(name, light, dark)
+		case excalidrawLinksFigure:
+			return "<figure>" + images(name, light, dark) + "<figcaption>" + html.EscapeString(name) + "</figcaption></figure>"
+		}
+		return ""
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = themedWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := themedWikiLinkRe.FindStringSubmatch(match)
+			light, dark, ok := variants(parts[2])
+			if !ok {
+				return match
+			}
+			name := path.Base(parts[2])
+			if parts[1] != "" {
+				return images(name, light, dark)
+			}
+			if shown := linked(name, light, dark); shown != "" {
+				return shown
+			}
+			return fileLink{wiki: true, target: light, text: name}.String()
+		})
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			target, ok := drawingTarget(strings.Trim(parts[3], "<>"))
+			if !ok {
+				return match
+			}
+			drawing := fileLink{target: target}.decodedTarget()
+			light, dark, ok := variants(drawing)
+			if !ok {
+				return match
+			}
+			if parts[1] != "" {
+				name := parts[2]
+				if name == "" {
+					name = path.Base(drawing)
+				}
+				return images(name, light, dark)
+			}
+			name := parts[2]
+			if name == "" {
+				name = path.Base(drawing)
+			}
+			if shown := linked(name, light, dark); shown != "" {
+				return shown
+			}
+			return "[" + parts[2] + "](" + relativeURL(noteDir, light) + ")"
+		})
+	})
+}
+
+// rewriteDrawingLinkModes shows the drawings notes link to rather than linking to them, with --excalidraw-links:
+//   - embed: [[Flow.excalidraw]] → ![[Flow.excalidraw.svg]], [chart](Flow.excalidraw.md) → ![chart](Flow.excalidraw.svg)
+//   - figure: [[Flow.excalidraw|Login]] → <figure><img src="Flow.excalidraw.svg" alt="Login"><figcaption>Login</figcaption></figure>
+//
+// The caption is the alias or link text, or the drawing name. Embeds in the note stay embeds whatever the mode,
+// and a figure needs the SVG export: a drawing without one stays a link, listed as missing
+func (c *converter) rewriteDrawingLinkModes(src string, content []byte) []byte {
+	if c.opts.excalidrawLinks != excalidrawLinksEmbed && c.opts.excalidrawLinks != excalidrawLinksFigure {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	figure := func(drawing, caption string) (string, bool) {
+		svg, ok := c.findDrawingSVG(noteDir, drawing+".excalidraw.svg")
+		if !ok {
+			return "", false
+		}
+		caption = html.EscapeString(caption)
+		return `<figure><img src="` + relativeURL(noteDir, svg) + `" alt="` + caption + `"><figcaption>` + caption + `</figcaption></figure>`, true
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = aliasedDrawingLink.ReplaceAllStringFunc(text, func(match string) string {
+			parts := aliasedDrawingLink.FindStringSubmatch(match)
+			if parts[1] != "" {
+				return match
+			}
+			drawing, alias := parts[2], parts[3]
+			if c.opts.excalidrawLinks == excalidrawLinksEmbed {
+				if alias != "" {
+					return "![[" + drawing + ".excalidraw.svg|" + alias + "]]"
+				}
+				return "![[" + drawing + ".excalidraw.svg]]"
+			}
+			if alias == "" {
+				alias = path.Base(drawing)
+			}
+			if shown, ok := figure(drawing, alias); ok {
+				return shown
+			}
+			return match
+		})
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			target, ok := drawingTarget(strings.Trim(parts[3], "<>"))
+			if parts[1] != "" || !ok {
+				return match
+			}
+			if c.opts.excalidrawLinks == excalidrawLinksEmbed {
+				// The target is pointed at the SVG export with the other links to drawings
+				return "!" + match
+			}
+			drawing := fileLink{target: target}.decodedTarget()
+			caption := parts[2]
+			if caption == "" {
+				caption = path.Base(drawing)
+			}
+			if shown, ok := figure(drawing, caption); ok {
+				return shown
+			}
+			return match
+		})
+	})
+}
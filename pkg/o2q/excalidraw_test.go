@@ -0,0 +1,53 @@
+package o2q
+
+import "testing"
+
+func TestDrawingTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string // Empty if not a drawing
+	}{
+		{"Flow.excalidraw.md", "Flow"},
+		{"Flow.excalidraw", "Flow"},
+		{"Diagrams/Flow.Excalidraw.MD", "Diagrams/Flow"},
+		{"My%20Drawings/system%20design.excalidraw.md", "My%20Drawings/system%20design"},
+		{"Flow.excalidraw.svg", ""},
+		{"Flow.md", ""},
+		{".excalidraw.md", ""},
+		{"https://example.com/Flow.excalidraw.md", ""},
+	}
+	for _, tt := range tests {
+		got, ok := drawingTarget(tt.target)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("drawingTarget(%q) = %q, %v, want %q", tt.target, got, ok, tt.want)
+		}
+	}
+}
+
+func TestRewriteDrawingLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"link", "[flow](Flow.excalidraw.md)", "[flow](Flow.excalidraw.svg)"},
+		{"embed", "![flow](Diagrams/Flow.excalidraw)", "![flow](Diagrams/Flow.excalidraw.svg)"},
+		{"encoded spaces", "[arch](My%20Drawings/system%20design.excalidraw.md)", "[arch](My%20Drawings/system%20design.excalidraw.svg)"},
+		{"angle brackets", "[arch](<My Drawings/system design.excalidraw.md>)", "[arch](<My Drawings/system design.excalidraw.svg>)"},
+		{"title", `[arch](Flow.excalidraw.md "Overview")`, `[arch](Flow.excalidraw.svg "Overview")`},
+		{"parens in the text", "[the flow (v2)](Flow.excalidraw.md)", "[the flow (v2)](Flow.excalidraw.svg)"},
+		{"uppercase", "[flow](Flow.Excalidraw.MD)", "[flow](Flow.excalidraw.svg)"},
+		{"prose", "rename it to foo.excalidraw.md) if needed", "rename it to foo.excalidraw.md) if needed"},
+		{"external URL", "[flow](https://example.com/Flow.excalidraw.md)", "[flow](https://example.com/Flow.excalidraw.md)"},
+		{"inline code", "`[flow](Flow.excalidraw.md)`", "`[flow](Flow.excalidraw.md)`"},
+		{"code block", "```\n[flow](Flow.excalidraw.md)\n```\n", "```\n[flow](Flow.excalidraw.md)\n```\n"},
+		{"other note", "[note](Flow.md)", "[note](Flow.md)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(rewriteDrawingLinks([]byte(tt.content))); got != tt.want {
+				t.Errorf("rewriteDrawingLinks(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package o2q
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Exit codes telling apart how a run ended, for schedulers and CI jobs
+const (
+	exitSuccess     = 0 // The run completed without errors
+	exitFailure     = 1 // Invalid arguments, options or config, or a failure that stopped the run
+	exitFileErrors  = 2 // The run completed, but files failed, or check found problems
+	exitRefused     = 3 // A destination safety check refused the run or files of it
+	exitNothingToDo = 4 // The run published and deleted nothing, as the source is empty or nothing changed
+)
+
+// exitCodes describes each exit code, for --help and the end of a run
+var exitCodes = []struct {
+	code   int
+	reason string
+}{
+	{exitSuccess, "success"},
+	{exitFailure, "invalid arguments, options or config, or a failure that stopped the run"},
+	{exitFileErrors, "the run completed, but files failed (or check found problems)"},
+	{exitRefused, "a destination safety check refused the run or some of its files"},
+	{exitNothingToDo, "nothing to do: no file was published or deleted"},
+}
+
+// exitReason describes an exit code in words
+func exitReason(code int) string {
+	for _, e := range exitCodes {
+		if e.code == code {
+			return e.reason
+		}
+	}
+	return "unknown"
+}
+
+// printExitCodes lists the exit codes, at the end of --help
+func printExitCodes(w io.Writer) {
+	fmt.Fprintln(w, "Exit codes:")
+	for _, e := range exitCodes {
+		fmt.Fprintf(w, "  %d  %s\n", e.code, e.reason)
+	}
+}
+
+// exitStatus states the reason of the exit code of a run on the last line of the output, and returns the code
+func exitStatus(code int) int {
+	fmt.Printf("Exit code %d: %s\n", code, exitReason(code))
+	return code
+}
+
+// worseExit returns the exit code of a run whose sources ended with a and b
+// A failure outranks a refusal, which outranks file errors; nothing to do only holds if it holds for every source
+func worseExit(a, b int) int {
+	rank := map[int]int{exitNothingToDo: 0, exitSuccess: 1, exitFileErrors: 2, exitRefused: 3, exitFailure: 4}
+	if rank[a] >= rank[b] {
+		return a
+	}
+	return b
+}
+
+// refusal is the error of a destination safety check, which ends the run with exitRefused
+type refusal struct {
+	msg string
+}
+
+func (r refusal) Error() string {
+	return r.msg
+}
+
+// refusedf creates the error of a destination safety check
+func refusedf(format string, args ...interface{}) error {
+	return refusal{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitCodeFor returns the exit code of a run stopped by an error
+func exitCodeFor(err error) int {
+	var r refusal
+	switch {
+	case errors.As(err, &r):
+		return exitRefused
+	case errors.Is(err, errFailFast):
+		return exitFileErrors
+	}
+	return exitFailure
+}
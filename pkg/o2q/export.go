@@ -0,0 +1,425 @@
+package o2q
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// standaloneStyle is the stylesheet embedded in every page of a standalone export
+const standaloneStyle = `body { max-width: 48em; margin: 2em auto; padding: 0 1em; font-family: sans-serif; line-height: 1.5; }
+nav { font-size: 0.9em; border-bottom: 1px solid #ccc; margin-bottom: 1em; }
+pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; }
+img { max-width: 100%; }
+.broken { color: #c00; text-decoration: underline wavy; }`
+
+// Block-level patterns of the standalone renderer
+var (
+	mdHeadingRe = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	mdListRe    = regexp.MustCompile(`^\s*(?:([-*+])|\d{1,9}[.)])[ \t]+(.*)$`)
+	mdTaskRe    = regexp.MustCompile(`^\[([ xX])\][ \t]+`)
+	mdQuoteRe   = regexp.MustCompile(`^ {0,3}>[ ]?(.*)$`)
+	mdRuleRe    = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+)
+
+// mdInlineRe matches the inline constructs of the standalone renderer, in order of priority
+var mdInlineRe = regexp.MustCompile("`([^`]+)`" +
+	`|(!?)\[\[([^\]|]+)(?:\|([^\]]*))?\]\]` +
+	`|(!?)\[([^\]]*)\]\((?:<([^>\n]+)>|([^)\s]+))(?:\s+"[^"]*")?\)` +
+	`|<(https?://[^>\s]+)>` +
+	`|\*\*([^*]+)\*\*` +
+	`|\*([^*\s][^*]*)\*` +
+	`|(?:^|\b)_([^_\s][^_]*)_\b`)
+
+// standaloneRenderer renders the converted notes of an export folder to HTML
+type standaloneRenderer struct {
+	c      *converter
+	folder string   // Export folder
+	files  []string // Relative paths of the exported files, with forward slashes
+}
+
+// renderStandalone adds an HTML page for every note and an index.html for every folder of the export folder
+// The pages only use relative links so the folder can be opened in a browser without any toolchain
+func (c *converter) renderStandalone() error {
+	r := &standaloneRenderer{c: c, folder: c.contentFolder}
+	dirs := map[string]bool{".": true}
+	err := filepath.Walk(r.folder, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(r.folder, p)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			dirs[relPath] = true
+		} else {
+			r.files = append(r.files, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list export folder: %v", err)
+	}
+
+	// Leave out the pages generated by a previous export
+	var files []string
+	for _, file := range r.files {
+		if strings.HasSuffix(file, ".html") && (path.Base(file) == "index.html" || r.hasNote(strings.TrimSuffix(file, ".html")+".md")) {
+			continue
+		}
+		files = append(files, file)
+	}
+	r.files = files
+
+	for _, file := range r.files {
+		if strings.HasSuffix(file, ".md") {
+			if err := r.renderNote(file); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Folder pages are written in a fixed order, so the output of two runs compares line by line
+	dirList := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirList = append(dirList, dir)
+	}
+	sort.Strings(dirList)
+	for _, dir := range dirList {
+		if r.hasNote(path.Join(dir, "index.md")) {
+			continue
+		}
+		if err := r.writePage(path.Join(dir, "index.html"), dirTitle(dir), r.renderListing(dir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasNote checks if the export contains a file
+func (r *standaloneRenderer) hasNote(file string) bool {
+	for _, f := range r.files {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+// renderNote renders a converted note to an HTML page next to it
+// The page of a folder's index.md also lists the folder
+func (r *standaloneRenderer) renderNote(file string) error {
+	content, err := os.ReadFile(filepath.Join(r.folder, filepath.FromSlash(file)))
+	if err != nil {
+		return fmt.Errorf("failed to read markdown file: %v", err)
+	}
+
+	title := strings.TrimSuffix(path.Base(file), ".md")
+	if values, err := parseFrontmatter(content); err == nil {
+		if t := frontmatterString(values, "title"); t != "" {
+			title = t
+		}
+	}
+	_, body, _ := splitFrontmatter(content)
+
+	page := r.renderMarkdown(file, body)
+	if path.Base(file) == "index.md" {
+		page += r.renderListing(path.Dir(file))
+	}
+	return r.writePage(strings.TrimSuffix(file, ".md")+".html", title, page)
+}
+
+// writePage writes a complete HTML page to a file of the export folder
+func (r *standaloneRenderer) writePage(file, title, body string) error {
+	dir := path.Dir(file)
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n",
+		html.EscapeString(title), standaloneStyle)
+	fmt.Fprintf(&b, "<nav><a href=\"%s\">Home</a> · <a href=\"index.html\">%s</a></nav>\n",
+		relativeURL(dir, "index.html"), html.EscapeString(dirTitle(dir)))
+	fmt.Fprintf(&b, "<h1>%s</h1>\n%s</body>\n</html>\n", html.EscapeString(title), body)
+
+	src := filepath.Join(r.folder, filepath.FromSlash(strings.TrimSuffix(file, ".html")+".md"))
+	dest := filepath.Join(r.folder, filepath.FromSlash(file))
+	page := b.String()
+	_, err := writeFileAtomic(dest, 0644, func(w io.Writer) (int64, error) {
+		n, err := io.WriteString(w, page)
+		return int64(n), err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write page: %v", err)
+	}
+	r.c.record(reportEntry{Source: src, Destination: dest, Action: actionGenerated}, int64(len(page)))
+	return nil
+}
+
+// dirTitle returns the title of a folder's index page
+func dirTitle(dir string) string {
+	if dir == "." {
+		return "Index"
+	}
+	return path.Base(dir)
+}
+
+// renderListing lists the subfolders, notes and other files of a folder
+func (r *standaloneRenderer) renderListing(dir string) string {
+	subdirs := make(map[string]bool)
+	var notes, others []string
+	for _, file := range r.files {
+		rel := file
+		if dir != "." {
+			if !strings.HasPrefix(file, dir+"/") {
+				continue
+			}
+			rel = file[len(dir)+1:]
+		}
+		if i := strings.Index(rel, "/"); i >= 0 {
+			subdirs[rel[:i]] = true
+		} else if strings.HasSuffix(rel, ".md") {
+			if rel != "index.md" {
+				notes = append(notes, rel)
+			}
+		} else {
+			others = append(others, rel)
+		}
+	}
+
+	var names []string
+	for name := range subdirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(notes)
+	sort.Strings(others)
+
+	var b strings.Builder
+	list := func(title string, items []string, href, text func(string) string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<ul>\n", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href(item)), html.EscapeString(text(item)))
+		}
+		b.WriteString("</ul>\n")
+	}
+	same := func(s string) string { return s }
+	list("Folders", names, func(s string) string { return relativeURL(".", s+"/index.html") }, same)
+	list("Notes", notes, func(s string) string { return relativeURL(".", strings.TrimSuffix(s, ".md")+".html") },
+		func(s string) string { return strings.TrimSuffix(s, ".md") })
+	list("Files", others, func(s string) string { return relativeURL(".", s) }, same)
+	return b.String()
+}
+
+// renderMarkdown renders the body of a note with a conservative subset of markdown:
+// headings, paragraphs, lists, block quotes, rules, code blocks, links and images
+func (r *standaloneRenderer) renderMarkdown(file string, content []byte) string {
+	var b strings.Builder
+	var paragraph []string
+	listTag := ""
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + strings.Join(paragraph, "<br>\n") + "</p>\n")
+			paragraph = nil
+		}
+		if listTag != "" {
+			b.WriteString("</" + listTag + ">\n")
+			listTag = ""
+		}
+	}
+
+	lines := splitLines(content)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+
+		if marker, info, ok := parseFence(lines[i]); ok {
+			flush()
+			var code strings.Builder
+			for i+1 < len(lines) {
+				i++
+				if closesFence(lines[i], marker) {
+					break
+				}
+				code.WriteString(lines[i])
+			}
+			class := ""
+			if lang := fenceLanguage(info); lang != "" {
+				class = " class=\"language-" + html.EscapeString(lang) + "\""
+			}
+			fmt.Fprintf(&b, "<pre><code%s>%s</code></pre>\n", class, html.EscapeString(code.String()))
+			continue
+		}
+
+		switch {
+		case isBlankLine(line):
+			flush()
+		case mdRuleRe.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+		case mdHeadingRe.MatchString(line):
+			flush()
+			m := mdHeadingRe.FindStringSubmatch(line)
+			level := len(m[1])
+			fmt.Fprintf(&b, "<h%d id=\"%s\">%s</h%d>\n", level, headingID(m[2]), r.renderInline(file, m[2]), level)
+		case mdQuoteRe.MatchString(line):
+			flush()
+			fmt.Fprintf(&b, "<blockquote>%s</blockquote>\n", r.renderInline(file, mdQuoteRe.FindStringSubmatch(line)[1]))
+		case mdListRe.MatchString(line):
+			m := mdListRe.FindStringSubmatch(line)
+			tag := "ol"
+			if m[1] != "" {
+				tag = "ul"
+			}
+			if len(paragraph) > 0 || listTag != tag {
+				flush()
+				b.WriteString("<" + tag + ">\n")
+				listTag = tag
+			}
+			item := m[2]
+			if task := mdTaskRe.FindStringSubmatch(item); task != nil {
+				box := "☐ "
+				if task[1] != " " {
+					box = "☑ "
+				}
+				item = item[len(task[0]):]
+				b.WriteString("<li>" + box + r.renderInline(file, item) + "</li>\n")
+				continue
+			}
+			b.WriteString("<li>" + r.renderInline(file, item) + "</li>\n")
+		default:
+			if listTag != "" {
+				flush()
+			}
+			paragraph = append(paragraph, r.renderInline(file, strings.TrimSpace(line)))
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// renderInline renders the inline markdown of a line, escaping everything else
+func (r *standaloneRenderer) renderInline(file, text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdInlineRe.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		last = m[1]
+		group := func(n int) string {
+			if m[2*n] < 0 {
+				return ""
+			}
+			return text[m[2*n]:m[2*n+1]]
+		}
+
+		switch {
+		case m[2] >= 0: // Code span
+			b.WriteString("<code>" + html.EscapeString(group(1)) + "</code>")
+		case m[6] >= 0: // Wikilink or wiki embed
+			target, fragment := group(3), ""
+			if i := strings.Index(target, "#"); i >= 0 {
+				target, fragment = target[:i], target[i:]
+			}
+			link := fileLink{embed: group(2) != "", wiki: true, target: target, fragment: fragment, text: group(4)}
+			b.WriteString(r.renderLink(file, link))
+		case m[12] >= 0: // Markdown link or image, its target optionally between angle brackets
+			target, fragment := group(7)+group(8), ""
+			if i := strings.Index(target, "#"); i >= 0 {
+				target, fragment = target[:i], target[i:]
+			}
+			link := fileLink{embed: group(5) != "", angle: m[14] >= 0, target: target, fragment: fragment, text: group(6)}
+			b.WriteString(r.renderLink(file, link))
+		case m[18] >= 0: // Autolink
+			url := html.EscapeString(group(9))
+			b.WriteString("<a href=\"" + url + "\">" + url + "</a>")
+		case m[20] >= 0:
+			b.WriteString("<strong>" + html.EscapeString(group(10)) + "</strong>")
+		case m[22] >= 0:
+			b.WriteString("<em>" + html.EscapeString(group(11)) + "</em>")
+		case m[24] >= 0:
+			b.WriteString("<em>" + html.EscapeString(group(12)) + "</em>")
+		}
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+// renderLink renders a link or embed with a relative href to the exported file it points to
+// Links to notes point at their HTML page; unresolved links are highlighted and reported
+func (r *standaloneRenderer) renderLink(file string, link fileLink) string {
+	text := link.displayText()
+	if link.wiki && link.text == "" {
+		text = path.Base(link.target)
+	}
+	if link.target == "" && link.fragment != "" {
+		// Link to a heading of the same note
+		if link.text == "" {
+			text = strings.TrimPrefix(link.fragment, "#")
+		}
+		return "<a href=\"#" + headingID(link.fragment[1:]) + "\">" + html.EscapeString(text) + "</a>"
+	}
+
+	href := link.target
+	if !isExternalURL(link.target) {
+		resolved, ok := r.resolve(file, link)
+		if !ok {
+			r.c.console.warnf("%s: unresolved link %q in the standalone export", filepath.Join(r.folder, file), link.target)
+			return "<span class=\"broken\" title=\"Unresolved link\">" + html.EscapeString(text) + "</span>"
+		}
+		if strings.HasSuffix(resolved, ".md") {
+			resolved = strings.TrimSuffix(resolved, ".md") + ".html"
+		}
+		href = relativeURL(path.Dir(file), resolved)
+		if strings.HasPrefix(link.fragment, "#") && !strings.HasPrefix(link.fragment, "#^") {
+			href += "#" + headingID(link.fragment[1:])
+		}
+	}
+
+	href = html.EscapeString(href)
+	if link.embed && imageExtensions[strings.ToLower(strings.TrimPrefix(path.Ext(link.target), "."))] {
+		return "<img src=\"" + href + "\" alt=\"" + html.EscapeString(text) + "\">"
+	}
+	return "<a href=\"" + href + "\">" + html.EscapeString(text) + "</a>"
+}
+
+// resolve finds the exported file a link points to
+// Wikilinks may omit the .md extension of notes
+func (r *standaloneRenderer) resolve(file string, link fileLink) (string, bool) {
+	noteDir := path.Dir(file)
+	target := link.decodedTarget()
+	if strings.HasPrefix(target, "/") {
+		return resolveFile(r.files, ".", strings.TrimPrefix(target, "/"))
+	}
+	if resolved, ok := resolveFile(r.files, noteDir, target); ok {
+		return resolved, true
+	}
+	if link.wiki && path.Ext(target) != ".md" {
+		return resolveFile(r.files, noteDir, target+".md")
+	}
+	return "", false
+}
+
+// headingID returns the id given to a heading, also used to link to it
+// Like Quartz, which uses github-slugger: lowercase, spaces become dashes, letters, digits, - and _ are kept
+// and other characters, such as punctuation and emoji, dropped; dashes are not merged, so A & B gives a--b
+func headingID(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,272 @@
+package o2q
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// commandExportNote exports a single note and the files it needs
+const commandExportNote = "export-note"
+
+// Policies of export-note for links to notes left out of the export
+const (
+	outsideLinksUnlink = "unlink" // Replace the link with its text
+	outsideLinksText   = "text"   // Keep the link as written, in a code span so it reads as plain text
+)
+
+// zipEpoch is the modification time of every entry of an export-note archive, so the same inputs give the same bytes
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// markdownLinkRe matches a whole markdown link or embed: [text](target) or [text](<target> "title")
+var markdownLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\((<[^>\n]+>|[^)\s]+)(?:\s+"[^"]*")?\)`)
+
+// noteReferences returns the vault-relative paths of the files a note links to or embeds, in the order of the note
+// Links in code are ignored, as are links to missing files and to the note itself
+func (c *converter) noteReferences(relPath string, content []byte) []string {
+	noteDir := path.Dir(relPath)
+	var refs []string
+	add := func(target string) {
+		if file, ok := c.resolveReference(noteDir, target); ok && file != relPath {
+			refs = append(refs, file)
+		}
+	}
+	mapOutsideCode(content, func(text string) string {
+		for _, parts := range noteWikiLinkRe.FindAllStringSubmatch(text, -1) {
+			add(parts[2])
+		}
+		for _, parts := range markdownLinkRe.FindAllStringSubmatch(text, -1) {
+			link := fileLink{target: strings.Trim(parts[3], "<>"), angle: strings.HasPrefix(parts[3], "<")}
+			if !isExternalURL(link.target) {
+				target, _, _ := strings.Cut(link.decodedTarget(), "#")
+				add(target)
+			}
+		}
+		return text
+	})
+	return refs
+}
+
+// resolveReference finds the vault file a link target points to, trying the implied .md of a note
+// Links to Excalidraw drawings point to their exported .svg, the only file of the drawing that is published
+func (c *converter) resolveReference(noteDir, target string) (string, bool) {
+	if target == "" {
+		return "", false
+	}
+	if strings.HasSuffix(target, ".excalidraw.md") {
+		target = strings.TrimSuffix(target, ".md")
+	}
+	candidates := []string{target}
+	if strings.HasSuffix(target, ".excalidraw") {
+		candidates = []string{target + ".svg"}
+	} else if path.Ext(target) == "" {
+		candidates = append(candidates, target+".md")
+	}
+	for _, candidate := range candidates {
+		if file, ok := c.resolveLink(noteDir, candidate); ok {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// findExportRoot returns the vault-relative path of the note given to export-note
+// The note is a path to the file, or a path relative to the vault, with or without .md; a bare name is looked up like a wikilink
+func (c *converter) findExportRoot(note string) (string, error) {
+	if info, err := os.Stat(note); err == nil && !info.IsDir() {
+		if abs, err := filepath.Abs(note); err == nil {
+			if vault, err := filepath.Abs(c.obsidianFolder); err == nil {
+				if rel, err := filepath.Rel(vault, abs); err == nil && filepath.IsLocal(rel) {
+					note = rel
+				}
+			}
+		}
+	}
+	file, ok := c.resolveReference(".", filepath.ToSlash(note))
+	if !ok {
+		return "", fmt.Errorf("note %q not found in the vault, or excluded from publishing", note)
+	}
+	if !hasExt(file, ".md") {
+		return "", fmt.Errorf("%s is not a note", file)
+	}
+	return file, nil
+}
+
+// selectExport works out the files export-note writes: the note, the notes it links to or embeds
+// up to --note-depth links away, and every other file these notes link to or embed
+func (c *converter) selectExport(note string) error {
+	root, err := c.findExportRoot(note)
+	if err != nil {
+		return err
+	}
+
+	c.exportFiles = map[string]bool{root: true}
+	notes, attachments := 1, 0
+	depth := map[string]int{root: 0}
+	queue := []string{root}
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		content, err := c.vault.ReadFile(filepath.Join(c.obsidianFolder, filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("failed to read markdown file: %v", err)
+		}
+		for _, ref := range c.noteReferences(file, content) {
+			if c.exportFiles[ref] {
+				continue
+			}
+			if !hasExt(ref, ".md") {
+				c.exportFiles[ref] = true
+				attachments++
+				continue
+			}
+			if depth[file] >= c.opts.noteDepth {
+				continue
+			}
+			c.exportFiles[ref] = true
+			depth[ref] = depth[file] + 1
+			notes++
+			queue = append(queue, ref)
+		}
+	}
+	c.console.infof("Exporting %s with %d notes and %d other files", root, notes, attachments)
+	return nil
+}
+
+// inExport checks if a vault file or folder is part of the export-note selection; everything is without export-note
+func (c *converter) inExport(relPath string, dir bool) bool {
+	if c.exportFiles == nil {
+		return true
+	}
+	relPath = filepath.ToSlash(relPath)
+	if !dir {
+		return c.exportFiles[relPath]
+	}
+	for file := range c.exportFiles {
+		if strings.HasPrefix(file, relPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteOutsideLinks applies the --outside-links policy to links to notes left out of an export-note
+// Links to missing notes are left alone, as they are already broken in the vault
+func (c *converter) rewriteOutsideLinks(src string, content []byte) []byte {
+	if c.exportFiles == nil {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	outside := func(target string) bool {
+		file, ok := c.resolveReference(noteDir, target)
+		return ok && !c.exportFiles[file]
+	}
+	replace := func(match, text string) string {
+		if c.opts.outsideLinks == outsideLinksText {
+			return "`" + match + "`"
+		}
+		return text
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			if !outside(parts[2]) {
+				return match
+			}
+			return replace(match, fileLink{wiki: true, target: parts[2], text: parts[4]}.displayText())
+		})
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownLinkRe.FindStringSubmatch(match)
+			link := fileLink{target: strings.Trim(parts[3], "<>"), angle: strings.HasPrefix(parts[3], "<"), text: parts[2]}
+			if isExternalURL(link.target) {
+				return match
+			}
+			target, _, _ := strings.Cut(link.decodedTarget(), "#")
+			if !outside(target) {
+				return match
+			}
+			return replace(match, link.displayText())
+		})
+	})
+}
+
+// runExportNote exports a note, and the files it needs, to a folder or to a .zip archive
+// Returns the exit code of the export
+func runExportNote(log *consoleLogger, opts options, cfg config, vault, out string) int {
+	if !hasExt(out, ".zip") {
+		return runConversion(log, opts, cfg, vaultSource{folder: vault, sub: "."}, out, commandExportNote, nil)
+	}
+
+	// An archive is built from an export to a temporary folder
+	tmp, err := os.MkdirTemp("", "obsidian-to-quartz-*")
+	if err != nil {
+		log.errorf("failed to create temporary folder: %v", err)
+		return exitFailure
+	}
+	defer os.RemoveAll(tmp)
+	if code := runConversion(log, opts, cfg, vaultSource{folder: vault, sub: "."}, tmp, commandExportNote, nil); code != exitSuccess {
+		return code
+	}
+	if err := zipFolder(tmp, out); err != nil {
+		log.errorf("%v", err)
+		return exitFailure
+	}
+	log.infof("Wrote %s", out)
+	return exitSuccess
+}
+
+// zipFolder writes the files of a folder to a zip archive, in sorted order and with a fixed time,
+// so the same files always give the same archive
+func zipFolder(folder, dest string) error {
+	var files []string
+	err := filepath.Walk(folder, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(folder, p)
+			if err != nil {
+				return fmt.Errorf("failed to get relative path: %v", err)
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list export folder: %v", err)
+	}
+	sort.Strings(files)
+
+	_, err = writeFileAtomic(dest, 0644, func(w io.Writer) (int64, error) {
+		zw := zip.NewWriter(w)
+		for _, file := range files {
+			header := &zip.FileHeader{Name: file, Method: zip.Deflate, Modified: zipEpoch}
+			header.SetMode(0644)
+			entry, err := zw.CreateHeader(header)
+			if err != nil {
+				return 0, err
+			}
+			content, err := os.ReadFile(filepath.Join(folder, filepath.FromSlash(file)))
+			if err != nil {
+				return 0, err
+			}
+			if _, err := entry.Write(content); err != nil {
+				return 0, err
+			}
+		}
+		return 0, zw.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive %s: %v", dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,579 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A filter expression selects the notes to publish, combining conditions on their path, tags, frontmatter, date and size
+//
+//	(path:Blog/** OR tag:public) AND NOT frontmatter.status=wip AND modified>2023-01-01
+//
+// NOT binds tighter than AND, which binds tighter than OR; parentheses group conditions
+type filterExpr interface {
+	// eval tells if a note matches, recording the steps in trace if it is not nil
+	eval(n *filterNote, trace *filterTrace) bool
+}
+
+// filterNote is a note being matched, its content read only if a condition needs it
+type filterNote struct {
+	src     string // Path of the note
+	vault   *vaultFS
+	relPath string // Vault-relative path, with forward slashes
+	info    os.FileInfo
+	loaded  bool
+	values  map[string]interface{} // Frontmatter; empty if it is invalid
+	tags    []string
+}
+
+// load reads the frontmatter and tags of the note
+func (n *filterNote) load() {
+	if n.loaded {
+		return
+	}
+	n.loaded = true
+	n.values = map[string]interface{}{}
+	content, err := n.vault.ReadFile(n.src)
+	if err != nil {
+		return
+	}
+	if values, err := parseFrontmatter(content); err == nil {
+		n.values = values
+	}
+	n.tags = noteTags(n.values, content)
+}
+
+// filterTrace records the evaluation of an expression, as indented lines
+type filterTrace struct {
+	depth int
+	lines []string
+}
+
+// step records the result of a condition or operator
+func (t *filterTrace) step(text string, result bool, detail string) {
+	if t == nil {
+		return
+	}
+	line := fmt.Sprintf("%s%s → %t", strings.Repeat("  ", t.depth), text, result)
+	if detail != "" {
+		line += " (" + detail + ")"
+	}
+	t.lines = append(t.lines, line)
+}
+
+// nest records the operands of an operator one level deeper; the operator line is written first, its result filled in after
+func (t *filterTrace) nest(text string, fn func() bool) bool {
+	if t == nil {
+		return fn()
+	}
+	i := len(t.lines)
+	t.lines = append(t.lines, "")
+	t.depth++
+	result := fn()
+	t.depth--
+	t.lines[i] = fmt.Sprintf("%s%s → %t", strings.Repeat("  ", t.depth), text, result)
+	return result
+}
+
+type filterAnd struct{ operands []filterExpr }
+type filterOr struct{ operands []filterExpr }
+type filterNot struct{ operand filterExpr }
+
+func (e filterAnd) eval(n *filterNote, trace *filterTrace) bool {
+	return trace.nest("AND", func() bool {
+		for _, operand := range e.operands {
+			if !operand.eval(n, trace) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (e filterOr) eval(n *filterNote, trace *filterTrace) bool {
+	return trace.nest("OR", func() bool {
+		for _, operand := range e.operands {
+			if operand.eval(n, trace) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (e filterNot) eval(n *filterNote, trace *filterTrace) bool {
+	return trace.nest("NOT", func() bool {
+		return !e.operand.eval(n, trace)
+	})
+}
+
+// filterCondition is a single condition, such as tag:public or size>1MB
+type filterCondition struct {
+	text  string // Condition as written
+	field string // path, tag, ext, frontmatter.<key>, modified or size
+	op    string // ":" for path, tag and ext; "", "=" or "!=" for frontmatter; a comparison for modified and size
+	value string
+	date  time.Time
+	size  int64
+}
+
+func (e filterCondition) eval(n *filterNote, trace *filterTrace) bool {
+	result, detail := e.match(n)
+	trace.step(e.text, result, detail)
+	return result
+}
+
+// match tells if a note meets the condition, with what was compared
+func (e filterCondition) match(n *filterNote) (bool, string) {
+	switch e.field {
+	case "path":
+		return globMatch(e.value, n.relPath), n.relPath
+	case "ext":
+		ext := strings.TrimPrefix(path.Ext(n.relPath), ".")
+		return strings.EqualFold(ext, e.value), "ext " + ext
+	case "tag":
+		n.load()
+		tag := normalizeTag(e.value)
+		for _, t := range n.tags {
+			if t == tag || strings.HasPrefix(t, tag+"/") {
+				return true, "#" + t
+			}
+		}
+		if len(n.tags) == 0 {
+			return false, "no tags"
+		}
+		return false, "#" + strings.Join(n.tags, ", #")
+	case "modified":
+		modified := n.info.ModTime()
+		return compare(e.op, modified.Compare(e.date)), modified.Format(time.DateTime)
+	case "size":
+		return compare(e.op, int(min(max(n.info.Size()-e.size, -1), 1))), formatSize(n.info.Size())
+	}
+
+	n.load()
+	key := strings.TrimPrefix(e.field, "frontmatter.")
+	value, ok := n.values[key]
+	if e.op == "" {
+		if !ok {
+			return false, key + " not set"
+		}
+		return true, key + " set"
+	}
+	values := frontmatterStrings(value)
+	found := false
+	for _, v := range values {
+		if v == e.value {
+			found = true
+		}
+	}
+	detail := key + " not set"
+	if ok {
+		detail = key + "=" + strings.Join(values, ", ")
+	}
+	return found == (e.op == "="), detail
+}
+
+// compare tells if the result of a comparison, -1, 0 or 1, satisfies an operator
+func compare(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "=":
+		return cmp == 0
+	}
+	return cmp != 0
+}
+
+// frontmatterStrings returns a frontmatter value as strings: a list gives one string per item, a date is written as YYYY-MM-DD
+func frontmatterStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, frontmatterStrings(item)...)
+		}
+		return values
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return []string{v.Format(time.DateOnly)}
+		}
+		return []string{v.Format(time.RFC3339)}
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+// globMatch checks if a vault path matches a glob pattern, where ** matches any number of folders
+// A pattern without / matches the file name too, so *.md matches every note
+func globMatch(pattern, relPath string) bool {
+	if !strings.Contains(pattern, "/") {
+		if ok, _ := path.Match(pattern, path.Base(relPath)); ok {
+			return true
+		}
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// filterSyntaxError is an error in a filter expression, pointing at the offending token
+type filterSyntaxError struct {
+	expr    string
+	pos     int // Byte offset of the offending token
+	message string
+}
+
+func (e *filterSyntaxError) Error() string {
+	column := len([]rune(e.expr[:e.pos])) + 1
+	return fmt.Sprintf("column %d: %s\n  %s\n  %s^", column, e.message, e.expr, strings.Repeat(" ", column-1))
+}
+
+// filterToken is a word, a parenthesis or an operator of a filter expression
+type filterToken struct {
+	text string
+	pos  int
+}
+
+// tokenizeFilter splits a filter expression into parentheses and words
+// Double quotes let a word hold spaces and parentheses: path:"Daily Notes/**"
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		switch b := expr[i]; {
+		case isFilterSpace(b):
+			i++
+		case b == '(' || b == ')':
+			tokens = append(tokens, filterToken{text: string(b), pos: i})
+			i++
+		default:
+			start := i
+			var word strings.Builder
+			for i < len(expr) && !isFilterSpace(expr[i]) && expr[i] != '(' && expr[i] != ')' {
+				if expr[i] != '"' {
+					word.WriteByte(expr[i])
+					i++
+					continue
+				}
+				end := strings.IndexByte(expr[i+1:], '"')
+				if end < 0 {
+					return nil, &filterSyntaxError{expr: expr, pos: i, message: "unterminated quote"}
+				}
+				word.WriteString(expr[i+1 : i+1+end])
+				i += end + 2
+			}
+			tokens = append(tokens, filterToken{text: word.String(), pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// isFilterSpace checks if a byte separates the words of a filter expression
+func isFilterSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// filterParser parses a filter expression by recursive descent
+type filterParser struct {
+	expr   string
+	tokens []filterToken
+	next   int
+}
+
+// parseFilter parses a filter expression
+func parseFilter(expr string) (filterExpr, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{expr: expr, tokens: tokens}
+	if len(tokens) == 0 {
+		return nil, p.errorf("empty filter")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		if p.peek() == ")" {
+			return nil, p.errorf("unexpected ), no ( to close")
+		}
+		return nil, p.errorf("expected AND or OR before %q", p.peek())
+	}
+	return e, nil
+}
+
+// peek returns the next token, or "" at the end of the expression
+func (p *filterParser) peek() string {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next].text
+	}
+	return ""
+}
+
+// keyword checks if the next token is an operator, whatever its case, and consumes it
+func (p *filterParser) keyword(name string) bool {
+	if p.next < len(p.tokens) && strings.EqualFold(p.tokens[p.next].text, name) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// errorf returns a syntax error pointing at the next token, or at the end of the expression
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	pos := len(p.expr)
+	if p.next < len(p.tokens) {
+		pos = p.tokens[p.next].pos
+	}
+	return &filterSyntaxError{expr: p.expr, pos: pos, message: fmt.Sprintf(format, args...)}
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := []filterExpr{e}
+	for p.keyword("OR") {
+		if e, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return filterOr{operands}, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	e, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	operands := []filterExpr{e}
+	for p.keyword("AND") {
+		if e, err = p.parseNot(); err != nil {
+			return nil, err
+		}
+		operands = append(operands, e)
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return filterAnd{operands}, nil
+}
+
+func (p *filterParser) parseNot() (filterExpr, error) {
+	if p.keyword("NOT") {
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{e}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterExpr, error) {
+	switch token := p.peek(); {
+	case token == "":
+		return nil, p.errorf("expected a condition, the expression ends here")
+	case token == "(":
+		p.next++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			if p.peek() == "" {
+				return nil, p.errorf("expected ) to close the ( of column %d", p.openingColumn())
+			}
+			return nil, p.errorf("expected AND, OR or ) before %q", p.peek())
+		}
+		p.next++
+		return e, nil
+	case token == ")":
+		return nil, p.errorf("expected a condition before )")
+	case strings.EqualFold(token, "AND") || strings.EqualFold(token, "OR"):
+		return nil, p.errorf("expected a condition before %s", strings.ToUpper(token))
+	}
+
+	e, err := parseFilterCondition(p.tokens[p.next].text)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.next++
+	return e, nil
+}
+
+// openingColumn returns the column of the last unclosed ( of the expression
+func (p *filterParser) openingColumn() int {
+	var open []int
+	for _, token := range p.tokens {
+		switch token.text {
+		case "(":
+			open = append(open, token.pos)
+		case ")":
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	if len(open) == 0 {
+		return 1
+	}
+	return len([]rune(p.expr[:open[len(open)-1]])) + 1
+}
+
+// parseFilterCondition parses a single condition:
+//   - path:GLOB, tag:NAME, ext:EXT
+//   - frontmatter.KEY, frontmatter.KEY=VALUE, frontmatter.KEY!=VALUE
+//   - modified>DATE, size<=SIZE, with <, <=, >, >=, = or !=
+func parseFilterCondition(text string) (filterCondition, error) {
+	e := filterCondition{text: text}
+	end := strings.IndexAny(text, ":=!<>")
+	if end < 0 {
+		if strings.HasPrefix(text, "frontmatter.") && len(text) > len("frontmatter.") {
+			e.field = text
+			return e, nil
+		}
+		return e, fmt.Errorf("unknown condition %q, expected path:, tag:, ext:, frontmatter.KEY, modified or size", text)
+	}
+	e.field = text[:end]
+	rest := text[end:]
+	for _, op := range []string{"!=", "<=", ">=", ":", "=", "<", ">"} {
+		if strings.HasPrefix(rest, op) {
+			e.op, e.value = op, rest[len(op):]
+			break
+		}
+	}
+	if e.op == "" {
+		return e, fmt.Errorf("invalid operator in %q", text)
+	}
+
+	switch {
+	case e.field == "path" || e.field == "tag" || e.field == "ext":
+		if e.op != ":" {
+			return e, fmt.Errorf("%s takes a value after a colon, as in %s:VALUE", e.field, e.field)
+		}
+		if e.field == "ext" {
+			e.value = strings.TrimPrefix(e.value, ".")
+		}
+		if e.field == "path" {
+			if _, err := path.Match(e.value, ""); err != nil {
+				return e, fmt.Errorf("invalid glob pattern %q", e.value)
+			}
+		}
+	case strings.HasPrefix(e.field, "frontmatter.") && len(e.field) > len("frontmatter."):
+		if e.op != "=" && e.op != "!=" {
+			return e, fmt.Errorf("frontmatter values are compared with = or !=, as in %s=VALUE", e.field)
+		}
+	case e.field == "modified":
+		if e.op == ":" {
+			return e, fmt.Errorf("modified is compared with <, <=, >, >=, = or !=, as in modified>2023-01-01")
+		}
+		date, err := parseFilterDate(e.value)
+		if err != nil {
+			return e, err
+		}
+		e.date = date
+	case e.field == "size":
+		if e.op == ":" {
+			return e, fmt.Errorf("size is compared with <, <=, >, >=, = or !=, as in size<1MB")
+		}
+		size, err := parseSize(e.value)
+		if err != nil {
+			return e, err
+		}
+		e.size = size
+	default:
+		return e, fmt.Errorf("unknown field %q, expected path, tag, ext, frontmatter.KEY, modified or size", e.field)
+	}
+	if e.value == "" {
+		return e, fmt.Errorf("missing value in %q", text)
+	}
+	return e, nil
+}
+
+// parseFilterDate parses a date of a filter expression, in local time: 2023-01-01 or 2023-01-01T12:00
+func parseFilterDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DDTHH:MM", value)
+}
+
+// filtered tells if a note is left out by --filter
+// Only notes are filtered; attachments and other files are published when notes embed them
+func (c *converter) filtered(relPath string) bool {
+	if c.filter == nil || !hasExt(relPath, ".md") {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	if result, ok := c.filterResults[relPath]; ok {
+		return !result
+	}
+
+	src := filepath.Join(c.obsidianFolder, filepath.FromSlash(relPath))
+	info, err := c.vault.Stat(src)
+	if err != nil {
+		return false
+	}
+	n := &filterNote{src: src, vault: c.vault, relPath: relPath, info: info}
+	var trace *filterTrace
+	if c.opts.explainFilter != "" && path.Clean(filepath.ToSlash(c.opts.explainFilter)) == relPath {
+		trace = &filterTrace{}
+	}
+	result := c.filter.eval(n, trace)
+	if trace != nil {
+		c.console.infof("Filter evaluation for %s:", relPath)
+		for _, line := range trace.lines {
+			c.console.infof("  %s", line)
+		}
+		verdict := "published"
+		if !result {
+			verdict = "not published"
+		}
+		c.console.infof("  → %s", verdict)
+	}
+
+	if c.filterResults == nil {
+		c.filterResults = make(map[string]bool)
+	}
+	c.filterResults[relPath] = result
+	return !result
+}
@@ -0,0 +1,141 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// filterTree writes a parsed filter expression with its grouping made explicit: (OR a (AND b (NOT c)))
+func filterTree(e filterExpr) string {
+	operands := func(op string, operands []filterExpr) string {
+		parts := []string{op}
+		for _, operand := range operands {
+			parts = append(parts, filterTree(operand))
+		}
+		return "(" + strings.Join(parts, " ") + ")"
+	}
+	switch e := e.(type) {
+	case filterOr:
+		return operands("OR", e.operands)
+	case filterAnd:
+		return operands("AND", e.operands)
+	case filterNot:
+		return "(NOT " + filterTree(e.operand) + ")"
+	case filterCondition:
+		return e.text
+	}
+	return "?"
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"tag:public", "tag:public"},
+		{"path:Blog/** OR tag:public AND NOT frontmatter.draft", "(OR path:Blog/** (AND tag:public (NOT frontmatter.draft)))"},
+		{"(path:Blog/** OR tag:public) AND NOT frontmatter.status=wip AND modified>2023-01-01",
+			"(AND (OR path:Blog/** tag:public) (NOT frontmatter.status=wip) modified>2023-01-01)"},
+		{"NOT NOT ext:md", "(NOT (NOT ext:md))"},
+		{"NOT (tag:a OR tag:b)", "(NOT (OR tag:a tag:b))"},
+		{"tag:a or tag:b and not tag:c", "(OR tag:a (AND tag:b (NOT tag:c)))"},
+		{`path:"Daily Notes/**" AND size<=1MB`, "(AND path:Daily Notes/** size<=1MB)"},
+		{"frontmatter.status!=draft", "frontmatter.status!=draft"},
+	}
+	for _, tt := range tests {
+		e, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := filterTree(e); got != tt.want {
+			t.Errorf("parseFilter(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // Start of the error
+	}{
+		{"", "column 1: empty filter"},
+		{"tag:a AND", "column 10: expected a condition, the expression ends here"},
+		{"(tag:a OR tag:b", "column 16: expected ) to close the ( of column 1"},
+		{"tag:a)", "column 6: unexpected ), no ( to close"},
+		{"tag:a tag:b", "column 7: expected AND or OR before"},
+		{"OR tag:a", "column 1: expected a condition before OR"},
+		{`path:"Daily Notes/**`, "column 6: unterminated quote"},
+		{"title:Home", `column 1: unknown field "title"`},
+		{"draft", `column 1: unknown condition "draft"`},
+		{"tag=public", "column 1: tag takes a value after a colon"},
+		{"frontmatter.status:wip", "column 1: frontmatter values are compared with = or !="},
+		{"modified>yesterday", `column 1: invalid date "yesterday"`},
+		{"size<big", "column 1: invalid size"},
+		{"tag:", `column 1: missing value in "tag:"`},
+		{"path:[a", `column 1: invalid glob pattern "[a"`},
+	}
+	for _, tt := range tests {
+		_, err := parseFilter(tt.expr)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("parseFilter(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestFilterEval(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Blog/Post.md":     "---\ntags: [public]\nstatus: done\n---\nPost\n",
+		"Blog/Draft.md":    "---\nstatus: wip\n---\nDraft #public\n",
+		"Work/Meeting.md":  "Meeting #work/team\n",
+		"Work/Big Note.md": strings.Repeat("word ", 1000),
+	})
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(vault, "Work", "Meeting.md"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := openVault(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []string // Notes matching the expression
+	}{
+		{"path:Blog/**", []string{"Blog/Draft.md", "Blog/Post.md"}},
+		{"tag:public", []string{"Blog/Draft.md", "Blog/Post.md"}},
+		{"tag:work", []string{"Work/Meeting.md"}},
+		{"tag:public AND NOT frontmatter.status=wip", []string{"Blog/Post.md"}},
+		{"frontmatter.status", []string{"Blog/Draft.md", "Blog/Post.md"}},
+		{"frontmatter.status!=wip", []string{"Blog/Post.md", "Work/Big Note.md", "Work/Meeting.md"}},
+		{"modified<2021-01-01", []string{"Work/Meeting.md"}},
+		{"size>1KB", []string{"Work/Big Note.md"}},
+		{"tag:work OR tag:public AND frontmatter.status=done", []string{"Blog/Post.md", "Work/Meeting.md"}},
+		{"(tag:work OR tag:public) AND frontmatter.status=done", []string{"Blog/Post.md"}},
+	}
+	notes := []string{"Blog/Draft.md", "Blog/Post.md", "Work/Big Note.md", "Work/Meeting.md"}
+	for _, tt := range tests {
+		e, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("parseFilter(%q) error = %v", tt.expr, err)
+		}
+		var got []string
+		for _, note := range notes {
+			src := filepath.Join(vault, filepath.FromSlash(note))
+			info, err := fsys.Stat(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.eval(&filterNote{src: src, vault: fsys, relPath: note, info: info}, nil) {
+				got = append(got, note)
+			}
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s matches %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package o2q
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites the expected content of the fixture vault with what a run publishes: go test -run TestFixture -update
+var update = flag.Bool("update", false, "replace testdata/expected-content with the content published from the fixture vault")
+
+// fixtureExpected is the content folder the fixture vault is published to with no options
+const fixtureExpected = "../../testdata/expected-content"
+
+// fixturePlan is the plan of a run over the fixture vault with no options, as --dry-run --verbose lists it
+const fixturePlan = "../../testdata/expected-plan.txt"
+
+// readTree returns the files of a folder, by slash-separated path relative to it
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFixture(t *testing.T) {
+	quartz := t.TempDir()
+	if code := runTestSync(t, fixtureVault, quartz); code != exitSuccess {
+		t.Fatalf("sync of the fixture vault exited with %d", code)
+	}
+	got := readTree(t, filepath.Join(quartz, "content"))
+
+	if *update {
+		if err := os.RemoveAll(fixtureExpected); err != nil {
+			t.Fatal(err)
+		}
+		for file, data := range got {
+			p := filepath.Join(fixtureExpected, filepath.FromSlash(file))
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	compareTree(t, got, readTree(t, fixtureExpected))
+}
+
+// compareTree reports the files published differently than expected, by slash-separated path
+func compareTree(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	for file, data := range want {
+		if _, ok := got[file]; !ok {
+			t.Errorf("%s: not published", file)
+		} else if got[file] != data {
+			t.Errorf("%s: published as\n%s\nwant\n%s", file, got[file], data)
+		}
+	}
+	for file := range got {
+		if _, ok := want[file]; !ok {
+			t.Errorf("%s: published, but not in the expected content", file)
+		}
+	}
+}
+
+// The fixture vault published for an older Quartz differs from the expected content by the files of testdata/quartz-<version>
+func TestFixtureQuartzCompat(t *testing.T) {
+	for _, version := range []string{"4.2", latestQuartz().version} {
+		t.Run(version, func(t *testing.T) {
+			quartz := t.TempDir()
+			if code := runTestSync(t, fixtureVault, quartz, "-quartz-compat", version); code != exitSuccess {
+				t.Fatalf("sync of the fixture vault exited with %d", code)
+			}
+			want := readTree(t, fixtureExpected)
+			if _, err := os.Stat("../../testdata/quartz-" + version); err == nil {
+				changed := 0
+				for file, data := range readTree(t, "../../testdata/quartz-"+version) {
+					if want[file] != data {
+						changed++
+					}
+					want[file] = data
+				}
+				if changed == 0 {
+					t.Errorf("testdata/quartz-%s holds no change from the expected content", version)
+				}
+			}
+			compareTree(t, readTree(t, filepath.Join(quartz, "content")), want)
+		})
+	}
+}
+
+// The plan is checked without writing anything: the dry run lists every decision of the plan, in order
+func TestFixturePlan(t *testing.T) {
+	opts := testOptions(t, "-dry-run", "-verbose")
+	sources := []vaultSource{{folder: fixtureVault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityVerbose, out: &out, err: &out}
+	quartz := t.TempDir()
+	if code := runSources(log, opts, config{}, sources, quartz, ""); code != exitSuccess {
+		t.Fatalf("dry run of the fixture vault exited with %d\n%s", code, out.String())
+	}
+	var plan strings.Builder
+	for _, line := range strings.SplitAfter(out.String(), "\n") {
+		if strings.HasPrefix(line, "  ") {
+			plan.WriteString(strings.TrimPrefix(line, "  "))
+		}
+	}
+
+	if *update {
+		if err := os.WriteFile(fixturePlan, []byte(plan.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(fixturePlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.String() != string(want) {
+		t.Errorf("plan of the fixture vault =\n%s\nwant\n%s", plan.String(), want)
+	}
+	if entries, err := os.ReadDir(quartz); err != nil || len(entries) > 0 {
+		t.Errorf("the dry run wrote %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,322 @@
+package o2q
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontmatterEdits drops, renames and sets frontmatter keys of published notes, in this order
+type frontmatterEdits struct {
+	drop   []string     // Glob patterns of keys to remove, such as banner*
+	rename []keyRename  // Keys to rename, in the order given
+	set    []keyDefault // Keys to add to notes that do not have them
+}
+
+// keyRename renames a frontmatter key: created=date
+type keyRename struct {
+	from, to string
+}
+
+// keyDefault is a frontmatter key added with a value when a note does not have it: draft=false
+type keyDefault struct {
+	key, value string // value is YAML, so false is a boolean and "false" a string
+}
+
+// parseFrontmatterEdits reads the --fm-drop, --fm-rename and --fm-set values; returns nil if there are none
+// --fm-drop values may hold several patterns separated by commas
+func parseFrontmatterEdits(drop, rename, set []string) (*frontmatterEdits, error) {
+	e := &frontmatterEdits{}
+	for _, value := range drop {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("--fm-drop: invalid pattern %q", pattern)
+			}
+			e.drop = append(e.drop, pattern)
+		}
+	}
+	for _, value := range rename {
+		from, to, ok := strings.Cut(value, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("--fm-rename: %q is not of the form old=new", value)
+		}
+		e.rename = append(e.rename, keyRename{from: from, to: to})
+	}
+	for _, value := range set {
+		key, val, ok := strings.Cut(value, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("--fm-set: %q is not of the form key=value", value)
+		}
+		var check map[string]interface{}
+		if err := yaml.Unmarshal([]byte(yamlString(key)+": "+val), &check); err != nil {
+			return nil, fmt.Errorf("--fm-set: %q is not a valid YAML value for %s", val, key)
+		}
+		e.set = append(e.set, keyDefault{key: key, value: val})
+	}
+	if len(e.drop) == 0 && len(e.rename) == 0 && len(e.set) == 0 {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// yamlString writes a string as a YAML scalar, quoted if needed
+func yamlString(key string) string {
+	out, err := yaml.Marshal(key)
+	if err != nil {
+		return key
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// dropped checks if a key matches a --fm-drop pattern
+func (e *frontmatterEdits) dropped(key string) bool {
+	for _, pattern := range e.drop {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// renamed returns the new name of a key, or the key itself
+func (e *frontmatterEdits) renamed(key string) string {
+	for _, r := range e.rename {
+		if r.from == key {
+			return r.to
+		}
+	}
+	return key
+}
+
+// editFrontmatter applies the frontmatter edits to a note
+// Lines of unaffected keys are kept as written, with their comments, quoting and indentation; only the frontmatter
+// at the very start of the note is edited, so a --- rule further down is never mistaken for it
+// A note without frontmatter only gets one if --fm-set adds keys to it
+func (c *converter) editFrontmatter(src string, content []byte) []byte {
+	e := c.frontmatterEdits
+	if e == nil {
+		return content
+	}
+
+	frontmatter, _, ok := splitFrontmatter(content)
+	if !ok {
+		var added strings.Builder
+		for _, d := range e.set {
+			fmt.Fprintf(&added, "%s: %s\n", yamlString(d.key), d.value)
+		}
+		if added.Len() == 0 {
+			return content
+		}
+		return append([]byte("---\n"+added.String()+"---\n"), content...)
+	}
+	if _, err := parseFrontmatter(content); err != nil {
+		c.frontmatterFallback(src, err, "frontmatter edits")
+		return content
+	}
+	lines, keys, ok := frontmatterKeys(frontmatter)
+	if !ok {
+		c.console.warnf("%s: frontmatter is not a block of keys, not edited", src)
+		return content
+	}
+
+	var out strings.Builder
+	changed := false
+	present := make(map[string]bool)
+	copied := 0 // Lines of the frontmatter already written
+	for _, k := range keys {
+		out.WriteString(strings.Join(lines[copied:k.start], ""))
+		copied = k.end
+
+		key := k.key.Value
+		if e.dropped(key) {
+			changed = true
+			continue
+		}
+		block := strings.Join(lines[k.start:k.end], "")
+		if to := e.renamed(key); to != key {
+			switch {
+			case present[to] || hasKey(keys, to) && e.renamed(to) == to && !e.dropped(to):
+				c.console.warnf("%s: frontmatter key %s not renamed to %s, which the note already has", src, key, to)
+			case k.key.Column != 1:
+				c.console.warnf("%s: frontmatter key %s not renamed, it does not start its line", src, key)
+			default:
+				block = yamlString(to) + block[keyLength(block, k.key):]
+				key = to
+				changed = true
+			}
+		}
+		present[key] = true
+		out.WriteString(block)
+	}
+	out.WriteString(strings.Join(lines[copied:], ""))
+
+	for _, d := range e.set {
+		if !present[d.key] {
+			present[d.key] = true
+			if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "%s: %s\n", yamlString(d.key), d.value)
+			changed = true
+		}
+	}
+	if !changed {
+		return content
+	}
+
+	return replaceFrontmatter(content, out.String())
+}
+
+// replaceFrontmatter replaces the frontmatter of a note, keeping its delimiters as they were
+// A note left without keys loses its frontmatter
+func replaceFrontmatter(content []byte, frontmatter string) []byte {
+	old, body, _ := splitFrontmatter(content)
+	if strings.TrimSpace(frontmatter) == "" {
+		return body
+	}
+	opening := content[:bytes.IndexByte(content, '\n')+1]
+	closing := content[len(opening)+len(old) : len(content)-len(body)]
+	result := append([]byte{}, opening...)
+	result = append(result, frontmatter...)
+	result = append(result, closing...)
+	return append(result, body...)
+}
+
+// frontmatterKey is a top-level key of a frontmatter block and the lines it spans
+type frontmatterKey struct {
+	key, value *yaml.Node
+	start, end int // Lines of the key and its value; comments and blank lines before the next key belong to it
+}
+
+// frontmatterKeys splits a frontmatter block into lines and finds its top-level keys, in order
+// ok is false if it is not a block of keys, such as {a: 1}, whose keys cannot be edited line by line
+func frontmatterKeys(frontmatter []byte) (lines []string, keys []frontmatterKey, ok bool) {
+	lines = splitLines(frontmatter)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(frontmatter, &doc); err != nil {
+		return nil, nil, false
+	}
+	if len(doc.Content) == 0 {
+		return lines, nil, true
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+		return nil, nil, false
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		start := root.Content[i].Line - 1
+		end := len(lines)
+		if i+2 < len(root.Content) {
+			end = root.Content[i+2].Line - 1
+		}
+		for end > start+1 && isYAMLFiller(lines[end-1]) {
+			end--
+		}
+		keys = append(keys, frontmatterKey{key: root.Content[i], value: root.Content[i+1], start: start, end: end})
+	}
+	return lines, keys, true
+}
+
+// frontmatterListItems finds the keys of a frontmatter with one of the names, and their items:
+// those of a list, or the value itself
+func frontmatterListItems(keys []frontmatterKey, names ...string) (found []frontmatterKey, items []string) {
+	for _, k := range keys {
+		if !slices.Contains(names, k.key.Value) {
+			continue
+		}
+		found = append(found, k)
+		switch k.value.Kind {
+		case yaml.SequenceNode:
+			for _, item := range k.value.Content {
+				items = append(items, item.Value)
+			}
+		case yaml.ScalarNode:
+			if k.value.Tag != "!!null" && k.value.Value != "" {
+				items = append(items, k.value.Value)
+			}
+		}
+	}
+	return found, items
+}
+
+// writeFrontmatterList writes items as a block list under name, in place of the first of the keys found,
+// which are all removed; the list is added at the end when no key was found, and with no items it is left out
+// lines and found come from frontmatterKeys; a note without frontmatter gets one
+func writeFrontmatterList(content []byte, lines []string, found []frontmatterKey, name string, items []string) []byte {
+	var list bytes.Buffer
+	if len(items) > 0 {
+		enc := yaml.NewEncoder(&list)
+		enc.SetIndent(2)
+		enc.Encode(map[string][]string{name: items})
+		enc.Close()
+	}
+	if _, _, ok := splitFrontmatter(content); !ok {
+		if list.Len() == 0 {
+			return content
+		}
+		return append([]byte("---\n"+list.String()+"---\n"), content...)
+	}
+
+	var out strings.Builder
+	copied := 0
+	for i, k := range found {
+		out.WriteString(strings.Join(lines[copied:k.start], ""))
+		copied = k.end
+		if i == 0 {
+			out.Write(list.Bytes())
+		}
+	}
+	out.WriteString(strings.Join(lines[copied:], ""))
+	if len(found) == 0 {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+		out.Write(list.Bytes())
+	}
+	return replaceFrontmatter(content, out.String())
+}
+
+// isYAMLFiller checks if a line is blank or a comment at the start of the line
+func isYAMLFiller(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(line, "#")
+}
+
+// hasKey checks if a frontmatter has a key
+func hasKey(keys []frontmatterKey, key string) bool {
+	for _, k := range keys {
+		if k.key.Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// keyLength returns the length of a key as written at the start of a line, with its quotes
+func keyLength(line string, keyNode *yaml.Node) int {
+	switch keyNode.Style {
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		quote := line[0]
+		for i := 1; i < len(line); i++ {
+			if line[i] == '\\' && quote == '"' {
+				i++
+			} else if line[i] == quote {
+				if quote == '\'' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	}
+	return len(keyNode.Value)
+}
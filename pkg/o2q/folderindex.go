@@ -0,0 +1,189 @@
+package o2q
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Orders of the notes on the pages of --generate-indexes
+const (
+	indexSortTitle = "title" // By title, ignoring case
+	indexSortDate  = "date"  // By date or created frontmatter, most recent first
+)
+
+// indexedNote is a published note listed on the generated index of its folder
+type indexedNote struct {
+	link  string // Content-relative path without .md, used as wikilink target
+	title string
+	date  string // YYYY-MM-DD, empty if unknown
+}
+
+// writeFolderIndexes writes an index.md listing the notes of each published folder that has none, then deletes the
+// generated indexes no longer needed
+// The notes are taken from the plan and listed if their page is in the content folder, so the notes left as they are
+// by --incremental are listed too; a folder whose own index.md is published keeps it, and its generated index is gone
+// as the note was written over it
+func (c *converter) writeFolderIndexes() error {
+	if !c.opts.generateIndexes {
+		return nil
+	}
+
+	folders := make(map[string][]indexedNote)
+	hasIndex := make(map[string]bool)
+	for _, f := range c.plan.files {
+		switch f.action {
+		case planPublish, planUnchangedGit, planUnchangedState, actionError:
+		default:
+			continue
+		}
+		if f.info == nil || f.info.IsDir() || !hasExt(f.relPath, ".md") || hasExt(f.relPath, ".excalidraw.md") {
+			continue
+		}
+		dest := strings.TrimSuffix(f.dest, filepath.Ext(f.dest)) + ".md"
+		dir := filepath.Dir(dest)
+		if strings.EqualFold(filepath.Base(dest), "index.md") {
+			hasIndex[dir] = true
+			continue
+		}
+		page := dest
+		if _, err := os.Stat(page); err != nil {
+			// A split note is published as a folder holding its index page and parts
+			page = filepath.Join(strings.TrimSuffix(dest, ".md"), "index.md")
+			if _, err := os.Stat(page); err != nil {
+				continue
+			}
+		}
+		folders[dir] = append(folders[dir], c.indexedNote(f.src, page))
+	}
+
+	var dirs []string
+	for dir := range folders {
+		if !hasIndex[dir] {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	written := make(map[string]bool)
+	for _, dir := range dirs {
+		file := filepath.Join(dir, "index.md")
+		if existing, err := os.ReadFile(file); err == nil && !isFolderIndex(existing) {
+			// An index.md written by hand in the content folder, outside the vault
+			c.console.progressf("Kept: %s (not generated by --generate-indexes)", c.paths.dest(file))
+			continue
+		}
+		written[file] = true
+		if err := c.writeGeneratedPage(file, c.folderIndex(dir, folders[dir]), isFolderIndex, "folder index"); err != nil {
+			return err
+		}
+	}
+	return c.pruneFolderIndexes(written)
+}
+
+// indexedNote returns how a published note is listed on the index of its folder: by its title frontmatter or its name
+func (c *converter) indexedNote(src, page string) indexedNote {
+	rel, _ := filepath.Rel(c.contentFolder, page)
+	note := indexedNote{link: strings.TrimSuffix(filepath.ToSlash(rel), ".md")}
+	note.title = strings.TrimSuffix(filepath.Base(page), ".md")
+	if note.title == "index" {
+		note.title = filepath.Base(filepath.Dir(page))
+	}
+
+	var values map[string]interface{}
+	if content, err := c.vault.ReadFile(src); err == nil {
+		values, _ = parseFrontmatter(content)
+	}
+	if title := frontmatterString(values, "title"); title != "" {
+		note.title = title
+	}
+	if c.opts.indexSort == indexSortDate {
+		note.date = noteDate(values, c.vault, src)
+	}
+	return note
+}
+
+// folderIndex returns the generated index of a folder, a list of links to its notes marked with generated: true
+//
+//	---
+//	title: "Projects"
+//	generated: true
+//	generated-by: obsidian-to-quartz
+//	---
+//
+//	- [[Projects/Roadmap|Roadmap]]
+func (c *converter) folderIndex(dir string, notes []indexedNote) []byte {
+	title := filepath.Base(dir)
+	if dir == c.contentFolder {
+		title = strings.TrimSuffix(filepath.Base(c.obsidianFolder), ".zip")
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		if c.opts.indexSort == indexSortDate && notes[i].date != notes[j].date {
+			return notes[i].date > notes[j].date
+		}
+		if a, b := strings.ToLower(notes[i].title), strings.ToLower(notes[j].title); a != b {
+			return a < b
+		}
+		return notes[i].link < notes[j].link
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\ngenerated: true\ngenerated-by: %s\n---\n\n", strconv.Quote(title), tagPageMarker)
+	for _, note := range notes {
+		fmt.Fprintf(&b, "- [[%s|%s]]", note.link, note.title)
+		if note.date != "" {
+			fmt.Fprintf(&b, " (%s)", note.date)
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+// isFolderIndex checks if a file was generated by --generate-indexes
+// Both keys are checked, as a note of the vault may well say generated: true
+func isFolderIndex(content []byte) bool {
+	values, err := parseFrontmatter(content)
+	generated, _ := frontmatterBool(values, "generated")
+	return err == nil && generated && frontmatterString(values, "generated-by") == tagPageMarker
+}
+
+// pruneFolderIndexes deletes the generated indexes not written by this run, whose folder has no published note left
+func (c *converter) pruneFolderIndexes(written map[string]bool) error {
+	removed := 0
+	err := filepath.WalkDir(c.contentFolder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "index.md" || written[p] {
+			return nil
+		}
+		if content, err := os.ReadFile(p); err != nil || !isFolderIndex(content) {
+			return nil
+		}
+		if err := c.checkContained(p); err != nil {
+			return fmt.Errorf("%s %w", p, err)
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		if dir := filepath.Dir(p); dir != c.contentFolder {
+			// Removing a folder that is not empty fails and is ignored
+			os.Remove(dir)
+		}
+		c.console.progressf("Deleted: %s (folder index no longer needed)", c.paths.dest(p))
+		removed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prune folder indexes: %v", err)
+	}
+	if removed > 0 {
+		c.console.infof("Removed %d generated folder indexes no longer needed", removed)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// folderMapSeparator separates the vault folder from its published name in --map entries: "03 - Projects=>projects"
+const folderMapSeparator = "=>"
+
+// folderMapping publishes a vault folder, and everything in it, under another path of the content folder
+type folderMapping struct {
+	source string // Vault-relative folder, with forward slashes
+	dest   string // Content-relative folder, with forward slashes; "." for the content folder itself
+}
+
+// parseFolderMap reads --map entries of the form "vault folder=>published folder"
+// A later entry for the same vault folder replaces an earlier one, so --map flags override the config file;
+// the result is sorted with the deepest folders first, so the most specific mapping applies
+func parseFolderMap(entries []string) ([]folderMapping, error) {
+	bySource := make(map[string]string)
+	for _, entry := range entries {
+		source, dest, ok := strings.Cut(entry, folderMapSeparator)
+		if !ok {
+			return nil, fmt.Errorf("%q has no %s between the vault folder and its published name", entry, folderMapSeparator)
+		}
+		source = path.Clean(filepath.ToSlash(strings.TrimSpace(source)))
+		dest = path.Clean(filepath.ToSlash(strings.TrimSpace(dest)))
+		if source == "." || !filepath.IsLocal(source) {
+			return nil, fmt.Errorf("%q: the vault folder must be a relative path inside the vault", entry)
+		}
+		if !filepath.IsLocal(dest) {
+			return nil, fmt.Errorf("%q: the published folder must be a relative path inside the content folder", entry)
+		}
+		bySource[source] = dest
+	}
+
+	mappings := make([]folderMapping, 0, len(bySource))
+	for source, dest := range bySource {
+		mappings = append(mappings, folderMapping{source: source, dest: dest})
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		if a, b := strings.Count(mappings[i].source, "/"), strings.Count(mappings[j].source, "/"); a != b {
+			return a > b
+		}
+		return mappings[i].source < mappings[j].source
+	})
+	return mappings, nil
+}
+
+// mapPath returns the path a vault file or folder is published to according to the folder map
+// The path and the vault folders of the map are compared in the Unicode form of published names
+func (c *converter) mapPath(relPath string) string {
+	slashPath := filepath.ToSlash(relPath)
+	for _, m := range c.folderMap {
+		source := c.normalizeName(m.source)
+		if slashPath == source {
+			return filepath.FromSlash(m.dest)
+		}
+		if rest, ok := strings.CutPrefix(slashPath, source+"/"); ok {
+			return filepath.FromSlash(path.Join(m.dest, rest))
+		}
+	}
+	return relPath
+}
+
+// planFolderMap records the destination of every file published under a mapped folder, so links to it are rewritten
+func (c *converter) planFolderMap() error {
+	if len(c.folderMap) == 0 {
+		return nil
+	}
+	if c.renames == nil {
+		c.renames = make(map[string]string)
+	}
+	c.mapped = make(map[string]bool)
+	counts := make(map[folderMapping]int)
+	err := c.walkEligible(func(relPath string) error {
+		file := filepath.ToSlash(relPath)
+		normalized := c.normalizeName(file)
+		for _, m := range c.folderMap {
+			source := c.normalizeName(m.source)
+			if strings.HasPrefix(normalized, source+"/") {
+				counts[m]++
+				if _, ok := c.renames[file]; !ok {
+					c.renames[file] = filepath.ToSlash(c.destRel(relPath))
+				}
+				c.mapped[file] = true
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, m := range c.folderMap {
+		if counts[m] == 0 {
+			c.console.warnf("--map: no published file in %s", m.source)
+			continue
+		}
+		c.console.infof("Publishing %d files of %s to %s", counts[m], m.source, m.dest)
+	}
+	return nil
+}
+
+// movedFolder checks if the folder map publishes a vault folder under another path
+func (c *converter) movedFolder(dir string) bool {
+	return len(c.folderMap) > 0 && c.mapPath(c.normalizeName(dir)) != c.normalizeName(dir)
+}
@@ -0,0 +1,67 @@
+package o2q
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// splitFrontmatter separates the YAML frontmatter block from the body of a note
+// The frontmatter is returned without its --- delimiters; ok is false if the note has none
+func splitFrontmatter(content []byte) (frontmatter, body []byte, ok bool) {
+	lines := splitLines(content)
+	if len(lines) == 0 || string(bytes.TrimRight([]byte(lines[0]), "\r\n")) != "---" {
+		return nil, content, false
+	}
+
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		trimmed := string(bytes.TrimRight([]byte(line), "\r\n"))
+		if trimmed == "---" || trimmed == "..." {
+			return content[len(lines[0]):offset], content[offset+len(line):], true
+		}
+		offset += len(line)
+	}
+	return nil, content, false
+}
+
+// parseFrontmatter parses the frontmatter of a note into a map
+// A note without frontmatter yields an empty map
+func parseFrontmatter(content []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	frontmatter, _, ok := splitFrontmatter(content)
+	if !ok {
+		return values, nil
+	}
+	if err := yaml.Unmarshal(frontmatter, &values); err != nil {
+		return make(map[string]interface{}), newFrontmatterError(frontmatter, err)
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	return values, nil
+}
+
+// frontmatterBool reads a boolean frontmatter value, accepting true/false strings
+func frontmatterBool(values map[string]interface{}, key string) (value, ok bool) {
+	switch v := values[key].(type) {
+	case bool:
+		return v, true
+	case string:
+		switch v {
+		case "true", "True", "TRUE", "yes":
+			return true, true
+		case "false", "False", "FALSE", "no":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// frontmatterString reads a string frontmatter value
+func frontmatterString(values map[string]interface{}, key string) string {
+	if v, ok := values[key].(string); ok {
+		return v
+	}
+	return ""
+}
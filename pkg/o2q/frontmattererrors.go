@@ -0,0 +1,173 @@
+package o2q
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// yamlLineRe extracts the line and message of a YAML parser error
+	yamlLineRe = regexp.MustCompile(`^yaml: (?:unmarshal errors:\s*)?line (\d+): ([^\n]*)`)
+	// tabIndentRe matches a line indented with a tab
+	tabIndentRe = regexp.MustCompile(`^ *\t`)
+	// unquotedColonRe matches a plain value holding ": ", as in title: Part 1: Intro
+	unquotedColonRe = regexp.MustCompile(`^\s*(?:-\s+)?[^\s:'"#-][^:]*:\s+[^\s'"\[{|>&*!#][^'"]*?(:)(?:\s|$)`)
+	// definedAtRe matches the line of the first occurrence of a duplicate key in a YAML parser message
+	definedAtRe = regexp.MustCompile(`already defined at line (\d+)`)
+	// reservedStartRe matches a plain value starting with a character YAML reserves, as in author: @me
+	reservedStartRe = regexp.MustCompile("^\\s*(?:-\\s+)?[^\\s:'\"#][^:]*:\\s+([@`])")
+)
+
+// frontmatterError is a YAML error in the frontmatter of a note, located in the note
+type frontmatterError struct {
+	line    int    // Line of the note, counting the opening ---; 0 if unknown
+	column  int    // Column of the line, 1-based; 0 if unknown
+	message string // Parser message without its location
+	snippet string // Offending line
+	hint    string // Likely cause and fix, if recognized
+}
+
+func (e *frontmatterError) Error() string {
+	location := ""
+	if e.line > 0 {
+		location = fmt.Sprintf(" at line %d", e.line)
+		if e.column > 0 {
+			location += fmt.Sprintf(", column %d", e.column)
+		}
+	}
+	return "invalid frontmatter" + location + ": " + e.message
+}
+
+// newFrontmatterError locates a YAML parser error in the frontmatter and looks for its likely cause
+// The parser often reports the line after the culprit, so the line before it and then the whole frontmatter are searched too
+func newFrontmatterError(frontmatter []byte, err error) *frontmatterError {
+	e := &frontmatterError{message: err.Error()}
+	lines := strings.Split(strings.TrimRight(string(frontmatter), "\r\n"), "\n")
+	if m := yamlLineRe.FindStringSubmatch(e.message); m != nil {
+		e.line, _ = strconv.Atoi(m[1])
+		e.message = m[2]
+	} else {
+		e.message = strings.TrimPrefix(e.message, "yaml: ")
+	}
+	// Lines of the message count from the start of the note too
+	e.message = definedAtRe.ReplaceAllStringFunc(e.message, func(match string) string {
+		n, _ := strconv.Atoi(definedAtRe.FindStringSubmatch(match)[1])
+		return "already defined at line " + strconv.Itoa(n+1)
+	})
+
+	candidates := []int{e.line - 1, e.line - 2}
+	for i := range lines {
+		candidates = append(candidates, i)
+	}
+	for _, i := range candidates {
+		if i < 0 || i >= len(lines) {
+			continue
+		}
+		if hint, column := frontmatterHint(strings.TrimRight(lines[i], "\r")); hint != "" {
+			e.line, e.column, e.hint = i+1, column, hint
+			break
+		}
+	}
+
+	if e.line > 0 && e.line <= len(lines) {
+		e.snippet = strings.TrimRight(lines[e.line-1], "\r")
+	}
+	if e.line > 0 {
+		e.line++
+	}
+	return e
+}
+
+// frontmatterHint recognizes the common causes of invalid frontmatter in a line
+// Returns a hint and the 1-based column of the culprit, or "" if the line looks fine
+func frontmatterHint(line string) (hint string, column int) {
+	if loc := tabIndentRe.FindStringIndex(line); loc != nil {
+		return "YAML does not allow tabs for indentation; indent with spaces", loc[1]
+	}
+	key, value, _ := strings.Cut(line, ":")
+	value = strings.TrimSpace(value)
+	quoted := key + ": \"" + strings.ReplaceAll(value, `"`, `\"`) + "\""
+	if m := unquotedColonRe.FindStringSubmatchIndex(line); m != nil {
+		return "quote the value, as it contains \": \": " + strings.TrimSpace(quoted), m[2] + 1
+	}
+	if m := reservedStartRe.FindStringSubmatchIndex(line); m != nil {
+		return "quote the value, as YAML reserves " + line[m[2]:m[3]] + " at the start of a value: " + strings.TrimSpace(quoted), m[2] + 1
+	}
+	return "", 0
+}
+
+// reportFrontmatterError prints an invalid frontmatter error once per note, with the offending line, a caret and a hint
+func (c *converter) reportFrontmatterError(src string, err *frontmatterError, strict bool) {
+	if c.frontmatterReported[src] {
+		return
+	}
+	if c.frontmatterReported == nil {
+		c.frontmatterReported = make(map[string]bool)
+	}
+	c.frontmatterReported[src] = true
+	c.report.Frontmatter = append(c.report.Frontmatter, fmProblem{
+		Source: c.paths.source(src), Line: err.line, Message: err.message, Error: true,
+	})
+
+	location := src
+	if err.line > 0 {
+		location += ":" + strconv.Itoa(err.line)
+		if err.column > 0 {
+			location += ":" + strconv.Itoa(err.column)
+		}
+	}
+	if strict {
+		c.console.errorf("%s: invalid frontmatter: %s", location, err.message)
+	} else {
+		c.console.warnf("%s: invalid frontmatter: %s", location, err.message)
+	}
+	if err.snippet != "" {
+		c.console.detailf("%s", err.snippet)
+		if err.column > 0 {
+			// Keep tabs so the caret lines up with the snippet
+			caret := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, string([]rune(err.snippet)[:min(err.column-1, len([]rune(err.snippet)))]))
+			c.console.detailf("%s^", caret)
+		}
+	}
+	if err.hint != "" {
+		c.console.detailf("hint: %s", err.hint)
+	}
+	if !strict {
+		c.console.detailf("published as if it had no frontmatter; use --strict-frontmatter to make this an error, or --fail-on-frontmatter-errors to fail the run")
+	}
+}
+
+// rejectFrontmatter refuses a note with invalid frontmatter when --strict-frontmatter is set
+// Returns true if the note is reported as an error and must not be published
+func (c *converter) rejectFrontmatter(src, dest string, err error) bool {
+	var fmErr *frontmatterError
+	if !c.opts.strictFrontmatter || !errors.As(err, &fmErr) {
+		return false
+	}
+	c.reportFrontmatterError(src, fmErr, true)
+	c.record(reportEntry{Source: src, Destination: dest, Action: actionError, Error: fmErr.Error()}, 0)
+	return true
+}
+
+// frontmatterFallback carries on with a note whose frontmatter is invalid, as if it had none
+// The error is reported once, and the step skipped because of it is noted in the report entries of the note
+// Returns false if err is not a frontmatter error
+func (c *converter) frontmatterFallback(src string, err error, skipped string) bool {
+	var fmErr *frontmatterError
+	if !errors.As(err, &fmErr) {
+		return false
+	}
+	c.reportFrontmatterError(src, fmErr, false)
+	if skipped != "" {
+		c.addReportNote(src, skipped+" skipped: invalid frontmatter")
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package o2q
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFrontmatterErrorHints(t *testing.T) {
+	tests := []struct {
+		name         string
+		note         string
+		line, column int
+		hint         string // Empty for no hint
+		message      string // Part of the message, if checked
+	}{
+		{"unquoted colon", "---\ntitle: Part 1: Intro\n---\nBody\n", 2, 14, `quote the value, as it contains ": ": title: "Part 1: Intro"`, ""},
+		{"tab indentation", "---\ntags:\n\t- a\n---\nBody\n", 3, 1, "YAML does not allow tabs for indentation; indent with spaces", ""},
+		{"stray @", "---\ntitle: Note\nauthor: @me\n---\nBody\n", 3, 9, `quote the value, as YAML reserves @ at the start of a value: author: "@me"`, ""},
+		// Both lines count from the opening ---, as in the editor
+		{"duplicate key", "---\ntitle: A\ndate: 1\ntitle: B\n---\nBody\n", 4, 0, "", `mapping key "title" already defined at line 2`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFrontmatter([]byte(tt.note))
+			var fmErr *frontmatterError
+			if !errors.As(err, &fmErr) {
+				t.Fatalf("parseFrontmatter() error = %v, want a frontmatter error", err)
+			}
+			if fmErr.line != tt.line || fmErr.column != tt.column {
+				t.Errorf("error at line %d, column %d, want line %d, column %d", fmErr.line, fmErr.column, tt.line, tt.column)
+			}
+			if fmErr.hint != tt.hint {
+				t.Errorf("hint = %q, want %q", fmErr.hint, tt.hint)
+			}
+			if !strings.Contains(fmErr.message, tt.message) {
+				t.Errorf("message = %q, want %q in it", fmErr.message, tt.message)
+			}
+		})
+	}
+}
+
+func TestFrontmatterErrorMessages(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Colon.md":  "---\ntitle: Part 1: Intro\n---\nBody\n",
+		"Tab.md":    "---\ntags:\n\t- a\n---\nBody\n",
+		"Author.md": "---\nauthor: @me\n---\nBody\n",
+	})
+	opts := testOptions(t)
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var messages bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &messages}
+	if code := runSources(log, opts, config{}, sources, t.TempDir(), ""); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	// Each note is located, with its line, a caret under the culprit and the hint
+	for _, want := range []string{
+		"Colon.md:2:14: invalid frontmatter",
+		"title: Part 1: Intro\n  " + strings.Repeat(" ", 13) + "^\n",
+		`hint: quote the value, as it contains ": ": title: "Part 1: Intro"`,
+		"Tab.md:3:1: invalid frontmatter",
+		"\t- a\n  ^\n",
+		"hint: YAML does not allow tabs for indentation; indent with spaces",
+		"Author.md:2:9: invalid frontmatter",
+		`hint: quote the value, as YAML reserves @ at the start of a value: author: "@me"`,
+	} {
+		if !strings.Contains(messages.String(), want) {
+			t.Errorf("messages do not show %q:\n%s", want, messages.String())
+		}
+	}
+}
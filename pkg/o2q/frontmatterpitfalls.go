@@ -0,0 +1,109 @@
+package o2q
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// quartzDateKeys are the frontmatter keys Quartz reads dates from, for the created, modified and published dates
+var quartzDateKeys = []string{"date", "created", "modified", "updated", "lastmod", "last-modified", "published", "publishDate"}
+
+// isoDateLayouts are the date forms Quartz parses the same way in every browser and build environment
+var isoDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+}
+
+// fmProblem is an invalid frontmatter, or a frontmatter value Quartz is likely to mishandle
+type fmProblem struct {
+	Source  string `json:"source"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+	Error   bool   `json:"error"` // Invalid YAML, as opposed to a value Quartz may mishandle
+}
+
+// isISODate checks if a date value is in one of the ISO 8601 forms Quartz parses reliably
+func isISODate(value string) bool {
+	for _, layout := range isoDateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFrontmatterPitfalls warns about valid frontmatter values Quartz mishandles, such as dates in a local format,
+// which make the page show no date or the build fail, and tags written as a map, which Quartz ignores
+// Skipped with --no-validate
+func (c *converter) checkFrontmatterPitfalls(src string, content []byte) int {
+	if c.opts.noValidate {
+		return 0
+	}
+	frontmatter, _, ok := splitFrontmatter(content)
+	if !ok {
+		return 0
+	}
+	_, keys, ok := frontmatterKeys(frontmatter)
+	if !ok {
+		return 0
+	}
+
+	found := 0
+	warn := func(k frontmatterKey, message string) {
+		line := k.key.Line + 1 // Counting the opening ---
+		c.console.warnf("%s:%d: %s", src, line, message)
+		c.report.Frontmatter = append(c.report.Frontmatter, fmProblem{
+			Source: c.paths.source(src), Line: line, Message: message,
+		})
+		found++
+	}
+	for _, k := range keys {
+		name, value := k.key.Value, k.value
+		switch {
+		case name == "tags" || name == "tag":
+			if value.Kind == yaml.MappingNode {
+				warn(k, name+" is a map, which Quartz ignores; write the tags as a list, such as tags: [project, draft]")
+			}
+		case containsFold(quartzDateKeys, name):
+			if value.Kind != yaml.ScalarNode || value.Tag == "!!null" || value.Value == "" {
+				if value.Kind != yaml.ScalarNode {
+					warn(k, name+" is not a single date; Quartz expects a date such as 2024-03-17")
+				}
+				continue
+			}
+			if value.Tag != "!!timestamp" && !isISODate(strings.TrimSpace(value.Value)) {
+				warn(k, name+": "+value.Value+" is not an ISO date, which Quartz may not read; write it as 2024-03-17 or 2024-03-17T14:30:00")
+			}
+		}
+	}
+	return found
+}
+
+// containsFold checks if a list holds a string, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// frontmatterErrors counts the notes with invalid frontmatter, for --fail-on-frontmatter-errors
+func (r *runReport) frontmatterErrors() int {
+	n := 0
+	for _, p := range r.Frontmatter {
+		if p.Error {
+			n++
+		}
+	}
+	return n
+}
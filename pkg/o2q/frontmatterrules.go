@@ -0,0 +1,183 @@
+package o2q
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter value formats checked by frontmatter rules
+const (
+	formatURL      = "url"       // Absolute URL with a scheme and a host
+	formatHTTPSURL = "https-url" // Absolute https URL
+	formatDate     = "date"      // YYYY-MM-DD date, optionally with a time
+	formatEnum     = "enum"      // One of the listed values
+)
+
+// frontmatterRule is a validation rule for a frontmatter key, read from the frontmatter-rules list of the config file
+//
+//	frontmatter-rules:
+//	  - key: [canonical, canonicalUrl]
+//	    format: https-url
+//	    required: true
+//	    when: {crosspost: true}
+type frontmatterRule struct {
+	Keys     []string          // The value is read from the first of these keys present in the note
+	Format   string            // Format of the value, if checked
+	Values   []string          // Allowed values of an enum
+	Required bool              // The note must have one of the keys
+	When     map[string]string // The rule only applies to notes whose frontmatter has these values
+}
+
+// parseFrontmatterRules reads and checks the frontmatter-rules list of the config file
+func parseFrontmatterRules(value interface{}) ([]frontmatterRule, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		Key      interface{}            `yaml:"key"`
+		Format   string                 `yaml:"format"`
+		Values   []string               `yaml:"values"`
+		Required bool                   `yaml:"required"`
+		When     map[string]interface{} `yaml:"when"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("frontmatter-rules must be a list of rules: %v", err)
+	}
+
+	rules := make([]frontmatterRule, len(raw))
+	for i, r := range raw {
+		rule := frontmatterRule{Keys: configList(r.Key), Format: r.Format, Values: r.Values, Required: r.Required}
+		if len(rule.Keys) == 0 {
+			return nil, fmt.Errorf("frontmatter rule %d has no key", i+1)
+		}
+		switch rule.Format {
+		case "", formatURL, formatHTTPSURL, formatDate:
+		case formatEnum:
+			if len(rule.Values) == 0 {
+				return nil, fmt.Errorf("frontmatter rule for %s: enum format needs values", rule.Keys[0])
+			}
+		default:
+			return nil, fmt.Errorf("frontmatter rule for %s: unknown format %q", rule.Keys[0], rule.Format)
+		}
+		if len(r.When) > 0 {
+			rule.When = make(map[string]string)
+			for key, v := range r.When {
+				rule.When[key] = fmt.Sprint(v)
+			}
+		}
+		rules[i] = rule
+	}
+	return rules, nil
+}
+
+// applies checks if the conditions of the rule hold for a note
+func (r frontmatterRule) applies(values map[string]interface{}) bool {
+	for key, want := range r.When {
+		v, ok := values[key]
+		if !ok || !strings.EqualFold(fmt.Sprint(v), want) {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns the violations of the rule by a note, as one-line messages
+func (r frontmatterRule) check(values map[string]interface{}) []string {
+	if !r.applies(values) {
+		return nil
+	}
+
+	for _, key := range r.Keys {
+		v, ok := values[key]
+		if !ok || v == nil {
+			continue
+		}
+		if err := checkFormat(v, r.Format, r.Values); err != nil {
+			return []string{fmt.Sprintf("%s: %v", key, err)}
+		}
+		return nil
+	}
+
+	if r.Required {
+		condition := ""
+		if len(r.When) > 0 {
+			var conditions []string
+			for key, v := range r.When {
+				conditions = append(conditions, key+": "+v)
+			}
+			sort.Strings(conditions)
+			condition = " (required with " + strings.Join(conditions, ", ") + ")"
+		}
+		return []string{fmt.Sprintf("missing %s%s", strings.Join(r.Keys, " or "), condition)}
+	}
+	return nil
+}
+
+// checkFormat checks a frontmatter value against a format
+func checkFormat(value interface{}, format string, values []string) error {
+	text := fmt.Sprint(value)
+	switch format {
+	case formatURL, formatHTTPSURL:
+		u, err := url.Parse(text)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q is not an absolute URL", text)
+		}
+		if format == formatHTTPSURL && u.Scheme != "https" {
+			return fmt.Errorf("%q is not an https URL", text)
+		}
+	case formatDate:
+		if _, ok := value.(time.Time); ok {
+			return nil
+		}
+		for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", time.RFC3339, "2006-01-02 15:04"} {
+			if _, err := time.Parse(layout, text); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a date (YYYY-MM-DD)", text)
+	case formatEnum:
+		for _, allowed := range values {
+			if text == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", text, strings.Join(values, ", "))
+	}
+	return nil
+}
+
+// checkFrontmatterRules reports the frontmatter rule violations of a note
+// Returns an error describing them if --strict-frontmatter-rules is set, so the note is not published
+func (c *converter) checkFrontmatterRules(src string, content []byte) error {
+	if len(c.frontmatterRules) == 0 {
+		return nil
+	}
+
+	// Invalid frontmatter is reported on its own, its values cannot be checked
+	values, err := parseFrontmatter(content)
+	if c.frontmatterFallback(src, err, "frontmatter rules") {
+		return nil
+	}
+	var violations []string
+	for _, rule := range c.frontmatterRules {
+		violations = append(violations, rule.check(values)...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	c.lintFindings += len(violations)
+	if c.opts.strictFrontmatterRules {
+		return fmt.Errorf("frontmatter rules violated: %s", strings.Join(violations, "; "))
+	}
+	for _, violation := range violations {
+		c.console.warnf("%s: frontmatter: %s", src, violation)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readRules parses the frontmatter-rules list of a config file
+func readRules(t *testing.T, text string) ([]frontmatterRule, error) {
+	t.Helper()
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatal(err)
+	}
+	return parseFrontmatterRules(value)
+}
+
+func TestParseFrontmatterRules(t *testing.T) {
+	rules, err := readRules(t, "- key: [canonical, canonicalUrl]\n  format: https-url\n  required: true\n  when: {crosspost: true}\n"+
+		"- key: status\n  format: enum\n  values: [draft, done]\n")
+	if err != nil {
+		t.Fatalf("parseFrontmatterRules() error = %v", err)
+	}
+	want := []frontmatterRule{
+		{Keys: []string{"canonical", "canonicalUrl"}, Format: formatHTTPSURL, Required: true, When: map[string]string{"crosspost": "true"}},
+		{Keys: []string{"status"}, Format: formatEnum, Values: []string{"draft", "done"}},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("parseFrontmatterRules() = %+v, want %+v", rules, want)
+	}
+
+	for text, want := range map[string]string{
+		"key: status\n":                    "frontmatter-rules must be a list of rules",
+		"- format: url\n":                  "frontmatter rule 1 has no key",
+		"- key: status\n  format: enum\n":  "frontmatter rule for status: enum format needs values",
+		"- key: status\n  format: email\n": `frontmatter rule for status: unknown format "email"`,
+	} {
+		if _, err := readRules(t, text); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("parseFrontmatterRules(%q) error = %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestFrontmatterRuleCheck(t *testing.T) {
+	canonical := frontmatterRule{Keys: []string{"canonical", "canonicalUrl"}, Format: formatHTTPSURL, Required: true,
+		When: map[string]string{"crosspost": "true"}}
+	tests := []struct {
+		name   string
+		rule   frontmatterRule
+		values map[string]interface{}
+		want   string // Violation, if any
+	}{
+		{"condition not met", canonical, map[string]interface{}{}, ""},
+		{"condition not met, false", canonical, map[string]interface{}{"crosspost": false}, ""},
+		{"required key missing", canonical, map[string]interface{}{"crosspost": true},
+			"missing canonical or canonicalUrl (required with crosspost: true)"},
+		{"required key empty", canonical, map[string]interface{}{"crosspost": true, "canonical": nil},
+			"missing canonical or canonicalUrl (required with crosspost: true)"},
+		{"condition case-insensitive", canonical, map[string]interface{}{"crosspost": "True"},
+			"missing canonical or canonicalUrl (required with crosspost: true)"},
+		{"second key", canonical, map[string]interface{}{"crosspost": true, "canonicalUrl": "https://blog.example.com/post"}, ""},
+		{"not https", canonical, map[string]interface{}{"crosspost": true, "canonical": "http://blog.example.com/post"},
+			`canonical: "http://blog.example.com/post" is not an https URL`},
+		{"relative URL", canonical, map[string]interface{}{"crosspost": true, "canonical": "/post"},
+			`canonical: "/post" is not an absolute URL`},
+		{"unconditional, missing", frontmatterRule{Keys: []string{"title"}, Required: true}, map[string]interface{}{}, "missing title"},
+		{"optional, missing", frontmatterRule{Keys: []string{"source"}, Format: formatURL}, map[string]interface{}{}, ""},
+		{"url", frontmatterRule{Keys: []string{"source"}, Format: formatURL}, map[string]interface{}{"source": "ftp://example.com/a"}, ""},
+		{"not a url", frontmatterRule{Keys: []string{"source"}, Format: formatURL}, map[string]interface{}{"source": "example.com"},
+			`source: "example.com" is not an absolute URL`},
+		{"date", frontmatterRule{Keys: []string{"date"}, Format: formatDate}, map[string]interface{}{"date": "2024-03-01"}, ""},
+		{"date and time", frontmatterRule{Keys: []string{"date"}, Format: formatDate}, map[string]interface{}{"date": "2024-03-01 14:30"}, ""},
+		{"date read by YAML", frontmatterRule{Keys: []string{"date"}, Format: formatDate},
+			map[string]interface{}{"date": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, ""},
+		{"not a date", frontmatterRule{Keys: []string{"date"}, Format: formatDate}, map[string]interface{}{"date": "01/03/2024"},
+			`date: "01/03/2024" is not a date (YYYY-MM-DD)`},
+		{"enum", frontmatterRule{Keys: []string{"status"}, Format: formatEnum, Values: []string{"draft", "done"}},
+			map[string]interface{}{"status": "done"}, ""},
+		{"not in enum", frontmatterRule{Keys: []string{"status"}, Format: formatEnum, Values: []string{"draft", "done"}},
+			map[string]interface{}{"status": "wip"}, `status: "wip" is not one of draft, done`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(tt.rule.check(tt.values), "; ")
+			if got != tt.want {
+				t.Errorf("check(%v) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictFrontmatterRules(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Original.md":   "Original\n",
+		"Crosspost.md":  "---\ncrosspost: true\ncanonical: https://blog.example.com/post\n---\nPost\n",
+		"Duplicated.md": "---\ncrosspost: true\n---\nPost\n",
+	})
+	var opts options
+	registry := newOptionRegistry(&opts)
+	cfg, err := loadConfig(console, writeConfig(t, "frontmatter-rules:\n  - key: [canonical, canonicalUrl]\n    format: https-url\n"+
+		"    required: true\n    when: {crosspost: true}\n"), registry, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, strict := range []bool{false, true} {
+		args := []string{"-quiet"}
+		if strict {
+			args = append(args, "-strict-frontmatter-rules")
+		}
+		opts := testOptions(t, args...)
+		sources := []vaultSource{{folder: vault, sub: "."}}
+		if err := checkOptions(console, &opts, cfg, sources, ""); err != nil {
+			t.Fatal(err)
+		}
+		quartz := t.TempDir()
+		code := runSources(console, opts, cfg, sources, quartz, "")
+		if (code == exitSuccess) == strict {
+			t.Errorf("strict %v: run exited with %d", strict, code)
+		}
+		_, err := os.Stat(filepath.Join(quartz, "content", "Duplicated.md"))
+		if (err == nil) == strict {
+			t.Errorf("strict %v: note violating the rules published: %v", strict, err == nil)
+		}
+		readContent(t, quartz, "Crosspost.md")
+		readContent(t, quartz, "Original.md")
+	}
+}
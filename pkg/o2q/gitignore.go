@@ -0,0 +1,176 @@
+package o2q
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreRule is a line of a .gitignore file of the vault
+type gitignoreRule struct {
+	base     string   // Vault-relative folder of the .gitignore, with forward slashes; "." for the root
+	segments []string // Pattern split at /, without its leading and trailing /
+	anchored bool     // Pattern with a / other than a trailing one, matched from base rather than at any depth
+	dirOnly  bool     // Pattern ending with /, which only matches folders
+	negate   bool     // Line starting with !, which publishes again what an earlier line excludes
+}
+
+// gitignore holds the rules of the .gitignore files of the vault, the root one first and nested ones after their parents
+type gitignore struct {
+	rules []gitignoreRule
+	files int // Number of .gitignore files read
+}
+
+// parseGitignoreLine parses a line of a .gitignore file found in base; ok is false for blank lines and comments
+// As in git, trailing spaces are dropped unless escaped with \, and \# and \! start a pattern with # or !
+func parseGitignoreLine(base, line string) (gitignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimSuffix(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitignoreRule{}, false
+	}
+	r := gitignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	r.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// matches checks if a vault path, with forward slashes, matches the rule
+func (r gitignoreRule) matches(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel := relPath
+	if r.base != "." {
+		var ok bool
+		if rel, ok = strings.CutPrefix(relPath, r.base+"/"); !ok {
+			return false
+		}
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	segments := strings.Split(rel, "/")
+	// A trailing /** matches what is inside a folder, not the folder itself
+	if r.segments[len(r.segments)-1] == "**" && len(segments) < len(r.segments) {
+		return false
+	}
+	return matchSegments(r.segments, segments)
+}
+
+// ignored checks if a vault path is excluded by the .gitignore files
+// As in git, the last matching line wins, and nothing inside an excluded folder can be published again
+func (g *gitignore) ignored(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	if dir := path.Dir(relPath); dir != "." && g.ignored(dir, true) {
+		return true
+	}
+	ignored := false
+	for _, r := range g.rules {
+		if r.matches(relPath, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// readGitignore reads the .gitignore files of a vault, at its root and in its folders
+// Folders the .gitignore files exclude are not searched, as git does not read the files they hold
+func readGitignore(vault *vaultFS) (*gitignore, error) {
+	g := &gitignore{}
+	err := fs.WalkDir(vault.fsys, ".", func(relPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" || (relPath != "." && g.ignored(relPath, true)) {
+			return fs.SkipDir
+		}
+		return g.readFile(vault, filepath.Join(vault.root, filepath.FromSlash(relPath), ".gitignore"), relPath)
+	})
+	return g, err
+}
+
+// readFile adds the rules of a .gitignore file of the vault folder base, if it exists
+func (g *gitignore) readFile(vault *vaultFS, file, base string) error {
+	f, err := vault.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	defer f.Close()
+	g.files++
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseGitignoreLine(base, scanner.Text()); ok {
+			g.rules = append(g.rules, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return nil
+}
+
+// gitignored checks if a vault path is left out by --respect-gitignore
+// A ! line of the ignore file publishes a path git ignores, and a folder holding one is walked,
+// though its other files stay excluded
+func (c *converter) gitignored(relPath string, isDir bool) bool {
+	if c.gitignore == nil || !c.gitignore.ignored(relPath, isDir) {
+		return false
+	}
+	if matchesPathOrParent(relPath, c.gitignoreKeeps, isDir) {
+		return false
+	}
+	if isDir {
+		slashPath := filepath.ToSlash(relPath)
+		for _, n := range c.gitignoreKeeps {
+			if n.mayMatchInside(slashPath) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// loadGitignore reads the .gitignore files of the vault for --respect-gitignore
+// negations are the ! lines of the ignore file, which publish paths git ignores
+func (c *converter) loadGitignore(negations []ignorePattern) error {
+	g, err := readGitignore(c.vault)
+	if err != nil {
+		return err
+	}
+	if g.files == 0 {
+		c.console.progressf("No .gitignore file in the vault, --respect-gitignore excludes nothing")
+		return nil
+	}
+	c.console.infof("Loaded %d patterns from %d .gitignore files", len(g.rules), g.files)
+	c.gitignore = g
+	c.gitignoreKeeps = negations
+	return nil
+}
@@ -0,0 +1,167 @@
+package o2q
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// gitChanges are the vault files changed between a git revision and HEAD, for --since-git
+type gitChanges struct {
+	changed map[string]bool // Vault-relative paths added, modified, copied or renamed to, in NFC form with forward slashes
+	deleted []string        // Vault-relative paths deleted or renamed from, with forward slashes
+}
+
+// runGit runs a git command in the vault, returning its output; the error holds what git printed
+func runGit(vault string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", vault}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// gitHead returns the commit the vault repository is at, failing if the vault is not in a git repository
+func gitHead(vault string) (string, error) {
+	if _, err := runGit(vault, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return "", fmt.Errorf("%s is not in a git repository: %v", vault, err)
+	}
+	head, err := runGit(vault, "rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("the git repository of %s has no commit: %v", vault, err)
+	}
+	return strings.TrimSpace(head), nil
+}
+
+// readGitChanges lists the files of the vault changed between a revision and HEAD
+// Paths are relative to the vault, which may be a folder of a larger repository; files outside it are left out
+// Changes not committed yet are not included
+func readGitChanges(vault, ref string) (*gitChanges, error) {
+	if _, err := gitHead(vault); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("%q is not a git revision", ref)
+	}
+	if _, err := runGit(vault, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("%q is not a commit of the git repository of %s", ref, vault)
+	}
+	out, err := runGit(vault, "diff", "--name-status", "-z", "-M", "--relative", ref, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("listing the changes since %s: %v", ref, err)
+	}
+
+	changes := &gitChanges{changed: make(map[string]bool)}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, file := fields[i], fields[i+1]
+		switch status[0] {
+		case 'R', 'C':
+			// Renames and copies are followed by the old and the new path
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("listing the changes since %s: unexpected output of git diff", ref)
+			}
+			if status[0] == 'R' {
+				changes.deleted = append(changes.deleted, file)
+			}
+			i++
+			changes.changed[norm.NFC.String(fields[i+1])] = true
+		case 'D':
+			changes.deleted = append(changes.deleted, file)
+		default:
+			changes.changed[norm.NFC.String(file)] = true
+		}
+	}
+	return changes, nil
+}
+
+// unchangedSinceGit checks if a file is left as it is with --since-git, as it did not change since the given revision
+func (c *converter) unchangedSinceGit(relPath string) bool {
+	return c.gitChanges != nil && !c.gitChanges.changed[norm.NFC.String(filepath.ToSlash(relPath))]
+}
+
+// removeDeletedSinceGit deletes the published files whose source was deleted or renamed since the --since-git revision
+// Files the ignore and Excalidraw rules leave out were never published, so their destination is left alone
+func (c *converter) removeDeletedSinceGit() error {
+	for _, relPath := range c.gitChanges.deleted {
+		if !c.wasPublished(relPath) {
+			continue
+		}
+		destPath := filepath.Join(c.contentFolder, c.destRel(filepath.FromSlash(relPath)))
+		switch {
+		case isInExcalidrawFolder(relPath):
+			destPath = strings.TrimSuffix(destPath, filepath.Ext(destPath)) + ".svg"
+		case hasExt(relPath, ".md"):
+			destPath = strings.TrimSuffix(destPath, filepath.Ext(destPath)) + ".md"
+		case hasExt(relPath, ".canvas") && c.opts.canvas == canvasList:
+			destPath += ".md"
+		}
+		if c.relocatedHere(destPath) {
+			continue
+		}
+		if err := c.checkContained(destPath); err != nil {
+			return fmt.Errorf("%s %w", destPath, err)
+		}
+		if _, err := os.Stat(destPath); os.IsNotExist(err) {
+			continue
+		}
+		if buried, err := c.buryNote(destPath, "source deleted since --since-git"); err != nil {
+			return err
+		} else if buried {
+			continue
+		}
+		if c.wouldDelete(destPath, "source deleted since --since-git") {
+			continue
+		}
+		if err := os.Remove(destPath); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to delete %s: %v", destPath, err)
+		}
+		c.record(reportEntry{Source: filepath.Join(c.obsidianFolder, filepath.FromSlash(relPath)), Destination: destPath, Action: actionDeleted}, 0)
+	}
+	return nil
+}
+
+// wasPublished checks if a vault file that no longer exists passed the folder, ignore and Excalidraw rules
+func (c *converter) wasPublished(relPath string) bool {
+	if isToolFile(relPath) || (isInExcalidrawFolder(relPath) && !hasExt(relPath, ".svg")) {
+		return false
+	}
+	dirs := strings.Split(relPath, "/")
+	for i := range dirs[:len(dirs)-1] {
+		if strings.HasPrefix(dirs[i], ".") {
+			return false
+		}
+		if ignored, _ := c.ignored(filepath.FromSlash(strings.Join(dirs[:i+1], "/")), true); ignored {
+			return false
+		}
+	}
+	ignored, _ := c.ignored(filepath.FromSlash(relPath), false)
+	return !ignored
+}
+
+// writeGitRef records the commit a successful run published, for --write-ref
+// The next run reads it back with --since-git "$(cat FILE)"
+func writeGitRef(file, head string) error {
+	_, err := writeFileAtomic(file, 0644, func(w io.Writer) (int64, error) {
+		n, err := io.WriteString(w, head+"\n")
+		return int64(n), err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write --write-ref file: %v", err)
+	}
+	return nil
+}
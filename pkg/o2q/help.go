@@ -0,0 +1,211 @@
+package o2q
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Help topics, in the order they are listed
+const (
+	topicFiltering  = "filtering"
+	topicTransforms = "transforms"
+	topicExcalidraw = "excalidraw"
+	topicSync       = "sync"
+	topicOutput     = "output"
+)
+
+// helpTopic groups related options in the help output
+type helpTopic struct {
+	name        string
+	title       string
+	description string
+	examples    [][]string // Example argument lists, without the program name
+}
+
+// helpTopics lists every help topic
+var helpTopics = []helpTopic{
+	{
+		name:  topicFiltering,
+		title: "Filtering",
+		description: "Which files are published. Directories starting with . are always skipped, " +
+			"and patterns from the .obsidian-to-quartz-ignore file at the vault root exclude files and folders.",
+		examples: [][]string{
+			{"--canvas=list", "MyVault", "MyQuartzSite"},
+			{"--html=iframe", "MyVault", "MyQuartzSite"},
+			{"--filter", "(path:Blog/** OR tag:public) AND NOT frontmatter.status=wip", "MyVault", "MyQuartzSite"},
+		},
+	},
+	{
+		name:        topicTransforms,
+		title:       "Transforms",
+		description: "How the content of markdown files is rewritten for Quartz.",
+		examples: [][]string{
+			{"--strip-dataview", "--dataview-placeholder", "_Dynamic content omitted_", "MyVault", "MyQuartzSite"},
+			{"--media-embeds=html", "--block-refs=strip", "MyVault", "MyQuartzSite"},
+			{"check", "--lint-disable", "list-indent", "MyVault"},
+		},
+	},
+	{
+		name:  topicExcalidraw,
+		title: "Excalidraw",
+		description: "Only .svg files are copied from Excalidraw folders. Links to drawings are rewritten to their exported SVG: " +
+			"[[drawing.excalidraw]] becomes [[drawing.excalidraw.svg|drawing]] and [text](drawing.excalidraw.md) becomes [text](drawing.excalidraw.svg). " +
+			"With --excalidraw-links=embed or figure, links to drawings show them instead, " +
+			"and --render-drawings renders the drawings that have no SVG export to one.",
+		examples: [][]string{
+			{"--render-drawings", "--excalidraw-links=embed", "MyVault", "MyQuartzSite"},
+		},
+	},
+	{
+		name:  topicSync,
+		title: "Sync behavior",
+		description: "Files are written to the content folder of the Quartz folder (or --content-dir), overwriting existing files. " +
+			"Each file is written to a temporary file and renamed into place, so a running Quartz watcher never sees a half-written file.",
+		examples: [][]string{
+			{"--content-dir", "content/notes", "MyVault", "MyQuartzSite"},
+			{"--every", "15m", "--jitter", "2m", "MyVault", "MyQuartzSite"},
+		},
+	},
+	{
+		name:  topicOutput,
+		title: "Output",
+		description: "What the tool prints, and where settings are read from. Settings are read from the config file, " +
+			"then from OBSIDIAN_TO_QUARTZ_* environment variables, then from command-line flags, each overriding the previous one.",
+		examples: [][]string{
+			{"--media-embeds=link", "--print-config"},
+			{"export", "--standalone", "MyVault", "preview"},
+			{"export-note", "--note-depth", "1", "MyVault", "Projects/Roadmap", "roadmap.zip"},
+		},
+	},
+}
+
+// findTopic returns the help topic with the given name
+func findTopic(name string) (helpTopic, bool) {
+	for _, topic := range helpTopics {
+		if topic.name == name {
+			return topic, true
+		}
+	}
+	return helpTopic{}, false
+}
+
+// runHelp handles the help command: help [--plain] [topic]
+func runHelp(w io.Writer, program string, registry []option, args []string) error {
+	plain := false
+	if len(args) > 0 && args[0] == "--plain" {
+		plain = true
+		args = args[1:]
+	}
+
+	width := helpWidth()
+	if plain {
+		width = 0
+	}
+
+	if len(args) == 0 {
+		printHelp(w, program, registry, width)
+		return nil
+	}
+
+	topic, ok := findTopic(args[0])
+	if !ok {
+		return fmt.Errorf("unknown help topic %q", args[0])
+	}
+	printTopic(w, program, topic, registry, width, true)
+	return nil
+}
+
+// printHelp writes the full help: usage, then every topic with its options
+func printHelp(w io.Writer, program string, registry []option, width int) {
+	fmt.Fprintf(w, "Usage: %s [options] <Obsidian_Folder> <Quartz_Folder>\n", program)
+	fmt.Fprintf(w, "       %s [options] --source <Folder>:<Subfolder> [--source ...] <Quartz_Folder>\n", program)
+	fmt.Fprintf(w, "       %s [options] --config <Config_File>\n", program)
+	fmt.Fprintf(w, "       %s check [options] <Obsidian_Folder>\n", program)
+	fmt.Fprintf(w, "       %s export [--standalone] [options] <Obsidian_Folder> <Output_Folder>\n", program)
+	fmt.Fprintf(w, "       %s export-note [options] <Obsidian_Folder> <Note> <Output_Folder_or_Zip>\n", program)
+	fmt.Fprintf(w, "       %s help [--plain] [topic]\n", program)
+	for _, topic := range helpTopics {
+		fmt.Fprintln(w)
+		printTopic(w, program, topic, registry, width, false)
+	}
+
+	names := make([]string, len(helpTopics))
+	for i, topic := range helpTopics {
+		names[i] = topic.name
+	}
+	fmt.Fprintln(w)
+	printExitCodes(w)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Run '%s help <topic>' for examples. Topics: %s\n", program, strings.Join(names, ", "))
+}
+
+// printTopic writes the description and options of a topic, and its examples if requested
+func printTopic(w io.Writer, program string, topic helpTopic, registry []option, width int, examples bool) {
+	fmt.Fprintf(w, "%s:\n", topic.title)
+	writeWrapped(w, topic.description, "  ", width)
+
+	for _, o := range registry {
+		if o.topic != topic.name {
+			continue
+		}
+		fmt.Fprintln(w)
+		line := "  --" + o.name
+		if o.metavar != "" {
+			line += " " + o.metavar
+			if o.defValue != "" {
+				line += " (default " + strconv.Quote(o.defValue) + ")"
+			}
+		}
+		fmt.Fprintln(w, line)
+		writeWrapped(w, o.usage, "        ", width)
+		fmt.Fprintf(w, "        Environment: %s, config key: %s\n", o.envVar(), o.configKey())
+	}
+
+	if examples && len(topic.examples) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Examples:")
+		for _, example := range topic.examples {
+			fmt.Fprintf(w, "  %s %s\n", program, shellJoin(example))
+		}
+	}
+}
+
+// writeWrapped writes text word-wrapped to width, each line prefixed with indent
+// A width of 0 disables wrapping
+func writeWrapped(w io.Writer, text, indent string, width int) {
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if width > 0 && line != indent && len(line)+1+len(word) > width {
+			fmt.Fprintln(w, line)
+			line = indent
+		}
+		if line != indent {
+			line += " "
+		}
+		line += word
+	}
+	fmt.Fprintln(w, line)
+}
+
+// helpWidth returns the width used to wrap help text, from $COLUMNS if set
+func helpWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns >= 40 {
+		return columns
+	}
+	return 80
+}
+
+// shellJoin joins arguments into a command line, quoting those that need it
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$|&;<>*?()") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,184 @@
+package o2q
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// errFailFast stops the walk of the vault at the first failing --hook-file command, with --fail-fast
+var errFailFast = errors.New("run stopped at the first failing --hook-file command (--fail-fast)")
+
+// splitCommand splits the command line of a hook into its arguments, the same way on every system, as no shell is involved
+// Spaces separate arguments, and quotes group them: 'single quotes' keep everything as is, and in "double quotes"
+// \" and \\ stand for " and \; backslashes are otherwise kept, so C:\Tools\tool.exe needs no quoting
+func splitCommand(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case ch == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed ' in %q", line)
+			}
+			arg.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case ch == '"':
+			closed := false
+			for i++; i < len(line); i++ {
+				if line[i] == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+					i++
+				} else if line[i] == '"' {
+					closed = true
+					break
+				}
+				arg.WriteByte(line[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unclosed \" in %q", line)
+			}
+			inArg = true
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+	return args, nil
+}
+
+// quoteCommand joins arguments into a command line that splitCommand splits back into them,
+// for hooks given as a list of arguments in the config file
+func quoteCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, `\`, `\\`)
+		quoted[i] = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// commandLine shows the arguments of a hook as a command line, quoting those holding spaces or quotes
+func commandLine(args []string) string {
+	shown := make([]string, len(args))
+	for i, arg := range args {
+		shown[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"") {
+			shown[i] = quoteCommand([]string{arg})
+		}
+	}
+	return strings.Join(shown, " ")
+}
+
+// runHook runs a hook command in a folder, stopping it after timeout unless it is zero
+// output holds what the command printed on stdout and stderr
+func runHook(args []string, dir string, timeout time.Duration) (output string, err error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return string(out), err
+}
+
+// printHookOutput shows the output of a hook, line by line, with --verbose or when it failed
+func printHookOutput(log *consoleLogger, output string, failed bool) {
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if failed {
+			log.detailf("%s", line)
+		} else {
+			log.progressf("  %s", line)
+		}
+	}
+}
+
+// noteWritten remembers the files written for a source, for --incremental, --hook-file and --self-check
+func (c *converter) noteWritten(entry reportEntry) {
+	if (c.state == nil && c.opts.hookFile == "" && c.selfCheck == nil) || entry.Destination == "" {
+		return
+	}
+	switch entry.Action {
+	case actionTransformed, actionGenerated, actionCopied:
+		if c.written == nil {
+			c.written = make(map[string][]string)
+		}
+		c.written[entry.Source] = append(c.written[entry.Source], entry.Destination)
+	}
+}
+
+// runFileHooks runs --hook-file for each file written for a source, with {src} and {dest} replaced with their paths
+// The paths are absolute, as the command runs in the Quartz folder rather than the current folder
+// A failing command is recorded as an error of the file; the error returned stops the run with --fail-fast
+func (c *converter) runFileHooks(src string) error {
+	if c.opts.hookFile == "" {
+		return nil
+	}
+	command, _ := splitCommand(c.opts.hookFile) // Checked before the first run
+	for _, dest := range c.written[src] {
+		args := make([]string, len(command))
+		for i, arg := range command {
+			args[i] = strings.NewReplacer("{src}", absPath(src), "{dest}", absPath(dest)).Replace(arg)
+		}
+		output, err := runHook(args, c.quartzFolder, c.opts.hookTimeout)
+		if err == nil {
+			c.console.progressf("Hook: %s", strings.Join(args, " "))
+			printHookOutput(c.console, output, false)
+			continue
+		}
+		err = fmt.Errorf("--hook-file %s: %v", commandLine(args), err)
+		c.console.errorf("%s: %v", src, err)
+		// The paths of the command are shown whole, as they were given to it
+		c.console.verbatimf("command: %s", c.paths.hideHome(commandLine(args)))
+		printHookOutput(c.console, output, true)
+		c.record(reportEntry{Source: src, Destination: dest, Action: actionError, Error: err.Error()}, 0)
+		if c.opts.failFast {
+			return errFailFast
+		}
+	}
+	return nil
+}
+
+// runPostHook runs --hook-post in the Quartz folder once a run succeeded, such as npx quartz build
+// Returns false if the command failed
+func runPostHook(log *consoleLogger, opts options, quartzFolder string) bool {
+	if opts.hookPost == "" {
+		return true
+	}
+	args, _ := splitCommand(opts.hookPost) // Checked before the first run
+	log.infof("Running %s", strings.Join(args, " "))
+	output, err := runHook(args, quartzFolder, opts.hookTimeout)
+	if err != nil {
+		log.errorf("--hook-post %s: %v", args[0], err)
+		printHookOutput(log, output, true)
+		return false
+	}
+	printHookOutput(log, output, false)
+	return true
+}
@@ -0,0 +1,101 @@
+package o2q
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"optipng -quiet {dest}", []string{"optipng", "-quiet", "{dest}"}},
+		{`sh -c "echo \"hi\" >> {dest}"`, []string{"sh", "-c", `echo "hi" >> {dest}`}},
+		{`tool 'a b' C:\Tools\x.exe`, []string{"tool", "a b", `C:\Tools\x.exe`}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.line)
+		if err != nil || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitCommand(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+		if back, err := splitCommand(quoteCommand(tt.want)); err != nil || strings.Join(back, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitCommand(quoteCommand(%q)) = %q, %v", tt.want, back, err)
+		}
+	}
+	for _, line := range []string{"", "  ", `sh -c "open`, "sh 'open"} {
+		if _, err := splitCommand(line); err == nil {
+			t.Errorf("splitCommand(%q) error = nil", line)
+		}
+	}
+}
+
+// chdir changes the current folder for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(old)
+	})
+}
+
+func TestFileHookRelativeFolders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook needs a POSIX shell")
+	}
+	// The command runs in the Quartz folder, so paths relative to the current folder would not be found from there
+	work := t.TempDir()
+	chdir(t, work)
+	for _, dir := range []string{"vault", "site"} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join("vault", "Note.md"), []byte("Text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hook := `sh -c "cat {src} >> {dest}"`
+	if code := runTestSync(t, "vault", "site", "-hook-file", hook); code != exitSuccess {
+		t.Fatalf("run with relative folders exited with %d", code)
+	}
+	if got := readContent(t, "site", "Note.md"); got != "Text\nText\n" {
+		t.Errorf("published Note.md = %q, want the hook applied", got)
+	}
+}
+
+func TestFileHookError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook needs a POSIX shell")
+	}
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n"})
+	quartz := t.TempDir()
+	opts := testOptions(t, "-hook-file", `sh -c "exit 3" {dest}`)
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var messages bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &messages}
+	if code := runSources(log, opts, config{}, sources, quartz, ""); code != exitFileErrors {
+		t.Fatalf("run exited with %d, want %d", code, exitFileErrors)
+	}
+	// The command is shown as it ran, with the path it was given
+	want := `command: sh -c "exit 3" ` + filepath.Join(quartz, "content", "Note.md")
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(quartz, home+string(filepath.Separator)) {
+		want = `command: sh -c "exit 3" ~` + strings.TrimPrefix(filepath.Join(quartz, "content", "Note.md"), home)
+	}
+	if !strings.Contains(messages.String(), want) || !strings.Contains(messages.String(), "exit status 3") {
+		t.Errorf("messages do not show the failing command %q:\n%s", want, messages.String())
+	}
+}
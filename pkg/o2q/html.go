@@ -0,0 +1,88 @@
+package o2q
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HTML handling modes
+const (
+	htmlCopy   = "copy"   // Copy HTML files to the content folder as-is
+	htmlStatic = "static" // Copy HTML files to the Quartz static folder and point links at them
+	htmlIframe = "iframe" // Like static, plus a markdown page embedding the file in an iframe
+	htmlSkip   = "skip"   // Do not publish HTML files
+)
+
+// htmlLinks matches links to HTML files: [[chart.html]], ![[chart.html]] and [text](chart.html)
+var htmlLinks = newLinkPattern("html")
+
+// processHTMLFile publishes an HTML file according to the html mode
+func (c *converter) processHTMLFile(src, relPath, dest string) error {
+	switch c.opts.html {
+	case htmlSkip:
+		c.record(reportEntry{Source: src, Action: actionSkippedType}, 0)
+		return nil
+	case htmlCopy:
+		return c.copyFile(src, dest)
+	}
+
+	// Quartz serves the files of quartz/static under /static
+	staticPath := filepath.Join(c.quartzFolder, "quartz", "static", relPath)
+	if err := c.copyFile(src, staticPath); err != nil {
+		return err
+	}
+	if c.opts.html == htmlStatic {
+		return nil
+	}
+
+	// Generate a page embedding the file, unless a note already uses that name
+	wrapperSrc := strings.TrimSuffix(src, filepath.Ext(src)) + ".md"
+	if _, err := c.vault.Stat(wrapperSrc); err == nil {
+		c.console.warnf("%s: a note with the same name exists, no iframe page generated", src)
+		return nil
+	}
+	c.console.warnf("%s: embedded in a sandboxed iframe, scripts cannot access the site", src)
+
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	content := fmt.Sprintf("---\ntitle: %s\n---\n\n<iframe src=\"%s\" sandbox=\"allow-scripts\" width=\"100%%\" height=\"600\" style=\"border: none;\"></iframe>\n",
+		strconv.Quote(name), staticURL(relPath))
+	wrapperDest := strings.TrimSuffix(dest, filepath.Ext(dest)) + ".md"
+	return c.writeMarkdownFile(src, wrapperDest, []byte(content), actionGenerated)
+}
+
+// rewriteHTMLLinks rewrites links to HTML files according to the html mode
+//   - static: [[chart.html]] → [chart](/static/chart.html)
+//   - iframe: [[chart.html]] → [[chart]], [text](chart.html) → [text](chart.md)
+func (c *converter) rewriteHTMLLinks(src string, content []byte) []byte {
+	if c.opts.html != htmlStatic && c.opts.html != htmlIframe {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	return htmlLinks.rewrite(content, func(link fileLink) string {
+		target, ok := c.resolveLink(noteDir, link.decodedTarget())
+		if !ok {
+			return link.String()
+		}
+		if c.opts.html == htmlStatic {
+			return "[" + link.displayText() + "](" + staticURL(target) + ")"
+		}
+		link.target = link.target[:len(link.target)-len(".html")]
+		if !link.wiki {
+			link.target += ".md"
+		}
+		return link.String()
+	})
+}
+
+// staticURL returns the URL under which Quartz serves a file of its static folder
+func staticURL(relPath string) string {
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/static/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,66 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTMLModes(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Note.md":           "See [[sales.html]] and [the chart](Charts/sales.html).\n",
+		"Charts/sales.html": "<html>sales</html>",
+	})
+	tests := []struct {
+		mode    string
+		content bool   // Published to the content folder as it is
+		static  bool   // Copied to the static folder of Quartz
+		page    string // Start of the generated page, if any
+		note    string // Note once published
+	}{
+		{htmlCopy, true, false, "", "See [[sales.html]] and [the chart](Charts/sales.html).\n"},
+		{htmlSkip, false, false, "", "See [[sales.html]] and [the chart](Charts/sales.html).\n"},
+		{htmlStatic, false, true, "", "See [sales](/static/Charts/sales.html) and [the chart](/static/Charts/sales.html).\n"},
+		{htmlIframe, false, true, "---\ntitle: \"sales\"\n---\n\n<iframe src=\"/static/Charts/sales.html\"",
+			"See [[sales]] and [the chart](Charts/sales.md).\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			quartz := t.TempDir()
+			if code := runTestSync(t, vault, quartz, "-html", tt.mode); code != exitSuccess {
+				t.Fatalf("run exited with %d", code)
+			}
+			if _, err := os.Stat(filepath.Join(quartz, "content", "Charts", "sales.html")); (err == nil) != tt.content {
+				t.Errorf("published to the content folder: %v, want %v", err == nil, tt.content)
+			}
+			if _, err := os.Stat(filepath.Join(quartz, "quartz", "static", "Charts", "sales.html")); (err == nil) != tt.static {
+				t.Errorf("copied to the static folder: %v, want %v", err == nil, tt.static)
+			}
+			page, err := os.ReadFile(filepath.Join(quartz, "content", "Charts", "sales.md"))
+			if tt.page == "" && err == nil {
+				t.Errorf("page generated for the HTML file: %q", page)
+			} else if tt.page != "" && !strings.HasPrefix(string(page), tt.page) {
+				t.Errorf("generated page = %q, want it to start with %q", page, tt.page)
+			}
+			if note := readContent(t, quartz, "Note.md"); note != tt.note {
+				t.Errorf("Note.md = %q, want %q", note, tt.note)
+			}
+		})
+	}
+}
+
+func TestStaticURL(t *testing.T) {
+	tests := map[string]string{
+		"chart.html":             "/static/chart.html",
+		"Charts/Q1 sales.html":   "/static/Charts/Q1%20sales.html",
+		"Charts/R&D/100%.html":   "/static/Charts/R&D/100%25.html",
+		"Graphiques/été.html":    "/static/Graphiques/%C3%A9t%C3%A9.html",
+		"Charts/what?#ever.html": "/static/Charts/what%3F%23ever.html",
+	}
+	for relPath, want := range tests {
+		if got := staticURL(relPath); got != want {
+			t.Errorf("staticURL(%q) = %q, want %q", relPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package o2q
+
+import (
+	"regexp"
+	"strings"
+)
+
+// templateSyntaxRe matches Templater commands and Obsidian template placeholders left unexpanded in a note
+// A Templater command spanning several lines is matched from its opening <% to the end of the line
+var templateSyntaxRe = regexp.MustCompile(`<%.*?(?:%>|$)|\{\{.*?\}\}`)
+
+// templateFinding is template syntax found in a published note, listed in the summary
+type templateFinding struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Text   string `json:"text"`
+}
+
+// stripHTMLComments removes the <!-- ... --> comments of a note, including those spanning several lines
+// Comments in code blocks and code spans are kept, and so is a comment that is never closed
+// Lines left blank by the removal are dropped, unless keepLines is set, which keeps the line numbers of the note
+func stripHTMLComments(content []byte, keepLines bool) (out []byte, unclosed bool) {
+	var b, line strings.Builder
+	s := string(content)
+	fence := ""
+	atLineStart := true // s starts a line, and not the rest of one after a comment
+	removed := false    // A comment was removed from the line being written
+
+	// endLine writes the line being built, unless only a comment made it blank
+	endLine := func() {
+		if !removed || keepLines || !isBlankLine(line.String()) {
+			b.WriteString(line.String())
+		}
+		line.Reset()
+		removed = false
+	}
+
+	for len(s) > 0 {
+		text := s
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			text = s[:i+1]
+		}
+		if fence != "" || atLineStart {
+			if fence != "" {
+				if closesFence(text, fence) {
+					fence = ""
+				}
+				b.WriteString(text)
+				s = s[len(text):]
+				continue
+			}
+			if marker, _, ok := parseFence(text); ok {
+				fence = marker
+				b.WriteString(text)
+				s = s[len(text):]
+				continue
+			}
+		}
+
+		start := commentStart(text)
+		if start < 0 {
+			line.WriteString(text)
+			endLine()
+			s = s[len(text):]
+			atLineStart = true
+			continue
+		}
+		end := strings.Index(s[start+4:], "-->")
+		if end < 0 {
+			line.WriteString(s)
+			endLine()
+			return []byte(b.String()), true
+		}
+		end += start + 4 + len("-->")
+		line.WriteString(text[:start])
+		if keepLines {
+			// The lines of the comment are kept empty, and the line after it starts where the comment ended
+			for _, r := range s[start:end] {
+				if r == '\n' {
+					line.WriteString("\n")
+					endLine()
+				}
+			}
+		}
+		removed = true
+		s = s[end:]
+		atLineStart = false
+	}
+	if line.Len() > 0 {
+		endLine()
+	}
+	return []byte(b.String()), false
+}
+
+// commentStart returns the index of the first <!-- of a line that is not in a code span, or -1
+func commentStart(line string) int {
+	for i := 0; i < len(line); {
+		if line[i] == '`' {
+			n := 0
+			for i+n < len(line) && line[i+n] == '`' {
+				n++
+			}
+			if end := findBacktickRun(line, i+n, n); end >= 0 {
+				i = end + n
+			} else {
+				i += n
+			}
+			continue
+		}
+		if strings.HasPrefix(line[i:], "<!--") {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// checkTemplateSyntax looks for Templater commands and template placeholders left in a note, such as
+// <% tp.date.now() %> or {{title}}, which Quartz would publish as they are; they are listed in the summary
+// Code is ignored, and so are comments when --strip-html-comments removes them; line numbers are those of the vault file
+func (c *converter) checkTemplateSyntax(src string, content []byte) {
+	if !c.opts.warnTemplateSyntax {
+		return
+	}
+	if c.opts.stripHTMLComments {
+		content, _ = stripHTMLComments(content, true)
+	}
+	fence := ""
+	for i, line := range splitLines(content) {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			fence = marker
+			continue
+		}
+		mapOutsideCodeSpans(line, func(text string) string {
+			for _, match := range templateSyntaxRe.FindAllString(text, -1) {
+				c.report.TemplateSyntax = append(c.report.TemplateSyntax, templateFinding{
+					Source: c.paths.source(src),
+					Line:   i + 1,
+					Text:   strings.TrimSpace(match),
+				})
+			}
+			return text
+		})
+	}
+}
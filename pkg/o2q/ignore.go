@@ -0,0 +1,201 @@
+package o2q
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the name of the ignore file looked up at the root of the Obsidian folder
+const ignoreFileName = ".obsidian-to-quartz-ignore"
+
+// ignorePattern is an exclusion pattern of the ignore file, the config or --exclude, parsed once for the run
+type ignorePattern struct {
+	text     string   // Pattern as written
+	path     string   // Pattern with forward slashes, without the leading / of an anchored pattern or the / of a folder pattern
+	dirOnly  bool     // Pattern ending with /, which only matches folders
+	anchored bool     // Pattern with a / other than a trailing one, matched from the root of the vault rather than at any depth
+	segments []string // Segments of a pattern with wildcards, nil for a plain path
+	negate   bool     // Ignore file line starting with !, which publishes again what the lines before it exclude
+	base     string   // Vault-relative folder of the ignore file holding the pattern, with forward slashes; "" for the root
+}
+
+// compileIgnorePattern parses an exclusion pattern, which follows the rules of .gitignore
+// A pattern holding *, ?, [ or \ is a glob: * and ? stop at /, ** matches any number of folders,
+// and \ escapes the character after it; other characters, spaces and parentheses included, match themselves
+// As in .gitignore, a pattern without a / other than a trailing one matches a name at any depth, and a leading /
+// anchors a pattern at the root of the vault
+func compileIgnorePattern(text string) (ignorePattern, error) {
+	p := ignorePattern{text: text, path: filepath.ToSlash(text)}
+	if strings.HasSuffix(p.path, "/") {
+		p.dirOnly = true
+		p.path = strings.TrimRight(p.path, "/")
+	}
+	p.anchored = strings.Contains(p.path, "/")
+	p.path = strings.TrimPrefix(p.path, "/")
+	if p.path == "" {
+		return p, fmt.Errorf("invalid pattern %q: it names no file or folder", text)
+	}
+	if !strings.ContainsAny(p.path, `*?[\`) {
+		return p, nil
+	}
+	p.segments = strings.Split(p.path, "/")
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return p, fmt.Errorf("invalid pattern %q: an unclosed [ or a \\ escaping nothing", text)
+		}
+	}
+	return p, nil
+}
+
+// compileIgnorePatterns parses a list of exclusion patterns, naming where they come from in the error
+func compileIgnorePatterns(texts []string, origin string) ([]ignorePattern, error) {
+	var patterns []ignorePattern
+	for _, text := range texts {
+		p, err := compileIgnorePattern(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", origin, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// readExcludePatterns reads exclusion patterns from an ignore file
+// A missing file has no patterns, unless it was given with --ignore-file
+// A line starting with ! is a negation, publishing again what the lines before it exclude, or a template or script
+// folder that would be excluded automatically
+// An invalid pattern is an error giving its line
+func readExcludePatterns(vault *vaultFS, ignoreFile string, required bool) ([]ignorePattern, error) {
+	file, err := vault.Open(ignoreFile)
+	if os.IsNotExist(err) && !required {
+		return []ignorePattern{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %v", err)
+	}
+	defer file.Close()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		// As in .gitignore, trailing spaces are dropped unless escaped with \, and \# and \! start a pattern with # or !
+		text := strings.TrimLeft(strings.TrimRight(scanner.Text(), "\r"), " \t")
+		for strings.HasSuffix(text, " ") && !strings.HasSuffix(text, `\ `) {
+			text = strings.TrimSuffix(text, " ")
+		}
+		// Skip empty lines and comments
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(text, "!")
+		p, err := compileIgnorePattern(strings.TrimPrefix(text, "!"))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", ignoreFile, line, err)
+		}
+		p.negate = negate
+		patterns = append(patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %v", err)
+	}
+	return patterns, nil
+}
+
+// readNestedIgnoreFiles reads the ignore files of the folders of the vault, whose patterns apply inside their folder
+// like those of nested .gitignore files; each file comes after those of the folders holding it, so it takes precedence
+// Hidden folders and the folders excluded by the patterns read so far are not searched
+func readNestedIgnoreFiles(log *consoleLogger, vault *vaultFS, patterns []ignorePattern) ([]ignorePattern, error) {
+	all := append([]ignorePattern{}, patterns...)
+	err := fs.WalkDir(vault.fsys, ".", func(relPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || relPath == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || shouldExclude(relPath, all, true) {
+			return fs.SkipDir
+		}
+		file := filepath.Join(vault.root, filepath.FromSlash(relPath), ignoreFileName)
+		found, err := readExcludePatterns(vault, file, false)
+		if err != nil {
+			return err
+		}
+		if len(found) > 0 {
+			log.progressf("Loaded %d exclusion patterns from %s", len(found), path.Join(relPath, ignoreFileName))
+		}
+		for _, p := range found {
+			p.base = relPath
+			all = append(all, p)
+		}
+		return nil
+	})
+	return all[len(patterns):], err
+}
+
+// matches checks if a vault path matches the pattern
+// An anchored pattern matches the whole path, and one that is not the file or folder name, so *.tmp matches
+// temporary files in any folder; what is inside a matching folder is excluded with it, see shouldExclude
+func (p ignorePattern) matches(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		var ok bool
+		if relPath, ok = strings.CutPrefix(relPath, p.base+"/"); !ok {
+			return false
+		}
+	}
+	if !p.anchored {
+		relPath = path.Base(relPath)
+	}
+	if p.segments != nil {
+		segments := strings.Split(relPath, "/")
+		// A trailing /** matches what is inside a folder, not the folder itself
+		if p.segments[len(p.segments)-1] == "**" && len(segments) < len(p.segments) {
+			return false
+		}
+		return matchSegments(p.segments, segments)
+	}
+	return relPath == p.path
+}
+
+// mayMatchInside checks if the pattern may match a path inside a folder, so the folder must be walked
+func (p ignorePattern) mayMatchInside(dir string) bool {
+	if p.base != "" {
+		inside, ok := strings.CutPrefix(filepath.ToSlash(dir)+"/", p.base+"/")
+		if !ok {
+			// Only the folders holding the ignore file lead to what it matches
+			return strings.HasPrefix(p.base+"/", filepath.ToSlash(dir)+"/")
+		}
+		if inside == "" {
+			return true
+		}
+		dir = strings.TrimSuffix(inside, "/")
+	}
+	return !p.anchored || mayMatchInside(p.path, dir)
+}
+
+// shouldExclude checks if a path is excluded by the patterns
+// As in .gitignore, the last matching pattern wins, so a negation publishes again what an earlier pattern excludes,
+// and nothing inside an excluded folder can be published again
+func shouldExclude(relPath string, patterns []ignorePattern, isDir bool) bool {
+	// Normalize path separators for consistent matching
+	relPath = filepath.ToSlash(relPath)
+	if dir := path.Dir(relPath); dir != "." && dir != "/" && shouldExclude(dir, patterns, true) {
+		return true
+	}
+
+	excluded := false
+	for _, p := range patterns {
+		if p.matches(relPath, isDir) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
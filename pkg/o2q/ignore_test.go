@@ -0,0 +1,57 @@
+package o2q
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShouldExclude(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		relPath  string
+		isDir    bool
+		want     bool
+	}{
+		{"plain path", []string{"Private"}, "Private", true, true},
+		{"inside a plain path", []string{"Private"}, "Private/Diary/2024.md", false, true},
+		{"plain name at any depth", []string{"Private"}, "Work/Private/Notes.md", false, true},
+		{"plain name, other name", []string{"Private"}, "Privateer.md", false, false},
+		{"folder pattern, folder", []string{"Drafts/"}, "Drafts", true, true},
+		{"folder pattern, file", []string{"Drafts/"}, "Drafts", false, false},
+		{"inside a folder pattern", []string{"Drafts/"}, "Drafts/Idea.md", false, true},
+		{"anchored path", []string{"/index.md"}, "index.md", false, true},
+		{"anchored path, other folder", []string{"/index.md"}, "Projects/index.md", false, false},
+		{"path with a folder is anchored", []string{"Projects/old"}, "Archive/Projects/old", true, false},
+		{"file name glob", []string{"*.tmp"}, "Journal/draft.tmp", false, true},
+		{"glob does not cross folders", []string{"Journal/*.md"}, "Journal/2024/Jan.md", false, false},
+		{"** at any depth", []string{"**/scratch-*.md"}, "Projects/Alpha/scratch-1.md", false, true},
+		{"** at the root", []string{"**/scratch-*.md"}, "scratch-2.md", false, true},
+		{"** inside a path", []string{"Projects/**/notes.md"}, "Projects/a/b/notes.md", false, true},
+		{"case matters", []string{"private"}, "Private/Notes.md", false, false},
+		{"negation", []string{"*.png", "!cover.png"}, "assets/cover.png", false, false},
+		{"last match wins", []string{"!cover.png", "*.png"}, "assets/cover.png", false, true},
+		{"negation inside an excluded folder", []string{"assets/", "!assets/cover.png"}, "assets/cover.png", false, true},
+		{"escaped wildcard", []string{`What\?.md`}, "What?.md", false, true},
+		{"escaped wildcard, other name", []string{`What\?.md`}, "Whats.md", false, false},
+		{"no patterns", nil, "Note.md", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patterns []ignorePattern
+			for _, line := range tt.patterns {
+				// A line starting with ! publishes paths again, as in the ignore file
+				text, negate := strings.CutPrefix(line, "!")
+				p, err := compileIgnorePattern(text)
+				if err != nil {
+					t.Fatalf("compileIgnorePattern(%q) error = %v", text, err)
+				}
+				p.negate = negate
+				patterns = append(patterns, p)
+			}
+			if got := shouldExclude(tt.relPath, patterns, tt.isDir); got != tt.want {
+				t.Errorf("shouldExclude(%q) with %q = %v, want %v", tt.relPath, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
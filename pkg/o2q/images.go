@@ -0,0 +1,74 @@
+package o2q
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Sized image embed handling modes
+const (
+	imageSizeKeep = "keep" // Leave sized image embeds untouched
+	imageSizeAlt  = "alt"  // Rewrite sized wiki embeds into markdown embeds with the size in the alt text
+	imageSizeHTML = "html" // Rewrite sized image embeds into <img> tags with width and height
+)
+
+// imageSizeRe matches the size of an image embed: 400 or 400x300
+var imageSizeRe = regexp.MustCompile(`^\s*(\d+)(?:\s*x\s*(\d+))?\s*$`)
+
+// imageLinks matches links to images, the files Quartz embeds as images
+var imageLinks = newLinkPattern("png", "jpg", "jpeg", "gif", "svg", "webp", "avif")
+
+// imageSize splits the alias of a wiki embed or the alt text of a markdown embed into its text and the size after its last |
+// The size of a wiki embed may also be its whole alias; ok is false without a size, as in ![[photo.png|my caption]]
+func imageSize(link fileLink) (alt, width, height string, ok bool) {
+	size := link.text
+	if i := strings.LastIndex(link.text, "|"); i >= 0 {
+		alt, size = strings.TrimSpace(link.text[:i]), link.text[i+1:]
+	} else if !link.wiki {
+		return "", "", "", false
+	}
+	m := imageSizeRe.FindStringSubmatch(size)
+	if m == nil {
+		return "", "", "", false
+	}
+	return alt, m[1], m[2], true
+}
+
+// rewriteImageSizes rewrites image embeds sized the Obsidian way, which Quartz would show at full size with the size as caption
+//   - alt: ![[photo.png|400]] → ![|400](photo.png), ![[photo.png|my caption|400x300]] → ![my caption|400x300](photo.png)
+//   - html: ![[photo.png|400]] and ![|400](photo.png) → <img src="photo.png" alt="" width="400">
+//
+// Embeds without a size, links, Excalidraw drawings, code blocks and inline code are left untouched
+func (c *converter) rewriteImageSizes(src string, content []byte) []byte {
+	if c.opts.imageSize == imageSizeKeep {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	return mapOutsideCode(content, func(text string) string {
+		return string(imageLinks.rewrite([]byte(text), func(link fileLink) string {
+			if !link.embed || strings.Contains(strings.ToLower(link.target), ".excalidraw.") {
+				return link.String()
+			}
+			alt, width, height, ok := imageSize(link)
+			if !ok || (c.opts.imageSize == imageSizeAlt && !link.wiki) {
+				return link.String()
+			}
+
+			target := c.embedURL(noteDir, link)
+			if c.opts.imageSize == imageSizeAlt {
+				size := width
+				if height != "" {
+					size += "x" + height
+				}
+				return "![" + alt + "|" + size + "](" + target + ")"
+			}
+			tag := `<img src="` + html.EscapeString(target) + `" alt="` + html.EscapeString(alt) + `" width="` + width + `"`
+			if height != "" {
+				tag += ` height="` + height + `"`
+			}
+			return tag + ">"
+		}))
+	})
+}
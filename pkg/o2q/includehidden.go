@@ -0,0 +1,127 @@
+package o2q
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// hiddenInclude publishes the files of hidden folders matching a pattern, which the walk otherwise skips
+//
+//	.obsidian/snippets/*.css=>styles → .obsidian/snippets/callouts.css is published as styles/callouts.css
+type hiddenInclude struct {
+	text    string // Entry as written
+	pattern string // Vault-relative glob, with forward slashes
+	dest    string // Content-relative folder the files are published in, with forward slashes; "" for their own path
+	matched int    // Files published by the entry in this run
+}
+
+// parseHiddenIncludes reads --include-hidden entries of the form "pattern", or "pattern=>folder" to publish
+// the matching files in a folder of the content folder instead of under their own path
+func parseHiddenIncludes(entries []string) ([]*hiddenInclude, error) {
+	var includes []*hiddenInclude
+	for _, entry := range entries {
+		pattern, dest, mapped := strings.Cut(entry, folderMapSeparator)
+		h := &hiddenInclude{text: entry, pattern: strings.Trim(filepath.ToSlash(strings.TrimSpace(pattern)), "/")}
+		if h.pattern == "" || strings.HasPrefix(h.pattern, "../") || h.pattern == ".." || path.IsAbs(h.pattern) {
+			return nil, fmt.Errorf("%q: the pattern must be a relative path inside the vault", entry)
+		}
+		for _, segment := range strings.Split(h.pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("%q: an unclosed [ or a \\ escaping nothing", entry)
+			}
+		}
+		if mapped {
+			h.dest = path.Clean(filepath.ToSlash(strings.TrimSpace(dest)))
+			if !filepath.IsLocal(h.dest) && h.dest != "." {
+				return nil, fmt.Errorf("%q: the published folder must be a relative path inside the content folder", entry)
+			}
+		}
+		includes = append(includes, h)
+	}
+	return includes, nil
+}
+
+// hiddenIncludeOf returns the entry of --include-hidden publishing a vault file, or nil
+func (c *converter) hiddenIncludeOf(relPath string) *hiddenInclude {
+	relPath = filepath.ToSlash(relPath)
+	for _, h := range c.hiddenIncludes {
+		if globMatch(h.pattern, relPath) {
+			return h
+		}
+	}
+	return nil
+}
+
+// mayIncludeHidden checks if an --include-hidden pattern may match a file inside a hidden folder, so the walk enters it
+// A pattern without / matches file names in any hidden folder
+func (c *converter) mayIncludeHidden(dir string) bool {
+	for _, h := range c.hiddenIncludes {
+		if !strings.Contains(h.pattern, "/") || mayMatchInside(h.pattern, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenDest returns the content-relative path a file of a hidden folder is published to
+func (c *converter) hiddenDest(h *hiddenInclude, relPath string) string {
+	if h.dest == "" {
+		return c.destRel(relPath)
+	}
+	return filepath.FromSlash(path.Join(h.dest, c.normalizeName(path.Base(filepath.ToSlash(relPath)))))
+}
+
+// planHiddenFolder plans the files of a hidden folder matching --include-hidden, walking it apart from the vault
+// These files are published whatever the ignore patterns say, as they were named one by one
+func (c *converter) planHiddenFolder(dir string, info os.FileInfo) error {
+	err := c.walkPath(dir, info, make(map[string]bool), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(c.obsidianFolder, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		if info.IsDir() {
+			if c.mayIncludeHidden(relPath) {
+				return nil
+			}
+			return filepath.SkipDir
+		}
+		h := c.hiddenIncludeOf(relPath)
+		if h == nil || !c.inExport(relPath, false) {
+			return nil
+		}
+		f := plannedFile{src: path, relPath: relPath, info: info, action: planPublish}
+		f.destRel = c.hiddenDest(h, relPath)
+		f.dest = filepath.Join(c.contentFolder, f.destRel)
+		h.matched++
+		c.report.IncludedHidden++
+		c.console.progressf("Included: %s (--include-hidden %s)", path, h.text)
+		if c.unchangedSinceState(relPath, path, info) {
+			f.action = planUnchangedState
+		} else if f.err = c.claimPlannedDest(relPath, path, f.dest); f.err != nil {
+			var r refusal
+			f.action, f.refused = actionError, errors.As(f.err, &r)
+		}
+		c.plan.add(f)
+		return nil
+	})
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// warnUnusedHiddenIncludes warns about the --include-hidden entries that matched no file, usually a typo in the pattern
+func (c *converter) warnUnusedHiddenIncludes() {
+	for _, h := range c.hiddenIncludes {
+		if h.matched == 0 {
+			c.console.warnf("--include-hidden %s matches no file of a hidden folder", h.text)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package o2q
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseHiddenIncludes(t *testing.T) {
+	includes, err := parseHiddenIncludes([]string{".obsidian/snippets/*.css", " .obsidian/favicon.png => . ", "*.css=>styles/"})
+	if err != nil {
+		t.Fatalf("parseHiddenIncludes() error = %v", err)
+	}
+	want := []struct{ pattern, dest string }{{".obsidian/snippets/*.css", ""}, {".obsidian/favicon.png", "."}, {"*.css", "styles"}}
+	for i, w := range want {
+		if includes[i].pattern != w.pattern || includes[i].dest != w.dest {
+			t.Errorf("parseHiddenIncludes()[%d] = %q, %q, want %q, %q", i, includes[i].pattern, includes[i].dest, w.pattern, w.dest)
+		}
+	}
+	for _, entry := range []string{"", "../.obsidian/*.css", ".obsidian/[ab.css", ".obsidian/*.css=>../styles"} {
+		if _, err := parseHiddenIncludes([]string{entry}); err == nil {
+			t.Errorf("parseHiddenIncludes(%q) error = nil, want an invalid entry", entry)
+		}
+	}
+}
+
+func TestMayIncludeHidden(t *testing.T) {
+	includes, err := parseHiddenIncludes([]string{".obsidian/snippets/*.css"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &converter{hiddenIncludes: includes}
+	for dir, want := range map[string]bool{".obsidian": true, ".obsidian/snippets": true, ".trash": false, ".obsidian/plugins": false} {
+		if got := c.mayIncludeHidden(dir); got != want {
+			t.Errorf("mayIncludeHidden(%q) = %v, want %v", dir, got, want)
+		}
+	}
+	// A pattern without / may match in any hidden folder
+	c.hiddenIncludes, _ = parseHiddenIncludes([]string{"favicon.png"})
+	if !c.mayIncludeHidden(".trash") {
+		t.Error("mayIncludeHidden(.trash) = false with a name pattern")
+	}
+}
+
+func TestIncludeHidden(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"index.md":                          "Home\n",
+		".obsidian/app.json":                "{}\n",
+		".obsidian/snippets/callouts.css":   ".callout {}\n",
+		".obsidian/snippets/wide.css":       ".wide {}\n",
+		".obsidian/snippets/readme.txt":     "Snippets\n",
+		".obsidian/favicon.png":             "png",
+		".trash/old.md":                     "Old\n",
+		".obsidian/plugins/theme/theme.css": ".theme {}\n",
+	})
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", nil, "index.md"},
+		{"own path", []string{"-include-hidden", ".obsidian/snippets/*.css"},
+			".obsidian/snippets/callouts.css, .obsidian/snippets/wide.css, index.md"},
+		{"mapped", []string{"-include-hidden", ".obsidian/snippets/*.css=>styles", "-include-hidden", ".obsidian/favicon.png=>."},
+			"favicon.png, index.md, styles/callouts.css, styles/wide.css"},
+		// The files were named one by one, so the ignore patterns do not leave them out
+		{"over the ignore patterns", []string{"-include-hidden", ".obsidian/snippets/*.css", "-exclude", ".obsidian/", "-exclude", "*.css"},
+			".obsidian/snippets/callouts.css, .obsidian/snippets/wide.css, index.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quartz := t.TempDir()
+			if code := runTestSync(t, vault, quartz, tt.args...); code != exitSuccess {
+				t.Fatalf("run exited with %d", code)
+			}
+			if got := contentFiles(t, quartz); got != tt.want {
+				t.Errorf("published %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnusedHiddenInclude(t *testing.T) {
+	vault := writeVault(t, map[string]string{"index.md": "Home\n", ".obsidian/snippets/callouts.css": ".callout {}\n"})
+	opts := testOptions(t, "-include-hidden", ".obsidian/snippets/*.css", "-include-hidden", ".obsidian/snipets/*.css")
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var messages bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &messages}
+	if code := runSources(log, opts, config{}, sources, t.TempDir(), ""); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	if got := messages.String(); !strings.Contains(got, "--include-hidden .obsidian/snipets/*.css matches no file") ||
+		strings.Contains(got, "--include-hidden .obsidian/snippets/*.css matches") {
+		t.Errorf("messages do not warn about the unused entry only:\n%s", got)
+	}
+}
+
+func TestIncludeHiddenConfig(t *testing.T) {
+	path := writeConfig(t, "include-hidden:\n  - .obsidian/snippets/*.css=>styles\n  - .obsidian/favicon.png\n")
+	var opts options
+	if _, err := loadConfig(console, path, newOptionRegistry(&opts), nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(opts.includeHidden, ", "); got != ".obsidian/snippets/*.css=>styles, .obsidian/favicon.png" {
+		t.Errorf("include-hidden read from the config = %s", got)
+	}
+}
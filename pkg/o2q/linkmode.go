@@ -0,0 +1,78 @@
+package o2q
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Modes of --link-mode for the files published as they are
+const (
+	linkModeCopy     = "copy"     // Copy the bytes of the file
+	linkModeHardlink = "hardlink" // Link the destination to the file of the vault
+	linkModeReflink  = "reflink"  // Clone the file, sharing its blocks until either copy changes (btrfs, XFS)
+)
+
+// linkFile publishes a file of the vault without copying its bytes, with a hard link or a reflink
+// Like every write of the run, the destination is replaced through a temporary file, never written to,
+// so changing or relinking it cannot change the vault; a destination already hard-linked to the source is left as it is
+// Returns false, with a warning on the first failure of the run, if the file system cannot do it; the file is then copied
+// A --hook-file command may write to the files it is given, which for a hard link is the file of the vault, so with
+// --hook-file hard links are never made
+func (c *converter) linkFile(src, dest string, info os.FileInfo) bool {
+	var err error
+	if c.opts.linkMode == linkModeHardlink && c.opts.hookFile != "" {
+		err = errHardlinkHooked
+	} else if c.opts.linkMode == linkModeHardlink {
+		if existing, statErr := os.Stat(dest); statErr == nil && os.SameFile(info, existing) {
+			return true
+		}
+		err = hardlinkAtomic(src, dest)
+	} else {
+		_, err = writeFileAtomic(dest, info.Mode().Perm(), func(w io.Writer) (int64, error) {
+			srcFile, err := os.Open(src)
+			if err != nil {
+				return 0, err
+			}
+			defer srcFile.Close()
+			return 0, cloneFile(w.(*os.File), srcFile)
+		})
+	}
+	if err != nil {
+		if !c.linkFallbackWarned {
+			c.linkFallbackWarned = true
+			c.console.warnf("cannot %s %s: %v; it is copied instead, as are the other files that cannot be linked", c.opts.linkMode, src, err)
+		}
+		return false
+	}
+	return true
+}
+
+// errHardlinkHooked explains why files are copied rather than hard-linked with --hook-file
+var errHardlinkHooked = errors.New("--hook-file would change the vault through the link")
+
+// hardlinkAtomic links dest to src, through a temporary link in the folder of dest renamed over it
+func hardlinkAtomic(src, dest string) error {
+	for i := 0; ; i++ {
+		// The name matches tempFileRe, so a link left by an interrupted run is removed by the next one
+		tmp := filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+".tmp"+strconv.FormatInt(time.Now().UnixNano()+int64(i), 10))
+		err := os.Link(src, tmp)
+		if errors.Is(err, os.ErrExist) && i < 10 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, dest); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+}
+
+// errReflinkUnsupported is returned by cloneFile where reflinks are not available
+var errReflinkUnsupported = errors.New("reflinks are not supported on this system")
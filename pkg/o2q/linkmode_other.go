@@ -0,0 +1,10 @@
+//go:build !linux
+
+package o2q
+
+import "os"
+
+// cloneFile makes dst a reflink of src, which is only implemented on Linux
+func cloneFile(dst, src *os.File) error {
+	return errReflinkUnsupported
+}
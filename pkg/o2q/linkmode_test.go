@@ -0,0 +1,76 @@
+package o2q
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHardlink(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n", "pic.png": "orig"})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-link-mode", "hardlink"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	src, err := os.Stat(filepath.Join(vault, "pic.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest, err := os.Stat(filepath.Join(quartz, "content", "pic.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(src, dest) {
+		t.Error("pic.png is not published as a hard link")
+	}
+}
+
+func TestHardlinkFileHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook needs a POSIX shell")
+	}
+	// A hook appending to the published file would append to the file of the vault through a hard link
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n", "pic.png": "orig"})
+	quartz := t.TempDir()
+	hook := `sh -c "echo hooked >> {dest}"`
+	if code := runTestSync(t, vault, quartz, "-link-mode", "hardlink", "-hook-file", hook); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	data, err := os.ReadFile(filepath.Join(vault, "pic.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "orig" {
+		t.Errorf("vault pic.png = %q after the hook, want it unchanged", data)
+	}
+	if got := readContent(t, quartz, "pic.png"); got != "orighooked\n" {
+		t.Errorf("published pic.png = %q, want the hook applied", got)
+	}
+}
+
+func TestLinkModeSummary(t *testing.T) {
+	tests := []struct {
+		mode                          string
+		copied, hardlinked, reflinked int
+		want                          string // Line about the mode used, or "" for none
+	}{
+		{linkModeHardlink, 3, 3, 0, ""},
+		{linkModeReflink, 3, 0, 0, "--link-mode reflink requested, copy used"},
+		{linkModeHardlink, 3, 2, 0, "--link-mode hardlink requested, hardlink and copy used"},
+		{linkModeReflink, 3, 0, 1, "--link-mode reflink requested, reflink and copy used"},
+	}
+	for _, tt := range tests {
+		r := &runReport{LinkMode: tt.mode, Copied: tt.copied, Hardlinked: tt.hardlinked, Reflinked: tt.reflinked}
+		var out bytes.Buffer
+		r.print(&out)
+		if !strings.Contains(out.String(), "byte copies: ") {
+			t.Errorf("%s, %d copied, %d hardlinked, %d reflinked: summary has no breakdown:\n%s", tt.mode, tt.copied, tt.hardlinked, tt.reflinked, out.String())
+		}
+		if got := strings.Contains(out.String(), "requested"); got != (tt.want != "") || !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s, %d copied, %d hardlinked, %d reflinked: summary\n%s\nwant %q", tt.mode, tt.copied, tt.hardlinked, tt.reflinked, out.String(), tt.want)
+		}
+	}
+}
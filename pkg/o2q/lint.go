@@ -0,0 +1,191 @@
+package o2q
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lintRule describes a construct that Obsidian renders differently than Quartz's CommonMark parser
+// Contributors can add rules to lintRules; fix is nil when no safe automatic correction exists
+type lintRule struct {
+	name        string
+	explanation string
+	check       func(lines []string, bodyStart, i int) bool // Reports whether line i triggers the rule
+	fix         func(line string) string                    // Returns the corrected line, which may span several lines
+}
+
+// lintRules lists every lint rule, in the order they are checked
+var lintRules = []lintRule{
+	{
+		name:        "list-indent",
+		explanation: "list item indented with 3 spaces; Obsidian nests it, CommonMark may not (indent with 2 or 4 spaces)",
+		check: func(lines []string, bodyStart, i int) bool {
+			return threeSpaceListRe.MatchString(lines[i])
+		},
+	},
+	{
+		name:        "line-start-angle",
+		explanation: "line starts with < followed by a name that is not an HTML element; Quartz treats it as raw HTML and hides it (escape it as \\<)",
+		check: func(lines []string, bodyStart, i int) bool {
+			m := lineStartTagRe.FindStringSubmatch(lines[i])
+			return m != nil && !htmlElements[strings.ToLower(m[1])]
+		},
+		fix: func(line string) string {
+			return strings.Replace(line, "<", `\<`, 1)
+		},
+	},
+}
+
+// threeSpaceListRe matches a list item indented with exactly 3 spaces
+var threeSpaceListRe = regexp.MustCompile(`^   (?:[-*+]|\d{1,9}[.)])[ \t]`)
+
+// lineStartTagRe matches a line starting with what looks like an HTML tag, capturing its name
+var lineStartTagRe = regexp.MustCompile(`^ {0,3}</?([A-Za-z][A-Za-z0-9-]*)`)
+
+// htmlElements lists the HTML elements that may legitimately start a line of a note
+var htmlElements = make(map[string]bool)
+
+func init() {
+	for _, name := range strings.Fields(`a abbr address article aside audio b blockquote body br button caption center cite code col colgroup
+		dd del details dfn div dl dt em embed fieldset figcaption figure font footer form h1 h2 h3 h4 h5 h6 head header hr html
+		i iframe img input ins kbd label legend li main mark nav object ol optgroup option p picture pre q s samp script section
+		select small source span strong style sub summary sup svg table tbody td textarea tfoot th thead title tr u ul var video wbr`) {
+		htmlElements[name] = true
+	}
+}
+
+// parseLintRules checks a comma-separated list of rule names and returns them as a set
+func parseLintRules(list string) (map[string]bool, error) {
+	rules := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if findLintRule(name) == nil {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		rules[name] = true
+	}
+	return rules, nil
+}
+
+// lintRuleNames returns the names of all lint rules, comma-separated
+func lintRuleNames() string {
+	names := make([]string, len(lintRules))
+	for i, rule := range lintRules {
+		names[i] = rule.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// findLintRule returns the lint rule with the given name, or nil
+func findLintRule(name string) *lintRule {
+	for i := range lintRules {
+		if lintRules[i].name == name {
+			return &lintRules[i]
+		}
+	}
+	return nil
+}
+
+// checkVault lints every note that would be published, without writing anything
+// Returns the number of notes with findings
+func (c *converter) checkVault() (int, error) {
+	notes := 0
+	err := c.walkEligible(func(relPath string) error {
+		if !hasExt(relPath, ".md") {
+			return nil
+		}
+		path := filepath.Join(c.obsidianFolder, relPath)
+		content, err := c.vault.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read markdown file: %v", err)
+		}
+
+		before := c.lintFindings
+
+		// Invalid frontmatter is a finding of its own; the rest of the note is still checked
+		values, err := parseFrontmatter(content)
+		var fmErr *frontmatterError
+		if errors.As(err, &fmErr) {
+			c.lintFindings++
+			c.reportFrontmatterError(path, fmErr, c.opts.strictFrontmatter)
+		} else {
+			c.lintFindings += c.checkFrontmatterPitfalls(path, content)
+		}
+		if c.unpublishedReason(values) != "" {
+			if c.lintFindings > before {
+				notes++
+			}
+			return nil
+		}
+		if err := c.checkFrontmatterRules(path, content); err != nil {
+			c.console.errorf("%s: %v", path, err)
+		}
+		c.transformMarkdown(path, content)
+		if c.lintFindings > before {
+			notes++
+		}
+		return nil
+	})
+	return notes, err
+}
+
+// lintMarkdown reports the lint findings of a note with the check command, and applies the safe fixes if --fix is set
+// A sync without --fix leaves the note alone; frontmatter and fenced code blocks are never checked
+func (c *converter) lintMarkdown(src string, content []byte) []byte {
+	if !c.checking && !c.opts.fix {
+		return content
+	}
+	lines := splitLines(content)
+	bodyStart := 0
+	if _, body, ok := splitFrontmatter(content); ok {
+		bodyStart = len(splitLines(content[:len(content)-len(body)]))
+	}
+
+	var out strings.Builder
+	lineNumber := 0
+	for i := 0; i < len(lines); i++ {
+		lineNumber++
+		if i < bodyStart {
+			out.WriteString(lines[i])
+			continue
+		}
+		if marker, _, ok := parseFence(lines[i]); ok {
+			// Copy the whole fenced block
+			out.WriteString(lines[i])
+			for i+1 < len(lines) {
+				i++
+				lineNumber++
+				out.WriteString(lines[i])
+				if closesFence(lines[i], marker) {
+					break
+				}
+			}
+			continue
+		}
+
+		line := lines[i]
+		for _, rule := range lintRules {
+			if c.lintDisabled[rule.name] || !rule.check(lines, bodyStart, i) {
+				continue
+			}
+			if c.opts.fix && rule.fix != nil {
+				line = rule.fix(line)
+				lineNumber += strings.Count(line, "\n") - strings.Count(lines[i], "\n")
+				continue
+			}
+			if c.checking {
+				c.lintFindings++
+				c.console.warnf("%s:%d: %s: %s", src, lineNumber, rule.name, rule.explanation)
+			}
+		}
+		out.WriteString(line)
+	}
+
+	return []byte(out.String())
+}
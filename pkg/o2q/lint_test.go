@@ -0,0 +1,36 @@
+package o2q
+
+import "testing"
+
+func TestLintMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		checking bool
+		fix      bool
+		content  string
+		want     string
+		findings int
+	}{
+		{"heading after frontmatter", true, false, "---\ntitle: A\n---\n# Title\n", "---\ntitle: A\n---\n# Title\n", 0},
+		{"bare URL", true, false, "see https://example.com\n", "see https://example.com\n", 0},
+		{"list indent", true, false, "- a\n   - b\n", "- a\n   - b\n", 1},
+		{"line start angle", true, false, "<placeholder> text\n", "<placeholder> text\n", 1},
+		{"HTML element", true, false, "<div>text</div>\n", "<div>text</div>\n", 0},
+		{"fenced code", true, false, "```\n<placeholder>\n```\n", "```\n<placeholder>\n```\n", 0},
+		{"frontmatter", true, false, "---\n<placeholder>: 1\n---\n", "---\n<placeholder>: 1\n---\n", 0},
+		{"check with fix", true, true, "<placeholder> text\n   - b\n", "\\<placeholder> text\n   - b\n", 1},
+		{"sync", false, false, "<placeholder> text\n   - b\n", "<placeholder> text\n   - b\n", 0},
+		{"sync with fix", false, true, "<placeholder> text\n   - b\n", "\\<placeholder> text\n   - b\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &converter{console: console.fork(), checking: tt.checking, opts: options{fix: tt.fix}}
+			if got := string(c.lintMarkdown("Note.md", []byte(tt.content))); got != tt.want {
+				t.Errorf("lintMarkdown() = %q, want %q", got, tt.want)
+			}
+			if c.lintFindings != tt.findings {
+				t.Errorf("lintMarkdown() found %d problems, want %d", c.lintFindings, tt.findings)
+			}
+		})
+	}
+}
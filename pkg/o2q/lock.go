@@ -0,0 +1,195 @@
+package o2q
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// lockFileName is the file of the Quartz folder held by the running sync, so that two syncs never write at once
+const lockFileName = ".obsidian-to-quartz.lock"
+
+// maxRunDuration is the longest a sync is expected to run; an older lock is stale even if its PID is running,
+// as the PID may have been given to another process since
+const maxRunDuration = 12 * time.Hour
+
+// lockGracePeriod is how long a lock file may stay empty or unreadable while its process writes it
+const lockGracePeriod = 10 * time.Second
+
+// lockPollInterval is how often --wait checks if the lock was released
+const lockPollInterval = time.Second
+
+// lockInfo is the content of the lock file
+type lockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// heldLocks are the locks of the syncs running in this process, by path, released if a signal stops them
+var heldLocks struct {
+	sync.Mutex
+	paths map[string]bool
+}
+
+// stale checks if the process holding a lock is gone: its PID is not running on this computer,
+// or it started longer ago than maxRunDuration
+// A lock of another computer, on a shared folder, is only stale once that old
+func (l lockInfo) stale() bool {
+	if time.Since(l.StartedAt) > maxRunDuration {
+		return true
+	}
+	host, _ := os.Hostname()
+	return l.Host == host && !processAlive(l.PID)
+}
+
+// String describes the holder of a lock, for messages
+func (l lockInfo) String() string {
+	holder := fmt.Sprintf("PID %d", l.PID)
+	if host, _ := os.Hostname(); l.Host != "" && l.Host != host {
+		holder += " on " + l.Host
+	}
+	return fmt.Sprintf("%s, started at %s", holder, l.StartedAt.Local().Format(time.DateTime))
+}
+
+// readLock reads the lock file; a file that cannot be read is described by its modification time
+func readLock(path string) (lockInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lockInfo{}, err
+	}
+	var l lockInfo
+	if err := json.Unmarshal(data, &l); err != nil || l.PID <= 0 {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return lockInfo{}, statErr
+		}
+		// A process stopped while writing the lock left it empty, or it is being written
+		if time.Since(info.ModTime()) < lockGracePeriod {
+			return lockInfo{StartedAt: info.ModTime()}, nil
+		}
+		return lockInfo{StartedAt: info.ModTime().Add(-maxRunDuration)}, nil
+	}
+	return l, nil
+}
+
+// acquireLock creates the lock file of the Quartz folder, holding the PID and start time of the process
+// A lock held by a running sync is waited for up to wait, then refused; a stale lock is only taken over with force
+func acquireLock(log *consoleLogger, quartzFolder string, wait time.Duration, force bool) error {
+	if err := os.MkdirAll(quartzFolder, 0755); err != nil {
+		return fmt.Errorf("failed to create Quartz folder: %v", err)
+	}
+	path := filepath.Join(quartzFolder, lockFileName)
+	host, _ := os.Hostname()
+	deadline := time.Now().Add(wait)
+	waiting := false
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			data, _ := json.Marshal(lockInfo{PID: os.Getpid(), Host: host, StartedAt: time.Now()})
+			_, err = file.Write(append(data, '\n'))
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return fmt.Errorf("failed to write lock file: %v", err)
+			}
+			heldLocks.Lock()
+			if heldLocks.paths == nil {
+				heldLocks.paths = make(map[string]bool)
+			}
+			heldLocks.paths[path] = true
+			heldLocks.Unlock()
+			return nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		holder, err := readLock(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Released meanwhile
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read lock file: %v", err)
+		}
+		if holder.stale() {
+			if !force {
+				return refusedf("%s is left by a sync that is no longer running (%s); remove it with --force-unlock",
+					path, holder)
+			}
+			log.warnf("removing the stale lock %s (%s)", path, holder)
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove stale lock file: %v", err)
+			}
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			if waiting {
+				return refusedf("another sync still holds %s after %s (%s)", path, wait, holder)
+			}
+			return refusedf("another sync is writing to %s (%s); use --wait to wait for it", quartzFolder, holder)
+		}
+		if !waiting {
+			log.infof("Waiting for the sync holding %s (%s)", path, holder)
+			waiting = true
+		}
+		time.Sleep(min(lockPollInterval, time.Until(deadline)))
+	}
+}
+
+// releaseLock removes a lock file held by this process
+func releaseLock(log *consoleLogger, path string) {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	if !heldLocks.paths[path] {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.warnf("failed to remove lock file: %v", err)
+	}
+	delete(heldLocks.paths, path)
+}
+
+// releaseHeldLocks removes every lock file held by this process, when a signal stops it
+func releaseHeldLocks(log *consoleLogger) {
+	heldLocks.Lock()
+	paths := make([]string, 0, len(heldLocks.paths))
+	for path := range heldLocks.paths {
+		paths = append(paths, path)
+	}
+	heldLocks.Unlock()
+	for _, path := range paths {
+		releaseLock(log, path)
+	}
+}
+
+// withLock runs a sync holding the lock of the Quartz folder, returning its exit code
+func withLock(log *consoleLogger, opts options, quartzFolder string, run func() int) int {
+	if err := acquireLock(log, quartzFolder, opts.lockWait, opts.forceUnlock); err != nil {
+		log.errorf("%v", err)
+		return exitCodeFor(err)
+	}
+	defer releaseLock(log, filepath.Join(quartzFolder, lockFileName))
+	return run()
+}
+
+// releaseLockOnSignal removes the lock file when SIGINT or SIGTERM stops a single run, instead of leaving it behind
+// With --every, the scheduler handles these signals itself
+func releaseLockOnSignal(log *consoleLogger) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		log.errorf("received %v, stopping", sig)
+		releaseHeldLocks(log)
+		os.Exit(exitFailure)
+	}()
+}
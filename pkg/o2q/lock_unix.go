@@ -0,0 +1,14 @@
+//go:build !windows
+
+package o2q
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive checks if a process with this PID is running; EPERM means it runs as another user
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,18 @@
+package o2q
+
+import "syscall"
+
+// stillActive is the exit code GetExitCodeProcess gives for a process that has not exited
+const stillActive = 259
+
+// processAlive checks if a process with this PID is running
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access is denied to the processes of other users, which are running
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	return syscall.GetExitCodeProcess(handle, &code) == nil && code == stillActive
+}
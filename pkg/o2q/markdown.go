@@ -0,0 +1,337 @@
+package o2q
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// splitLines splits content into lines, keeping the line endings
+func splitLines(content []byte) []string {
+	var lines []string
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
+
+// parseFence checks if a line opens or closes a fenced code block
+// Returns the fence marker (e.g. "```" or "~~~~") and the info string
+func parseFence(line string) (marker string, info string, ok bool) {
+	trimmed := strings.TrimRight(line, "\r\n")
+
+	// Fences may be indented by up to three spaces
+	indent := len(trimmed) - len(strings.TrimLeft(trimmed, " "))
+	if indent > 3 {
+		return "", "", false
+	}
+	trimmed = trimmed[indent:]
+
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return "", "", false
+	}
+
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	marker = trimmed[:n]
+	info = strings.TrimSpace(trimmed[n:])
+
+	// Backtick fences cannot have backticks in their info string
+	if marker[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	return marker, info, true
+}
+
+// closesFence checks if a line closes the fence opened with the given marker
+func closesFence(line, marker string) bool {
+	closing, info, ok := parseFence(line)
+	return ok && info == "" && closing[0] == marker[0] && len(closing) >= len(marker)
+}
+
+// fenceLanguage returns the lowercased language of a fence info string
+func fenceLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// isBlankLine checks if a line contains only whitespace
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// fileLink is a wiki-style or markdown-style link to a file
+type fileLink struct {
+	embed    bool   // Link is an embed (![[...]] or ![...](...))
+	wiki     bool   // Link is wiki-style ([[...]])
+	angle    bool   // Markdown link target is written between angle brackets: [text](<my file.png>)
+	target   string // Link target without fragment, as written (percent-encoded in markdown links)
+	fragment string // Heading or block fragment including the leading #, if any
+	text     string // Alias of a wikilink or text of a markdown link
+}
+
+// String formats the link back into its original style
+func (l fileLink) String() string {
+	prefix := ""
+	if l.embed {
+		prefix = "!"
+	}
+	if l.wiki {
+		if l.text != "" {
+			return prefix + "[[" + l.target + l.fragment + "|" + l.text + "]]"
+		}
+		return prefix + "[[" + l.target + l.fragment + "]]"
+	}
+	if l.angle {
+		return prefix + "[" + l.text + "](<" + l.target + l.fragment + ">)"
+	}
+	return prefix + "[" + l.text + "](" + l.target + l.fragment + ")"
+}
+
+// displayText returns the text shown for a link: its alias or text, or the file name without extension
+func (l fileLink) displayText() string {
+	if l.text != "" {
+		return l.text
+	}
+	base := path.Base(filepath.ToSlash(l.decodedTarget()))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// decodedTarget returns the target of the link as a file path, decoding percent-encoding in markdown links
+// Wikilinks are never percent-encoded by Obsidian, so a literal % in their target is kept
+func (l fileLink) decodedTarget() string {
+	if l.wiki || l.angle {
+		return l.target
+	}
+	if decoded, err := url.PathUnescape(l.target); err == nil {
+		return decoded
+	}
+	return l.target
+}
+
+// linkPattern matches wiki-style and markdown-style links to files with a given extension
+type linkPattern struct {
+	wiki     *regexp.Regexp
+	markdown *regexp.Regexp
+}
+
+// newLinkPattern creates a linkPattern for the extensions (without dot), matched case-insensitively
+func newLinkPattern(exts ...string) linkPattern {
+	if len(exts) == 0 {
+		return linkPattern{}
+	}
+	quoted := make([]string, len(exts))
+	for i, ext := range exts {
+		quoted[i] = regexp.QuoteMeta(ext)
+	}
+	ext := `\.(?i:` + strings.Join(quoted, "|") + `)`
+	return linkPattern{
+		wiki:     regexp.MustCompile(`(!?)\[\[([^|\]#]+?` + ext + `)(#[^|\]]*)?(?:\|([^\]]*))?\]\]`),
+		markdown: regexp.MustCompile(`(!?)\[([^\]]*)\]\((?:<([^>\n]+?` + ext + `)(#[^>\n]*)?>|([^)\s]+?` + ext + `)(#[^)\s]*)?)\)`),
+	}
+}
+
+// rewrite replaces every matching link with the result of fn
+func (p linkPattern) rewrite(content []byte, fn func(link fileLink) string) []byte {
+	if p.wiki == nil {
+		return content
+	}
+	content = p.wiki.ReplaceAllFunc(content, func(match []byte) []byte {
+		parts := p.wiki.FindSubmatch(match)
+		return []byte(fn(fileLink{
+			embed:    len(parts[1]) > 0,
+			wiki:     true,
+			target:   string(parts[2]),
+			fragment: string(parts[3]),
+			text:     string(parts[4]),
+		}))
+	})
+	return p.markdown.ReplaceAllFunc(content, func(match []byte) []byte {
+		parts := p.markdown.FindSubmatch(match)
+		link := fileLink{embed: len(parts[1]) > 0, text: string(parts[2])}
+		if parts[3] != nil {
+			link.angle, link.target, link.fragment = true, string(parts[3]), string(parts[4])
+		} else {
+			link.target, link.fragment = string(parts[5]), string(parts[6])
+		}
+		if isExternalURL(link.target) {
+			return match
+		}
+		return []byte(fn(link))
+	})
+}
+
+// isExternalURL checks if a link target is a URL with a scheme (https://, mailto:, ...)
+func isExternalURL(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// relativeURL returns the URL of a vault file relative to the page of a note in noteDir
+// Quartz serves a note folder/note.md as folder/note, so relative URLs start from the note folder
+func relativeURL(noteDir, target string) string {
+	var from []string
+	for _, part := range strings.Split(noteDir, "/") {
+		if part != "" && part != "." {
+			from = append(from, part)
+		}
+	}
+	to := strings.Split(target, "/")
+
+	// Skip the folders shared by the note and the target
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+
+	var segments []string
+	for range from[common:] {
+		segments = append(segments, "..")
+	}
+	for _, segment := range to[common:] {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	return strings.Join(segments, "/")
+}
+
+// mapOutsideCode applies fn to every part of the content that is not code
+// Fenced code blocks and inline code spans are copied unchanged
+// fn is called once per line segment, including the line ending when the segment ends the line
+func mapOutsideCode(content []byte, fn func(text string) string) []byte {
+	return mapOutsideCodeKeepingLinks(content, fn)
+}
+
+// mapOutsideCodeSpans applies fn to the parts of a line that are not inline code spans
+func mapOutsideCodeSpans(line string, fn func(text string) string) string {
+	var out strings.Builder
+	start := 0
+	for _, span := range codeSpans(line) {
+		out.WriteString(fn(line[start:span[0]]))
+		out.WriteString(line[span[0]:span[1]])
+		start = span[1]
+	}
+	out.WriteString(fn(line[start:]))
+	return out.String()
+}
+
+// codeSpans returns the byte ranges of the inline code spans of a line, backticks included
+func codeSpans(line string) [][2]int {
+	var spans [][2]int
+	i := 0
+	for i < len(line) {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+
+		// Measure the opening backtick run and look for a closing run of the same length
+		n := 0
+		for i+n < len(line) && line[i+n] == '`' {
+			n++
+		}
+		end := findBacktickRun(line, i+n, n)
+		if end < 0 {
+			i += n
+			continue
+		}
+		spans = append(spans, [2]int{i, end + n})
+		i = end + n
+	}
+	return spans
+}
+
+// mapOutsideCodeKeepingLinks is mapOutsideCode for rewriting links whose text or fragment may hold inline code,
+// such as [[Guide#`go test` flags]]: a match of one of links that starts outside code and holds whole code spans
+// is given to fn in one piece instead of being split around them
+func mapOutsideCodeKeepingLinks(content []byte, fn func(text string) string, links ...*regexp.Regexp) []byte {
+	var out strings.Builder
+	lines := splitLines(content)
+
+	for i := 0; i < len(lines); i++ {
+		if marker, _, ok := parseFence(lines[i]); ok {
+			// Copy the whole fenced block
+			out.WriteString(lines[i])
+			for i+1 < len(lines) {
+				i++
+				out.WriteString(lines[i])
+				if closesFence(lines[i], marker) {
+					break
+				}
+			}
+			continue
+		}
+
+		line := lines[i]
+		start := 0
+		for _, link := range linksAroundCode(line, links) {
+			out.WriteString(mapOutsideCodeSpans(line[start:link[0]], fn))
+			out.WriteString(fn(line[link[0]:link[1]]))
+			start = link[1]
+		}
+		out.WriteString(mapOutsideCodeSpans(line[start:], fn))
+	}
+
+	return []byte(out.String())
+}
+
+// linksAroundCode returns the byte ranges of the links of a line holding inline code, in order and without overlaps
+// A link counts when it starts outside code and every code span it overlaps lies within it
+func linksAroundCode(line string, links []*regexp.Regexp) [][2]int {
+	if !strings.Contains(line, "`") {
+		return nil
+	}
+	spans := codeSpans(line)
+	var found [][2]int
+	for _, re := range links {
+	matches:
+		for _, m := range re.FindAllStringIndex(line, -1) {
+			if !strings.Contains(line[m[0]:m[1]], "`") {
+				continue
+			}
+			for _, span := range spans {
+				if span[0] < m[1] && span[1] > m[0] && (span[0] < m[0] || span[1] > m[1]) {
+					continue matches
+				}
+			}
+			found = append(found, [2]int{m[0], m[1]})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i][0] < found[j][0] })
+	var kept [][2]int
+	for _, link := range found {
+		if len(kept) == 0 || link[0] >= kept[len(kept)-1][1] {
+			kept = append(kept, link)
+		}
+	}
+	return kept
+}
+
+// findBacktickRun returns the index of the next run of exactly n backticks at or after from, or -1
+func findBacktickRun(line string, from, n int) int {
+	for i := from; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(line) && line[i+run] == '`' {
+			run++
+		}
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
@@ -0,0 +1,125 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLinkRegexps(t *testing.T) {
+	tests := []struct {
+		name string
+		re   *regexp.Regexp
+		text string
+		want []string // Submatches of the first match, whole match first; nil for no match
+	}{
+		{"wikilink", noteWikiLinkRe, "See [[Roadmap]].", []string{"[[Roadmap]]", "", "Roadmap", "", ""}},
+		{"wikilink with heading and alias", noteWikiLinkRe, "[[Projects/Roadmap#Goals|goals]]",
+			[]string{"[[Projects/Roadmap#Goals|goals]]", "", "Projects/Roadmap", "#Goals", "goals"}},
+		{"wikilink to a heading of the note", noteWikiLinkRe, "[[#Goals]]", []string{"[[#Goals]]", "", "", "#Goals", ""}},
+		{"wikilink embed", noteWikiLinkRe, "![[Roadmap]]", []string{"![[Roadmap]]", "!", "Roadmap", "", ""}},
+		{"wikilink to nested headings", noteWikiLinkRe, "[[Roadmap#Goals#2024]]",
+			[]string{"[[Roadmap#Goals#2024]]", "", "Roadmap", "#Goals#2024", ""}},
+		{"unclosed wikilink", noteWikiLinkRe, "[[Roadmap", nil},
+		{"markdown link to a note", noteMarkdownLinkRe, "[the plan](Projects/Roadmap.md#goals)",
+			[]string{"[the plan](Projects/Roadmap.md#goals)", "", "the plan", "Projects/Roadmap.md", "#goals"}},
+		{"markdown link to a note, uppercase", noteMarkdownLinkRe, "[plan](Roadmap.MD)",
+			[]string{"[plan](Roadmap.MD)", "", "plan", "Roadmap.MD", ""}},
+		{"markdown link to an image", noteMarkdownLinkRe, "![chart](chart.png)", nil},
+		{"markdown link", markdownLinkRe, `![chart](assets/chart.png "Sales")`,
+			[]string{`![chart](assets/chart.png "Sales")`, "!", "chart", "assets/chart.png"}},
+		{"markdown link in angle brackets", markdownLinkRe, "[doc](<My Notes/Read me.md>)",
+			[]string{"[doc](<My Notes/Read me.md>)", "", "doc", "<My Notes/Read me.md>"}},
+		{"markdown link with a space", markdownLinkRe, "[doc](My Notes.md)", nil},
+		{"block reference", blockWikiLinkRe, "[[Roadmap#^decision-1|decided]]",
+			[]string{"[[Roadmap#^decision-1|decided]]", "", "Roadmap", "decided"}},
+		{"heading is not a block reference", blockWikiLinkRe, "[[Roadmap#Goals]]", nil},
+		{"drawing", aliasedDrawingLink, "![[Diagrams/Flow.excalidraw|flow]]",
+			[]string{"![[Diagrams/Flow.excalidraw|flow]]", "!", "Diagrams/Flow", "flow"}},
+		{"drawing exported as SVG", aliasedDrawingLink, "![[Flow.excalidraw.svg]]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.re.FindStringSubmatch(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s.FindStringSubmatch(%q) = %q, want %q", tt.re, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodedTarget(t *testing.T) {
+	tests := []struct {
+		link fileLink
+		want string
+	}{
+		{fileLink{wiki: true, target: "Pasted image 1.png"}, "Pasted image 1.png"},
+		{fileLink{wiki: true, target: "100%25.png"}, "100%25.png"},
+		{fileLink{target: "Pasted%20image%201.png"}, "Pasted image 1.png"},
+		{fileLink{target: "caf%C3%A9.png"}, "caf\u00e9.png"},
+		{fileLink{target: "100%.png"}, "100%.png"},
+		{fileLink{angle: true, target: "Pasted image 100%25.png"}, "Pasted image 100%25.png"},
+	}
+	for _, tt := range tests {
+		if got := tt.link.decodedTarget(); got != tt.want {
+			t.Errorf("%s: decodedTarget() = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	files := []string{"assets/cafe\u0301.png", "Notes/Pasted image 1.png", "Other/Pasted image 1.png", "Notes/chart.png"}
+	tests := []struct {
+		noteDir string
+		target  string
+		want    string // Empty for no match
+	}{
+		{".", "caf\u00e9.png", "assets/cafe\u0301.png"},
+		{".", "assets/caf\u00e9.png", "assets/cafe\u0301.png"},
+		{"Notes", "Pasted image 1.png", "Notes/Pasted image 1.png"},
+		{".", "Pasted image 1.png", ""},
+		{".", "Other/Pasted image 1.png", "Other/Pasted image 1.png"},
+		{"Other", "../Notes/chart.png", "Notes/chart.png"},
+		{".", "missing.png", ""},
+	}
+	for _, tt := range tests {
+		got, ok := resolveFile(files, tt.noteDir, tt.target)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("resolveFile(%q, %q) = %q, %v, want %q", tt.noteDir, tt.target, got, ok, tt.want)
+		}
+	}
+}
+
+// The link forms to an attachment must keep pointing to it once renaming options change its name
+func TestOddAttachmentNames(t *testing.T) {
+	nfd := "Pasted image 20240101 12:34 cafe\u0301.png" // As named on macOS
+	vault := writeVault(t, map[string]string{
+		"assets/" + nfd: "png",
+		"Note.md": "![[Pasted image 20240101 12:34 caf\u00e9.png]]\n" +
+			"![shot](Pasted%20image%2020240101%2012%3A34%20caf%C3%A9.png)\n" +
+			"![shot](<assets/Pasted image 20240101 12:34 caf\u00e9.png>)\n" +
+			"[[Pasted image 20240101 12:34 cafe\u0301.png|the shot]]\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-sanitize-names"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+
+	published := "assets/Pasted image 20240101 12-34 caf\u00e9.png"
+	if _, err := os.Stat(filepath.Join(quartz, "content", filepath.FromSlash(published))); err != nil {
+		t.Fatalf("attachment not published as %q: %v", published, err)
+	}
+	encoded := "assets/Pasted%20image%2020240101%2012-34%20caf%C3%A9.png"
+	want := "![[" + published + "]]\n" +
+		"![shot](" + encoded + ")\n" +
+		"![shot](" + encoded + ")\n" +
+		"[[" + published + "|the shot]]\n"
+	if got := readContent(t, quartz, "Note.md"); got != want {
+		t.Errorf("Note.md =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(readContent(t, quartz, "Note.md"), "\u0301") {
+		t.Errorf("Note.md keeps a decomposed name")
+	}
+}
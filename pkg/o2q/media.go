@@ -0,0 +1,84 @@
+package o2q
+
+import (
+	"path"
+	"strings"
+)
+
+// Media embed handling modes
+const (
+	mediaKeep = "keep" // Leave media embeds untouched
+	mediaLink = "link" // Rewrite media embeds into plain links
+	mediaHTML = "html" // Rewrite audio and video embeds into <audio>/<video> tags, other media into links
+)
+
+// defaultMediaExtensions lists the extensions of non-image embeds rewritten by --media-embeds
+const defaultMediaExtensions = "pdf,mp3,wav,ogg,m4a,flac,mp4,webm,ogv,mov,mkv"
+
+// imageExtensions lists the extensions Quartz embeds as images, which are never rewritten
+var imageExtensions = map[string]bool{
+	"png": true, "jpg": true, "jpeg": true, "gif": true, "svg": true, "webp": true, "avif": true,
+}
+
+// audioExtensions and videoExtensions select the HTML tag used in html mode
+var (
+	audioExtensions = map[string]bool{"mp3": true, "wav": true, "ogg": true, "m4a": true, "flac": true}
+	videoExtensions = map[string]bool{"mp4": true, "webm": true, "ogv": true, "mov": true, "mkv": true}
+)
+
+// parseMediaExtensions parses a comma-separated extension list, ignoring image extensions
+func parseMediaExtensions(list string) []string {
+	var exts []string
+	for _, ext := range strings.Split(list, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" && !imageExtensions[ext] {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// rewriteMediaEmbeds rewrites embeds of non-image files according to the media embed mode
+//   - link: ![[report.pdf]] → [report.pdf](report.pdf)
+//   - html: ![[talk.mp3]] → <audio controls src="talk.mp3"></audio>, ![[demo.mp4]] → <video ...>
+func (c *converter) rewriteMediaEmbeds(src string, content []byte) []byte {
+	if c.opts.mediaEmbeds == mediaKeep {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	return c.mediaLinks.rewrite(content, func(link fileLink) string {
+		if !link.embed {
+			return link.String()
+		}
+
+		target := c.embedURL(noteDir, link)
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(link.target), "."))
+		if c.opts.mediaEmbeds == mediaHTML {
+			if audioExtensions[ext] {
+				return `<audio controls src="` + target + `"></audio>`
+			}
+			if videoExtensions[ext] {
+				return `<video controls src="` + target + `"></video>`
+			}
+		}
+
+		text := link.text
+		if text == "" {
+			text = path.Base(link.decodedTarget())
+		}
+		return "[" + text + "](" + target + ")"
+	})
+}
+
+// embedURL returns the URL of the copied asset an embed points at, relative to the note
+// An asset missing from the vault keeps its target, percent-encoded if needed
+func (c *converter) embedURL(noteDir string, link fileLink) string {
+	if resolved, ok := c.resolveLink(noteDir, link.decodedTarget()); ok {
+		return relativeURL(noteDir, resolved)
+	}
+	if link.wiki || link.angle {
+		return relativeURL(".", link.decodedTarget())
+	}
+	return link.target
+}
@@ -0,0 +1,343 @@
+// Package o2q converts an Obsidian vault into the content folder of a Quartz site
+//
+// It is the engine of the ObsidianToQuartz command, which runs Main; other Go programs embed the conversion with a
+// Converter, given the options of the command as the fields of Options:
+//
+//	opts := o2q.NewOptions()
+//	opts.StripDataview = true
+//	opts.Exclude = append(opts.Exclude, "Templates/")
+//	conv, err := o2q.New("/home/me/Vault", "/home/me/Sites/Quartz", opts)
+//	if err != nil {
+//		return err
+//	}
+//	report, err := conv.Run()
+package o2q
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options are the options of a Converter, each field set as the command option named by its tag
+// Values are written as for the command: Canvas is "skip" or "list", MaxFileSize is in bytes, and a list such as
+// Exclude holds a value per time the option is given; start from NewOptions, which sets the defaults of the command
+type Options struct {
+	// Filtering
+	Canvas              string `o2q:"canvas"`
+	HTML                string `o2q:"html"`
+	FollowSymlinks      bool   `o2q:"follow-symlinks"`
+	FromObsidianPublish bool   `o2q:"from-obsidian-publish"`
+	SkipUnpublished     bool   `o2q:"skip-unpublished"`
+
+	// Transforms
+	StripDataview           bool     `o2q:"strip-dataview"`
+	DataviewPlaceholder     string   `o2q:"dataview-placeholder"`
+	StripHTMLComments       bool     `o2q:"strip-html-comments"`
+	WarnTemplateSyntax      bool     `o2q:"warn-template-syntax"`
+	ObsidianURIs            bool     `o2q:"obsidian-uris"`
+	LocalURLs               string   `o2q:"local-urls"`
+	MediaEmbeds             string   `o2q:"media-embeds"`
+	ImageSize               string   `o2q:"image-size"`
+	MediaExtensions         string   `o2q:"media-extensions"`
+	BlockRefs               string   `o2q:"block-refs"`
+	HeadingLinks            string   `o2q:"heading-links"`
+	MissingEmbeds           string   `o2q:"missing-embeds"`
+	UnpublishedLinks        string   `o2q:"unpublished-links"`
+	ResolveLinks            bool     `o2q:"resolve-links"`
+	CalloutMap              []string `o2q:"callout-map"`
+	CalloutDefault          string   `o2q:"callout-default"`
+	CalloutFolds            string   `o2q:"callout-folds"`
+	NormalizeTasks          bool     `o2q:"normalize-tasks"`
+	TaskStatuses            []string `o2q:"task-status"`
+	StripTaskMetadata       bool     `o2q:"strip-task-metadata"`
+	SiteBaseURL             string   `o2q:"site-base-url"`
+	NormalizeUnicode        string   `o2q:"normalize-unicode"`
+	Fix                     bool     `o2q:"fix"`
+	LintDisable             string   `o2q:"lint-disable"`
+	EmitTagPages            string   `o2q:"emit-tag-pages"`
+	GenerateIndexes         bool     `o2q:"generate-indexes"`
+	IndexSort               string   `o2q:"index-sort"`
+	StrictFrontmatterRules  bool     `o2q:"strict-frontmatter-rules"`
+	StrictFrontmatter       bool     `o2q:"strict-frontmatter"`
+	FailOnFrontmatterErrors bool     `o2q:"fail-on-frontmatter-errors"`
+	NoValidate              bool     `o2q:"no-validate"`
+	ExcalidrawTheme         string   `o2q:"excalidraw-theme"`
+	ExcalidrawLinks         string   `o2q:"excalidraw-links"`
+	RenderDrawings          bool     `o2q:"render-drawings"`
+	FailOnMissingDrawings   bool     `o2q:"fail-on-missing-drawings"`
+	FailOnAmbiguousLinks    bool     `o2q:"fail-on-ambiguous-links"`
+	QuartzCompat            string   `o2q:"quartz-compat"`
+	AddTitle                bool     `o2q:"add-title"`
+	StripH1                 bool     `o2q:"strip-h1"`
+	AddDescription          bool     `o2q:"add-description"`
+	DescriptionLength       int      `o2q:"description-length"`
+	PrependFile             string   `o2q:"prepend-file"`
+	AppendFile              string   `o2q:"append-file"`
+	NoSnippet               []string `o2q:"no-snippet"`
+	CollectInlineTags       bool     `o2q:"collect-inline-tags"`
+	StripInlineTags         bool     `o2q:"strip-inline-tags"`
+	FrontmatterDrop         []string `o2q:"fm-drop"`
+	FrontmatterRename       []string `o2q:"fm-rename"`
+	FrontmatterSet          []string `o2q:"fm-set"`
+	Exclude                 []string `o2q:"exclude"`
+	IgnoreFile              string   `o2q:"ignore-file"`
+	NoAutoExclude           bool     `o2q:"no-auto-exclude"`
+	NoSkipConflicts         bool     `o2q:"no-skip-conflicts"`
+	ConflictPatterns        []string `o2q:"conflict-pattern"`
+	SkipNestedVaults        bool     `o2q:"skip-nested-vaults"`
+	RespectGitignore        bool     `o2q:"respect-gitignore"`
+	IncludeHidden           []string `o2q:"include-hidden"`
+	Overrides               []string `o2q:"override"`
+	Filter                  string   `o2q:"filter"`
+	ExplainFilter           string   `o2q:"explain-filter"`
+	MaxFileSize             int64    `o2q:"max-file-size"`
+	ExcludeExt              string   `o2q:"exclude-ext"`
+	IncludeExt              string   `o2q:"include-ext"`
+	MaxNoteSize             int64    `o2q:"max-note-size"`
+	OversizeNotes           string   `o2q:"oversize-notes"`
+	MaxTransformSize        int64    `o2q:"max-transform-size"`
+
+	// Sync
+	ContentDir          string        `o2q:"content-dir"`
+	NoContentSubdir     bool          `o2q:"no-content-subdir"`
+	SkipSpaceCheck      bool          `o2q:"skip-space-check"`
+	LinkMode            string        `o2q:"link-mode"`
+	Clean               bool          `o2q:"clean"`
+	CleanKeep           string        `o2q:"clean-keep"`
+	Prune               bool          `o2q:"prune"`
+	NoClobber           bool          `o2q:"no-clobber"`
+	UpdateOnly          bool          `o2q:"update-only"`
+	Jitter              time.Duration `o2q:"jitter"`
+	DryRun              bool          `o2q:"dry-run"`
+	SelfCheck           bool          `o2q:"self-check"`
+	Wait                time.Duration `o2q:"wait"`
+	ForceUnlock         bool          `o2q:"force-unlock"`
+	SinceGit            string        `o2q:"since-git"`
+	WriteRef            string        `o2q:"write-ref"`
+	Incremental         bool          `o2q:"incremental"`
+	MigrateState        bool          `o2q:"migrate-state"`
+	ResetState          bool          `o2q:"reset-state"`
+	Redirects           string        `o2q:"redirects"`
+	Tombstones          int           `o2q:"tombstones"`
+	PinAssets           string        `o2q:"pin-assets"`
+	BreakPins           bool          `o2q:"break-pins"`
+	HookFile            string        `o2q:"hook-file"`
+	HookPost            string        `o2q:"hook-post"`
+	HookTimeout         time.Duration `o2q:"hook-timeout"`
+	FailFast            bool          `o2q:"fail-fast"`
+	AttachmentsTo       string        `o2q:"attachments-to"`
+	FolderMap           []string      `o2q:"map"`
+	DateFolders         []string      `o2q:"date-folders"`
+	SanitizeNames       bool          `o2q:"sanitize-names"`
+	SanitizeReplacement string        `o2q:"sanitize-replacement"`
+	Yes                 bool          `o2q:"yes"`
+
+	// Output
+	Quiet         bool   `o2q:"quiet"`
+	Verbose       bool   `o2q:"verbose"`
+	Progress      string `o2q:"progress"`
+	AbsolutePaths bool   `o2q:"absolute-paths"`
+	ReportJSON    string `o2q:"report-json"`
+
+	// Stdout and Stderr receive the messages and the summary of the runs, instead of the standard output and error
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewOptions returns the default options of a run, those of the command
+func NewOptions() *Options {
+	var defaults options
+	values := optionValues(&defaults)
+	o := &Options{}
+	v := reflect.ValueOf(o).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch value := values[v.Type().Field(i).Tag.Get("o2q")].(type) {
+		case *boolValue:
+			field.SetBool(*value.p)
+		case *stringValue:
+			field.SetString(*value.p)
+		case *intValue:
+			field.SetInt(int64(*value.p))
+		case *sizeValue:
+			field.SetInt(*value.p)
+		case *durationValue:
+			field.SetInt(int64(*value.p))
+		case *listValue:
+			field.Set(reflect.ValueOf(slices.Clone(*value.p)))
+		}
+	}
+	return o
+}
+
+// optionValues returns the values of the options of the command bound to opts, by option name
+func optionValues(opts *options) map[string]flag.Value {
+	values := make(map[string]flag.Value)
+	for _, opt := range newOptionRegistry(opts) {
+		values[opt.name] = opt.value
+	}
+	return values
+}
+
+// options returns the options of a run, each field set through its command option so its value is checked alike
+func (o *Options) options() (options, error) {
+	var opts options
+	values := optionValues(&opts)
+	v := reflect.ValueOf(o).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Field(i), values[v.Type().Field(i).Tag.Get("o2q")]
+		var text []string
+		switch value.(type) {
+		case *boolValue:
+			text = []string{strconv.FormatBool(field.Bool())}
+		case *stringValue:
+			text = []string{field.String()}
+		case *intValue, *sizeValue:
+			text = []string{strconv.FormatInt(field.Int(), 10)}
+		case *durationValue:
+			text = []string{time.Duration(field.Int()).String()}
+		case *listValue:
+			text = field.Interface().([]string)
+		}
+		for _, s := range text {
+			if err := value.Set(s); err != nil {
+				return options{}, fmt.Errorf("invalid value for %s: %v", v.Type().Field(i).Name, err)
+			}
+		}
+	}
+	return opts, nil
+}
+
+// Report is what a run did: the counts of its summary, and what happened to each file
+// Paths are relative to the vault and content folders, unless AbsolutePaths is set
+type Report struct {
+	Source       string // Vault folder
+	Destination  string // Content folder
+	StartedAt    time.Time
+	Elapsed      time.Duration
+	Transformed  int   // Notes written with their transformations
+	Generated    int   // Pages generated from other files, such as canvases
+	Copied       int   // Files copied as they are
+	Renamed      int   // Files published under another name
+	Skipped      int   // Files left out, for any reason
+	Deleted      int   // Published files deleted, as their source is gone
+	Tombstoned   int   // Published notes replaced with a stub by Tombstones
+	Errors       int   // Files that failed
+	BytesWritten int64 // Size of the files written
+	Files        []FileReport
+}
+
+// FileReport is what a run did with a file
+type FileReport struct {
+	Source      string
+	Destination string // Empty for a file left out
+	Action      string // As in the report of --report-json, such as transformed, copied, skipped-ignored or error
+	Error       string // Why the file failed, for the error action
+}
+
+// newReport returns the report of a run for other Go programs
+func newReport(r *runReport) *Report {
+	report := &Report{
+		Source:       r.Base.Source,
+		Destination:  r.Base.Destination,
+		StartedAt:    r.StartedAt,
+		Elapsed:      time.Duration(r.ElapsedSeconds * float64(time.Second)),
+		Transformed:  r.Transformed,
+		Generated:    r.Generated,
+		Copied:       r.Copied,
+		Renamed:      r.Renamed,
+		Deleted:      r.Deleted,
+		Tombstoned:   r.Tombstoned,
+		Errors:       r.Errors,
+		BytesWritten: r.BytesWritten,
+		Files:        make([]FileReport, len(r.Files)),
+	}
+	for i, entry := range r.Files {
+		report.Files[i] = FileReport{Source: entry.Source, Destination: entry.Destination, Action: entry.Action, Error: entry.Error}
+		if strings.HasPrefix(entry.Action, "skipped-") {
+			report.Skipped++
+		}
+	}
+	return report
+}
+
+// Converter publishes an Obsidian vault to the content folder of a Quartz folder, like the command given both folders
+// Messages and the summary are printed to the standard output and error, as by the command, or to Options.Stdout and
+// Options.Stderr; each Converter prints its own, and holds the lock of its own Quartz folder, so Converters of other Quartz folders may run at once
+type Converter struct {
+	vaultFolder  string
+	quartzFolder string
+	opts         options
+	console      *consoleLogger
+}
+
+// New checks the options of a conversion and returns a Converter ready to run it
+// The folders are read when the conversion runs; the vault may be a folder or a zip archive
+func New(vaultFolder, quartzFolder string, opts *Options) (*Converter, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	o, err := opts.options()
+	if err != nil {
+		return nil, err
+	}
+	if vaultFolder == "" || quartzFolder == "" {
+		return nil, errors.New("the vault and Quartz folders are needed")
+	}
+	log := &consoleLogger{verbosity: verbosityNormal, out: opts.Stdout, err: opts.Stderr}
+	if log.out == nil {
+		log.out = os.Stdout
+	}
+	if log.err == nil {
+		log.err = os.Stderr
+	}
+	if o.quiet {
+		log.verbosity = verbosityQuiet
+	} else if o.verbose {
+		log.verbosity = verbosityVerbose
+	}
+	source := vaultSource{folder: vaultFolder, sub: "."}
+	if err := checkOptions(log, &o, config{}, []vaultSource{source}, ""); err != nil {
+		return nil, err
+	}
+	return &Converter{vaultFolder: vaultFolder, quartzFolder: quartzFolder, opts: o, console: log}, nil
+}
+
+// Run converts the vault, holding the lock of the Quartz folder as the command does, and returns the report of the run
+// The report is returned even when the run fails, unless it stopped before reading the vault; a dry run has none,
+// as it lists the plan instead
+func (c *Converter) Run() (*Report, error) {
+	run := &sourceRun{destOwners: make(map[string]string), cleaned: make(map[string]bool)}
+	convert := func() int {
+		code := runConversion(c.console, c.opts, config{}, vaultSource{folder: c.vaultFolder, sub: "."}, c.quartzFolder, "", run)
+		return withPostHook(c.console, c.opts, c.quartzFolder, code)
+	}
+	var code int
+	if c.opts.dryRun {
+		code = convert()
+	} else {
+		code = withLock(c.console, c.opts, c.quartzFolder, convert)
+	}
+
+	var report *Report
+	if len(run.reports) > 0 {
+		report = newReport(run.reports[0])
+		if c.opts.reportJSON != "" {
+			if err := run.reports[0].writeJSON(c.opts.reportJSON); err != nil {
+				return report, err
+			}
+		}
+	}
+	if code != exitSuccess && code != exitNothingToDo {
+		return report, fmt.Errorf("conversion of %s failed: %s", c.vaultFolder, exitReason(code))
+	}
+	return report, nil
+}
@@ -0,0 +1,122 @@
+package o2q
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// commandOnlyOptions are the options a Converter has no field for, as they only make sense to the command
+var commandOnlyOptions = map[string]bool{
+	"every": true, "watch": true, "source": true, "config": true, "print-config": true, "version": true,
+	"standalone": true, "note-depth": true, "outside-links": true,
+}
+
+func TestOptionsFields(t *testing.T) {
+	var opts options
+	values := optionValues(&opts)
+	fields := make(map[string]string)
+	typ := reflect.TypeOf(Options{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, tagged := typ.Field(i).Tag.Lookup("o2q")
+		if !tagged {
+			continue // Stdout and Stderr
+		}
+		if _, ok := values[name]; !ok || commandOnlyOptions[name] {
+			t.Errorf("Options.%s is for %q, which is not an option of a Converter", typ.Field(i).Name, name)
+		}
+		if other, ok := fields[name]; ok {
+			t.Errorf("Options.%s and Options.%s are both for %q", other, typ.Field(i).Name, name)
+		}
+		fields[name] = typ.Field(i).Name
+	}
+	for name := range values {
+		if _, ok := fields[name]; !ok && !commandOnlyOptions[name] {
+			t.Errorf("Options has no field for %q", name)
+		}
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	o, err := NewOptions().options()
+	if err != nil {
+		t.Fatalf("options() error = %v", err)
+	}
+	var defaults options
+	newOptionRegistry(&defaults)
+	if !reflect.DeepEqual(o, defaults) {
+		t.Errorf("options() = %+v, want the defaults %+v", o, defaults)
+	}
+
+	opts := NewOptions()
+	opts.Canvas = "board"
+	if _, err := New("vault", "quartz", opts); err == nil || !strings.Contains(err.Error(), "Canvas") {
+		t.Errorf("New() with Canvas = %q: error = %v, want an invalid value for Canvas", opts.Canvas, err)
+	}
+
+	opts = NewOptions()
+	opts.BreakPins = true
+	if _, err := New("vault", "quartz", opts); err == nil {
+		t.Errorf("New() with BreakPins and no PinAssets: error = nil, want the error of the command")
+	}
+}
+
+func TestConvertersRunAtOnce(t *testing.T) {
+	vaults := []string{
+		writeVault(t, map[string]string{"Note.md": "# Note\n\nSee [[Other]].\n", "Other.md": "Other\n"}),
+		writeVault(t, map[string]string{"Only.md": "Only\n"}),
+	}
+	reports := make([]*Report, len(vaults))
+	errs := make([]error, len(vaults))
+	var wg sync.WaitGroup
+	for i, vault := range vaults {
+		opts := NewOptions()
+		opts.Quiet = true
+		conv, err := New(vault, t.TempDir(), opts)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i], errs[i] = conv.Run()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, want := range []int{2, 1} {
+		if errs[i] != nil {
+			t.Fatalf("Run() of vault %d error = %v", i, errs[i])
+		}
+		if reports[i].Transformed != want || len(reports[i].Files) != want {
+			t.Errorf("Run() of vault %d transformed %d notes, %d files reported, want %d", i, reports[i].Transformed, len(reports[i].Files), want)
+		}
+	}
+	if len(heldLocks.paths) != 0 {
+		t.Errorf("locks still held after the runs: %v", heldLocks.paths)
+	}
+}
+
+func TestConverterOutput(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Note\n"})
+	var stdout, stderr bytes.Buffer
+	opts := NewOptions()
+	opts.QuartzCompat = "0.1"
+	opts.Stdout, opts.Stderr = &stdout, &stderr
+	conv, err := New(vault, t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := conv.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// The warnings of New and the summary of Run go to the writers of the Converter
+	if !strings.Contains(stderr.String(), `unknown Quartz version "0.1"`) {
+		t.Errorf("stderr = %q, want the warning about the Quartz version", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Summary:") || !strings.Contains(stdout.String(), "Markdown files transformed:   1") {
+		t.Errorf("stdout = %q, want the summary", stdout.String())
+	}
+}
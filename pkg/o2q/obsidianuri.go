@@ -0,0 +1,123 @@
+package o2q
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Policies of --local-urls for obsidian://, app:// and file:// URLs left in published notes
+const (
+	localURLsReport = "report" // Keep them, with a warning and a note in the report
+	localURLsText   = "text"   // Also replace links and embeds pointing to them with their text
+)
+
+// localURLRe matches markdown links and embeds to an obsidian://, app:// or file:// URL, autolinks and bare URLs
+// Markdown links come first so a URL inside a link is not also matched as a bare URL
+var localURLRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(<?((?:obsidian|app|file)://[^)>\s]*)>?\)|<((?:obsidian|app|file)://[^\s>]+)>|(?:obsidian|app|file)://[^\s<>()\[\]"']+`)
+
+// rewriteLocalURLs handles the URLs that only work on the computer the vault was edited on
+// With --obsidian-uris, obsidian://open links to a note of the vault become wikilinks:
+//   - [text](obsidian://open?vault=Notes&file=Projects%2FRoadmap) → [[Projects/Roadmap|text]]
+//
+// Other obsidian:// URLs, and app:// and file:// URLs, such as images dragged in from the desktop, are dead
+// on the published site; they are reported, and with --local-urls=text links to them are replaced with their text
+func (c *converter) rewriteLocalURLs(src string, content []byte) []byte {
+	return mapOutsideCode(content, func(text string) string {
+		return localURLRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := localURLRe.FindStringSubmatch(match)
+			embed, label, target := parts[1] != "", parts[2], parts[3]
+			trailing := ""
+			switch {
+			case parts[4] != "":
+				target = parts[4]
+			case target == "":
+				// Punctuation ending a sentence is not part of a bare URL
+				target = strings.TrimRight(match, ".,;:!?")
+				trailing = match[len(target):]
+			}
+
+			problem := "does not work on the published site"
+			if c.opts.obsidianURIs && strings.HasPrefix(target, "obsidian://") {
+				file, err := c.resolveObsidianURI(target)
+				if err == nil {
+					link := fileLink{embed: embed, wiki: true, target: file, text: label}
+					if hasExt(file, ".md") {
+						link.target = strings.TrimSuffix(file, path.Ext(file))
+					}
+					return link.String() + trailing
+				}
+				problem = err.Error()
+			}
+
+			c.console.warnf("%s: link to %s %s", src, target, problem)
+			c.addReportNote(src, "local URL: "+target)
+			if c.opts.localURLs != localURLsText {
+				return match
+			}
+			switch {
+			case parts[3] != "":
+				return label
+			case parts[4] != "":
+				return target
+			}
+			// A bare URL may be an attribute of an HTML tag, which is left as is
+			return match
+		})
+	})
+}
+
+// resolveObsidianURI finds the published vault file an obsidian://open URI opens
+// Both obsidian://open?vault=Notes&file=Some%20Note and obsidian://open?path=/home/me/Notes/Some%20Note.md are understood;
+// the error says why a URI cannot be rewritten, such as a note of another vault or a note that is not published
+func (c *converter) resolveObsidianURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host != "open" {
+		return "", fmt.Errorf("cannot be rewritten, only obsidian://open links to a note can")
+	}
+	query := u.Query()
+	file := query.Get("file")
+	if p := query.Get("path"); file == "" && p != "" {
+		rel, err := filepath.Rel(c.vaultRoot(), filepath.FromSlash(p))
+		if err != nil || !filepath.IsLocal(rel) {
+			return "", fmt.Errorf("opens a file outside the vault")
+		}
+		file = filepath.ToSlash(rel)
+	}
+	if file == "" {
+		return "", fmt.Errorf("opens no note")
+	}
+	if vault := query.Get("vault"); vault != "" && vault != filepath.Base(c.vaultRoot()) {
+		return "", fmt.Errorf("opens a note of the vault %s, not of this one", vault)
+	}
+
+	published, ok := c.resolveReference(".", file)
+	if !ok {
+		if c.inVault(file) {
+			return "", fmt.Errorf("opens %s, which is not published", file)
+		}
+		return "", fmt.Errorf("does not match any note of the vault")
+	}
+	return published, nil
+}
+
+// vaultRoot returns the absolute path of the vault, whose folder name is the vault name of obsidian:// URIs
+func (c *converter) vaultRoot() string {
+	if abs, err := filepath.Abs(c.obsidianFolder); err == nil {
+		return abs
+	}
+	return c.obsidianFolder
+}
+
+// inVault checks if a vault-relative path names a file of the vault, published or not, trying the implied .md of a note
+func (c *converter) inVault(file string) bool {
+	for _, candidate := range []string{file, file + ".md"} {
+		if info, err := c.vault.Stat(filepath.Join(c.obsidianFolder, filepath.FromSlash(candidate))); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
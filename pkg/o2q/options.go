@@ -0,0 +1,731 @@
+package o2q
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options holds the settings that control the conversion
+type options struct {
+	stripDataview          bool
+	dataviewPlaceholder    string
+	canvas                 string
+	html                   string
+	mediaEmbeds            string
+	imageSize              string
+	mediaExtensions        string
+	blockRefs              string
+	headingLinks           string
+	calloutMap             []string
+	calloutDefault         string
+	calloutFolds           string
+	normalizeTasks         bool
+	taskStatuses           []string
+	stripTaskMetadata      bool
+	missingEmbeds          string
+	unpublishedLinks       string
+	resolveLinks           bool
+	siteBaseURL            string
+	fromObsidianPublish    bool
+	skipUnpublished        bool
+	printConfig            bool
+	configPath             string
+	reportJSON             string
+	quiet                  bool
+	verbose                bool
+	progress               string
+	fix                    bool
+	lintDisable            string
+	strictFrontmatterRules bool
+	strictFrontmatter      bool
+	failOnFrontmatter      bool
+	noValidate             bool
+	folderMap              []string
+	redirects              string
+	pinAssets              string
+	breakPins              bool
+	selfCheck              bool
+	tombstones             int
+	dateFolders            []string
+	fmDrop                 []string
+	addTitle               bool
+	quartzCompat           string
+	stripH1                bool
+	addDescription         bool
+	descriptionLength      int
+	prependFile            string
+	appendFile             string
+	noSnippet              []string
+	collectInlineTags      bool
+	stripInlineTags        bool
+	stripHTMLComments      bool
+	warnTemplateSyntax     bool
+	obsidianURIs           bool
+	localURLs              string
+	failOnMissingDrawings  bool
+	failOnAmbiguousLinks   bool
+	excalidrawTheme        string
+	excalidrawLinks        string
+	renderDrawings         bool
+	linkMode               string
+	maxFileSize            int64
+	excludeExt             string
+	includeExt             string
+	exclude                []string
+	ignoreFile             string
+	noAutoExclude          bool
+	noSkipConflicts        bool
+	skipNestedVaults       bool
+	conflictPatterns       []string
+	respectGitignore       bool
+	includeHidden          []string
+	version                bool
+	fmRename               []string
+	fmSet                  []string
+	sources                []string
+	skipSpaceCheck         bool
+	filter                 string
+	overrides              []string
+	explainFilter          string
+	standalone             bool
+	noteDepth              int
+	outsideLinks           string
+	exportNote             string // Note given to the export-note command; not an option
+	contentDir             string
+	noContentSubdir        bool
+	clean                  bool
+	cleanKeep              string
+	prune                  bool
+	yes                    bool
+	absolutePaths          bool
+	noClobber              bool
+	updateOnly             bool
+	followSymlinks         bool
+	emitTagPages           string
+	generateIndexes        bool
+	indexSort              string
+	maxNoteSize            int64
+	maxTransformSize       int64
+	normalizeUnicode       string
+	sanitizeNames          bool
+	attachmentsTo          string
+	sanitizeReplacement    string
+	oversizeNotes          string
+	every                  time.Duration
+	watch                  bool
+	dryRun                 bool
+	sinceGit               string
+	incremental            bool
+	migrateState           bool
+	resetState             bool
+	hookFile               string
+	hookPost               string
+	hookTimeout            time.Duration
+	failFast               bool
+	writeRef               string
+	jitter                 time.Duration
+	lockWait               time.Duration
+	forceUnlock            bool
+}
+
+// envPrefix is prepended to option names to build their environment variable
+const envPrefix = "OBSIDIAN_TO_QUARTZ_"
+
+// option describes a single setting; help, environment parsing and --print-config are all derived from it
+type option struct {
+	name     string     // Flag name without dashes, also used as config key
+	topic    string     // Help topic the option is listed under
+	usage    string     // One-sentence description
+	metavar  string     // Placeholder for the value in help, empty for booleans
+	choices  []string   // Allowed values, if restricted
+	value    flag.Value // Value bound to a field of options
+	defValue string     // Default value as text
+}
+
+// envVar returns the environment variable that sets the option
+func (o option) envVar() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(o.name, "-", "_"))
+}
+
+// configKey returns the key of the option in the config file
+func (o option) configKey() string {
+	return o.name
+}
+
+// isBool checks if the option is a boolean switch
+func (o option) isBool() bool {
+	_, ok := o.value.(*boolValue)
+	return ok
+}
+
+// newOptionRegistry describes every option, bound to the fields of opts
+// This is the single source for flags, environment variables, help and --print-config
+func newOptionRegistry(opts *options) []option {
+	return []option{
+		// Filtering
+		stringOption(&opts.canvas, "canvas", canvasSkip, topicFiltering,
+			"How to handle .canvas files: skip them, or publish a generated page listing their cards.",
+			canvasSkip, canvasList),
+		stringOption(&opts.html, "html", htmlCopy, topicFiltering,
+			"How to handle .html files: route them to the Quartz static folder, wrap them in an iframe page, copy them as-is or skip them.",
+			htmlStatic, htmlIframe, htmlCopy, htmlSkip),
+		boolOption(&opts.followSymlinks, "follow-symlinks", topicFiltering,
+			"Descend into folders the vault links to with symbolic links; without it they are skipped with a notice."),
+		boolOption(&opts.fromObsidianPublish, "from-obsidian-publish", topicFiltering,
+			"Migrate from Obsidian Publish: only publish notes marked publish: true, honor their permalink like a quartz-path, and list published notes that other rules exclude."),
+		boolOption(&opts.skipUnpublished, "skip-unpublished", topicFiltering,
+			"Leave out the notes marked publish: false or draft: true in their frontmatter."),
+
+		// Transforms
+		boolOption(&opts.stripDataview, "strip-dataview", topicTransforms,
+			"Remove dataview, dataviewjs and query blocks and inline dataview expressions."),
+		stringOption(&opts.dataviewPlaceholder, "dataview-placeholder", "", topicTransforms,
+			"Replace each removed dataview block with this line.").withMetavar("text"),
+		boolOption(&opts.stripHTMLComments, "strip-html-comments", topicTransforms,
+			"Remove <!-- ... --> HTML comments from notes, including comments spanning several lines; comments in code are kept."),
+		boolOption(&opts.warnTemplateSyntax, "warn-template-syntax", topicTransforms,
+			"List the Templater commands <% ... %> and template placeholders {{...}} left in published notes in the summary, with their file and line."),
+		boolOption(&opts.obsidianURIs, "obsidian-uris", topicTransforms,
+			"Rewrite obsidian://open links to notes of the vault, as copied with Copy Obsidian URL, into wikilinks."),
+		stringOption(&opts.localURLs, "local-urls", localURLsReport, topicTransforms,
+			"What to do with obsidian://, app:// and file:// links, which do not work on the published site: report them, or also replace them with their text.",
+			localURLsReport, localURLsText),
+		stringOption(&opts.mediaEmbeds, "media-embeds", mediaKeep, topicTransforms,
+			"How to rewrite PDF, audio and video embeds: keep them, turn them into links, or into <audio>/<video> tags.",
+			mediaKeep, mediaLink, mediaHTML),
+		stringOption(&opts.imageSize, "image-size", imageSizeKeep, topicTransforms,
+			"How to rewrite sized image embeds such as ![[photo.png|400]] and ![[photo.png|400x300]]: keep them, turn them into markdown embeds with the size in the alt text, or into <img> tags with width and height.",
+			imageSizeKeep, imageSizeAlt, imageSizeHTML),
+		stringOption(&opts.mediaExtensions, "media-extensions", defaultMediaExtensions, topicTransforms,
+			"Comma-separated list of extensions treated as media embeds.").withMetavar("list"),
+		stringOption(&opts.blockRefs, "block-refs", blockRefsKeep, topicTransforms,
+			"How to handle ^blockid markers and [[Note#^blockid]] links: keep both, strip markers and link the note, or keep markers and link the note.",
+			blockRefsKeep, blockRefsStrip, blockRefsLinkNote),
+		stringOption(&opts.headingLinks, "heading-links", headingLinksSlug, topicTransforms,
+			"How to handle [[Note#Heading]] links: rewrite the heading into the anchor Quartz gives it, such as #data-flow--storage, or keep it as written.",
+			headingLinksSlug, headingLinksKeep),
+		stringOption(&opts.missingEmbeds, "missing-embeds", missingEmbedsPlaceholder, topicTransforms,
+			"What to do with embeds of notes that are excluded or missing, such as ![[Project Plan#Milestones]], which Quartz shows as broken blocks: replace them with an italic placeholder, remove them, or keep them.",
+			missingEmbedsPlaceholder, missingEmbedsRemove, missingEmbedsKeep),
+		stringOption(&opts.unpublishedLinks, "unpublished-links", unpublishedLinksKeep, topicTransforms,
+			"What to do with links to notes that are not published, such as [[Private Meeting Notes]], which Quartz shows as dead links giving away their title: keep them, turn them into their text, or remove them; each is reported either way.",
+			unpublishedLinksKeep, unpublishedLinksUnlink, unpublishedLinksRemove),
+		boolOption(&opts.resolveLinks, "resolve-links", topicTransforms,
+			"Rewrite wikilinks to notes to the vault path of the note Obsidian opens, such as [[Roadmap]] to [[Projects/Roadmap|Roadmap]], preferring the folder of the linking note and then the shortest path; links to a shared name and links matching no note are reported."),
+		listOption(&opts.calloutMap, "callout-map", topicTransforms,
+			"Rewrite a callout type Quartz does not style into one it does, such as theorem=important, keeping the title; can be given several times.").withMetavar("custom=supported"),
+		stringOption(&opts.calloutDefault, "callout-default", "", topicTransforms,
+			"Callout type given to the callouts whose type Quartz does not style and --callout-map does not list, such as note; by default they are kept and reported.").withMetavar("type"),
+		stringOption(&opts.calloutFolds, "callout-folds", calloutFoldsKeep, topicTransforms,
+			"What to do with the fold markers of callouts, as in [!note]- and [!note]+: keep them, or strip them so every callout is shown open.",
+			calloutFoldsKeep, calloutFoldsStrip),
+		boolOption(&opts.normalizeTasks, "normalize-tasks", topicTransforms,
+			"Show tasks with a custom status, such as - [/] or - [>], as unchecked, and cancelled ones, - [-], as checked, since Quartz only knows [ ] and [x]."),
+		listOption(&opts.taskStatuses, "task-status", topicTransforms,
+			"Show the tasks with this custom status as checked or unchecked with --normalize-tasks, such as \"?=checked\"; can be given several times.").withMetavar("status=checked|unchecked"),
+		boolOption(&opts.stripTaskMetadata, "strip-task-metadata", topicTransforms,
+			"Remove the metadata of the Tasks plugin from the text of tasks: dates such as 📅 2024-05-01, recurrence (🔁 every week), priority (⏫) and dependency markers."),
+		stringOption(&opts.siteBaseURL, "site-base-url", "", topicTransforms,
+			"URL of the published site; absolute links to it are rewritten to wikilinks to the notes they point at.").withMetavar("url"),
+		stringOption(&opts.normalizeUnicode, "normalize-unicode", unicodeNFC, topicTransforms,
+			"Unicode form of published file and folder names and of link targets, so names typed on one system match files stored on another (macOS stores NFD).",
+			unicodeNFC, unicodeNFD, unicodeNone),
+		boolOption(&opts.fix, "fix", topicTransforms,
+			"Apply safe corrections for lint findings while converting, such as \\< for a line starting with <; without it, lint findings are only reported by the check command."),
+		stringOption(&opts.lintDisable, "lint-disable", "", topicTransforms,
+			"Comma-separated list of lint rules to turn off: "+lintRuleNames()+".").withMetavar("list"),
+		stringOption(&opts.emitTagPages, "emit-tag-pages", "", topicTransforms,
+			"Generate a page per tag listing its notes, and an overview page, in this folder of the content folder, such as tags.").withMetavar("dir"),
+		boolOption(&opts.generateIndexes, "generate-indexes", topicTransforms,
+			"Generate an index.md listing the notes of each published folder that has none, replaced by the folder's own index.md once it has one."),
+		stringOption(&opts.indexSort, "index-sort", indexSortTitle, topicTransforms,
+			"Order of the notes on the pages of --generate-indexes: by title, or by date frontmatter, most recent first.",
+			indexSortTitle, indexSortDate),
+		boolOption(&opts.strictFrontmatterRules, "strict-frontmatter-rules", topicTransforms,
+			"Treat violations of the frontmatter-rules of the config file as errors: the note is not published and the run fails."),
+		boolOption(&opts.strictFrontmatter, "strict-frontmatter", topicTransforms,
+			"Treat notes whose YAML frontmatter cannot be parsed as errors: the note is not published and the run fails. By default they are published without their frontmatter."),
+		boolOption(&opts.failOnFrontmatter, "fail-on-frontmatter-errors", topicTransforms,
+			"Fail the run when a note has YAML frontmatter that cannot be parsed, once every file is published without it; the notes are listed in the summary either way."),
+		boolOption(&opts.noValidate, "no-validate", topicTransforms,
+			"Do not check frontmatter values Quartz mishandles, such as dates that are not ISO dates or tags written as a map; frontmatter that cannot be parsed is still reported."),
+		stringOption(&opts.excalidrawTheme, "excalidraw-theme", excalidrawThemeSingle, topicTransforms,
+			"How drawings are exported: as one .excalidraw.svg, or as a .excalidraw.light.svg and .excalidraw.dark.svg pair, shown according to the theme of the site.",
+			excalidrawThemeSingle, excalidrawThemeDual),
+		stringOption(&opts.excalidrawLinks, "excalidraw-links", excalidrawLinksLink, topicTransforms,
+			"How links to drawings, such as [[Flow.excalidraw]], are published: as links to the SVG export, as embeds showing it, or as an HTML figure captioned with the alias or the drawing name. Embeds stay embeds.",
+			excalidrawLinksLink, excalidrawLinksEmbed, excalidrawLinksFigure),
+		boolOption(&opts.renderDrawings, "render-drawings", topicTransforms,
+			"Render the .excalidraw.md drawings that have no SVG export next to them to the .excalidraw.svg their links point to, from the scene they hold, with plain strokes rather than the hand-drawn look."),
+		boolOption(&opts.failOnMissingDrawings, "fail-on-missing-drawings", topicTransforms,
+			"Fail the run when a note links to an Excalidraw drawing that has no SVG export in the vault; the links are listed in the summary either way."),
+		boolOption(&opts.failOnAmbiguousLinks, "fail-on-ambiguous-links", topicTransforms,
+			"Fail the run when a note links by name only, such as [[Ideas]], to a name several published notes share; the names and links are warned about either way."),
+		stringOption(&opts.quartzCompat, "quartz-compat", latestQuartz().version, topicTransforms,
+			"Version of Quartz the output targets, such as 4.2, for the syntax Quartz changed between versions; known versions are "+quartzCompatVersions()+".").withMetavar("version"),
+		boolOption(&opts.addTitle, "add-title", topicTransforms,
+			"Give notes without a title frontmatter key one, taken from their first H1 heading as plain text, or else from their file name."),
+		boolOption(&opts.stripH1, "strip-h1", topicTransforms,
+			"With --add-title, remove the H1 heading the title was taken from, so the page does not show it twice."),
+		boolOption(&opts.addDescription, "add-description", topicTransforms,
+			"Give notes without a description frontmatter key one, taken from their first paragraph as plain text, for RSS feeds and link previews."),
+		intOption(&opts.descriptionLength, "description-length", 160, topicTransforms,
+			"With --add-description, the maximum length of the description in characters; longer paragraphs are cut at a word and end with an ellipsis."),
+		stringOption(&opts.prependFile, "prepend-file", "", topicTransforms,
+			"Insert the contents of this file at the top of every published note, after its frontmatter; {{title}}, {{source_path}} and {{date}} are replaced with the title of the note, its vault path and the date of the run.").withMetavar("path"),
+		stringOption(&opts.appendFile, "append-file", "", topicTransforms,
+			"Add the contents of this file at the end of every published note, after a blank line, such as a footer; it takes the same variables as --prepend-file.").withMetavar("path"),
+		listOption(&opts.noSnippet, "no-snippet", topicTransforms,
+			"Do not add --prepend-file and --append-file to the notes matching this glob pattern, such as index.md; can be given several times.").withMetavar("pattern"),
+		boolOption(&opts.collectInlineTags, "collect-inline-tags", topicTransforms,
+			"Merge the inline #tags of notes into their tags frontmatter key, written as a YAML list without duplicates; tag: and comma-separated tags are rewritten the same way."),
+		boolOption(&opts.stripInlineTags, "strip-inline-tags", topicTransforms,
+			"With --collect-inline-tags, remove the inline tags from the body of notes, and the lines that held nothing else."),
+		listOption(&opts.fmDrop, "fm-drop", topicTransforms,
+			"Remove the frontmatter keys matching this glob pattern, such as banner* for the keys of a plugin; can be given several times or hold a comma-separated list.").withMetavar("key"),
+		listOption(&opts.fmRename, "fm-rename", topicTransforms,
+			"Rename a frontmatter key, such as created=date; can be given several times.").withMetavar("old=new"),
+		listOption(&opts.fmSet, "fm-set", topicTransforms,
+			"Add a frontmatter key to the notes that do not have it, such as draft=false; the value is YAML, and notes without frontmatter get one. Can be given several times.").withMetavar("key=value"),
+
+		listOption(&opts.exclude, "exclude", topicFiltering,
+			"Leave out the paths matching this pattern, in the .gitignore syntax of the ignore file, such as /Private/ or *.pdf; added to the patterns of the ignore file and can be given several times.").withMetavar("pattern"),
+		stringOption(&opts.ignoreFile, "ignore-file", "", topicFiltering,
+			"Read the ignore patterns from this file instead of the .obsidian-to-quartz-ignore file at the root of the vault.").withMetavar("path"),
+		boolOption(&opts.noAutoExclude, "no-auto-exclude", topicFiltering,
+			"Publish the template folders of the Templates core plugin and Templater, and the script folders of Templater and Excalidraw, which are otherwise left out; a single one is kept with a line such as !Templates/ in the ignore file."),
+		boolOption(&opts.noSkipConflicts, "no-skip-conflicts", topicFiltering,
+			"Publish the conflict copies of Syncthing, Dropbox and Nextcloud, the iCloud placeholders and the temporary files of editors, which are otherwise left out and listed in the summary."),
+		listOption(&opts.conflictPatterns, "conflict-pattern", topicFiltering,
+			"Also leave out as conflict copies the files whose name matches this glob pattern, such as *.conflict.md; can be given several times.").withMetavar("pattern"),
+		boolOption(&opts.skipNestedVaults, "skip-nested-vaults", topicFiltering,
+			"Leave out the folders holding a vault of their own, recognized by their .obsidian folder, with everything in them; each one is reported."),
+		boolOption(&opts.respectGitignore, "respect-gitignore", topicFiltering,
+			"Also leave out the paths ignored by the .gitignore files of the vault, at its root and in its folders; a path is published anyway with a ! line in the ignore file, such as !Drafts/idea.md."),
+		listOption(&opts.includeHidden, "include-hidden", topicFiltering,
+			"Publish the files of hidden folders matching this glob pattern, such as .obsidian/snippets/*.css, which are otherwise skipped with their folder; \"pattern=>folder\" publishes them in a folder of the content folder instead of under their own path. Takes precedence over the ignore patterns, and can be given several times.").withMetavar("pattern[=>dst]"),
+		listOption(&opts.overrides, "override", topicFiltering,
+			"For this run only, publish (include:PATTERN) or leave out (exclude:PATTERN) the paths matching an ignore pattern, such as include:Drafts/**; takes precedence over the ignore file and the config, and can be given several times.").withMetavar("include|exclude:pattern"),
+		stringOption(&opts.filter, "filter", "", topicFiltering,
+			"Only publish the notes matching this expression of path:GLOB, tag:NAME, ext:EXT, frontmatter.KEY=VALUE, modified>DATE and size<SIZE conditions, combined with AND, OR, NOT and parentheses.").withMetavar("expr"),
+		stringOption(&opts.explainFilter, "explain-filter", "", topicFiltering,
+			"Print how --filter is evaluated for this note, given by its path in the vault.").withMetavar("note"),
+		sizeOption(&opts.maxFileSize, "max-file-size", 0, topicFiltering,
+			"Leave out files other than notes larger than this, such as 50MB; they are listed in the summary, and embeds of them are replaced with a note that they were omitted."),
+		stringOption(&opts.excludeExt, "exclude-ext", "", topicFiltering,
+			"Leave out files other than notes with these extensions, such as mp4,mov,zip.").withMetavar("exts"),
+		stringOption(&opts.includeExt, "include-ext", "", topicFiltering,
+			"Only publish files other than notes with these extensions, such as png,jpg,svg,pdf; notes are always published.").withMetavar("exts"),
+		sizeOption(&opts.maxNoteSize, "max-note-size", 0, topicFiltering,
+			"Size above which a note is too large for Quartz to render comfortably, such as 1MB; see --oversize-notes."),
+		stringOption(&opts.oversizeNotes, "oversize-notes", oversizeWarn, topicFiltering,
+			"What to do with notes larger than --max-note-size: warn and publish them, exclude them, or split them at their top-level headings into a folder of parts.",
+			oversizeWarn, oversizeExclude, oversizeSplit),
+		sizeOption(&opts.maxTransformSize, "max-transform-size", defaultMaxTransformSize, topicFiltering,
+			"Copy notes larger than this as they are, with a warning, instead of transforming them, as transforming a note takes several times its size in memory; 0 transforms every note. Binary files named .md are always copied as they are."),
+
+		// Sync
+		listOption(&opts.sources, "source", topicSync,
+			"Publish this Obsidian folder to a subfolder of the content folder, such as ~/work:work; can be given several times to merge vaults into one site, and then only the Quartz folder is given as argument.").withMetavar("folder:subfolder"),
+		stringOption(&opts.contentDir, "content-dir", "content", topicSync,
+			"Folder of the Quartz folder the vault is published to, such as content/notes to keep hand-written pages of content out of the sync.").withMetavar("path"),
+		boolOption(&opts.noContentSubdir, "no-content-subdir", topicSync,
+			"Publish the vault to the destination folder itself instead of its content folder, for a site whose pages are read from another folder, such as site/notes; --clean then needs --yes."),
+		boolOption(&opts.skipSpaceCheck, "skip-space-check", topicSync,
+			"Do not check that the destination has room for the run before writing; by default the run stops early when the estimated size of the files to write exceeds the free space."),
+		stringOption(&opts.linkMode, "link-mode", linkModeCopy, topicSync,
+			"How files published as they are reach the content folder: copied, hard-linked to the vault, or reflinked on file systems such as btrfs; files that cannot be linked are copied.",
+			linkModeCopy, linkModeHardlink, linkModeReflink),
+		boolOption(&opts.clean, "clean", topicSync,
+			"Delete the contents of the content folder before copying, so removed notes disappear from the site."),
+		stringOption(&opts.cleanKeep, "clean-keep", "", topicSync,
+			"Comma-separated glob patterns of files and folders --clean and --prune keep, matched against the path in the content folder or the name.").withMetavar("list"),
+		boolOption(&opts.prune, "prune", topicSync,
+			"Delete the files of the content folder published from no vault file, such as deleted or renamed notes, keeping --clean-keep matches and the root index.md."),
+		boolOption(&opts.noClobber, "no-clobber", topicSync,
+			"Never overwrite a file that already exists in the content folder."),
+		boolOption(&opts.updateOnly, "update-only", topicSync,
+			"Do not overwrite a file of the content folder that was modified after its source, e.g. edited by hand."),
+		durationOption(&opts.every, "every", topicSync,
+			"Keep running and sync on this interval, such as 15m; send SIGUSR1 for an extra sync, SIGINT or SIGTERM to stop after the current one."),
+		durationOption(&opts.jitter, "jitter", topicSync,
+			"With --every, delay each scheduled sync by a random duration up to this one, so several machines do not sync at once."),
+		boolOption(&opts.dryRun, "dry-run", topicSync,
+			"List what the run would write, overwrite, skip and delete in the content folder, without writing anything; --verbose also lists the files skipped or left unchanged."),
+		boolOption(&opts.selfCheck, "self-check", topicSync,
+			"After a run, check that every file was published as planned, to the path --dry-run lists, that the state file of --incremental matches the content folder and that --prune left nothing to delete, and fail with a report of what does not match; meant for bug reports and tests."),
+		boolOption(&opts.watch, "watch", topicSync,
+			"Keep running and sync a second after files of the vault change, publishing only the files that changed like --incremental; SIGINT or SIGTERM stops after the current sync."),
+		durationOption(&opts.lockWait, "wait", topicSync,
+			"When another sync holds the lock of the Quartz folder, "+lockFileName+", wait up to this long for it to finish, such as 5m; by default the run is refused at once."),
+		boolOption(&opts.forceUnlock, "force-unlock", topicSync,
+			"Take over a lock of the Quartz folder left by a sync that is no longer running, as its PID is gone or it started more than "+maxRunDuration.String()+" ago; a lock held by a running sync is never taken."),
+		stringOption(&opts.sinceGit, "since-git", "", topicSync,
+			"Only publish the files changed in the vault's git repository between this revision and HEAD, and delete those deleted or renamed since; the vault must be in a git repository.").withMetavar("ref"),
+		stringOption(&opts.writeRef, "write-ref", "", topicSync,
+			"After a successful run, write the commit the vault is at to this file, for the next --since-git.").withMetavar("file"),
+		boolOption(&opts.incremental, "incremental", topicSync,
+			"Only publish the files changed since the last --incremental run, recorded in "+stateFileName+" in the Quartz folder, and delete those whose source is gone."),
+		boolOption(&opts.migrateState, "migrate-state", topicSync,
+			"Take over the state file of --incremental written for another vault, content folder or profile, as after renaming the content folder; without it such a state file stops the run."),
+		boolOption(&opts.resetState, "reset-state", topicSync,
+			"Ignore the state file of --incremental and publish every file, writing a new state file for this vault, content folder and profile."),
+		stringOption(&opts.redirects, "redirects", "", topicSync,
+			"Keep the old URLs of the notes whose published path changed since an earlier run with this option working: as 301 rules of a _redirects file of the Quartz folder, as aliases of the moved notes so Quartz writes redirect pages, or as a redirects.json file. The URLs are recorded in "+redirectsStateFileName+" in the Quartz folder.",
+			redirectsNetlify, redirectsAliases, redirectsJSON),
+		intOption(&opts.tombstones, "tombstones", 0, topicSync,
+			"Replace a published note that --prune, --incremental or --since-git would delete with a stub saying the page moved or was removed, linking to the note it moved to with --redirects, and delete the stub after this many days; assets are deleted as before. The stubs are recorded in "+tombstonesFileName+" in the Quartz folder.").withMetavar("days"),
+		stringOption(&opts.pinAssets, "pin-assets", "", topicSync,
+			"Keep the assets matching these comma-separated patterns, such as \"*.png,Diagrams/*\", at the path an earlier run with this option published them to, even when --sanitize-names, --attachments-to, --normalize-unicode or --map would now move them, so links from other sites keep working. The paths are recorded in "+pinsFileName+" in the Quartz folder.").withMetavar("patterns"),
+		boolOption(&opts.breakPins, "break-pins", topicSync,
+			"Move the assets pinned by --pin-assets to their new path once, redirecting their old URL with --redirects=netlify or --redirects=json, and pin them there."),
+		stringOption(&opts.hookFile, "hook-file", "", topicSync,
+			"Run this command after each file is written, with {src} and {dest} replaced with the paths of the vault file and the written file, such as \"optipng {dest}\"; it runs without a shell, from the Quartz folder, and a failure is an error of the file.").withMetavar("command"),
+		stringOption(&opts.hookPost, "hook-post", "", topicSync,
+			"Run this command from the Quartz folder once a run succeeded, such as \"npx quartz build\".").withMetavar("command"),
+		durationOption(&opts.hookTimeout, "hook-timeout", topicSync,
+			"Stop a --hook-file or --hook-post command running longer than this, such as 30s; by default hooks have no time limit."),
+		boolOption(&opts.failFast, "fail-fast", topicSync,
+			"Stop the run at the first failing --hook-file command, instead of recording the failure and carrying on with the other files."),
+		stringOption(&opts.attachmentsTo, "attachments-to", "", topicSync,
+			"Move all attachments to this folder of the content folder, such as assets, and rewrite embeds and links to them; attachments are found with the attachment folder of the Obsidian settings.").withMetavar("dir"),
+		listOption(&opts.folderMap, "map", topicSync,
+			"Publish a vault folder under another name, such as \"03 - Projects=>projects\", and rewrite links to its files; can be given several times.").withMetavar("src=>dst"),
+		listOption(&opts.dateFolders, "date-folders", topicSync,
+			"Publish the notes of a vault folder named after a date, such as Daily/2024-03-17.md, in folders built from it, such as \"Daily:YYYY/MM\" for Daily/2024/03/2024-03-17.md, and rewrite links to them; a third field gives the name format when it is not YYYY-MM-DD, such as \"Journal:YYYY:DD.MM.YYYY\". Can be given several times.").withMetavar("folder:layout[:format]"),
+		boolOption(&opts.sanitizeNames, "sanitize-names", topicSync,
+			"Rename files and folders whose names break on Windows or some web hosts (: ? \" < > | *, trailing dots and spaces, CON and other reserved names), and rewrite links to them."),
+		stringOption(&opts.sanitizeReplacement, "sanitize-replacement", "-", topicSync,
+			"Text that replaces each unsafe character with --sanitize-names.").withMetavar("text"),
+		boolOption(&opts.yes, "yes", topicSync,
+			"Clean even if the Quartz folder has no quartz.config.ts or package.json, and clean or prune with --no-content-subdir."),
+
+		// Output
+		stringOption(&opts.configPath, "config", "", topicOutput,
+			"Read settings from this config file instead of "+configFileName+" at the root of the Obsidian folder.").withMetavar("path"),
+		boolOption(&opts.quiet, "quiet", topicOutput,
+			"Only print errors and the summary."),
+		boolOption(&opts.verbose, "verbose", topicOutput,
+			"Also print a line for every file processed, copied or skipped, with the reason for skips."),
+		stringOption(&opts.progress, "progress", progressAuto, topicOutput,
+			"Show progress: in place when stdout is a terminal (auto), also as periodic lines when output is piped (always), or never.",
+			progressAuto, progressAlways, progressNever),
+		boolOption(&opts.standalone, "standalone", topicOutput,
+			"With the export command, also render every note to an HTML page and every folder to an index.html, linked with relative links."),
+		intOption(&opts.noteDepth, "note-depth", 0, topicOutput,
+			"With the export-note command, also export the notes the note links to or embeds, and theirs, up to this many links away."),
+		stringOption(&opts.outsideLinks, "outside-links", outsideLinksUnlink, topicOutput,
+			"With the export-note command, how to write links to notes left out of the export: replaced by their text, or kept as written in a code span.",
+			outsideLinksUnlink, outsideLinksText),
+		boolOption(&opts.absolutePaths, "absolute-paths", topicOutput,
+			"Show absolute paths in messages and reports instead of paths relative to the vault and content folders."),
+		stringOption(&opts.reportJSON, "report-json", "", topicOutput,
+			"Write a JSON report of the run, with counts and an entry per file, to this path.").withMetavar("path"),
+		boolOption(&opts.version, "version", topicOutput,
+			"Print the version and exit."),
+		boolOption(&opts.printConfig, "print-config", topicOutput,
+			"Print the effective value of every option and exit."),
+	}
+}
+
+// boolOption creates a boolean option, off by default
+func boolOption(p *bool, name, topic, usage string) option {
+	*p = false
+	return option{name: name, topic: topic, usage: usage, value: &boolValue{p}, defValue: "false"}
+}
+
+// stringOption creates a string option, optionally restricted to a set of choices
+func stringOption(p *string, name, def, topic, usage string, choices ...string) option {
+	*p = def
+	metavar := "value"
+	if len(choices) > 0 {
+		metavar = strings.Join(choices, "|")
+	}
+	return option{
+		name:     name,
+		topic:    topic,
+		usage:    usage,
+		metavar:  metavar,
+		choices:  choices,
+		value:    &stringValue{p, def, choices},
+		defValue: def,
+	}
+}
+
+// durationOption creates a duration option such as 15m or 1h30m, off (zero) by default
+func durationOption(p *time.Duration, name, topic, usage string) option {
+	*p = 0
+	return option{name: name, topic: topic, usage: usage, metavar: "duration", value: &durationValue{p}}
+}
+
+// sizeOption creates a size option such as 500KB or 1MB; a zero default turns it off
+func sizeOption(p *int64, name string, def int64, topic, usage string) option {
+	*p = def
+	o := option{name: name, topic: topic, usage: usage, metavar: "size", value: &sizeValue{p}}
+	if def > 0 {
+		o.defValue = formatSize(def)
+	}
+	return o
+}
+
+// intOption creates a non-negative integer option
+func intOption(p *int, name string, def int, topic, usage string) option {
+	*p = def
+	return option{name: name, topic: topic, usage: usage, metavar: "n", value: &intValue{p}, defValue: strconv.Itoa(def)}
+}
+
+// listOption creates an option that can be given several times, each value adding an entry
+func listOption(p *[]string, name, topic, usage string) option {
+	*p = nil
+	return option{name: name, topic: topic, usage: usage, metavar: "value", value: &listValue{p}}
+}
+
+// withMetavar sets the placeholder shown for the value in help
+func (o option) withMetavar(metavar string) option {
+	o.metavar = metavar
+	return o
+}
+
+// boolValue is a flag.Value bound to a bool field
+type boolValue struct {
+	p *bool
+}
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v.p = b
+	return nil
+}
+
+func (v *boolValue) String() string {
+	if v.p == nil {
+		return "false"
+	}
+	return strconv.FormatBool(*v.p)
+}
+
+// IsBoolFlag allows the flag to be given without a value
+func (v *boolValue) IsBoolFlag() bool {
+	return true
+}
+
+// stringValue is a flag.Value bound to a string field, optionally restricted to a set of choices
+// The default is accepted too, so --print-config can write an option left off, such as redirects: ""
+type stringValue struct {
+	p       *string
+	def     string
+	choices []string
+}
+
+func (v *stringValue) Set(s string) error {
+	if len(v.choices) > 0 && s != v.def {
+		valid := false
+		for _, choice := range v.choices {
+			if s == choice {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("must be one of %s", strings.Join(v.choices, ", "))
+		}
+	}
+	*v.p = s
+	return nil
+}
+
+func (v *stringValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+// listValue is a flag.Value bound to a list of strings, appending a value each time it is set
+type listValue struct {
+	p *[]string
+}
+
+func (v *listValue) Set(s string) error {
+	*v.p = append(*v.p, s)
+	return nil
+}
+
+func (v *listValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strings.Join(*v.p, ",")
+}
+
+// intValue is a flag.Value bound to a non-negative int field
+type intValue struct {
+	p *int
+}
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid number %q, use 0 or more", s)
+	}
+	*v.p = n
+	return nil
+}
+
+func (v *intValue) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.Itoa(*v.p)
+}
+
+// durationValue is a flag.Value bound to a time.Duration field
+type durationValue struct {
+	p *time.Duration
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid duration %q, use a value like 90s, 15m or 1h", s)
+	}
+	*v.p = d
+	return nil
+}
+
+func (v *durationValue) String() string {
+	if v.p == nil {
+		return "0s"
+	}
+	return v.p.String()
+}
+
+// sizeValue is a flag.Value bound to a size in bytes, written with an optional KB, MB or GB suffix
+type sizeValue struct {
+	p *int64
+}
+
+func (v *sizeValue) Set(s string) error {
+	size, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*v.p = size
+	return nil
+}
+
+func (v *sizeValue) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return formatSize(*v.p)
+}
+
+// sizeUnits are the suffixes of sizes, largest first
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+// parseSize parses a size such as 1MB, 1.5mb, 500K or 2048 (bytes)
+func parseSize(s string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if number, ok := strings.CutSuffix(text, unit.suffix); ok {
+			text, multiplier = number, unit.bytes
+			break
+		}
+		if number, ok := strings.CutSuffix(text, strings.TrimSuffix(unit.suffix, "B")); ok && unit.bytes > 1 {
+			text, multiplier = number, unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use a value like 500KB or 1MB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatSize formats a size in bytes with the largest unit it holds, such as 1.5MB
+func formatSize(size int64) string {
+	for _, unit := range sizeUnits {
+		if size >= unit.bytes && unit.bytes > 1 {
+			text := strconv.FormatFloat(float64(size)/float64(unit.bytes), 'f', 1, 64)
+			return strings.TrimSuffix(text, ".0") + unit.suffix
+		}
+	}
+	return strconv.FormatInt(size, 10) + "B"
+}
+
+// splitList splits a comma-separated option value, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// registerFlags defines a flag for every option of the registry
+func registerFlags(fs *flag.FlagSet, registry []option) {
+	for _, o := range registry {
+		fs.Var(o.value, o.name, o.usage)
+	}
+}
+
+// applyEnv sets options from their environment variables, except those listed in skip
+func applyEnv(registry []option, skip map[string]bool) error {
+	for _, o := range registry {
+		if skip[o.name] {
+			continue
+		}
+		if value, ok := os.LookupEnv(o.envVar()); ok {
+			if err := o.value.Set(value); err != nil {
+				return fmt.Errorf("invalid value for %s: %v", o.envVar(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// envOptions returns the names of the options set by an environment variable
+func envOptions(registry []option) map[string]bool {
+	set := make(map[string]bool)
+	for _, o := range registry {
+		if _, ok := os.LookupEnv(o.envVar()); ok {
+			set[o.name] = true
+		}
+	}
+	return set
+}
+
+// printConfig writes the effective value of every option, in config file syntax
+func printConfig(w io.Writer, registry []option) {
+	for _, o := range registry {
+		if o.name == "print-config" || o.name == "config" {
+			continue
+		}
+		value := o.value.String()
+		if list, ok := o.value.(*listValue); ok {
+			quoted := make([]string, len(*list.p))
+			for i, item := range *list.p {
+				quoted[i] = strconv.Quote(item)
+			}
+			value = "[" + strings.Join(quoted, ", ") + "]"
+		} else if !o.isBool() {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(w, "%s: %s\n", o.configKey(), value)
+	}
+}
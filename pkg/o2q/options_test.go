@@ -0,0 +1,162 @@
+package o2q
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestOptionRegistry(t *testing.T) {
+	var opts options
+	seen := make(map[string]bool)
+	for _, o := range newOptionRegistry(&opts) {
+		if seen[o.name] {
+			t.Errorf("--%s is registered twice", o.name)
+		}
+		seen[o.name] = true
+		if _, ok := findTopic(o.topic); !ok {
+			t.Errorf("--%s is listed under the unknown help topic %q", o.name, o.topic)
+		}
+		if !strings.HasSuffix(o.usage, ".") {
+			t.Errorf("--%s: usage %q is not a sentence", o.name, o.usage)
+		}
+		if o.defValue != "" && o.value.String() != o.defValue {
+			t.Errorf("--%s defaults to %q, but its help says %q", o.name, o.value.String(), o.defValue)
+		}
+		if o.isBool() != (o.metavar == "") {
+			t.Errorf("--%s: metavar %q for a boolean %v", o.name, o.metavar, o.isBool())
+		}
+	}
+}
+
+func TestOptionPrecedence(t *testing.T) {
+	config := writeConfig(t, "canvas: list\nhtml: skip\ncontent-dir: site\nexclude: [Private/, Drafts/]\n")
+	t.Setenv(envPrefix+"HTML", "static")
+	t.Setenv(envPrefix+"CONTENT_DIR", "notes")
+
+	// As Main does: flags, then the environment for the options without a flag, then the config file for the rest
+	var opts options
+	registry := newOptionRegistry(&opts)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, registry)
+	if err := fs.Parse([]string{"-content-dir", "public", "-exclude", "Archive/"}); err != nil {
+		t.Fatal(err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if err := applyEnv(registry, set); err != nil {
+		t.Fatal(err)
+	}
+	for name := range envOptions(registry) {
+		set[name] = true
+	}
+	if _, err := loadConfig(console, config, registry, set, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.canvas != canvasList {
+		t.Errorf("canvas = %q, want %q from the config file", opts.canvas, canvasList)
+	}
+	if opts.html != htmlStatic {
+		t.Errorf("html = %q, want %q from the environment over the config file", opts.html, htmlStatic)
+	}
+	if opts.contentDir != "public" {
+		t.Errorf("content-dir = %q, want %q from the flag over the environment and the config file", opts.contentDir, "public")
+	}
+	if strings.Join(opts.exclude, ",") != "Archive/" {
+		t.Errorf("exclude = %q, want only the value of the flag", opts.exclude)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	t.Setenv(envPrefix+"CANVAS", "board")
+	var opts options
+	err := applyEnv(newOptionRegistry(&opts), nil)
+	if err == nil || !strings.Contains(err.Error(), envPrefix+"CANVAS") {
+		t.Errorf("applyEnv() error = %v, want an invalid value naming %sCANVAS", err, envPrefix)
+	}
+}
+
+func TestPrintConfigRoundTrip(t *testing.T) {
+	var opts options
+	registry := newOptionRegistry(&opts)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, registry)
+	args := []string{"-canvas", "list", "-exclude", "Private/", "-exclude", `Say "hi", twice/`, "-strip-dataview",
+		"-max-file-size", "2MB", "-hook-timeout", "90s", "-dataview-placeholder", "_Omitted: see the vault_"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	var printed bytes.Buffer
+	printConfig(&printed, registry)
+
+	// Reading the printed settings back gives the same options; exclude patterns are added to those of the ignore file
+	var read options
+	readRegistry := newOptionRegistry(&read)
+	cfg, err := loadConfig(console, writeConfig(t, printed.String()), readRegistry, nil, true)
+	if err != nil {
+		t.Fatalf("loadConfig() of the --print-config output error = %v\n%s", err, printed.String())
+	}
+	if len(cfg.exclude) != 2 || cfg.exclude[1].text != `Say "hi", twice/` {
+		t.Errorf("exclude read back as %+v, want the 2 patterns", cfg.exclude)
+	}
+	read.exclude = opts.exclude
+	var want, got bytes.Buffer
+	printConfig(&want, registry)
+	printConfig(&got, readRegistry)
+	if got.String() != want.String() {
+		t.Errorf("--print-config output read back as\n%s\nwant\n%s", got.String(), want.String())
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+	}{
+		{"2048", 2048},
+		{"500KB", 500 << 10},
+		{"500k", 500 << 10},
+		{"1.5mb", 3 << 19},
+		{"1GB", 1 << 30},
+		{" 10 MB ", 10 << 20},
+		{"12B", 12},
+	}
+	for _, tt := range tests {
+		if got, err := parseSize(tt.text); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.text, got, err, tt.want)
+		}
+	}
+	for _, text := range []string{"", "MB", "-1KB", "ten"} {
+		if _, err := parseSize(text); err == nil {
+			t.Errorf("parseSize(%q) error = nil, want an invalid size", text)
+		}
+	}
+
+	for size, want := range map[int64]string{0: "0B", 512: "512B", 1 << 10: "1KB", 3 << 19: "1.5MB", 1 << 30: "1GB"} {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	var opts options
+	registry := newOptionRegistry(&opts)
+	var out bytes.Buffer
+	if err := runHelp(&out, "ObsidianToQuartz", registry, []string{"--plain", topicSync}); err != nil {
+		t.Fatalf("runHelp() error = %v", err)
+	}
+	for _, o := range registry {
+		listed := strings.Contains(out.String(), "Environment: "+o.envVar()+",")
+		if listed != (o.topic == topicSync) {
+			t.Errorf("help sync lists --%s: %v, want %v", o.name, listed, o.topic == topicSync)
+		}
+	}
+	if err := runHelp(&out, "ObsidianToQuartz", registry, []string{"drawings"}); err == nil {
+		t.Errorf("runHelp() of an unknown topic error = nil")
+	}
+}
@@ -0,0 +1,149 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreOverride is an --override rule, deciding for this run only whether matching paths are published
+type ignoreOverride struct {
+	include bool
+	pattern string        // Pattern in the syntax of .obsidian-to-quartz-ignore
+	match   ignorePattern // pattern, parsed
+}
+
+// String returns the rule as given on the command line
+func (o ignoreOverride) String() string {
+	if o.include {
+		return "include:" + o.pattern
+	}
+	return "exclude:" + o.pattern
+}
+
+// parseOverrides reads --override values of the form include:PATTERN or exclude:PATTERN
+func parseOverrides(values []string) ([]ignoreOverride, error) {
+	var overrides []ignoreOverride
+	for _, value := range values {
+		kind, pattern, ok := strings.Cut(value, ":")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || (kind != "include" && kind != "exclude") || pattern == "" {
+			return nil, fmt.Errorf("%q is not of the form include:PATTERN or exclude:PATTERN", value)
+		}
+		match, err := compileIgnorePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, ignoreOverride{include: kind == "include", pattern: pattern, match: match})
+	}
+	return overrides, nil
+}
+
+// ignored checks if a vault path is left out by the ignore patterns, then by the --override rules,
+// which take precedence; the last matching rule wins
+// override is the rule that decided, or "" if the ignore patterns did
+// A folder is walked into if an include rule may match something inside it, even if the ignore patterns exclude it
+func (c *converter) ignored(relPath string, isDir bool) (ignored bool, override string) {
+	if len(c.overrides) == 0 {
+		return shouldExclude(relPath, c.excludePatterns, isDir) || c.gitignored(relPath, isDir), ""
+	}
+	ignored = matchesPathOrParent(relPath, c.excludePatterns, isDir) || c.gitignored(relPath, isDir)
+	for _, o := range c.overrides {
+		if matchesPathOrParent(relPath, []ignorePattern{o.match}, isDir) {
+			ignored, override = !o.include, o.String()
+		} else if isDir && o.include && ignored && o.match.mayMatchInside(relPath) {
+			ignored, override = false, o.String()
+		}
+	}
+	return ignored, override
+}
+
+// matchesPathOrParent checks if a path, or one of the folders holding it, matches an ignore pattern
+// Folders are normally skipped as a whole, but a folder opened by an include rule still excludes the rest of its files
+func matchesPathOrParent(relPath string, patterns []ignorePattern, isDir bool) bool {
+	if shouldExclude(relPath, patterns, isDir) {
+		return true
+	}
+	for dir := filepath.Dir(relPath); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if shouldExclude(dir, patterns, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayMatchInside checks if a pattern may match a path inside a folder, from the part of the pattern before its first wildcard
+func mayMatchInside(pattern, dir string) bool {
+	prefix := filepath.ToSlash(pattern)
+	if i := strings.IndexAny(prefix, `*?[\`); i >= 0 {
+		prefix = prefix[:i]
+	}
+	dir = filepath.ToSlash(dir) + "/"
+	return strings.HasPrefix(prefix, dir) || strings.HasPrefix(dir, prefix)
+}
+
+// planOverrideKeeps finds the published files --override excludes for this run only, so --clean and --prune keep them
+// Files published only because of an include rule need nothing: no run deletes a file because its source is gone
+func (c *converter) planOverrideKeeps() error {
+	c.overrideKept = make(map[string]bool)
+	hasExclude := false
+	for _, o := range c.overrides {
+		hasExclude = hasExclude || !o.include
+	}
+	if !hasExclude {
+		return nil
+	}
+	return c.walkVault(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == c.obsidianFolder {
+			return nil
+		}
+		relPath, err := filepath.Rel(c.obsidianFolder, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		// Only what the ignore patterns publish matters
+		if shouldExclude(relPath, c.excludePatterns, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if ignored, override := c.ignored(relPath, false); ignored && override != "" {
+			c.overrideKept[filepath.ToSlash(c.destRel(relPath))] = true
+		}
+		return nil
+	})
+}
+
+// reportOverrides lists the --override rules of the run, so a preview build says how it differs from the usual one
+func (c *converter) reportOverrides() {
+	if len(c.overrides) == 0 {
+		return
+	}
+	rules := make([]string, len(c.overrides))
+	for i, o := range c.overrides {
+		rules[i] = o.String()
+	}
+	c.report.Overrides = rules
+	c.console.warnf("ignore rules overridden for this run: %s", strings.Join(rules, ", "))
+}
+
+// reportOverrideEffects explains what the --override rules leave behind in the content folder
+func (c *converter) reportOverrideEffects() {
+	if n := c.report.IncludedOverride; n > 0 {
+		c.console.infof("%d files were published only because of --override; a run without it leaves them in the content folder, until --clean", n)
+	}
+	if n := c.overrideKeptCount; n > 0 {
+		c.console.infof("Kept %d files of the content folder that --override excludes for this run only", n)
+	}
+}
@@ -0,0 +1,126 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := parseOverrides([]string{"include:Drafts/**", "exclude: Blog/wip-*"})
+	if err != nil {
+		t.Fatalf("parseOverrides() error = %v", err)
+	}
+	if len(overrides) != 2 || overrides[0].String() != "include:Drafts/**" || overrides[1].String() != "exclude:Blog/wip-*" {
+		t.Errorf("parseOverrides() = %v", overrides)
+	}
+	for _, value := range []string{"Drafts/**", "publish:Drafts/**", "include:", "exclude:  "} {
+		if _, err := parseOverrides([]string{value}); err == nil {
+			t.Errorf("parseOverrides(%q) error = nil, want an invalid rule", value)
+		}
+	}
+}
+
+func TestIgnoredWithOverrides(t *testing.T) {
+	excludes, err := compileIgnorePatterns([]string{"Drafts/", "*.tmp"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := parseOverrides([]string{"include:Drafts/**", "exclude:Drafts/secret.md", "exclude:Blog/wip-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &converter{excludePatterns: excludes, overrides: overrides}
+	tests := []struct {
+		path     string
+		isDir    bool
+		ignored  bool
+		override string
+	}{
+		{"Drafts", true, false, "include:Drafts/**"},
+		{"Drafts/Idea.md", false, false, "include:Drafts/**"},
+		{"Drafts/secret.md", false, true, "exclude:Drafts/secret.md"},
+		{"Blog/wip-one.md", false, true, "exclude:Blog/wip-*"},
+		{"Blog/Post.md", false, false, ""},
+		{"notes.tmp", false, true, ""},
+	}
+	for _, tt := range tests {
+		ignored, override := c.ignored(filepath.FromSlash(tt.path), tt.isDir)
+		if ignored != tt.ignored || override != tt.override {
+			t.Errorf("ignored(%q) = %v, %q, want %v, %q", tt.path, ignored, override, tt.ignored, tt.override)
+		}
+	}
+}
+
+// contentFiles returns the files of the content folder of a Quartz folder, by slash-separated path
+func contentFiles(t *testing.T, quartz string) string {
+	t.Helper()
+	var files []string
+	for file := range readTree(t, filepath.Join(quartz, "content")) {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return strings.Join(files, ", ")
+}
+
+func TestOverrides(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Blog/Post.md":     "Post\n",
+		"Blog/wip-one.md":  "WIP\n",
+		"Drafts/Idea.md":   "Idea\n",
+		"Drafts/secret.md": "Secret\n",
+	})
+	overrides := []string{"-override", "include:Drafts/**", "-override", "exclude:Drafts/secret.md", "-override", "exclude:Blog/wip-*"}
+
+	for _, deletion := range []string{"-prune", "-clean"} {
+		t.Run(deletion, func(t *testing.T) {
+			quartz := t.TempDir()
+			if err := os.WriteFile(filepath.Join(quartz, "quartz.config.ts"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if code := runTestSync(t, vault, quartz, "-exclude", "Drafts/"); code != exitSuccess {
+				t.Fatalf("run exited with %d", code)
+			}
+			if got := contentFiles(t, quartz); got != "Blog/Post.md, Blog/wip-one.md" {
+				t.Fatalf("published %s", got)
+			}
+
+			// The include rule widens the published set, and the exclude rule narrows it without deleting what it excludes
+			if code := runTestSync(t, vault, quartz, append([]string{"-exclude", "Drafts/", deletion}, overrides...)...); code != exitSuccess {
+				t.Fatalf("run with overrides exited with %d", code)
+			}
+			if got := contentFiles(t, quartz); got != "Blog/Post.md, Blog/wip-one.md, Drafts/Idea.md" {
+				t.Errorf("with overrides, the content folder holds %s", got)
+			}
+
+			// Back to the usual rules, the files published only for the preview go
+			if code := runTestSync(t, vault, quartz, "-exclude", "Drafts/", deletion); code != exitSuccess {
+				t.Fatalf("run without overrides exited with %d", code)
+			}
+			if got := contentFiles(t, quartz); got != "Blog/Post.md, Blog/wip-one.md" {
+				t.Errorf("without overrides, the content folder holds %s", got)
+			}
+		})
+	}
+}
+
+func TestOverridesReport(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Drafts/Idea.md": "Idea\n", "Blog/wip-one.md": "WIP\n"})
+	quartz := t.TempDir()
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if code := runTestSync(t, vault, quartz, "-exclude", "Drafts/", "-override", "include:Drafts/**",
+		"-override", "exclude:Blog/wip-*", "-report-json", reportPath); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"overrides": [`, `"include:Drafts/**"`, `"included_override": 1`, `"skipped_override": 1`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("report has no %s:\n%s", want, data)
+		}
+	}
+}
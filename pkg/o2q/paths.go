@@ -0,0 +1,115 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pathDisplay shortens the paths shown in messages and reports, so they can be shared without leaking
+// the home directory layout: vault files are shown relative to the vault, written files relative to the content folder
+type pathDisplay struct {
+	absolute      bool           // Show absolute paths (--absolute-paths)
+	sourceFolders []string       // Vault folder as given and absolute
+	destFolders   []string       // Content folder as given and absolute
+	home          string         // Home directory, shown as ~
+	prefixes      *regexp.Regexp // Matches a vault or content folder prefix in free text
+}
+
+// newPathDisplay creates the path display for a vault and a content folder
+func newPathDisplay(sourceFolder, destFolder string, absolute bool) *pathDisplay {
+	d := &pathDisplay{absolute: absolute}
+	d.sourceFolders = folderForms(sourceFolder)
+	d.destFolders = folderForms(destFolder)
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		d.home = filepath.Clean(home)
+	}
+
+	// Longest prefixes first, so a content folder inside the vault wins over the vault
+	prefixes := append(append([]string{}, d.sourceFolders...), d.destFolders...)
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	quoted := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		quoted[i] = regexp.QuoteMeta(prefix)
+	}
+	d.prefixes = regexp.MustCompile(`(^|[\s"'(=])(?:` + strings.Join(quoted, "|") + `)[/\\]`)
+	return d
+}
+
+// folderForms returns a folder as given and as an absolute path, both cleaned
+func folderForms(folder string) []string {
+	forms := []string{filepath.Clean(folder)}
+	if abs, err := filepath.Abs(folder); err == nil && abs != forms[0] {
+		forms = append(forms, abs)
+	}
+	return forms
+}
+
+// source returns the path of a vault file as shown to the user
+func (d *pathDisplay) source(p string) string {
+	return d.relative(p, d.sourceFolders)
+}
+
+// dest returns the path of a written file as shown to the user
+func (d *pathDisplay) dest(p string) string {
+	return d.relative(p, d.destFolders)
+}
+
+// relative returns p relative to the first of the folders it is in, with forward slashes
+func (d *pathDisplay) relative(p string, folders []string) string {
+	if p == "" {
+		return p
+	}
+	if d.absolute {
+		return absPath(p)
+	}
+	for _, folder := range folders {
+		if rel, err := filepath.Rel(folder, p); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	// Paths outside of the folders, like the Quartz static folder, start with ..
+	if rel, err := filepath.Rel(folders[len(folders)-1], absPath(p)); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return d.scrub(p)
+}
+
+// scrub shortens the vault and content folder paths found in a message, and hides the home directory
+func (d *pathDisplay) scrub(text string) string {
+	if d.absolute {
+		return text
+	}
+	return d.hideHome(d.prefixes.ReplaceAllString(text, "$1"))
+}
+
+// hideHome shows the home directory as ~ in a message, leaving the other paths whole
+func (d *pathDisplay) hideHome(text string) string {
+	if d.absolute || d.home == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, d.home+string(filepath.Separator), "~"+string(filepath.Separator))
+}
+
+// base returns a folder as recorded in the JSON report, with the home directory shown as ~
+func (d *pathDisplay) base(folder string) string {
+	abs := absPath(folder)
+	if d.absolute || d.home == "" {
+		return abs
+	}
+	if rel, err := filepath.Rel(d.home, abs); err == nil && filepath.IsLocal(rel) {
+		return "~" + string(filepath.Separator) + rel
+	}
+	return abs
+}
+
+// absPath returns the absolute form of a path, or the path itself if it cannot be resolved
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
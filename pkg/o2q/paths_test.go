@@ -0,0 +1,122 @@
+package o2q
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPathDisplay(t *testing.T) {
+	root := t.TempDir()
+	vault := filepath.Join(root, "vault")
+	content := filepath.Join(root, "quartz", "content")
+	d := newPathDisplay(vault, content, false)
+
+	for p, want := range map[string]string{
+		filepath.Join(vault, "Projects", "Roadmap.md"):   "Projects/Roadmap.md",
+		filepath.Join(root, "quartz", "static", "a.png"): "../quartz/static/a.png",
+		"": "",
+	} {
+		if got := d.source(p); got != want {
+			t.Errorf("source(%q) = %q, want %q", p, got, want)
+		}
+	}
+	if got := d.dest(filepath.Join(content, "projects", "roadmap.md")); got != "projects/roadmap.md" {
+		t.Errorf("dest() = %q, want %q", got, "projects/roadmap.md")
+	}
+	if got := d.dest(filepath.Join(root, "quartz", "quartz", "static", "a.png")); got != "../quartz/static/a.png" {
+		t.Errorf("dest() of the static folder = %q, want %q", got, "../quartz/static/a.png")
+	}
+
+	message := "copying " + filepath.Join(vault, "a.png") + " to " + filepath.Join(content, "a.png") + ": " +
+		`open "` + filepath.Join(vault, "Sub", "b.md") + `": denied`
+	want := "copying a.png to a.png: " + `open "` + filepath.Join("Sub", "b.md") + `": denied`
+	if got := d.scrub(message); got != want {
+		t.Errorf("scrub(%q) = %q, want %q", message, got, want)
+	}
+
+	absolute := newPathDisplay(vault, content, true)
+	if p := filepath.Join(vault, "Roadmap.md"); absolute.source(p) != p || absolute.scrub(message) != message {
+		t.Errorf("--absolute-paths shortens paths: %q, %q", absolute.source(p), absolute.scrub(message))
+	}
+}
+
+// reportStrings collects the strings of a decoded JSON value, by their path in the value
+func reportStrings(prefix string, value interface{}, values map[string]string) {
+	switch v := value.(type) {
+	case string:
+		values[prefix] = v
+	case []interface{}:
+		for i, item := range v {
+			reportStrings(prefix+"/"+strconv.Itoa(i), item, values)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			reportStrings(prefix+"/"+key, item, values)
+		}
+	}
+}
+
+func TestRelativeReportPaths(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Home.md":          "See [[Nowhere]] and ![[chart.png]].\n",
+		"Broken.md":        "---\ntitle: [unclosed\n---\nBroken\n",
+		"assets/chart.png": "png",
+		"Private/Diary.md": "Diary\n",
+	})
+	for _, absolute := range []bool{false, true} {
+		quartz := t.TempDir()
+		reportPath := filepath.Join(t.TempDir(), "report.json")
+		args := []string{"-resolve-links", "-exclude", "Private/", "-report-json", reportPath}
+		if absolute {
+			args = append(args, "-absolute-paths")
+		}
+		opts := testOptions(t, args...)
+		sources := []vaultSource{{folder: vault, sub: "."}}
+		if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+			t.Fatal(err)
+		}
+		var messages bytes.Buffer
+		log := &consoleLogger{verbosity: verbosityVerbose, out: &messages, err: &messages}
+		runSources(log, opts, config{}, sources, quartz, "")
+
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report interface{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		values := make(map[string]string)
+		reportStrings("", report, values)
+		leaked := false
+		for key, value := range values {
+			if strings.HasPrefix(key, "/base/") || key == "/started_at" {
+				continue
+			}
+			if strings.Contains(value, vault) || strings.Contains(value, quartz) {
+				leaked = true
+				if !absolute {
+					t.Errorf("report %s = %q, want a relative path", key, value)
+				}
+			}
+		}
+		if absolute && !leaked {
+			t.Errorf("with --absolute-paths, the report has no absolute path:\n%s", data)
+		}
+		if values["/base/source"] != vault && !strings.HasPrefix(values["/base/source"], "~") {
+			t.Errorf("report base source = %q, want the vault folder", values["/base/source"])
+		}
+		if strings.Contains(messages.String(), vault) != absolute {
+			t.Errorf("--absolute-paths %v: messages show the vault folder: %v, want %v\n%s", absolute, !absolute, absolute, messages.String())
+		}
+		if !strings.Contains(messages.String(), "Broken.md") {
+			t.Errorf("messages do not name the note with invalid frontmatter:\n%s", messages.String())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package o2q
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// pinsFileName is the file of the Quartz folder recording the published path of the assets pinned by --pin-assets
+const pinsFileName = ".obsidian-to-quartz-pins.json"
+
+// pinsVersion is the version of the pins file format; a file of another version is ignored
+const pinsVersion = 1
+
+// pinsState is the content of the pins file
+type pinsState struct {
+	Version int                          `json:"version"`
+	Pins    map[string]map[string]string `json:"pins"` // Published path of each pinned asset, by vault-relative path, by content folder
+}
+
+// loadPins reads the pins file of the Quartz folder for --pin-assets
+// A missing or outdated file starts a new one: the assets are pinned to the path this run publishes them to
+func loadPins(log *consoleLogger, quartzFolder string) *pinsState {
+	state := &pinsState{Pins: map[string]map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(quartzFolder, pinsFileName))
+	if os.IsNotExist(err) {
+		log.infof("No pins file from a previous run, pinning every asset to the path it is published to")
+		return state
+	}
+	var saved pinsState
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	switch {
+	case err != nil:
+		log.warnf("Ignoring the pins file, it cannot be read: %v", err)
+	case saved.Version != pinsVersion:
+		log.warnf("Ignoring the pins file, it was written by another version of obsidian-to-quartz")
+	default:
+		if saved.Pins != nil {
+			state.Pins = saved.Pins
+		}
+	}
+	return state
+}
+
+// isPinnedAsset reports whether --pin-assets pins a vault file: an asset, not a note or canvas, matching one of its patterns
+func (c *converter) isPinnedAsset(relPath string) bool {
+	if hasExt(relPath, ".md") || hasExt(relPath, ".canvas") {
+		return false
+	}
+	file := filepath.ToSlash(relPath)
+	return matchesAny(file, path.Base(file), splitList(c.opts.pinAssets))
+}
+
+// planPins keeps the assets matched by --pin-assets at the path recorded by an earlier run, once every other
+// rename is planned, so --sanitize-names, --attachments-to, --normalize-unicode or --map do not change the URL
+// of an image other sites link to
+// With --break-pins the assets move to their new path instead, and their old URL is redirected
+func (c *converter) planPins() error {
+	if c.opts.pinAssets == "" {
+		return nil
+	}
+	c.pins = loadPins(c.console, c.quartzFolder)
+	key := path.Clean(filepath.ToSlash(c.opts.contentDir))
+	previous := c.pins.Pins[key]
+
+	var assets []string
+	taken := make(map[string]string)
+	err := c.walkEligible(func(relPath string) error {
+		file := filepath.ToSlash(relPath)
+		taken[filepath.ToSlash(c.destRel(relPath))] = file
+		if c.isPinnedAsset(relPath) {
+			assets = append(assets, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(assets)
+
+	// Pins of assets left out of this run are kept while the asset is in the vault
+	pinned := make(map[string]string)
+	for file, dest := range previous {
+		if _, err := c.vault.Lstat(filepath.Join(c.obsidianFolder, filepath.FromSlash(file))); err == nil {
+			pinned[file] = dest
+		}
+	}
+
+	var kept []string
+	for _, file := range assets {
+		dest := filepath.ToSlash(c.destRel(file))
+		old, ok := previous[file]
+		switch {
+		case !ok || old == dest:
+		case c.opts.breakPins:
+			c.brokenPins = append(c.brokenPins, redirect{From: old, To: dest})
+			c.console.progressf("Unpinned: %s → %s", old, dest)
+		case taken[old] != "" && taken[old] != file:
+			c.console.warnf("%s: pinned to %s, which %s is now published to, publishing it to %s", file, old, taken[old], dest)
+		default:
+			if c.renames == nil {
+				c.renames = make(map[string]string)
+			}
+			c.renames[file] = old
+			if c.pinned == nil {
+				c.pinned = make(map[string]bool)
+			}
+			c.pinned[file] = true
+			kept = append(kept, file)
+			dest = old
+		}
+		pinned[file] = dest
+	}
+	c.pins.Pins[key] = pinned
+
+	if len(kept) > 0 {
+		c.console.infof("%d pinned assets keep the path of an earlier run instead of moving:", len(kept))
+		for _, file := range kept {
+			c.console.detailf("%s → %s", file, c.renames[file])
+		}
+	}
+	if len(c.brokenPins) > 0 {
+		c.console.infof("%d pinned assets move with --break-pins, redirecting their old URL", len(c.brokenPins))
+	}
+	return nil
+}
+
+// redirectBrokenPins records a redirect from the old URL of each asset --break-pins moved to its new one
+func (c *converter) redirectBrokenPins() {
+	if c.redirects == nil {
+		return
+	}
+	prefix := c.sitePrefix()
+	for _, r := range c.brokenPins {
+		c.redirects.add(path.Join(prefix, quartzSlug(r.From)), path.Join(prefix, quartzSlug(r.To)))
+	}
+}
+
+// writePins saves the pins file
+func (c *converter) writePins() error {
+	data, err := json.MarshalIndent(pinsState{Version: pinsVersion, Pins: c.pins.Pins}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the pins file: %v", err)
+	}
+	if err := writeTextFile(filepath.Join(c.quartzFolder, pinsFileName), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write the pins file: %v", err)
+	}
+	return nil
+}
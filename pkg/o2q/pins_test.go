@@ -0,0 +1,49 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPinAssets(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Note.md":            "![[chart.png]]\n",
+		"Diagrams/chart.png": "png",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-pin-assets", "*.png"); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+	readContent(t, quartz, "Diagrams/chart.png")
+
+	// --attachments-to would move the image, the pin keeps it and the link where they were
+	if code := runTestSync(t, vault, quartz, "-pin-assets", "*.png", "-attachments-to", "assets", "-clean", "-yes"); code != exitSuccess {
+		t.Fatalf("second run exited with %d", code)
+	}
+	readContent(t, quartz, "Diagrams/chart.png")
+	if _, err := os.Stat(filepath.Join(quartz, "content", "assets", "chart.png")); err == nil {
+		t.Errorf("pinned image also published to assets/chart.png")
+	}
+	if note := readContent(t, quartz, "Note.md"); strings.Contains(note, "assets/") {
+		t.Errorf("link to the pinned image rewritten to its new folder: %q", note)
+	}
+
+	// --break-pins moves it once, redirects its old URL, and pins it to its new path
+	if code := runTestSync(t, vault, quartz, "-pin-assets", "*.png", "-attachments-to", "assets", "-break-pins", "-redirects", "json", "-clean", "-yes"); code != exitSuccess {
+		t.Fatalf("run with --break-pins exited with %d", code)
+	}
+	readContent(t, quartz, "assets/chart.png")
+	redirects, err := os.ReadFile(filepath.Join(quartz, "redirects.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(redirects), `"from": "Diagrams/chart.png"`) || !strings.Contains(string(redirects), `"to": "assets/chart.png"`) {
+		t.Errorf("redirects.json = %s, want a redirect from Diagrams/chart.png to assets/chart.png", redirects)
+	}
+	if code := runTestSync(t, vault, quartz, "-pin-assets", "*.png", "-clean", "-yes"); code != exitSuccess {
+		t.Fatalf("run after --break-pins exited with %d", code)
+	}
+	readContent(t, quartz, "assets/chart.png")
+}
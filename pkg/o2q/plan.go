@@ -0,0 +1,276 @@
+package o2q
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Actions of the plan for the files that are not skipped; skipped files are planned with the actionSkipped* of their report entry
+const (
+	planFolder         = "folder"          // Folder mirrored in the content folder, created with its first file
+	planPublish        = "publish"         // File written by processFile
+	planUnchangedGit   = "unchanged-git"   // File left as it is, as it did not change since --since-git
+	planUnchangedState = "unchanged-state" // File left as it is, as it did not change since the last --incremental run
+)
+
+// plannedFile is what a run does with a file or folder of the vault, decided before anything is written
+type plannedFile struct {
+	src     string      // Path in the vault
+	relPath string      // Path relative to the vault
+	destRel string      // Path relative to the content folder; empty for skipped files
+	dest    string      // Path in the content folder; empty for skipped files
+	info    os.FileInfo // Information on the source, from the walk
+	action  string      // One of the plan* actions, an actionSkipped* or actionError
+	reason  string      // Why a file is skipped, for messages
+	asset   *skippedAsset
+	err     error // Why a file cannot be published, with actionError
+	refused bool  // The error is a destination outside the content folder
+}
+
+// filePlan is every decision of a run, in the order of the vault walk, with the files to publish indexed by name
+// Cross-file checks can read it before the first file is written
+type filePlan struct {
+	files        []plannedFile
+	notesByName  map[string][]string // Vault-relative paths of the published notes, by lowercased name without .md
+	assetsByName map[string][]string // Vault-relative paths of the other published files, by lowercased name
+}
+
+// newFilePlan returns an empty plan
+func newFilePlan() *filePlan {
+	return &filePlan{notesByName: make(map[string][]string), assetsByName: make(map[string][]string)}
+}
+
+// add appends a decision to the plan, indexing the files it publishes
+func (p *filePlan) add(f plannedFile) {
+	p.files = append(p.files, f)
+	if f.action != planPublish && f.action != planUnchangedGit && f.action != planUnchangedState {
+		return
+	}
+	file := filepath.ToSlash(f.relPath)
+	name := strings.ToLower(path.Base(file))
+	if hasExt(file, ".md") {
+		name = strings.TrimSuffix(name, path.Ext(name))
+		p.notesByName[name] = append(p.notesByName[name], file)
+	} else {
+		p.assetsByName[name] = append(p.assetsByName[name], file)
+	}
+}
+
+// eligible counts the files the execution goes through one by one, for the progress meter
+func (p *filePlan) eligible() int {
+	n := 0
+	for _, f := range p.files {
+		if f.action == planPublish || f.action == planUnchangedState || (f.action == actionError && f.info != nil && !f.info.IsDir()) {
+			n++
+		}
+	}
+	return n
+}
+
+// planVisit decides what happens to a single file or folder of the vault, without writing anything
+func (c *converter) planVisit(path string, info os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+
+	// Skip the root folder itself
+	if path == c.obsidianFolder {
+		return nil
+	}
+
+	// Get relative path from obsidian folder
+	relPath, err := filepath.Rel(c.obsidianFolder, path)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path: %v", err)
+	}
+
+	// Skip any directory starting with . (hidden folders like .obsidian, .trash, etc.)
+	// Only the files --include-hidden names are published from them
+	if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+		if c.mayIncludeHidden(relPath) {
+			if err := c.planHiddenFolder(path, info); err != nil {
+				return err
+			}
+		} else {
+			c.console.progressf("Skipped: %s (hidden folder)", path)
+		}
+		return filepath.SkipDir
+	}
+
+	// Skip the folders holding a vault of their own with --skip-nested-vaults
+	if info.IsDir() && c.isNestedVault(path) {
+		c.console.infof("Skipped: %s (nested vault, holding its own .obsidian folder)", path)
+		c.plan.add(plannedFile{src: path, relPath: relPath, info: info, action: actionSkippedVault, reason: "nested vault"})
+		return filepath.SkipDir
+	}
+
+	// export-note only writes the note and the files it needs, and the folders holding them
+	if !c.inExport(relPath, info.IsDir()) {
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	f := plannedFile{src: path, relPath: relPath, info: info}
+
+	// Check if path matches any exclusion pattern, or an --override rule for this run
+	if ignored, override := c.ignored(relPath, info.IsDir()); ignored {
+		f.action, f.reason = actionSkippedIgnored, "ignore pattern"
+		if override != "" {
+			f.action, f.reason = actionSkippedOverride, "excluded by --override "+override
+			c.addReportNote(path, f.reason)
+		}
+		c.plan.add(f)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	} else if override != "" && !info.IsDir() && matchesPathOrParent(relPath, c.excludePatterns, false) {
+		c.addReportNote(path, "published by --override "+override)
+		c.report.IncludedOverride++
+	}
+
+	// Leave out the conflict copies and temporary files of sync services and editors
+	if kind := c.conflictKind(info.Name()); kind != "" && !info.IsDir() {
+		f.action, f.reason = actionSkippedConflict, kind
+		c.plan.add(f)
+		return nil
+	}
+
+	// Never publish the files of the tool, wherever they are
+	if isToolFile(relPath) && !info.IsDir() {
+		return nil
+	}
+
+	// Determine destination path, with names in the chosen Unicode form and made safe by --sanitize-names
+	f.destRel = c.destRel(relPath)
+	f.dest = filepath.Join(c.contentFolder, f.destRel)
+
+	// Folders are created unless they lead out of the content folder
+	if info.IsDir() {
+		f.action = planFolder
+		if err := c.checkContained(f.dest); err != nil {
+			f.action, f.err, f.refused = actionError, err, true
+			c.plan.add(f)
+			return filepath.SkipDir
+		}
+		c.plan.add(f)
+		return nil
+	}
+
+	switch {
+	case c.unchangedSinceGit(relPath):
+		// Leave the files that did not change since --since-git as they are
+		f.action = planUnchangedGit
+	case c.filtered(relPath):
+		f.action, f.reason = actionSkippedFilter, "not matching --filter"
+	case isInExcalidrawFolder(relPath) && !hasExt(path, ".svg") && !c.rendersDrawing(path):
+		f.action, f.reason = actionSkippedExcalidraw, "not an SVG in an Excalidraw folder"
+	case c.assetSkipped(relPath) != nil:
+		// Files left out by --max-file-size, --exclude-ext and --include-ext
+		f.asset = c.assetSkipped(relPath)
+		f.action, f.reason = actionSkippedType, f.asset.reason
+		if f.asset.tooLarge {
+			f.action = actionSkippedFileSize
+		}
+	default:
+		if isInExcalidrawFolder(relPath) || c.rendersDrawing(path) {
+			// Links to drawings are rewritten to .excalidraw.svg, whatever the case of the exported file,
+			// and drawings rendered by --render-drawings are published there
+			f.dest = strings.TrimSuffix(f.dest, filepath.Ext(f.dest)) + ".svg"
+		}
+		f.action = planPublish
+		if c.unchangedSinceState(relPath, path, info) {
+			// Leave the files that did not change since the last --incremental run as they are
+			f.action = planUnchangedState
+		} else if f.err = c.claimPlannedDest(relPath, path, f.dest); f.err != nil {
+			var r refusal
+			f.action, f.refused = actionError, errors.As(f.err, &r)
+		}
+	}
+	c.plan.add(f)
+	return nil
+}
+
+// claimPlannedDest checks that a file can be published to dest: its quartz-path is valid, no other file
+// is published there and it stays inside the content folder
+func (c *converter) claimPlannedDest(relPath, src, dest string) error {
+	if err := c.relocationErr(relPath); err != nil {
+		return err
+	}
+	if err := c.claimDest(src, dest); err != nil {
+		return err
+	}
+	return c.checkContained(dest)
+}
+
+// executePlan carries out the decisions of the plan in order, recording each file in the report
+// It stops at the first error that ends the run, such as a full disk or a failing hook with --fail-fast
+func (c *converter) executePlan() error {
+	for _, f := range c.plan.files {
+		if err := c.execute(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execute carries out the decision of the plan for a single file or folder
+func (c *converter) execute(f plannedFile) error {
+	switch f.action {
+	case planFolder:
+		// Folders are created by the files written to them, unless a marker file keeps them when empty
+		if hasKeepMarker(f.src) {
+			return c.makeDir(f.dest)
+		}
+		return nil
+	case planUnchangedGit:
+		c.report.UnchangedSinceGit++
+		return nil
+	case planUnchangedState:
+		c.report.SkippedUnchanged++
+		c.console.meter.step(f.relPath)
+		return nil
+	case actionError:
+		if f.refused {
+			c.refusedFiles++
+		}
+		c.console.errorf("%s: %v", f.src, f.err)
+		c.record(reportEntry{Source: f.src, Destination: f.dest, Action: actionError, Error: f.err.Error()}, 0)
+		if !f.info.IsDir() {
+			c.console.meter.step(f.relPath)
+		}
+		return nil
+	case planPublish:
+		c.warnModifiedOutputs(f.relPath, f.src)
+		err := c.processFile(f.src, f.destRel, f.dest)
+		c.console.meter.step(f.relPath)
+		if err != nil {
+			c.record(reportEntry{Source: f.src, Destination: f.dest, Action: actionError, Error: err.Error()}, 0)
+			return err
+		}
+		// Hooks may rewrite the files, such as an image optimizer, so the state records them as the hooks left them
+		err = c.runFileHooks(f.src)
+		c.recordState(f.relPath, f.src, f.info)
+		c.noteApplied(f)
+		delete(c.written, f.src)
+		return err
+	}
+
+	// Skipped files
+	if f.action == actionSkippedConflict {
+		c.skipConflict(f.src, f.reason)
+		return nil
+	}
+	if f.asset != nil {
+		c.skipAsset(f.src, f.asset)
+		return nil
+	}
+	c.record(reportEntry{Source: f.src, Action: f.action}, 0)
+	return nil
+}
@@ -0,0 +1,82 @@
+package o2q
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Progress display modes
+const (
+	progressAuto   = "auto"   // Show progress in place when stdout is a terminal
+	progressAlways = "always" // Like auto, with periodic progress lines when output is piped
+	progressNever  = "never"  // Never show progress
+)
+
+// Periodic progress lines are printed every progressEvery files or progressInterval, whichever comes first
+const (
+	progressEvery    = 100
+	progressInterval = 5 * time.Second
+)
+
+// progressMeter displays how many of the eligible files have been processed
+type progressMeter struct {
+	out       io.Writer
+	total     int
+	done      int
+	inPlace   bool      // Redraw a single line instead of printing periodic lines
+	shown     bool      // An in-place line is currently displayed
+	lastPrint time.Time // Time of the last periodic line
+	lastDone  int       // Files done at the last periodic line
+	width     int       // Terminal width used to truncate in-place lines
+}
+
+// isTerminal checks if a file is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newProgressMeter creates the meter for the mode, or returns nil if no progress is shown
+func newProgressMeter(log *consoleLogger, mode string, total int) *progressMeter {
+	file, ok := log.out.(*os.File)
+	tty := ok && isTerminal(file)
+	if mode == progressNever || (mode == progressAuto && !tty) || log.verbosity == verbosityQuiet {
+		return nil
+	}
+	return &progressMeter{out: log.out, total: total, inPlace: tty, lastPrint: time.Now(), width: helpWidth()}
+}
+
+// step records one more processed file and updates the display
+func (p *progressMeter) step(relPath string) {
+	if p == nil {
+		return
+	}
+	p.done++
+
+	if p.inPlace {
+		line := fmt.Sprintf("%d/%d files (%s)", p.done, p.total, relPath)
+		if len(line) > p.width-1 {
+			line = line[:p.width-1]
+		}
+		fmt.Fprintf(p.out, "\r\033[K%s", line)
+		p.shown = true
+		return
+	}
+
+	if p.done-p.lastDone >= progressEvery || time.Since(p.lastPrint) >= progressInterval || p.done == p.total {
+		fmt.Fprintf(p.out, "Progress: %d/%d files\n", p.done, p.total)
+		p.lastDone = p.done
+		p.lastPrint = time.Now()
+	}
+}
+
+// clear erases the in-place line so other messages start on a clean line
+func (p *progressMeter) clear() {
+	if p == nil || !p.shown {
+		return
+	}
+	fmt.Fprint(p.out, "\r\033[K")
+	p.shown = false
+}
@@ -0,0 +1,142 @@
+package o2q
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quartzOwned are the files and folders of a Quartz folder, which --prune keeps when it is the content folder itself,
+// with --no-content-subdir
+var quartzOwned = map[string]bool{
+	"quartz": true, "node_modules": true, "public": true,
+	"package.json": true, "package-lock.json": true, "tsconfig.json": true,
+	"quartz.config.ts": true, "quartz.layout.ts": true, "globals.d.ts": true, "index.d.ts": true,
+	"Dockerfile": true, "LICENSE.txt": true, "README.md": true, "CODE_OF_CONDUCT.md": true,
+}
+
+// pruneContent deletes the files of the content folder that no vault file was published to by this run, such as the
+// notes deleted or renamed in the vault since they were published, then the folders it leaves empty
+// Files matching --clean-keep, hidden files and an index.md at the root of the content folder, the home page Quartz
+// needs, are kept, as are the files of Quartz with --no-content-subdir and the files --override excludes for this run only
+func (c *converter) pruneContent() error {
+	if !c.opts.prune {
+		return nil
+	}
+	if c.overrideKept == nil {
+		if err := c.planOverrideKeeps(); err != nil {
+			return fmt.Errorf("failed to prune the content folder: %v", err)
+		}
+	}
+	emptied := make(map[string]bool)
+	err := c.walkPrunable(func(p, rel string) error {
+		if c.overrideKept[rel] {
+			c.console.progressf("Kept: %s (source excluded by --override for this run only)", p)
+			c.overrideKeptCount++
+			return nil
+		}
+		if c.opts.dryRun && filepath.Base(p) == "index.md" {
+			// Tag pages and folder indexes are not generated by a dry run
+			if content, err := os.ReadFile(p); err == nil && (isTagPage(content) || isFolderIndex(content)) {
+				return nil
+			}
+		}
+
+		if err := c.checkContained(p); err != nil {
+			return fmt.Errorf("%s %w", p, err)
+		}
+		if buried, err := c.buryNote(p, "--prune, published from no vault file"); err != nil || buried {
+			return err
+		}
+		if c.wouldDelete(p, "--prune, published from no vault file") {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", p, err)
+		}
+		c.record(reportEntry{Destination: p, Action: actionDeleted}, 0)
+		emptied[filepath.Dir(p)] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prune the content folder: %v", err)
+	}
+
+	// Removing a folder that is not empty fails and is ignored
+	for dir := range emptied {
+		for ; dir != c.contentFolder && isWithin(dir, c.contentFolder); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// walkPrunable calls fn for every file of the content folder this run published nothing to, with its path relative
+// to the content folder, leaving out the files --prune always keeps and the stubs of --tombstones
+func (c *converter) walkPrunable(fn func(p, rel string) error) error {
+	files, folders := c.claimedOutputs()
+	keep := c.keepPatterns()
+	return filepath.WalkDir(c.contentFolder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == c.contentFolder {
+			return nil
+		}
+		rel, err := filepath.Rel(c.contentFolder, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(d.Name(), ".") || matchesAny(rel, d.Name(), keep) || folders[p] || rel == "index.md" ||
+			(c.opts.noContentSubdir && quartzOwned[rel]) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || files[p] || c.liveTombstone(rel) {
+			return nil
+		}
+		return fn(p, rel)
+	})
+}
+
+// claimedOutputs returns the files of the content folder this run published, or left as they are as they did not
+// change, and the folders of split notes, which are kept whole
+// A file that failed keeps its previous copy, and a file kept by --no-clobber or --update-only stays
+func (c *converter) claimedOutputs() (files, folders map[string]bool) {
+	files = make(map[string]bool)
+	folders = make(map[string]bool)
+	for file := range c.outputs {
+		files[file] = true
+	}
+	for _, f := range c.plan.files {
+		switch f.action {
+		case planPublish, planUnchangedGit, planUnchangedState, actionError:
+		default:
+			continue
+		}
+		if f.info == nil || f.info.IsDir() || f.dest == "" {
+			continue
+		}
+		files[f.dest] = true
+		if _, dest := c.plannedWrite(f); dest != "" {
+			files[dest] = true
+		}
+		key := filepath.ToSlash(f.relPath)
+		if _, ok := c.splitNotes[key]; ok {
+			folders[strings.TrimSuffix(f.dest, filepath.Ext(f.dest))] = true
+		}
+		if f.action == planUnchangedState {
+			for _, out := range c.state.previous[key].Outputs {
+				files[filepath.Join(c.contentFolder, filepath.FromSlash(out.Path))] = true
+			}
+		}
+	}
+	return files, folders
+}
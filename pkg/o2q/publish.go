@@ -0,0 +1,134 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lostNote is a note published on Obsidian Publish that this run will not publish
+type lostNote struct {
+	relPath string
+	reason  string
+}
+
+// publishSettings reads the Obsidian Publish flag of a note: publish: true
+// Its permalink is read with the quartz-path of the notes, see findRelocations
+func (c *converter) publishSettings(src string) (published bool, err error) {
+	content, err := c.vault.ReadFile(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to read markdown file: %v", err)
+	}
+	values, err := parseFrontmatter(content)
+	if err != nil {
+		return false, err
+	}
+	published, _ = frontmatterBool(values, "publish")
+	return published, nil
+}
+
+// unpublishedReason explains why the frontmatter of a note keeps it off the site, or returns ""
+// With --from-obsidian-publish a note needs publish: true; with --skip-unpublished, publish: false or draft: true
+// leave it out
+func (c *converter) unpublishedReason(values map[string]interface{}) string {
+	published, set := frontmatterBool(values, "publish")
+	if c.opts.fromObsidianPublish && !published {
+		return "not marked publish: true"
+	}
+	if c.opts.skipUnpublished {
+		if set && !published {
+			return "marked publish: false"
+		}
+		if draft, _ := frontmatterBool(values, "draft"); draft {
+			return "marked draft: true"
+		}
+	}
+	return ""
+}
+
+// unpublished reads the frontmatter of a note and explains why it is left out, see unpublishedReason
+func (c *converter) unpublished(src string) (reason string, err error) {
+	content, err := c.vault.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read markdown file: %v", err)
+	}
+	values, err := parseFrontmatter(content)
+	if err != nil {
+		return "", err
+	}
+	return c.unpublishedReason(values), nil
+}
+
+// exclusionReason explains why a vault path would not be published by the other rules, or returns ""
+func (c *converter) exclusionReason(relPath string) string {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for i := range parts {
+		isDir := i < len(parts)-1
+		if isDir && strings.HasPrefix(parts[i], ".") {
+			if c.hiddenIncludeOf(relPath) != nil {
+				return ""
+			}
+			return "hidden folder"
+		}
+		if ignored, override := c.ignored(strings.Join(parts[:i+1], "/"), isDir); ignored && override != "" {
+			return "--override " + override
+		} else if ignored {
+			return "ignore pattern"
+		}
+	}
+	if isInExcalidrawFolder(relPath) && !hasExt(relPath, ".svg") {
+		return "Excalidraw folder"
+	}
+	if c.filtered(relPath) {
+		return "--filter"
+	}
+	return ""
+}
+
+// findLostPublishedNotes lists the notes marked publish: true that other rules exclude
+// Hidden folders are searched too, since Obsidian Publish does not skip them
+func (c *converter) findLostPublishedNotes() error {
+	return c.walkVault(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Deleted notes and Obsidian settings were never published
+		if info.IsDir() && (info.Name() == ".trash" || info.Name() == ".obsidian") {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !hasExt(path, ".md") {
+			return nil
+		}
+		relPath, err := filepath.Rel(c.obsidianFolder, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		reason := c.exclusionReason(relPath)
+		if reason == "" {
+			return nil
+		}
+		published, err := c.publishSettings(path)
+		if err != nil {
+			if !c.frontmatterFallback(path, err, "") {
+				c.console.warnf("%s: %v", path, err)
+			}
+			return nil
+		}
+		if published {
+			c.lostPublishedNotes = append(c.lostPublishedNotes, lostNote{relPath: relPath, reason: reason})
+		}
+		return nil
+	})
+}
+
+// reportLostPublishedNotes warns about notes that were published on Obsidian Publish but are not published here
+func (c *converter) reportLostPublishedNotes() {
+	if len(c.lostPublishedNotes) == 0 {
+		return
+	}
+	c.console.warnf("%d NOTES MARKED publish: true ARE NOT PUBLISHED BY THIS RUN:", len(c.lostPublishedNotes))
+	for _, note := range c.lostPublishedNotes {
+		c.console.detailf("%s (excluded by %s)", note.relPath, note.reason)
+	}
+}
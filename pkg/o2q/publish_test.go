@@ -0,0 +1,92 @@
+package o2q
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromObsidianPublish(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Home.md":          "---\npublish: true\n---\nSee [[About]] and ![[assets/chart.png]].\n",
+		"About.md":         "---\npublish: true\npermalink: about/me\n---\nAbout\n",
+		"Moved.md":         "---\npublish: true\npermalink: old/place\nquartz-path: new/place\n---\nMoved\n",
+		"Private.md":       "Private\n",
+		"Hidden.md":        "---\npublish: false\n---\nHidden\n",
+		"Drafts/Old.md":    "---\npublish: true\n---\nOld\n",
+		".archive/Post.md": "---\npublish: true\n---\nPost\n",
+		"Drafts/Idea.md":   "Idea\n",
+		"assets/chart.png": "png",
+	})
+	opts := testOptions(t, "-from-obsidian-publish", "-exclude", "Drafts/")
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var warnings bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &warnings}
+	quartz := t.TempDir()
+	if code := runSources(log, opts, config{}, sources, quartz, ""); code != exitSuccess {
+		t.Fatalf("run exited with %d\n%s", code, warnings.String())
+	}
+
+	for file, published := range map[string]bool{
+		"Home.md":          true,
+		"about/me.md":      true,
+		"About.md":         false,
+		"new/place.md":     true,
+		"old/place.md":     false,
+		"Private.md":       false,
+		"Hidden.md":        false,
+		"assets/chart.png": true,
+	} {
+		if _, err := os.Stat(filepath.Join(quartz, "content", filepath.FromSlash(file))); (err == nil) != published {
+			t.Errorf("%s published: %v, want %v", file, err == nil, published)
+		}
+	}
+	if got := readContent(t, quartz, "Home.md"); !strings.Contains(got, "[[about/me|About]]") {
+		t.Errorf("Home.md = %q, want the link to follow the permalink", got)
+	}
+
+	// Notes marked publish: true that other rules exclude are all reported, and only them
+	for _, want := range []string{
+		"2 NOTES MARKED publish: true ARE NOT PUBLISHED BY THIS RUN:",
+		".archive/Post.md (excluded by hidden folder)",
+		"Drafts/Old.md (excluded by ignore pattern)",
+	} {
+		if !strings.Contains(filepath.ToSlash(warnings.String()), want) {
+			t.Errorf("warnings do not report %q:\n%s", want, warnings.String())
+		}
+	}
+	if strings.Contains(warnings.String(), "Idea.md") {
+		t.Errorf("warnings report a note never published:\n%s", warnings.String())
+	}
+}
+
+func TestUnpublishedReason(t *testing.T) {
+	tests := []struct {
+		fromPublish     bool
+		skipUnpublished bool
+		values          map[string]interface{}
+		want            string
+	}{
+		{false, false, map[string]interface{}{"publish": false}, ""},
+		{true, false, map[string]interface{}{"publish": true}, ""},
+		{true, false, map[string]interface{}{}, "not marked publish: true"},
+		{true, false, map[string]interface{}{"publish": "maybe"}, "not marked publish: true"},
+		{false, true, map[string]interface{}{}, ""},
+		{false, true, map[string]interface{}{"publish": false}, "marked publish: false"},
+		{false, true, map[string]interface{}{"draft": true}, "marked draft: true"},
+		{true, true, map[string]interface{}{"publish": true, "draft": true}, "marked draft: true"},
+	}
+	for _, tt := range tests {
+		c := &converter{opts: options{fromObsidianPublish: tt.fromPublish, skipUnpublished: tt.skipUnpublished}}
+		if got := c.unpublishedReason(tt.values); got != tt.want {
+			t.Errorf("unpublishedReason(%v) with --from-obsidian-publish %v, --skip-unpublished %v = %q, want %q",
+				tt.values, tt.fromPublish, tt.skipUnpublished, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package o2q
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// quartzCompat holds how a version of Quartz handles the syntax whose conversion depends on it
+// Transforms with version-dependent output read their switch here instead of comparing versions
+type quartzCompat struct {
+	version     string // Major and minor version, such as 4.4
+	aliasesList bool   // Quartz only reads aliases as a list under the aliases key, not alias or a single string
+}
+
+// quartzVersions lists the known versions of Quartz, oldest first; the last one is the default
+var quartzVersions = []quartzCompat{
+	{version: "4.0", aliasesList: true},
+	{version: "4.1", aliasesList: true},
+	{version: "4.2", aliasesList: true},
+	{version: "4.3"},
+	{version: "4.4"},
+	{version: "4.5"},
+}
+
+// latestQuartz returns the compatibility of the latest known version of Quartz
+func latestQuartz() quartzCompat {
+	return quartzVersions[len(quartzVersions)-1]
+}
+
+// quartzCompatVersions returns the known versions of Quartz, for help and messages
+func quartzCompatVersions() string {
+	versions := make([]string, len(quartzVersions))
+	for i, v := range quartzVersions {
+		versions[i] = v.version
+	}
+	return strings.Join(versions, ", ")
+}
+
+// lookupQuartzCompat returns the compatibility of a version of Quartz, such as 4.4, v4.4 or 4.4.1
+// ok is false for an unknown version, which gets the latest known one
+func lookupQuartzCompat(version string) (quartzCompat, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if version == "" {
+		return latestQuartz(), true
+	}
+	if parts := strings.SplitN(version, ".", 3); len(parts) == 3 {
+		version = parts[0] + "." + parts[1]
+	}
+	for _, v := range quartzVersions {
+		if v.version == version {
+			return v, true
+		}
+	}
+	return latestQuartz(), false
+}
+
+// normalizeAliases rewrites the aliases of a note into the shape older versions of Quartz read:
+// a list under the aliases key. alias: Old name and aliases: Old name both become aliases: [Old name]
+// Notes whose aliases are already a list, or that have none, are left untouched
+func (c *converter) normalizeAliases(src string, content []byte) []byte {
+	if !c.compat.aliasesList {
+		return content
+	}
+	frontmatter, _, ok := splitFrontmatter(content)
+	if !ok {
+		return content
+	}
+	lines, keys, ok := frontmatterKeys(frontmatter)
+	if !ok {
+		return content
+	}
+
+	found, aliases := frontmatterListItems(keys, "alias", "aliases")
+	if len(found) == 0 || len(found) == 1 && found[0].key.Value == "aliases" && found[0].value.Kind == yaml.SequenceNode {
+		return content
+	}
+
+	c.console.progressf("%s: aliases rewritten as a list for Quartz %s", src, c.compat.version)
+	return writeFrontmatterList(content, lines, found, "aliases", aliases)
+}
@@ -0,0 +1,51 @@
+package o2q
+
+import "testing"
+
+func TestLookupQuartzCompat(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		known   bool
+	}{
+		{"4.2", "4.2", true},
+		{"v4.2", "4.2", true},
+		{"4.2.1", "4.2", true},
+		{" 4.4 ", "4.4", true},
+		{"", latestQuartz().version, true},
+		{"3.3", latestQuartz().version, false},
+		{"4", latestQuartz().version, false},
+	}
+	for _, tt := range tests {
+		compat, ok := lookupQuartzCompat(tt.version)
+		if compat.version != tt.want || ok != tt.known {
+			t.Errorf("lookupQuartzCompat(%q) = %s, %v, want %s, %v", tt.version, compat.version, ok, tt.want, tt.known)
+		}
+	}
+}
+
+func TestNormalizeAliases(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string // For Quartz 4.2
+	}{
+		{"---\nalias: Old name\n---\nText\n", "---\naliases:\n  - Old name\n---\nText\n"},
+		{"---\naliases: Old name\ntags: [a]\n---\n", "---\naliases:\n  - Old name\ntags: [a]\n---\n"},
+		{"---\naliases:\n  - Old name\n---\n", "---\naliases:\n  - Old name\n---\n"},
+		{"---\ntitle: Note\n---\n", "---\ntitle: Note\n---\n"},
+		{"No frontmatter\n", "No frontmatter\n"},
+	}
+	for _, version := range []string{"4.2", "4.4"} {
+		compat, _ := lookupQuartzCompat(version)
+		c := &converter{console: console.fork(), compat: compat}
+		for _, tt := range tests {
+			want := tt.want
+			if !compat.aliasesList {
+				want = tt.content
+			}
+			if got := string(c.normalizeAliases("note.md", []byte(tt.content))); got != want {
+				t.Errorf("Quartz %s: normalizeAliases(%q) = %q, want %q", version, tt.content, got, want)
+			}
+		}
+	}
+}
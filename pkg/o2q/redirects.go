@@ -0,0 +1,283 @@
+package o2q
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Formats of --redirects
+const (
+	redirectsNetlify = "netlify" // A block of the _redirects file of the Quartz folder, read by Netlify and Cloudflare Pages
+	redirectsAliases = "aliases" // Old paths added to the aliases frontmatter of the moved note, so Quartz writes redirect pages
+	redirectsJSON    = "json"    // A redirects.json file of the Quartz folder, for other hosts
+)
+
+// redirectsStateFileName is the file of the Quartz folder recording the URL of every note and the redirects found so far
+const redirectsStateFileName = ".obsidian-to-quartz-redirects.json"
+
+// redirectsVersion is the version of the redirects state file format; a file of another version is ignored
+const redirectsVersion = 1
+
+// Lines around the redirects written to the _redirects file, so the rules written by hand are kept
+const (
+	redirectsBlockStart = "# BEGIN obsidian-to-quartz redirects"
+	redirectsBlockEnd   = "# END obsidian-to-quartz redirects"
+)
+
+// redirectsState is the content of the redirects state file
+type redirectsState struct {
+	Version   int                          `json:"version"`
+	URLs      map[string]map[string]string `json:"urls"`      // URL path of each note, by vault-relative path, by content folder
+	Redirects map[string]string            `json:"redirects"` // New URL path of each old one, without leading slash
+}
+
+// redirect is an old URL path of a moved note and the path it moved to
+type redirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// loadRedirects reads the redirects state file of the Quartz folder for --redirects
+// A missing or outdated file starts a new one: notes moved before this run get no redirect
+func loadRedirects(log *consoleLogger, quartzFolder string) *redirectsState {
+	state := &redirectsState{URLs: map[string]map[string]string{}, Redirects: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(quartzFolder, redirectsStateFileName))
+	if os.IsNotExist(err) {
+		log.infof("No redirects file from a previous run, recording the URL of every note")
+		return state
+	}
+	var saved redirectsState
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	switch {
+	case err != nil:
+		log.warnf("failed to read the redirects file, recording the URL of every note again: %v", err)
+	case saved.Version != redirectsVersion:
+		log.warnf("the redirects file has version %d instead of %d, recording the URL of every note again", saved.Version, redirectsVersion)
+	default:
+		if saved.URLs != nil {
+			state.URLs = saved.URLs
+		}
+		if saved.Redirects != nil {
+			state.Redirects = saved.Redirects
+		}
+	}
+	return state
+}
+
+// add records that a note moved from one URL path to another
+// Chained moves collapse, so A → B then B → C leaves A → C and B → C, and a note moved back drops its redirect
+func (s *redirectsState) add(from, to string) {
+	for old, target := range s.Redirects {
+		if target == from {
+			s.Redirects[old] = to
+		}
+		if s.Redirects[old] == old {
+			delete(s.Redirects, old)
+		}
+	}
+	delete(s.Redirects, to)
+	s.Redirects[from] = to
+}
+
+// successor returns the URL path a note published at url moved to, if the redirects know it
+func (s *redirectsState) successor(url string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	to, ok := s.Redirects[url]
+	return to, ok
+}
+
+// sorted returns the redirects in the order of their old path
+func (s *redirectsState) sorted() []redirect {
+	list := make([]redirect, 0, len(s.Redirects))
+	for from, to := range s.Redirects {
+		list = append(list, redirect{From: from, To: to})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].From < list[j].From })
+	return list
+}
+
+// sitePrefix returns the URL path of the content folder on the site
+// Quartz serves the files of its content folder from the site root, so a nested --content-dir
+// like content/notes adds its subfolders to the URLs
+func (c *converter) sitePrefix() string {
+	if sub, ok := strings.CutPrefix(path.Clean(filepath.ToSlash(c.opts.contentDir)), "content/"); ok {
+		return quartzSlug(sub)
+	}
+	return ""
+}
+
+// noteURL returns the URL path a note is published under, without leading slash
+func (c *converter) noteURL(relPath string) string {
+	return path.Join(c.sitePrefix(), quartzSlug(filepath.ToSlash(c.destRel(relPath))))
+}
+
+// planRedirects compares the URL of every note the plan publishes with the one recorded by the last run
+// and records a redirect for each note whose URL changed, before anything is written, so --redirects=aliases
+// can add the old URLs to the moved notes
+// Notes not published by this run keep their recorded URL while they are in the vault
+func (c *converter) planRedirects() {
+	if c.redirects == nil {
+		return
+	}
+	key := path.Clean(filepath.ToSlash(c.opts.contentDir))
+	previous := c.redirects.URLs[key]
+	urls := make(map[string]string)
+	for file, url := range previous {
+		if _, err := c.vault.Lstat(filepath.Join(c.obsidianFolder, filepath.FromSlash(file))); err == nil {
+			urls[file] = url
+		}
+	}
+
+	moved := 0
+	for _, f := range c.plan.files {
+		if !hasExt(f.relPath, ".md") || (f.action != planPublish && f.action != planUnchangedGit && f.action != planUnchangedState) {
+			continue
+		}
+		file := filepath.ToSlash(f.relPath)
+		url := c.noteURL(f.relPath)
+		if old, ok := previous[file]; ok && old != url {
+			c.redirects.add(old, url)
+			c.console.progressf("Redirect: /%s → /%s", old, url)
+			moved++
+		}
+		urls[file] = url
+	}
+	c.redirects.URLs[key] = urls
+	if moved > 0 {
+		c.console.infof("%d notes moved since the last run, redirecting their old URL", moved)
+	}
+}
+
+// addRedirectAliases adds the old URL paths of a moved note to its aliases with --redirects=aliases,
+// so Quartz writes a page redirecting each of them to the note
+// Aliases already listed are not added twice
+func (c *converter) addRedirectAliases(src string, content []byte) []byte {
+	if c.redirects == nil || c.opts.redirects != redirectsAliases {
+		return content
+	}
+	relPath, err := filepath.Rel(c.obsidianFolder, src)
+	if err != nil {
+		return content
+	}
+	url := c.noteURL(relPath)
+	var olds []string
+	for _, r := range c.redirects.sorted() {
+		if r.To == url {
+			olds = append(olds, r.From)
+		}
+	}
+	if len(olds) == 0 {
+		return content
+	}
+
+	frontmatter, _, _ := splitFrontmatter(content)
+	lines, keys, ok := frontmatterKeys(frontmatter)
+	if !ok {
+		c.console.warnf("%s: frontmatter is not a block of keys, old URLs not added to its aliases", src)
+		return content
+	}
+	found, aliases := frontmatterListItems(keys, "alias", "aliases")
+	added := false
+	for _, old := range olds {
+		if !slices.Contains(aliases, old) {
+			aliases = append(aliases, old)
+			added = true
+		}
+	}
+	if !added {
+		return content
+	}
+	return writeFrontmatterList(content, lines, found, "aliases", aliases)
+}
+
+// writeRedirects saves the redirects state file, and writes the redirects in the format of --redirects
+func (c *converter) writeRedirects() error {
+	data, err := json.MarshalIndent(redirectsState{Version: redirectsVersion, URLs: c.redirects.URLs, Redirects: c.redirects.Redirects}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the redirects file: %v", err)
+	}
+	if err := writeTextFile(filepath.Join(c.quartzFolder, redirectsStateFileName), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write the redirects file: %v", err)
+	}
+
+	switch c.opts.redirects {
+	case redirectsNetlify:
+		return c.writeNetlifyRedirects()
+	case redirectsJSON:
+		data, err := json.MarshalIndent(c.redirects.sorted(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode redirects.json: %v", err)
+		}
+		if err := writeTextFile(filepath.Join(c.quartzFolder, "redirects.json"), append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write redirects.json: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeNetlifyRedirects writes the redirects as 301 rules of the _redirects file of the Quartz folder
+// The rules are written between marker lines, replacing those of the last run; the other lines of the file are kept
+func (c *converter) writeNetlifyRedirects() error {
+	file := filepath.Join(c.quartzFolder, "_redirects")
+	existing, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read _redirects: %v", err)
+	}
+
+	var block strings.Builder
+	block.WriteString(redirectsBlockStart + "\n")
+	for _, r := range c.redirects.sorted() {
+		fmt.Fprintf(&block, "/%s /%s 301\n", r.From, r.To)
+	}
+	block.WriteString(redirectsBlockEnd + "\n")
+
+	var out strings.Builder
+	inBlock, written := false, false
+	for _, line := range splitLines(existing) {
+		switch strings.TrimSpace(line) {
+		case redirectsBlockStart:
+			inBlock = true
+			continue
+		case redirectsBlockEnd:
+			inBlock = false
+			if !written {
+				out.WriteString(block.String())
+				written = true
+			}
+			continue
+		}
+		if !inBlock {
+			out.WriteString(line)
+		}
+	}
+	if !written {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+		out.WriteString(block.String())
+	}
+	if err := writeTextFile(file, []byte(out.String())); err != nil {
+		return fmt.Errorf("failed to write _redirects: %v", err)
+	}
+	return nil
+}
+
+// writeTextFile writes a file of the Quartz folder atomically
+func writeTextFile(file string, data []byte) error {
+	_, err := writeFileAtomic(file, 0644, func(w io.Writer) (int64, error) {
+		n, err := w.Write(data)
+		return int64(n), err
+	})
+	return err
+}
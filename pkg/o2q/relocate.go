@@ -0,0 +1,187 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// relocationKey is the frontmatter key giving the path a note is published to, relative to the content folder
+// It wins over the permalink of Obsidian Publish, honored with --from-obsidian-publish
+const relocationKey = "quartz-path"
+
+// relocation is the destination a note asks for in its frontmatter
+type relocation struct {
+	key  string // Frontmatter key the destination comes from
+	dest string // Content-relative path, with forward slashes; empty if err is set
+	err  error  // Why the note is not published
+}
+
+// relocationDest checks the value of a quartz-path or permalink and returns the content-relative path of the note
+//   - resume → resume.md
+//   - about/Me.MD → about/Me.md
+//
+// Permalinks are site paths, so a leading slash is dropped rather than refused as an absolute path
+func relocationDest(key, value string) (string, error) {
+	value = filepath.ToSlash(strings.TrimSpace(value))
+	if key == "permalink" {
+		value = strings.TrimPrefix(value, "/")
+	}
+	switch {
+	case strings.HasPrefix(value, "/") || filepath.IsAbs(value) || filepath.VolumeName(value) != "":
+		return "", fmt.Errorf("invalid %s %q: absolute paths are not allowed; not published", key, value)
+	case strings.HasSuffix(value, "/"):
+		return "", fmt.Errorf("invalid %s %q: it names a folder, not a note; not published", key, value)
+	}
+	for _, segment := range strings.Split(value, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("invalid %s %q: .. is not allowed; not published", key, value)
+		}
+	}
+	dest := path.Clean(value)
+	if dest == "." || !filepath.IsLocal(dest) {
+		return "", fmt.Errorf("invalid %s %q: not a path inside the content folder; not published", key, value)
+	}
+	if hasExt(dest, ".md") {
+		dest = strings.TrimSuffix(dest, path.Ext(dest))
+	}
+	return dest + ".md", nil
+}
+
+// findRelocations reads the quartz-path, and with --from-obsidian-publish the permalink, of the published notes
+// Notes with invalid frontmatter are left where they are; the frontmatter error is reported when they are published
+func (c *converter) findRelocations() error {
+	c.relocations = make(map[string]*relocation)
+	return c.walkEligible(func(relPath string) error {
+		if !hasExt(relPath, ".md") || isInExcalidrawFolder(relPath) {
+			return nil
+		}
+		content, err := c.vault.ReadFile(filepath.Join(c.obsidianFolder, relPath))
+		if err != nil {
+			return fmt.Errorf("failed to read markdown file: %v", err)
+		}
+		values, err := parseFrontmatter(content)
+		if err != nil {
+			return nil
+		}
+		key, value := relocationKey, frontmatterString(values, relocationKey)
+		if c.opts.fromObsidianPublish {
+			if published, _ := frontmatterBool(values, "publish"); !published {
+				return nil
+			}
+			if value == "" {
+				key, value = "permalink", frontmatterString(values, "permalink")
+			}
+		}
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		r := &relocation{key: key}
+		if r.dest, r.err = relocationDest(key, value); r.err == nil {
+			r.dest = c.normalizeName(r.dest)
+			if c.opts.sanitizeNames {
+				r.dest = sanitizePath(r.dest, c.opts.sanitizeReplacement)
+			}
+		}
+		c.relocations[filepath.ToSlash(relPath)] = r
+		return nil
+	})
+}
+
+// planRelocations publishes the relocated notes to the path they ask for, so links to them are rewritten
+// Notes claiming the same path, or the path of a note left in place, are not published
+func (c *converter) planRelocations() error {
+	if len(c.relocations) == 0 {
+		return nil
+	}
+
+	// Paths of the notes published where they are
+	owners := make(map[string]string)
+	err := c.walkEligible(func(relPath string) error {
+		file := filepath.ToSlash(relPath)
+		if _, ok := c.relocations[file]; ok || !hasExt(file, ".md") {
+			return nil
+		}
+		dest := filepath.ToSlash(c.destRel(relPath))
+		owners[strings.TrimSuffix(dest, path.Ext(dest))+".md"] = file
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	claims := make(map[string][]string)
+	for file, r := range c.relocations {
+		if r.err == nil {
+			claims[r.dest] = append(claims[r.dest], file)
+		}
+	}
+	dests := make([]string, 0, len(claims))
+	for dest := range claims {
+		dests = append(dests, dest)
+	}
+	sort.Strings(dests)
+
+	if c.renames == nil {
+		c.renames = make(map[string]string)
+	}
+	c.relocatedDests = make(map[string]string)
+	for _, dest := range dests {
+		files := claims[dest]
+		sort.Strings(files)
+		if owner, ok := owners[dest]; ok {
+			for _, file := range files {
+				r := c.relocations[file]
+				r.err = fmt.Errorf("%s %s is where %s is published; not published, change one of them", r.key, dest, owner)
+			}
+			continue
+		}
+		if len(files) > 1 {
+			for _, file := range files {
+				r := c.relocations[file]
+				r.err = fmt.Errorf("%s %s is also claimed by %s; not published, change one of them",
+					r.key, dest, strings.Join(otherFiles(files, file), ", "))
+			}
+			continue
+		}
+		c.renames[files[0]] = dest
+		c.relocatedDests[dest] = files[0]
+		c.console.progressf("Relocated: %s → %s (%s)", files[0], dest, c.relocations[files[0]].key)
+	}
+	if len(c.relocatedDests) > 0 {
+		c.console.infof("Publishing %d notes to the path set in their frontmatter", len(c.relocatedDests))
+	}
+	return nil
+}
+
+// otherFiles returns the files of a list other than file
+func otherFiles(files []string, file string) []string {
+	var others []string
+	for _, f := range files {
+		if f != file {
+			others = append(others, f)
+		}
+	}
+	return others
+}
+
+// relocationErr returns why a note with an invalid or conflicting quartz-path or permalink is not published, or nil
+func (c *converter) relocationErr(relPath string) error {
+	if r, ok := c.relocations[filepath.ToSlash(relPath)]; ok {
+		return r.err
+	}
+	return nil
+}
+
+// relocatedHere checks if a destination belongs to a note relocated there by its frontmatter
+// Deletions of files whose source is gone leave it alone, as it now holds the relocated note
+func (c *converter) relocatedHere(dest string) bool {
+	rel, err := filepath.Rel(c.contentFolder, dest)
+	if err != nil {
+		return false
+	}
+	_, ok := c.relocatedDests[filepath.ToSlash(rel)]
+	return ok
+}
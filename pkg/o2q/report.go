@@ -0,0 +1,368 @@
+package o2q
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Actions recorded for each file in the run report
+const (
+	actionTransformed        = "transformed"         // Markdown file written with transformations
+	actionGenerated          = "generated"           // Markdown page generated from another file
+	actionCopied             = "copied"              // File copied as-is
+	actionSkippedIgnored     = "skipped-ignored"     // Excluded by an ignore pattern
+	actionSkippedExcalidraw  = "skipped-excalidraw"  // Non-SVG file in an Excalidraw folder
+	actionSkippedType        = "skipped-type"        // File type not published in the current mode
+	actionSkippedUnpublished = "skipped-unpublished" // Note not marked publish: true, or marked publish: false or draft: true
+	actionSkippedExisting    = "skipped-existing"    // Destination kept by --no-clobber or --update-only
+	actionSkippedSize        = "skipped-size"        // Note larger than --max-note-size, with --oversize-notes=exclude
+	actionSkippedFilter      = "skipped-filter"      // Note not matching --filter
+	actionSkippedFileSize    = "skipped-file-size"   // File other than a note larger than --max-file-size
+	actionSkippedOverride    = "skipped-override"    // Excluded by an --override rule for this run
+	actionSkippedConflict    = "skipped-conflict"    // Conflict copy or temporary file of a sync service or editor
+	actionSkippedVault       = "skipped-vault"       // Folder holding a vault of its own, with --skip-nested-vaults
+	actionDeleted            = "deleted"             // Published file whose source was deleted or renamed since --since-git or the last --incremental run
+	actionTombstoned         = "tombstoned"          // Published note about to be deleted, replaced with a stub by --tombstones
+	actionError              = "error"               // Processing failed
+)
+
+// runReport collects what happened during a run, for the summary and the JSON report
+type runReport struct {
+	Base               reportBase        `json:"base"`
+	StartedAt          time.Time         `json:"started_at"`
+	ElapsedSeconds     float64           `json:"elapsed_seconds"`
+	Transformed        int               `json:"markdown_transformed"`
+	Generated          int               `json:"pages_generated"`
+	Copied             int               `json:"files_copied"`
+	Hardlinked         int               `json:"files_hardlinked,omitempty"`
+	Reflinked          int               `json:"files_reflinked,omitempty"`
+	LinkMode           string            `json:"link_mode,omitempty"` // --link-mode, unless copy
+	SkippedIgnored     int               `json:"skipped_ignored"`
+	SkippedExcalidraw  int               `json:"skipped_excalidraw"`
+	SkippedType        int               `json:"skipped_type"`
+	SkippedUnpublished int               `json:"skipped_unpublished"`
+	SkippedExisting    int               `json:"skipped_existing"`
+	SkippedSize        int               `json:"skipped_size"`
+	SkippedFilter      int               `json:"skipped_filter"`
+	SkippedFileSize    int               `json:"skipped_file_size"`
+	SkippedLarge       []largeFile       `json:"skipped_large,omitempty"`
+	SkippedOverride    int               `json:"skipped_override"`
+	SkippedConflict    int               `json:"skipped_conflict"`
+	Conflicts          []conflictFile    `json:"conflicts,omitempty"`
+	SkippedVaults      int               `json:"skipped_nested_vaults,omitempty"`
+	IncludedOverride   int               `json:"included_override"`
+	IncludedHidden     int               `json:"included_hidden"`
+	Overrides          []string          `json:"overrides,omitempty"`
+	Renamed            int               `json:"renamed"`
+	Deleted            int               `json:"files_deleted,omitempty"`
+	Tombstoned         int               `json:"notes_tombstoned,omitempty"`
+	UnchangedSinceGit  int               `json:"unchanged_since_git,omitempty"`
+	SkippedUnchanged   int               `json:"skipped_unchanged,omitempty"`
+	TemplateSyntax     []templateFinding `json:"template_syntax,omitempty"`
+	MissingDrawings    []missingDrawing  `json:"missing_drawings,omitempty"`
+	MissingEmbeds      []missingEmbed    `json:"missing_embeds,omitempty"`
+	UnpublishedLinks   []unpublishedLink `json:"unpublished_links,omitempty"`
+	AmbiguousNames     []ambiguousName   `json:"ambiguous_names,omitempty"`
+	UnresolvedLinks    []unresolvedLink  `json:"unresolved_links,omitempty"`
+	Frontmatter        []fmProblem       `json:"frontmatter_problems,omitempty"`
+	DirectoriesCreated int               `json:"directories_created"`
+	Errors             int               `json:"errors"`
+	BytesWritten       int64             `json:"bytes_written"`
+	Files              []reportEntry     `json:"files"`
+}
+
+// reportBase holds the folders the paths of a report are relative to
+type reportBase struct {
+	Source      string `json:"source"`      // Obsidian folder, base of the source paths
+	Destination string `json:"destination"` // Content folder, base of the destination paths
+}
+
+// reportEntry describes what was done with a single file
+type reportEntry struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination,omitempty"`
+	Action      string   `json:"action"`
+	Mode        string   `json:"mode,omitempty"` // hardlink or reflink for a file copied with --link-mode
+	Error       string   `json:"error,omitempty"`
+	Notes       []string `json:"notes,omitempty"`
+}
+
+// newRunReport starts a report for a run beginning now
+func newRunReport() *runReport {
+	return &runReport{StartedAt: time.Now(), Files: []reportEntry{}}
+}
+
+// record adds a file entry to the run report and prints it as progress
+// Paths are made relative to the vault and content folders unless --absolute-paths is set
+func (c *converter) record(entry reportEntry, bytes int64) {
+	c.noteWritten(entry)
+	c.noteDeclined(entry)
+	if c.opts.prune && entry.Destination != "" && entry.Action != actionDeleted {
+		if c.outputs == nil {
+			c.outputs = make(map[string]bool)
+		}
+		c.outputs[entry.Destination] = true
+	}
+	entry.Notes = c.reportNotes[entry.Source]
+	entry.Source = c.paths.source(entry.Source)
+	entry.Destination = c.paths.dest(entry.Destination)
+	entry.Error = c.paths.scrub(entry.Error)
+	c.report.add(entry, bytes)
+	switch entry.Action {
+	case actionTransformed:
+		c.console.progressf("Processed: %s -> %s", entry.Source, entry.Destination)
+	case actionGenerated:
+		c.console.progressf("Generated: %s -> %s", entry.Source, entry.Destination)
+	case actionCopied:
+		if entry.Mode != "" {
+			c.console.progressf("Copied: %s -> %s (%s)", entry.Source, entry.Destination, entry.Mode)
+		} else {
+			c.console.progressf("Copied: %s -> %s", entry.Source, entry.Destination)
+		}
+	case actionSkippedIgnored:
+		c.console.progressf("Skipped: %s (ignore pattern)", entry.Source)
+	case actionSkippedExcalidraw:
+		c.console.progressf("Skipped: %s (not an SVG in an Excalidraw folder)", entry.Source)
+	case actionSkippedType:
+		c.console.progressf("Skipped: %s (file type not published)", entry.Source)
+	case actionSkippedSize:
+		c.console.progressf("Skipped: %s (larger than --max-note-size)", entry.Source)
+	case actionSkippedFilter:
+		c.console.progressf("Skipped: %s (not matching --filter)", entry.Source)
+	case actionSkippedFileSize:
+		c.console.progressf("Skipped: %s (larger than --max-file-size)", entry.Source)
+	case actionSkippedOverride, actionSkippedConflict, actionSkippedUnpublished:
+		c.console.progressf("Skipped: %s (%s)", entry.Source, strings.Join(entry.Notes, ", "))
+	case actionSkippedExisting:
+		c.console.progressf("Kept: %s (destination not overwritten)", entry.Destination)
+	case actionDeleted:
+		c.console.progressf("Deleted: %s (source gone)", entry.Destination)
+	case actionTombstoned:
+		c.console.progressf("Tombstoned: %s (source gone, replaced with a stub)", entry.Destination)
+	}
+}
+
+// addReportNote notes something about a vault file, added to its report entries
+func (c *converter) addReportNote(src, note string) {
+	if c.reportNotes == nil {
+		c.reportNotes = make(map[string][]string)
+	}
+	c.reportNotes[src] = append(c.reportNotes[src], note)
+}
+
+// add records a file entry and the number of bytes written for it
+func (r *runReport) add(entry reportEntry, bytes int64) {
+	switch entry.Action {
+	case actionTransformed:
+		r.Transformed++
+	case actionGenerated:
+		r.Generated++
+	case actionCopied:
+		r.Copied++
+		switch entry.Mode {
+		case linkModeHardlink:
+			r.Hardlinked++
+		case linkModeReflink:
+			r.Reflinked++
+		}
+	case actionSkippedIgnored:
+		r.SkippedIgnored++
+	case actionSkippedExcalidraw:
+		r.SkippedExcalidraw++
+	case actionSkippedType:
+		r.SkippedType++
+	case actionSkippedUnpublished:
+		r.SkippedUnpublished++
+	case actionSkippedExisting:
+		r.SkippedExisting++
+	case actionSkippedSize:
+		r.SkippedSize++
+	case actionSkippedFilter:
+		r.SkippedFilter++
+	case actionSkippedFileSize:
+		r.SkippedFileSize++
+	case actionSkippedOverride:
+		r.SkippedOverride++
+	case actionSkippedConflict:
+		r.SkippedConflict++
+	case actionSkippedVault:
+		r.SkippedVaults++
+	case actionDeleted:
+		r.Deleted++
+	case actionTombstoned:
+		r.Tombstoned++
+	case actionError:
+		r.Errors++
+	}
+	r.BytesWritten += bytes
+	r.Files = append(r.Files, entry)
+}
+
+// effectiveLinkMode describes how the files published as they are reached the content folder
+func (r *runReport) effectiveLinkMode() string {
+	switch linked := r.Hardlinked + r.Reflinked; {
+	case linked == 0:
+		return linkModeCopy
+	case r.Hardlinked == r.Copied:
+		return linkModeHardlink
+	case r.Reflinked == r.Copied:
+		return linkModeReflink
+	case r.Hardlinked > 0 && r.Reflinked > 0:
+		return "hardlink, reflink and copy"
+	case r.Hardlinked > 0:
+		return "hardlink and copy"
+	}
+	return "reflink and copy"
+}
+
+// finish records the elapsed time of the run
+func (r *runReport) finish() {
+	r.ElapsedSeconds = time.Since(r.StartedAt).Seconds()
+}
+
+// print writes the end-of-run summary
+func (r *runReport) print(w io.Writer) {
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  Markdown files transformed:   %d\n", r.Transformed)
+	if r.Generated > 0 {
+		fmt.Fprintf(w, "  Pages generated:              %d\n", r.Generated)
+	}
+	fmt.Fprintf(w, "  Files copied:                 %d\n", r.Copied)
+	if r.LinkMode != "" && r.Copied > 0 {
+		fmt.Fprintf(w, "    hardlinked: %d, reflinked: %d, byte copies: %d\n", r.Hardlinked, r.Reflinked, r.Copied-r.Hardlinked-r.Reflinked)
+		if mode := r.effectiveLinkMode(); mode != r.LinkMode {
+			fmt.Fprintf(w, "    --link-mode %s requested, %s used\n", r.LinkMode, mode)
+		}
+	}
+	fmt.Fprintf(w, "  Skipped by ignore patterns:   %d\n", r.SkippedIgnored)
+	fmt.Fprintf(w, "  Skipped non-SVG Excalidraw:   %d\n", r.SkippedExcalidraw)
+	if r.SkippedType > 0 {
+		fmt.Fprintf(w, "  Skipped by file type:         %d\n", r.SkippedType)
+	}
+	if r.SkippedUnpublished > 0 {
+		fmt.Fprintf(w, "  Skipped as unpublished:       %d\n", r.SkippedUnpublished)
+	}
+	if r.SkippedSize > 0 {
+		fmt.Fprintf(w, "  Skipped as too large:         %d\n", r.SkippedSize)
+	}
+	if r.SkippedFilter > 0 {
+		fmt.Fprintf(w, "  Skipped by --filter:          %d\n", r.SkippedFilter)
+	}
+	if r.SkippedFileSize > 0 {
+		fmt.Fprintf(w, "  Skipped by --max-file-size:   %d\n", r.SkippedFileSize)
+		for _, f := range r.SkippedLarge {
+			fmt.Fprintf(w, "    %s (%s)\n", f.Source, formatSize(f.Size))
+		}
+	}
+	if r.SkippedOverride > 0 {
+		fmt.Fprintf(w, "  Excluded by --override:       %d\n", r.SkippedOverride)
+	}
+	if r.SkippedConflict > 0 {
+		fmt.Fprintf(w, "  Conflict and temporary files: %d\n", r.SkippedConflict)
+		for _, f := range r.Conflicts {
+			fmt.Fprintf(w, "    %s (%s)\n", f.Source, f.Kind)
+		}
+	}
+	if r.SkippedVaults > 0 {
+		fmt.Fprintf(w, "  Skipped nested vaults:        %d\n", r.SkippedVaults)
+	}
+	if r.IncludedOverride > 0 {
+		fmt.Fprintf(w, "  Included by --override:       %d\n", r.IncludedOverride)
+	}
+	if r.IncludedHidden > 0 {
+		fmt.Fprintf(w, "  Included by --include-hidden: %d\n", r.IncludedHidden)
+	}
+	if r.SkippedExisting > 0 {
+		fmt.Fprintf(w, "  Writes suppressed:            %d\n", r.SkippedExisting)
+	}
+	if r.Renamed > 0 {
+		fmt.Fprintf(w, "  Files renamed:                %d\n", r.Renamed)
+	}
+	if r.UnchangedSinceGit > 0 {
+		fmt.Fprintf(w, "  Unchanged since --since-git:  %d\n", r.UnchangedSinceGit)
+	}
+	if r.SkippedUnchanged > 0 {
+		fmt.Fprintf(w, "  Unchanged since last run:     %d\n", r.SkippedUnchanged)
+	}
+	if r.Deleted > 0 {
+		fmt.Fprintf(w, "  Deleted as source is gone:    %d\n", r.Deleted)
+	}
+	if r.Tombstoned > 0 {
+		fmt.Fprintf(w, "  Replaced with a tombstone:    %d\n", r.Tombstoned)
+	}
+	if len(r.TemplateSyntax) > 0 {
+		fmt.Fprintf(w, "  Template syntax left:         %d\n", len(r.TemplateSyntax))
+		for _, f := range r.TemplateSyntax {
+			fmt.Fprintf(w, "    %s:%d: %s\n", f.Source, f.Line, f.Text)
+		}
+	}
+	if len(r.MissingDrawings) > 0 {
+		fmt.Fprintf(w, "  Drawings without SVG export:  %d\n", len(r.MissingDrawings))
+		for _, d := range r.MissingDrawings {
+			fmt.Fprintf(w, "    %s: %s\n", d.Source, d.Drawing)
+		}
+	}
+	if len(r.MissingEmbeds) > 0 {
+		fmt.Fprintf(w, "  Embeds of unpublished notes:  %d\n", len(r.MissingEmbeds))
+		for _, e := range r.MissingEmbeds {
+			fmt.Fprintf(w, "    %s: %s\n", e.Source, e.Target)
+		}
+	}
+	if len(r.UnpublishedLinks) > 0 {
+		fmt.Fprintf(w, "  Links to unpublished notes:   %d\n", len(r.UnpublishedLinks))
+		for _, l := range r.UnpublishedLinks {
+			fmt.Fprintf(w, "    %s:%d: %s\n", l.Source, l.Line, l.Target)
+		}
+	}
+	if len(r.Frontmatter) > 0 {
+		fmt.Fprintf(w, "  Frontmatter problems:         %d\n", len(r.Frontmatter))
+		for _, p := range r.Frontmatter {
+			level := "warning"
+			if p.Error {
+				level = "error"
+			}
+			if p.Line > 0 {
+				fmt.Fprintf(w, "    %s:%d: %s: %s\n", p.Source, p.Line, level, p.Message)
+			} else {
+				fmt.Fprintf(w, "    %s: %s: %s\n", p.Source, level, p.Message)
+			}
+		}
+	}
+	if len(r.AmbiguousNames) > 0 {
+		fmt.Fprintf(w, "  Shared note names:            %d\n", len(r.AmbiguousNames))
+		for _, a := range r.AmbiguousNames {
+			fmt.Fprintf(w, "    %s: %d notes, %d linking to it by name only\n", a.Name, len(a.Notes), len(a.Linked))
+		}
+	}
+	if len(r.UnresolvedLinks) > 0 {
+		fmt.Fprintf(w, "  Links matching no note:       %d\n", len(r.UnresolvedLinks))
+		for _, l := range r.UnresolvedLinks {
+			fmt.Fprintf(w, "    %s: %s\n", l.Source, l.Target)
+		}
+	}
+	fmt.Fprintf(w, "  Directories created:          %d\n", r.DirectoriesCreated)
+	fmt.Fprintf(w, "  Errors:                       %d\n", r.Errors)
+	fmt.Fprintf(w, "  Bytes written:                %d\n", r.BytesWritten)
+	fmt.Fprintf(w, "  Elapsed:                      %s\n", time.Duration(r.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond))
+}
+
+// writeJSON writes the report as JSON to path
+func (r *runReport) writeJSON(path string) error {
+	return writeJSONFile(path, r)
+}
+
+// writeJSONFile writes a report as indented JSON to path
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+	return nil
+}
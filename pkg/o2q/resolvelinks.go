@@ -0,0 +1,150 @@
+package o2q
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// unresolvedLink is a wikilink of a published note matching no file of the vault, listed in the summary with --resolve-links
+type unresolvedLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"` // Link target as written
+}
+
+// noteIndex holds the notes the plan publishes, by noteKey of their vault path and of their name, so a link is
+// resolved without going through every note
+type noteIndex struct {
+	byPath map[string]string
+	byName map[string][]string // Sorted by vault path
+}
+
+// publishedNoteIndex returns the index of the notes the plan publishes, with forward slashes, built on first use
+func (c *converter) publishedNoteIndex() *noteIndex {
+	if c.publishedNotes == nil {
+		c.publishedNotes = &noteIndex{byPath: make(map[string]string), byName: make(map[string][]string)}
+		for _, notes := range c.plan.notesByName {
+			for _, note := range notes {
+				// Notes marked publish: false or draft: true pass the walk, but are left out
+				if !c.unpublishedNotes[note] {
+					c.publishedNotes.byPath[noteKey(note)] = note
+					name := noteKey(path.Base(note))
+					c.publishedNotes.byName[name] = append(c.publishedNotes.byName[name], note)
+				}
+			}
+		}
+		for _, notes := range c.publishedNotes.byName {
+			sort.Strings(notes)
+		}
+	}
+	return c.publishedNotes
+}
+
+// resolveNote finds the published note a wikilink target points to, as Obsidian does, with the number of notes it matches
+// A target with a folder is looked up relative to the note, from the vault root, then as the end of a path;
+// a name alone picks the note of that name in the folder of the linking note, or else the one with the shortest path
+func (c *converter) resolveNote(noteDir, target string) (string, int) {
+	target = filepath.ToSlash(target)
+	if !hasExt(target, ".md") {
+		target += ".md"
+	}
+	index := c.publishedNoteIndex()
+
+	if strings.Contains(target, "/") {
+		for _, candidate := range []string{path.Join(noteDir, target), path.Clean(target)} {
+			if note, ok := index.byPath[noteKey(candidate)]; ok {
+				return note, 1
+			}
+		}
+	}
+
+	suffix := "/" + noteKey(path.Clean(target))
+	var found []string
+	for _, note := range index.byName[noteKey(path.Base(target))] {
+		if strings.HasSuffix("/"+noteKey(note), suffix) {
+			found = append(found, note)
+		}
+	}
+	if len(found) == 0 {
+		return "", 0
+	}
+	for _, note := range found {
+		if path.Dir(note) == noteDir {
+			return note, len(found)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		di, dj := strings.Count(found[i], "/"), strings.Count(found[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return len(found[i]) < len(found[j])
+	})
+	return found[0], len(found)
+}
+
+// resolveLinks points wikilinks to notes at the vault path of the note, with --resolve-links, so Quartz finds the
+// note Obsidian shows whatever its link resolution setting:
+//   - [[Roadmap]] → [[Projects/Roadmap|Roadmap]]
+//   - [[Roadmap#Goals]] → [[Projects/Roadmap#Goals|Roadmap > Goals]]
+//   - ![[Roadmap#Goals]] → ![[Projects/Roadmap#Goals]]
+//
+// Links to a name several notes share are resolved to the note Obsidian picks, with a warning, and links matching
+// no file are reported; links to unpublished notes are left to --unpublished-links, and code is left untouched
+func (c *converter) resolveLinks(src string, content []byte) []byte {
+	if !c.opts.resolveLinks || c.plan == nil {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	return mapOutsideCode(content, func(text string) string {
+		return noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			embed, target, fragment, alias := parts[1], parts[2], parts[3], parts[4]
+			if strings.TrimSpace(target) == "" || !isNoteTarget(target) {
+				return match
+			}
+			// Links to drawings and canvases are rewritten by their own steps
+			if _, ok := drawingTarget(target); ok || hasExt(target, ".canvas") {
+				return match
+			}
+			note, matches := c.resolveNote(noteDir, target)
+			switch {
+			case matches == 0:
+				c.reportUnresolved(src, noteDir, target)
+				return match
+			case matches > 1:
+				c.console.warnf("%s: [[%s]] matches %d notes, resolved to %s as Obsidian does", src, target, matches, note)
+			}
+
+			resolved := note
+			if !hasExt(target, ".md") {
+				resolved = strings.TrimSuffix(note, path.Ext(note))
+			}
+			if resolved == filepath.ToSlash(target) {
+				return match
+			}
+			// Links keep showing the text Obsidian shows for them, with the heading or block they point to
+			if alias == "" && embed == "" {
+				alias = target + strings.ReplaceAll(fragment, "#", " > ")
+			}
+			if alias == "" {
+				return embed + "[[" + resolved + fragment + "]]"
+			}
+			return embed + "[[" + resolved + fragment + "|" + alias + "]]"
+		})
+	})
+}
+
+// reportUnresolved lists a wikilink matching no published note, unless it points to an unpublished note or another file
+func (c *converter) reportUnresolved(src, noteDir, target string) {
+	if _, ok := c.unpublishedTarget(noteDir, target); ok {
+		return
+	}
+	if len(c.plan.assetsByName[strings.ToLower(path.Base(filepath.ToSlash(target)))]) > 0 {
+		return
+	}
+	c.report.UnresolvedLinks = append(c.report.UnresolvedLinks, unresolvedLink{Source: c.paths.source(src), Target: target})
+	c.console.warnf("%s: [[%s]] matches no note of the vault", src, target)
+}
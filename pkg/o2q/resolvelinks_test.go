@@ -0,0 +1,61 @@
+package o2q
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// resolvingConverter returns a converter with --resolve-links planning to publish these notes of a vault at /vault
+func resolvingConverter(notes ...string) *converter {
+	c := &converter{console: console.fork(), obsidianFolder: "/vault", opts: options{resolveLinks: true}, plan: newFilePlan()}
+	c.paths = newPathDisplay(c.obsidianFolder, "/quartz/content", false)
+	for _, note := range notes {
+		c.plan.add(plannedFile{relPath: filepath.FromSlash(note), action: planPublish})
+	}
+	return c
+}
+
+func TestResolveNote(t *testing.T) {
+	c := resolvingConverter("Projects/Roadmap.md", "Archive/2023/Roadmap.md", "Notes/Ideas.md", "Ideas.md", "Work/Team/Meeting.md")
+	tests := []struct {
+		noteDir, target string
+		want            string
+		matches         int
+	}{
+		{".", "Roadmap", "Projects/Roadmap.md", 2},
+		{"Archive/2023", "Roadmap", "Archive/2023/Roadmap.md", 2},
+		{"Notes", "ideas", "Notes/Ideas.md", 2},
+		{"Work", "Ideas", "Ideas.md", 2},
+		{"Work", "Team/Meeting", "Work/Team/Meeting.md", 1},
+		{".", "Team/Meeting.md", "Work/Team/Meeting.md", 1},
+		{".", "2023/Roadmap", "Archive/2023/Roadmap.md", 1},
+		{".", "Missing", "", 0},
+	}
+	for _, tt := range tests {
+		got, matches := c.resolveNote(tt.noteDir, tt.target)
+		if got != tt.want || matches != tt.matches {
+			t.Errorf("resolveNote(%q, %q) = %q, %d, want %q, %d", tt.noteDir, tt.target, got, matches, tt.want, tt.matches)
+		}
+	}
+}
+
+func TestResolveLinks(t *testing.T) {
+	c := resolvingConverter("Projects/Roadmap.md", "Home.md")
+	tests := []struct {
+		content, want string
+	}{
+		{"[[Roadmap]]", "[[Projects/Roadmap|Roadmap]]"},
+		{"[[Roadmap#Goals]]", "[[Projects/Roadmap#Goals|Roadmap > Goals]]"},
+		{"[[Roadmap#Goals#2024]]", "[[Projects/Roadmap#Goals#2024|Roadmap > Goals > 2024]]"},
+		{"[[Roadmap#^decision]]", "[[Projects/Roadmap#^decision|Roadmap > ^decision]]"},
+		{"[[Roadmap#Goals|goals]]", "[[Projects/Roadmap#Goals|goals]]"},
+		{"![[Roadmap#Goals]]", "![[Projects/Roadmap#Goals]]"},
+		{"[[Home]]", "[[Home]]"},
+		{"`[[Roadmap]]`", "`[[Roadmap]]`"},
+	}
+	for _, tt := range tests {
+		if got := string(c.resolveLinks("/vault/Home.md", []byte(tt.content))); got != tt.want {
+			t.Errorf("resolveLinks(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// unsafeNameChars are the characters Windows refuses in file names; some web hosts mangle them too
+const unsafeNameChars = `:?"<>|*\`
+
+// windowsReserved are the device names Windows refuses as file names, whatever their extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeSegment makes a file or folder name safe on Windows and web hosts
+//   - What? Why: Notes.md → What- Why- Notes.md
+//   - Draft. (folder) → Draft
+//   - CON.md → CON-.md
+func sanitizeSegment(name, replacement string) string {
+	if name == "." || name == ".." {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(unsafeNameChars, r) {
+			b.WriteString(replacement)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	name = b.String()
+
+	// Windows drops trailing dots and spaces, so Draft. and Draft would be the same folder
+	if trimmed := strings.TrimRight(name, ". "); trimmed != name {
+		name = trimmed
+		if name == "" {
+			name = replacement
+		}
+	}
+
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(base)] {
+		suffix := replacement
+		if suffix == "" {
+			suffix = "_"
+		}
+		name = base + suffix + name[len(base):]
+	}
+	return name
+}
+
+// sanitizePath sanitizes every segment of a slash-separated path
+func sanitizePath(p, replacement string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = sanitizeSegment(segment, replacement)
+	}
+	return strings.Join(segments, "/")
+}
+
+// planRenames finds the vault files whose names are unsafe on Windows or web hosts
+// With --sanitize-names they are given a safe destination, numbered when two of them would collide;
+// without it they are listed in a warning
+func (c *converter) planRenames() error {
+	var files []string
+	err := c.walkEligible(func(relPath string) error {
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	// Files published under their own name keep it; renamed files must not take it
+	taken := make(map[string]bool)
+	var unsafe []string
+	for _, file := range files {
+		name := filepath.ToSlash(c.mapPath(c.normalizeName(file)))
+		if sanitizePath(name, c.opts.sanitizeReplacement) == name {
+			taken[name] = true
+		} else {
+			unsafe = append(unsafe, file)
+		}
+	}
+	if len(unsafe) == 0 {
+		return nil
+	}
+
+	if !c.opts.sanitizeNames {
+		c.console.warnf("%d file names may break when the site is cloned on Windows or deployed to some hosts, rename them or use --sanitize-names:", len(unsafe))
+		for _, file := range unsafe {
+			c.console.detailf("%s", file)
+		}
+		return nil
+	}
+
+	c.renames = make(map[string]string)
+	for _, file := range unsafe {
+		dest := sanitizePath(filepath.ToSlash(c.mapPath(c.normalizeName(file))), c.opts.sanitizeReplacement)
+		ext := path.Ext(dest)
+		base := strings.TrimSuffix(dest, ext)
+		for n := 2; taken[dest]; n++ {
+			dest = fmt.Sprintf("%s %d%s", base, n, ext)
+		}
+		taken[dest] = true
+		c.renames[file] = dest
+	}
+	return nil
+}
+
+// destRel returns the path a vault file or folder is published to, relative to the content folder
+func (c *converter) destRel(relPath string) string {
+	if renamed, ok := c.renames[filepath.ToSlash(relPath)]; ok {
+		return filepath.FromSlash(renamed)
+	}
+	name := c.mapPath(c.normalizeName(relPath))
+	if c.opts.sanitizeNames {
+		name = filepath.FromSlash(sanitizePath(filepath.ToSlash(name), c.opts.sanitizeReplacement))
+	}
+	return name
+}
+
+// rewriteRenamedLinks points links to files renamed by --sanitize-names or moved by --attachments-to or --map at their new path
+//   - [[What? Why]] → [[What- Why|What? Why]]
+//   - ![chart](Data%3F/chart.png) → ![chart](Data-/chart.png)
+//
+// Code blocks and inline code are left untouched
+func (c *converter) rewriteRenamedLinks(src string, content []byte) []byte {
+	if len(c.renames) == 0 {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	destDir := filepath.ToSlash(c.destRel(noteDir))
+	moved := c.movedFolder(noteDir)
+	// A relocated note, or one moved by --date-folders, links from the folder of its new path
+	if relPath, err := filepath.Rel(c.obsidianFolder, src); err == nil && c.relocationErr(relPath) == nil {
+		if r, ok := c.relocations[filepath.ToSlash(relPath)]; ok {
+			destDir, moved = path.Dir(r.dest), true
+		} else if c.dated[filepath.ToSlash(relPath)] {
+			destDir, moved = path.Dir(c.renames[filepath.ToSlash(relPath)]), true
+		}
+	}
+	renamed := func(target string, wiki bool) (string, bool) {
+		if target == "" {
+			return "", false
+		}
+		if resolved, ok := c.resolveLink(noteDir, target); ok {
+			if dest, ok := c.renames[resolved]; ok {
+				return dest, true
+			}
+			// Relative links from a note of a mapped folder to files left in place must climb out of its new folder
+			if moved && !wiki {
+				return filepath.ToSlash(c.destRel(resolved)), true
+			}
+			return "", false
+		}
+		if wiki && path.Ext(target) == "" {
+			if resolved, ok := c.resolveLink(noteDir, target+".md"); ok {
+				if dest, ok := c.renames[resolved]; ok {
+					return strings.TrimSuffix(dest, path.Ext(dest)), true
+				}
+			}
+		}
+		return "", false
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			target, fragment, alias := parts[2], parts[3], parts[4]
+			dest, ok := renamed(target, true)
+			if !ok {
+				return match
+			}
+			// Embeds keep showing the file, links keep showing its original name
+			if alias == "" && parts[1] == "" {
+				alias = target
+			}
+			if alias == "" {
+				return parts[1] + "[[" + dest + fragment + "]]"
+			}
+			return parts[1] + "[[" + dest + fragment + "|" + alias + "]]"
+		})
+
+		return markdownTargetRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownTargetRe.FindStringSubmatch(match)
+			link := fileLink{target: strings.Trim(parts[2], "<>"), angle: strings.HasPrefix(parts[2], "<")}
+			if isExternalURL(link.target) {
+				return match
+			}
+			target, fragment, _ := strings.Cut(link.decodedTarget(), "#")
+			dest, ok := renamed(target, false)
+			if !ok {
+				return match
+			}
+			if fragment != "" {
+				fragment = "#" + fragment
+			}
+			return parts[1] + relativeURL(destDir, dest) + fragment
+		})
+	})
+}
+
+// reportRenames lists the files --sanitize-names published under another name
+func (c *converter) reportRenames() {
+	if len(c.renames) == 0 {
+		return
+	}
+	// Moved attachments, mapped folders, dated notes, relocated notes and pinned assets are announced when they are planned
+	var files []string
+	for file := range c.renames {
+		if !c.attachments[file] && !c.mapped[file] && !c.dated[file] && c.relocations[file] == nil && !c.pinned[file] {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+	c.report.Renamed = len(files)
+	c.console.infof("%d files were renamed for Windows and web hosting:", len(files))
+	for _, file := range files {
+		c.console.infof("  %s → %s", file, c.renames[file])
+	}
+}
@@ -0,0 +1,129 @@
+package o2q
+
+import (
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// scheduler runs a sync on an interval until it receives SIGINT or SIGTERM
+// A cycle is skipped if the previous sync is still running, and a failed sync does not stop the schedule
+type scheduler struct {
+	console  *consoleLogger
+	every    time.Duration
+	jitter   time.Duration
+	sync     func() bool // Performs one sync, returns false if it failed
+	slot     time.Time   // Start of the current interval, before jitter
+	next     time.Time   // Time of the next scheduled sync
+	failures int         // Consecutive failed syncs
+	skipped  int         // Cycles skipped because the previous sync was still running
+}
+
+// newScheduler creates a scheduler running sync every interval, delayed by up to jitter
+func newScheduler(log *consoleLogger, every, jitter time.Duration, sync func() bool) *scheduler {
+	return &scheduler{console: log, every: every, jitter: jitter, sync: sync}
+}
+
+// plan computes the time of the sync of the next interval
+func (s *scheduler) plan() {
+	now := time.Now()
+	s.slot = s.slot.Add(s.every)
+	for !s.slot.After(now) {
+		s.slot = s.slot.Add(s.every)
+	}
+	s.next = s.slot
+	if s.jitter > 0 {
+		s.next = s.next.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+}
+
+// run syncs immediately, then on every interval until stopped
+func (s *scheduler) run() {
+	// Notify relays all signals when given none
+	extra := make(chan os.Signal, 1)
+	if len(extraSyncSignals) > 0 {
+		signal.Notify(extra, extraSyncSignals...)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	var done chan bool // Receives the result of the running sync, nil when idle
+	start := func() {
+		done = make(chan bool, 1)
+		go func() {
+			done <- s.sync()
+		}()
+	}
+
+	s.slot = time.Now()
+	s.plan()
+	timer := time.NewTimer(time.Until(s.next))
+	start()
+
+	pending, stopping := false, false
+	for {
+		select {
+		case ok := <-done:
+			done = nil
+			s.finished(ok)
+			if stopping {
+				return
+			}
+			if pending {
+				pending = false
+				start()
+				continue
+			}
+			s.console.infof("Next sync at %s", s.next.Format(time.DateTime))
+
+		case <-timer.C:
+			if done != nil {
+				s.skipped++
+				s.console.warnf("previous sync still running, skipped the sync of %s (%d skipped so far)",
+					s.next.Format(time.DateTime), s.skipped)
+			} else {
+				start()
+			}
+			s.plan()
+			timer.Reset(time.Until(s.next))
+
+		case <-extra:
+			if done != nil {
+				s.console.infof("Extra sync requested, it will start when the current sync finishes")
+				pending = true
+			} else {
+				s.console.infof("Extra sync requested")
+				start()
+			}
+
+		case sig := <-stop:
+			if done == nil {
+				s.console.infof("Received %v, stopping", sig)
+				return
+			}
+			// A second signal stops the process right away
+			if stopping {
+				s.console.infof("Received %v again, stopping now", sig)
+				releaseHeldLocks(s.console)
+				os.Exit(exitFailure)
+			}
+			s.console.infof("Received %v, stopping after the current sync", sig)
+			stopping = true
+		}
+	}
+}
+
+// finished keeps count of consecutive failures after a sync
+func (s *scheduler) finished(ok bool) {
+	if !ok {
+		s.failures++
+		s.console.errorf("sync failed (consecutive failures: %d), retrying at the next interval", s.failures)
+		return
+	}
+	if s.failures > 0 {
+		s.console.infof("Sync succeeded after %d failed syncs", s.failures)
+	}
+	s.failures = 0
+}
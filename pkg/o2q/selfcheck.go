@@ -0,0 +1,283 @@
+package o2q
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// selfCheck records how the plan of a run was carried out, for --self-check
+type selfCheck struct {
+	applied  map[string]int      // Times each file the plan publishes was published, by vault-relative path
+	written  map[string][]string // Files written for each of them, by vault-relative path
+	declined map[string]bool     // Files of the vault left unwritten with a reason in the report, such as --no-clobber
+}
+
+// newSelfCheck returns an empty record of a run
+func newSelfCheck() *selfCheck {
+	return &selfCheck{applied: make(map[string]int), written: make(map[string][]string), declined: make(map[string]bool)}
+}
+
+// noteDeclined records that a file was not written for the reason of its report entry
+func (c *converter) noteDeclined(entry reportEntry) {
+	if c.selfCheck == nil || entry.Source == "" {
+		return
+	}
+	switch entry.Action {
+	case actionTransformed, actionGenerated, actionCopied, actionDeleted:
+	default:
+		c.selfCheck.declined[entry.Source] = true
+	}
+}
+
+// noteApplied records that a file the plan publishes was published, and the files written for it
+func (c *converter) noteApplied(f plannedFile) {
+	if c.selfCheck == nil {
+		return
+	}
+	key := filepath.ToSlash(f.relPath)
+	c.selfCheck.applied[key]++
+	c.selfCheck.written[key] = append(c.selfCheck.written[key], c.written[f.src]...)
+}
+
+// verifyRun checks a finished run against its plan and the content folder, and returns what does not match:
+//   - every file the plan publishes was published once, to the path a --dry-run lists for it
+//   - every file written or kept by the run is in the content folder
+//   - the state file of --incremental matches the files published, so a new run would leave them as they are
+//   - --prune left no file published from no vault file
+//
+// The run is then planned and carried out again by replan, which must change nothing
+func (c *converter) verifyRun() []string {
+	var problems []string
+	for _, f := range c.plan.files {
+		key := filepath.ToSlash(f.relPath)
+		n := c.selfCheck.applied[key]
+		if f.action != planPublish {
+			if n > 0 {
+				problems = append(problems, key+": published while the plan has it "+f.action)
+			}
+			continue
+		}
+		if n != 1 {
+			problems = append(problems, fmt.Sprintf("%s: published %d times instead of once", key, n))
+			continue
+		}
+		written := c.selfCheck.written[key]
+		verb, dest := c.plannedWrite(f)
+		_, split := c.splitNotes[key]
+		switch {
+		case verb == "skip" || split:
+		case len(written) == 0 && !c.selfCheck.declined[f.src]:
+			problems = append(problems, key+": nothing written while a dry run lists "+c.contentPath(dest))
+		case len(written) > 0 && !slices.Contains(written, dest):
+			problems = append(problems, key+": written to "+strings.Join(c.contentPaths(written), ", ")+" while a dry run lists "+c.contentPath(dest))
+		}
+	}
+
+	var outputs []string
+	for file := range c.outputs {
+		outputs = append(outputs, file)
+	}
+	for _, written := range c.selfCheck.written {
+		outputs = append(outputs, written...)
+	}
+	sort.Strings(outputs)
+	for _, file := range slices.Compact(outputs) {
+		if _, err := os.Lstat(file); err != nil {
+			problems = append(problems, c.contentPath(file)+": published by the run but not in the content folder")
+		}
+	}
+
+	if c.state != nil {
+		keys := make([]string, 0, len(c.state.next))
+		for key := range c.state.next {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			// Files left as they are keep the hashes of the run that published them, even if edited since
+			if c.selfCheck.applied[key] == 0 {
+				continue
+			}
+			entry := c.state.next[key]
+			if hash, err := c.hashSource(filepath.Join(c.obsidianFolder, filepath.FromSlash(key))); err != nil || hash != entry.Hash {
+				problems = append(problems, key+": the state file does not record the source as published")
+			}
+			for _, out := range entry.Outputs {
+				if hash, err := hashFile(filepath.Join(c.contentFolder, filepath.FromSlash(out.Path))); err != nil || hash != out.Hash {
+					problems = append(problems, key+": the state file does not match "+out.Path+" in the content folder")
+				}
+			}
+		}
+	}
+
+	if c.opts.prune {
+		err := c.walkPrunable(func(p, rel string) error {
+			if !c.overrideKept[rel] {
+				problems = append(problems, rel+": published from no vault file, but left by --prune")
+			}
+			return nil
+		})
+		if err != nil {
+			problems = append(problems, "walking through the content folder: "+err.Error())
+		}
+	}
+	return problems
+}
+
+// replan runs the conversion again, with the same vault and options, on a copy of the content folder and of the
+// files the tool keeps in the Quartz folder, and returns what the second run changed: files written differently,
+// added or deleted. A run the plan carried out leaves nothing for the next one to do
+// --hook-file runs again on the files of the copy, as it changes what is published, while --hook-post, --write-ref
+// and the report are left out of the second run, which writes nothing outside the copy
+func (c *converter) replan(opts options, cfg config, source vaultSource, command string) []string {
+	scratch, err := os.MkdirTemp("", "obsidian-to-quartz-self-check-")
+	if err != nil {
+		return []string{"copying the content folder: " + err.Error()}
+	}
+	defer os.RemoveAll(scratch)
+	rel, err := filepath.Rel(c.quartzFolder, c.contentFolder)
+	if err != nil {
+		return []string{"copying the content folder: " + err.Error()}
+	}
+	folders := map[string]string{c.contentFolder: filepath.Join(scratch, rel)}
+	if opts.html == htmlStatic || opts.html == htmlIframe {
+		folders[filepath.Join(c.quartzFolder, "quartz", "static")] = filepath.Join(scratch, "quartz", "static")
+	}
+	for from, to := range folders {
+		if err := copyTree(from, to); err != nil {
+			return []string{"copying the content folder: " + err.Error()}
+		}
+	}
+	// The markers of the Quartz folder let --clean empty the copy
+	names := append([]string{}, quartzMarkers...)
+	for name := range toolFileNames {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if err := copyTree(filepath.Join(c.quartzFolder, name), filepath.Join(scratch, name)); err != nil {
+			return []string{"copying the content folder: " + err.Error()}
+		}
+	}
+
+	opts.selfCheck, opts.hookPost, opts.writeRef, opts.reportJSON = false, "", "", ""
+	opts.migrateState, opts.resetState = false, false
+	log := &consoleLogger{verbosity: verbosityQuiet, out: io.Discard, err: io.Discard}
+	if code := runConversion(log, opts, cfg, source, scratch, command, nil); code == exitFailure || code == exitRefused {
+		return []string{fmt.Sprintf("a second run failed with exit code %d", code)}
+	}
+
+	var problems []string
+	for from, to := range folders {
+		before, err := hashTree(from)
+		if err == nil {
+			var after map[string]string
+			if after, err = hashTree(to); err == nil {
+				problems = append(problems, treeChanges(before, after)...)
+			}
+		}
+		if err != nil {
+			problems = append(problems, "comparing with a second run: "+err.Error())
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// copyTree copies a file, or a folder with everything in it; a missing one is not copied
+func copyTree(from, to string) error {
+	return filepath.WalkDir(from, func(p string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == from {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(to, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, data, 0644)
+	})
+}
+
+// hashTree returns the SHA-256 of every file of a folder, but the files of the tool, by relative path with forward slashes
+func hashTree(root string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == root {
+			return nil
+		} else if err != nil || d.IsDir() || isToolFile(p) {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(rel)], err = hashFile(p)
+		return err
+	})
+	return hashes, err
+}
+
+// treeChanges lists the files a second run changed, from the hashes of the folder before and after it
+func treeChanges(before, after map[string]string) []string {
+	var changes []string
+	for file, hash := range after {
+		switch was, ok := before[file]; {
+		case !ok:
+			changes = append(changes, file+": added by a second run")
+		case was != hash:
+			changes = append(changes, file+": written differently by a second run")
+		}
+	}
+	for file := range before {
+		if _, ok := after[file]; !ok {
+			changes = append(changes, file+": deleted by a second run")
+		}
+	}
+	return changes
+}
+
+// reportSelfCheck prints what --self-check found, with what a bug report needs
+func (c *converter) reportSelfCheck(problems []string) {
+	c.console.errorf("--self-check: the run does not match its plan, please report this bug with the lines below")
+	c.console.errorf("  version: %s (%s/%s)", buildVersion(), runtime.GOOS, runtime.GOARCH)
+	c.console.errorf("  arguments: %s", c.paths.scrub(strings.Join(os.Args[1:], " ")))
+	for _, problem := range problems {
+		c.console.errorf("  %s", problem)
+	}
+}
+
+// contentPath returns a path of the content folder relative to it, with forward slashes
+func (c *converter) contentPath(file string) string {
+	if rel, err := filepath.Rel(c.contentFolder, file); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+// contentPaths returns paths of the content folder relative to it
+func (c *converter) contentPaths(files []string) []string {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = c.contentPath(file)
+	}
+	return paths
+}
@@ -0,0 +1,110 @@
+package o2q
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fixtureVault is the fixture vault of the repository, described in testdata/README.md
+const fixtureVault = "../../testdata/vault"
+
+// TestSelfCheckFixture publishes the fixture vault with options changing what the plan does; runTestSync runs
+// with --self-check, so any run that does not match its plan, or a dry run of it, fails
+func TestSelfCheckFixture(t *testing.T) {
+	runs := map[string][][]string{
+		"defaults":    {{}},
+		"incremental": {{"-incremental"}, {"-incremental"}},
+		"prune":       {{"-prune"}, {"-prune", "-exclude", "Projects/Sub"}},
+		"renames":     {{"-sanitize-names", "-attachments-to", "media", "-map", "Projects=>work"}},
+		"generated":   {{"-render-drawings", "-generate-indexes", "-emit-tag-pages", "tags"}},
+		"moved":       {{"-incremental", "-prune", "-redirects", "json"}, {"-incremental", "-prune", "-redirects", "json", "-map", "Projects=>work"}},
+	}
+	for name, sequence := range runs {
+		t.Run(name, func(t *testing.T) {
+			quartz := t.TempDir()
+			for _, args := range sequence {
+				if code := runTestSync(t, fixtureVault, quartz, args...); code != exitSuccess && code != exitNothingToDo {
+					t.Fatalf("run with %q exited with %d", args, code)
+				}
+			}
+		})
+	}
+}
+
+func TestSelfCheckFileHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook needs a POSIX shell")
+	}
+	// A hook rewriting the published file, as an image optimizer does, leaves the state file matching it
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n"})
+	quartz := t.TempDir()
+	hook := `sh -c "echo hooked >> {dest}"`
+	if code := runTestSync(t, vault, quartz, "-incremental", "-hook-file", hook); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	if note := readContent(t, quartz, "Note.md"); !strings.Contains(note, "hooked") {
+		t.Fatalf("hook did not run: %q", note)
+	}
+}
+
+func TestVerifyRun(t *testing.T) {
+	vault := writeVault(t, map[string]string{"A.md": "A\n", "B.md": "B\n", "C.md": "C\n", "D.md": "D\n"})
+	content := t.TempDir()
+	c := &converter{console: console.fork(), obsidianFolder: vault, contentFolder: content, plan: newFilePlan(), selfCheck: newSelfCheck()}
+	c.selfCheck.applied = map[string]int{"A.md": 1, "B.md": 2, "C.md": 1, "D.md": 1}
+	c.selfCheck.written["A.md"] = []string{filepath.Join(content, "Other.md")}
+	// D.md was kept, as --no-clobber does, which its report entry tells
+	c.selfCheck.declined[filepath.Join(vault, "D.md")] = true
+	for _, name := range []string{"A.md", "B.md", "C.md", "D.md"} {
+		info, err := os.Stat(filepath.Join(vault, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.plan.add(plannedFile{src: filepath.Join(vault, name), relPath: name, destRel: name, dest: filepath.Join(content, name), info: info, action: planPublish})
+	}
+
+	want := []string{
+		"A.md: written to Other.md while a dry run lists A.md",
+		"B.md: published 2 times instead of once",
+		"C.md: nothing written while a dry run lists C.md",
+		"Other.md: published by the run but not in the content folder",
+	}
+	if got := c.verifyRun(); !slices.Equal(got, want) {
+		t.Errorf("verifyRun() = %q, want %q", got, want)
+	}
+}
+
+func TestSelfCheckNonIdempotentStep(t *testing.T) {
+	// A step publishing a note differently on every run, as one stamping the time would, is caught by the second run
+	runs := 0
+	steps := bodySteps
+	bodySteps = append(append([]transformStep{}, bodySteps...), transformStep{"count-runs", func(c *converter, src string, content []byte) []byte {
+		runs++
+		return append(content, fmt.Sprintf("Run %d\n", runs)...)
+	}})
+	t.Cleanup(func() {
+		bodySteps = steps
+	})
+
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n"})
+	opts := testOptions(t, "-self-check")
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	var messages bytes.Buffer
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: &messages}
+	if code := runSources(log, opts, config{}, sources, t.TempDir(), ""); code != exitFailure {
+		t.Errorf("run exited with %d, want %d", code, exitFailure)
+	}
+	if !strings.Contains(messages.String(), "Note.md: written differently by a second run") {
+		t.Errorf("messages do not report the second run:\n%s", messages.String())
+	}
+}
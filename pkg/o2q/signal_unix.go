@@ -0,0 +1,11 @@
+//go:build !windows
+
+package o2q
+
+import (
+	"os"
+	"syscall"
+)
+
+// extraSyncSignals trigger an immediate sync in --every mode
+var extraSyncSignals = []os.Signal{syscall.SIGUSR1}
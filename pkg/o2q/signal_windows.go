@@ -0,0 +1,6 @@
+package o2q
+
+import "os"
+
+// extraSyncSignals trigger an immediate sync in --every mode; Windows has no SIGUSR1
+var extraSyncSignals = []os.Signal{}
@@ -0,0 +1,140 @@
+package o2q
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// siteURLRe matches markdown links with an absolute target, autolinks and bare URLs
+// Markdown links come first so a URL inside a link is not also matched as a bare URL
+var siteURLRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\((https?://[^)\s]+)\)|<(https?://[^\s>]+)>|(https?://[^\s<>()\[\]]+)`)
+
+// parseSiteBaseURL validates the --site-base-url value
+func parseSiteBaseURL(value string) (*url.URL, error) {
+	base, err := url.Parse(value)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid site base URL %q: must be an absolute http(s) URL", value)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	return base, nil
+}
+
+// quartzSlug returns the URL path Quartz publishes a vault file under
+// Notes lose their .md extension and folder index notes are served at the folder path
+func quartzSlug(relPath string) string {
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments {
+		segment = strings.Join(strings.Fields(segment), "-")
+		segment = strings.ReplaceAll(segment, "&", "-and-")
+		segment = strings.ReplaceAll(segment, "%", "-percent")
+		segment = strings.ReplaceAll(segment, "?", "")
+		segment = strings.ReplaceAll(segment, "#", "")
+		segments[i] = segment
+	}
+	slug := strings.Join(segments, "/")
+	if strings.EqualFold(path.Ext(slug), ".md") {
+		slug = strings.TrimSuffix(slug, path.Ext(slug))
+	}
+	if slug == "index" {
+		return ""
+	}
+	return strings.TrimSuffix(slug, "/index")
+}
+
+// indexSiteSlugs maps the lowercased URL path of every published file on the site to its vault path
+// The path is that of the destination, once --map, --sanitize-names, --date-folders and the other renames
+// are planned, or the quartz-path or permalink of a relocated note
+func (c *converter) indexSiteSlugs() {
+	c.siteSlugs = make(map[string]string)
+	for _, file := range c.vaultFiles {
+		url := c.noteURL(filepath.FromSlash(file))
+		if r, ok := c.relocations[file]; ok && r.err == nil {
+			url = path.Join(c.sitePrefix(), quartzSlug(r.dest))
+		}
+		c.siteSlugs[strings.ToLower(url)] = file
+	}
+}
+
+// rewriteSiteURLs turns absolute links to the published site into wikilinks to the notes they point at
+//   - [text](https://notes.example.com/projects/roadmap#goals) → [[Projects/Roadmap#goals|text]]
+//   - https://notes.example.com/projects/roadmap → [[Projects/Roadmap]]
+//
+// Code blocks and inline code are left untouched
+func (c *converter) rewriteSiteURLs(src string, content []byte) []byte {
+	if c.siteBaseURL == nil {
+		return content
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		return siteURLRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := siteURLRe.FindStringSubmatch(match)
+			embed, label, target := parts[1] != "", parts[2], parts[3]
+			trailing := ""
+			switch {
+			case parts[4] != "":
+				target = parts[4]
+			case parts[5] != "":
+				// Punctuation ending a sentence is not part of a bare URL
+				target = strings.TrimRight(parts[5], ".,;:!?'\"")
+				trailing = parts[5][len(target):]
+			}
+
+			file, fragment, internal := c.resolveSiteURL(target)
+			if !internal {
+				return match
+			}
+			if file == "" {
+				c.console.warnf("%s: %s does not match any published note", src, target)
+				return match
+			}
+
+			link := fileLink{embed: embed, wiki: true, target: file, fragment: fragment, text: label}
+			if strings.EqualFold(path.Ext(file), ".md") {
+				link.target = strings.TrimSuffix(file, path.Ext(file))
+			}
+			return link.String() + trailing
+		})
+	})
+}
+
+// resolveSiteURL checks if a URL points into the published site and finds the vault file it refers to
+// internal is false for URLs outside the site; file is empty when no published file matches
+func (c *converter) resolveSiteURL(target string) (file, fragment string, internal bool) {
+	u, err := url.Parse(target)
+	if err != nil || !strings.EqualFold(u.Host, c.siteBaseURL.Host) {
+		return "", "", false
+	}
+	rest := u.Path
+	if rest != c.siteBaseURL.Path && !strings.HasPrefix(rest, c.siteBaseURL.Path+"/") {
+		return "", "", false
+	}
+	rest = strings.Trim(strings.TrimPrefix(rest, c.siteBaseURL.Path), "/")
+
+	if u.Fragment != "" {
+		fragment = "#" + u.Fragment
+	}
+
+	// Quartz serves pages both with and without a trailing .html
+	// Decoded paths like "Road Map" are slugged the same way as file names
+	rest = strings.TrimSuffix(rest, ".html")
+	slug := quartzSlug(rest)
+	if file, ok := c.siteSlugs[strings.ToLower(slug)]; ok {
+		return file, fragment, true
+	}
+
+	// A URL pasted before its note moved leads to it through the URLs --redirects recorded
+	if c.redirects != nil {
+		for from, to := range c.redirects.Redirects {
+			if strings.EqualFold(from, slug) {
+				if file, ok := c.siteSlugs[strings.ToLower(to)]; ok {
+					return file, fragment, true
+				}
+			}
+		}
+	}
+	return "", fragment, true
+}
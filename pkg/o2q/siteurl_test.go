@@ -0,0 +1,111 @@
+package o2q
+
+import "testing"
+
+func TestQuartzSlug(t *testing.T) {
+	tests := map[string]string{
+		"Projects/Roadmap.md":      "Projects/Roadmap",
+		"Projects/Road Map.MD":     "Projects/Road-Map",
+		"R&D/Q&A.md":               "R-and-D/Q-and-A",
+		"Stats/100%.md":            "Stats/100-percent",
+		"What? Why#.md":            "What-Why",
+		"index.md":                 "",
+		"Projects/index.md":        "Projects",
+		"assets/chart.png":         "assets/chart.png",
+		"Notes/  spaced   out.md":  "Notes/spaced-out",
+		"Projects/indexing.md":     "Projects/indexing",
+		"Projects/index/Notes.md":  "Projects/index/Notes",
+		"Diagrams/Flow.excalidraw": "Diagrams/Flow.excalidraw",
+	}
+	for relPath, want := range tests {
+		if got := quartzSlug(relPath); got != want {
+			t.Errorf("quartzSlug(%q) = %q, want %q", relPath, got, want)
+		}
+	}
+}
+
+func TestParseSiteBaseURL(t *testing.T) {
+	for value, want := range map[string]string{
+		"https://notes.example.com":   "https://notes.example.com",
+		"https://example.com/garden/": "https://example.com/garden",
+		"http://localhost:8080/notes": "http://localhost:8080/notes",
+		"notes.example.com":           "",
+		"ftp://notes.example.com":     "",
+		"https://":                    "",
+	} {
+		base, err := parseSiteBaseURL(value)
+		switch {
+		case want == "" && err == nil:
+			t.Errorf("parseSiteBaseURL(%q) = %s, want an error", value, base)
+		case want != "" && err != nil:
+			t.Errorf("parseSiteBaseURL(%q) error = %v", value, err)
+		case want != "" && base.String() != want:
+			t.Errorf("parseSiteBaseURL(%q) = %s, want %s", value, base, want)
+		}
+	}
+}
+
+func TestSiteBaseURL(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Projects/Road Map.md": "Road map\n",
+		"Projects/index.md":    "Projects\n",
+		"assets/chart.png":     "png",
+		"Home.md": "[the plan](https://notes.example.com/garden/projects/road-map#goals)\n" +
+			"See https://notes.example.com/garden/Projects/Road-Map.html.\n" +
+			"<https://notes.example.com/garden/projects>\n" +
+			"![chart](https://notes.example.com/garden/assets/chart.png)\n" +
+			"[gone](https://notes.example.com/garden/old-page)\n" +
+			"[elsewhere](https://notes.example.com/blog/post) and https://example.org/garden/projects\n" +
+			"`https://notes.example.com/garden/projects`\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-site-base-url", "https://notes.example.com/garden/"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	want := "[[Projects/Road Map#goals|the plan]]\n" +
+		"See [[Projects/Road Map]].\n" +
+		"[[Projects/index]]\n" +
+		"![[assets/chart.png|chart]]\n" +
+		"[gone](https://notes.example.com/garden/old-page)\n" +
+		"[elsewhere](https://notes.example.com/blog/post) and https://example.org/garden/projects\n" +
+		"`https://notes.example.com/garden/projects`\n"
+	if got := readContent(t, quartz, "Home.md"); got != want {
+		t.Errorf("Home.md =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSiteBaseURLMapped(t *testing.T) {
+	// Quartz serves the notes of a mapped folder under its new name, which the pasted URL holds
+	vault := writeVault(t, map[string]string{
+		"03 - Projects/Roadmap.md": "Road map\n",
+		"Home.md":                  "[the plan](https://notes.example.com/projects/roadmap) and https://notes.example.com/03-Projects/Roadmap\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-site-base-url", "https://notes.example.com", "-map", "03 - Projects=>projects"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	// The path of the vault folder is not a URL of the site
+	want := "[[projects/Roadmap|the plan]] and https://notes.example.com/03-Projects/Roadmap\n"
+	if got := readContent(t, quartz, "Home.md"); got != want {
+		t.Errorf("Home.md = %q, want %q", got, want)
+	}
+}
+
+func TestSiteBaseURLRedirected(t *testing.T) {
+	// A URL pasted before its note moved follows the redirects recorded since
+	vault := writeVault(t, map[string]string{
+		"Projects/Roadmap.md": "Road map\n",
+		"Home.md":             "[the plan](https://notes.example.com/Projects/Roadmap#goals)\n",
+	})
+	quartz := t.TempDir()
+	args := []string{"-site-base-url", "https://notes.example.com", "-redirects", "json"}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+	if code := runTestSync(t, vault, quartz, append(args, "-map", "Projects=>work")...); code != exitSuccess {
+		t.Fatalf("run with the folder mapped exited with %d", code)
+	}
+	if got, want := readContent(t, quartz, "Home.md"), "[[work/Roadmap#goals|the plan]]\n"; got != want {
+		t.Errorf("Home.md = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// snippets are the contents of --prepend-file and --append-file, added to every published note
+type snippets struct {
+	prepend string
+	append  string
+}
+
+// readSnippets reads the snippet files, without their trailing line breaks
+func readSnippets(prependFile, appendFile string) (snippets, error) {
+	var s snippets
+	for _, f := range []struct {
+		name, file string
+		text       *string
+	}{{"--prepend-file", prependFile, &s.prepend}, {"--append-file", appendFile, &s.append}} {
+		if f.file == "" {
+			continue
+		}
+		data, err := os.ReadFile(f.file)
+		if err != nil {
+			return s, fmt.Errorf("failed to read %s: %v", f.name, err)
+		}
+		*f.text = strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	}
+	return s, nil
+}
+
+// addSnippets inserts the snippets into a note: the prepended one after the frontmatter, and the appended one
+// at the end, each separated from the note by a blank line
+// {{title}}, {{source_path}} and {{date}} in a snippet are replaced with the title of the note, its path in the vault
+// and the date of the run; notes matching --no-snippet get neither
+// Snippets are added to the content read from the vault, so a run over an already synced content folder adds them once
+func (c *converter) addSnippets(src string, content []byte) []byte {
+	if c.snippets.prepend == "" && c.snippets.append == "" {
+		return content
+	}
+	relPath, err := filepath.Rel(c.obsidianFolder, src)
+	if err != nil {
+		return content
+	}
+	relPath = filepath.ToSlash(relPath)
+	for _, pattern := range c.opts.noSnippet {
+		if globMatch(pattern, relPath) {
+			return content
+		}
+	}
+
+	_, body, _ := splitFrontmatter(content)
+	head := string(content[:len(content)-len(body)])
+	values, _ := parseFrontmatter(content)
+	title := frontmatterString(values, "title")
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(relPath), path.Ext(relPath))
+		if _, heading, ok := firstH1(splitLines(body)); ok {
+			title = heading
+		}
+	}
+	expand := strings.NewReplacer("{{title}}", title, "{{source_path}}", relPath, "{{date}}", c.report.StartedAt.Format("2006-01-02"))
+
+	text := string(body)
+	if c.snippets.prepend != "" {
+		text = expand.Replace(c.snippets.prepend) + "\n\n" + text
+	}
+	if c.snippets.append != "" {
+		if text = strings.TrimRight(text, "\n"); text != "" {
+			text += "\n\n"
+		}
+		text += expand.Replace(c.snippets.append) + "\n"
+	}
+	return []byte(head + text)
+}
@@ -0,0 +1,48 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnippets(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"index.md":         "Home\n",
+		"Notes/Titled.md":  "---\ntitle: The Plan\n---\nBody\n",
+		"Notes/Heading.md": "# Roadmap\n\nBody\n\n\n",
+		"Notes/Empty.md":   "",
+		"assets/chart.svg": "<svg/>",
+	})
+	snippets := t.TempDir()
+	prepend := filepath.Join(snippets, "banner.md")
+	appendFile := filepath.Join(snippets, "footer.md")
+	if err := os.WriteFile(prepend, []byte("> Draft: {{title}}\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(appendFile, []byte("_From {{source_path}}, synced on {{date}}_\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	quartz := t.TempDir()
+	args := []string{"-prepend-file", prepend, "-append-file", appendFile, "-no-snippet", "index.md"}
+	for run := 1; run <= 2; run++ {
+		if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+			t.Fatalf("run %d exited with %d", run, code)
+		}
+		// The date is the one of the run; the snippets are added once, whatever the content folder holds
+		date := time.Now().Format("2006-01-02")
+		for file, want := range map[string]string{
+			"index.md":         "Home\n",
+			"Notes/Titled.md":  "---\ntitle: The Plan\n---\n> Draft: The Plan\n\nBody\n\n_From Notes/Titled.md, synced on " + date + "_\n",
+			"Notes/Heading.md": "> Draft: Roadmap\n\n# Roadmap\n\nBody\n\n_From Notes/Heading.md, synced on " + date + "_\n",
+			"Notes/Empty.md":   "> Draft: Empty\n\n_From Notes/Empty.md, synced on " + date + "_\n",
+			"assets/chart.svg": "<svg/>",
+		} {
+			if got := readContent(t, quartz, file); got != want {
+				t.Errorf("run %d: %s = %q, want %q", run, file, got, want)
+			}
+		}
+	}
+}
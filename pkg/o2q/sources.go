@@ -0,0 +1,124 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// vaultSource is a vault, or a folder of one, published to a subfolder of the content folder
+type vaultSource struct {
+	folder  string        // Obsidian folder
+	sub     string        // Subfolder of the content folder, with forward slashes; "." for the content folder itself
+	listing *vaultListing // Folders read by the last syncs of --watch, nil otherwise
+}
+
+// parseSourceSpec reads a --source value of the form "folder:subfolder"
+// Without a subfolder the source is published to the content folder itself;
+// a Windows drive letter such as C:\Vault is not taken for a subfolder
+func parseSourceSpec(spec string) (vaultSource, error) {
+	folder, sub := spec, "."
+	if i := strings.LastIndex(spec, ":"); i >= 0 && !(i == 1 && isDriveLetter(spec[0])) {
+		folder, sub = spec[:i], spec[i+1:]
+	}
+	if folder == "" {
+		return vaultSource{}, fmt.Errorf("%q has no folder before the colon", spec)
+	}
+	sub = path.Clean(filepath.ToSlash(strings.TrimSpace(sub)))
+	if !filepath.IsLocal(sub) {
+		return vaultSource{}, fmt.Errorf("%q: the subfolder must be a relative path inside the content folder", spec)
+	}
+	return vaultSource{folder: folder, sub: sub}, nil
+}
+
+// isDriveLetter checks if a byte is a letter, as in the C: of a Windows path
+func isDriveLetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// parseSources reads the --source values and checks they can be published together
+// pruning names the option deleting the generated pages of the subfolder of a source, if any
+func parseSources(specs []string, clean bool, pruning string) ([]vaultSource, error) {
+	var sources []vaultSource
+	for _, spec := range specs {
+		source, err := parseSourceSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(source.folder); isVaultArchive(source.folder) && (err != nil || info.IsDir()) {
+			return nil, fmt.Errorf("%q: %s is not a zip archive", spec, source.folder)
+		} else if !isVaultArchive(source.folder) && (err != nil || !info.IsDir()) {
+			return nil, fmt.Errorf("%q: %s is not a folder", spec, source.folder)
+		}
+		sources = append(sources, source)
+	}
+
+	// Cleaning or pruning the subfolder of a source would delete what another source published into it
+	for i, a := range sources {
+		for _, b := range sources[i+1:] {
+			if a.sub != b.sub && !isInside(a.sub, b.sub) && !isInside(b.sub, a.sub) {
+				continue
+			}
+			switch {
+			case clean && a.sub != b.sub:
+				return nil, fmt.Errorf("--clean cannot be used when a source is published inside the subfolder of another: %s and %s", a.sub, b.sub)
+			case pruning != "":
+				return nil, fmt.Errorf("%s needs every source in its own subfolder: %s and %s overlap", pruning, a.sub, b.sub)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// isInside checks if a slash-separated folder is inside another; every folder is inside "."
+func isInside(folder, parent string) bool {
+	return parent == "." && folder != "." || strings.HasPrefix(folder, parent+"/")
+}
+
+// sourceRun holds what the conversions of several sources share
+type sourceRun struct {
+	destOwners map[string]string // Source file published to each destination, to catch collisions between sources
+	reports    []*runReport
+	cleaned    map[string]bool // Subfolders already cleaned by --clean
+}
+
+// multiReport is the JSON report of a run publishing several sources, holding a report per source
+type multiReport struct {
+	Sources []*runReport `json:"sources"`
+}
+
+// runSources converts every source in turn, each into its subfolder of the content folder
+// Returns the exit code of the run, the most severe of those of its sources
+func runSources(log *consoleLogger, opts options, cfg config, sources []vaultSource, quartzFolder, command string) int {
+	if len(sources) == 1 {
+		return withPostHook(log, opts, quartzFolder, runConversion(log, opts, cfg, sources[0], quartzFolder, command, nil))
+	}
+
+	run := &sourceRun{destOwners: make(map[string]string), cleaned: make(map[string]bool)}
+	code := exitNothingToDo
+	for _, source := range sources {
+		log.infof("Source %s → %s", source.folder, source.sub)
+		code = worseExit(code, runConversion(log, opts, cfg, source, quartzFolder, command, run))
+	}
+
+	if opts.reportJSON != "" && command != "check" && !opts.dryRun {
+		if err := writeJSONFile(opts.reportJSON, multiReport{Sources: run.reports}); err != nil {
+			log.errorf("%v", err)
+			return exitFailure
+		}
+	}
+	return withPostHook(log, opts, quartzFolder, code)
+}
+
+// withPostHook runs --hook-post after a run that succeeded, or had nothing to do, returning the exit code of the run
+func withPostHook(log *consoleLogger, opts options, quartzFolder string, code int) int {
+	if (code != exitSuccess && code != exitNothingToDo) || opts.dryRun {
+		return code
+	}
+	if !runPostHook(log, opts, quartzFolder) {
+		return exitFailure
+	}
+	return code
+}
@@ -0,0 +1,337 @@
+package o2q
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Handling of notes larger than --max-note-size
+const (
+	oversizeWarn    = "warn"    // Publish the note and warn about it
+	oversizeExclude = "exclude" // Do not publish the note
+	oversizeSplit   = "split"   // Publish the note as a folder of parts and an index page
+)
+
+var (
+	// atxHeadingRe matches a heading line, capturing its level and text
+	atxHeadingRe = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*\r?\n?$`)
+	// footnoteDefRe matches the first line of a footnote definition, capturing its label
+	footnoteDefRe = regexp.MustCompile(`^\[\^([^\]\s]+)\]:`)
+	// footnoteRefRe matches a footnote reference
+	footnoteRefRe = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	// noteWikiLinkRe matches wikilinks to notes and headings: [[Note]], [[Note#Heading|alias]], [[#Heading]], ![[Note]]
+	noteWikiLinkRe = regexp.MustCompile(`(!?)\[\[([^\]|#]*)(#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+	// noteMarkdownLinkRe matches markdown links to notes: [text](Note.md#heading)
+	noteMarkdownLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s#]*\.(?i:md))(#[^)\s]*)?\)`)
+)
+
+// splitNote is an oversized note published as a folder holding an index page and a page per top-level section
+//
+//	Big Note.md → Big Note/index.md, Big Note/01 Introduction.md, Big Note/02 Results.md, ...
+type splitNote struct {
+	folder      string // Vault-relative path of the folder of parts: the note path without extension
+	frontmatter string // Frontmatter block with its delimiters, kept on the index page only
+	intro       string // Text before the first top-level heading, kept on the index page
+	parts       []notePart
+}
+
+// notePart is a section of a split note, starting at a top-level heading
+type notePart struct {
+	name     string   // File name without extension, such as "03 Results"
+	title    string   // Text of the heading starting the part
+	content  string   // Section text, followed by the footnote definitions it uses
+	headings []string // IDs of the headings of the part, to send heading links to it
+}
+
+// splitMarkdown splits a note at its top-level headings, the highest level of heading it uses
+// Footnote definitions are copied to every part using them; returns nil if the note has no heading
+func splitMarkdown(content []byte) *splitNote {
+	_, body, _ := splitFrontmatter(content)
+	note := &splitNote{frontmatter: string(content[:len(content)-len(body)])}
+	lines := splitLines(body)
+
+	// Find the top level, and set aside footnote definitions
+	level := 7
+	footnotes := make(map[string]string)
+	var text []string // Lines outside footnote definitions, with "" for lines inside code
+	var headings []int
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			text = append(text, line)
+			headings = append(headings, 0)
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			fence = marker
+			text = append(text, line)
+			headings = append(headings, 0)
+			continue
+		}
+		if m := footnoteDefRe.FindStringSubmatch(line); m != nil {
+			definition := line
+			for i+1 < len(lines) && (strings.HasPrefix(lines[i+1], "    ") || strings.HasPrefix(lines[i+1], "\t")) {
+				i++
+				definition += lines[i]
+			}
+			footnotes[m[1]] = definition
+			continue
+		}
+		h := 0
+		if m := atxHeadingRe.FindStringSubmatch(line); m != nil {
+			h = len(m[1])
+			level = min(level, h)
+		}
+		text = append(text, line)
+		headings = append(headings, h)
+	}
+	if level == 7 {
+		return nil
+	}
+
+	var intro strings.Builder
+	for i, line := range text {
+		if headings[i] == level {
+			title := atxHeadingRe.FindStringSubmatch(line)[2]
+			note.parts = append(note.parts, notePart{title: title})
+		}
+		if len(note.parts) == 0 {
+			intro.WriteString(line)
+			continue
+		}
+		part := &note.parts[len(note.parts)-1]
+		part.content += line
+		if headings[i] > 0 {
+			part.headings = append(part.headings, headingID(atxHeadingRe.FindStringSubmatch(line)[2]))
+		}
+	}
+	note.intro = withFootnotes(intro.String(), footnotes)
+
+	width := max(2, len(fmt.Sprint(len(note.parts))))
+	for i := range note.parts {
+		part := &note.parts[i]
+		part.name = fmt.Sprintf("%0*d %s", width, i+1, partName(part.title))
+		part.content = withFootnotes(part.content, footnotes)
+	}
+	return note
+}
+
+// withFootnotes appends the definitions of the footnotes referenced in text
+func withFootnotes(text string, footnotes map[string]string) string {
+	text = strings.TrimRight(text, "\r\n") + "\n"
+	seen := make(map[string]bool)
+	var definitions []string
+	for _, m := range footnoteRefRe.FindAllStringSubmatch(text, -1) {
+		if definition, ok := footnotes[m[1]]; ok && !seen[m[1]] {
+			seen[m[1]] = true
+			definitions = append(definitions, strings.TrimRight(definition, "\r\n")+"\n")
+		}
+	}
+	if len(definitions) == 0 {
+		return text
+	}
+	return text + "\n" + strings.Join(definitions, "")
+}
+
+// partName turns a heading into a file name, without the characters Obsidian and Quartz cannot use in names
+func partName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/:*?"<>|#^[]`, r) {
+			return -1
+		}
+		return r
+	}, title)
+	name = strings.Join(strings.Fields(name), " ")
+	if runes := []rune(name); len(runes) > 60 {
+		name = strings.TrimSpace(string(runes[:60]))
+	}
+	if name == "" {
+		return "Part"
+	}
+	return name
+}
+
+// index returns the markdown of the index page: the frontmatter, the introduction and a list of the parts in order
+func (n *splitNote) index() []byte {
+	var b strings.Builder
+	b.WriteString(n.frontmatter)
+	if intro := strings.TrimSpace(n.intro); intro != "" {
+		b.WriteString(intro + "\n\n")
+	}
+	b.WriteString("## Parts\n\n")
+	for i, part := range n.parts {
+		fmt.Fprintf(&b, "%d. [[%s|%s]]\n", i+1, path.Join(n.folder, part.name), part.title)
+	}
+	return []byte(b.String())
+}
+
+// page returns the markdown of a part, with a line linking back to the index page
+func (n *splitNote) page(i int) []byte {
+	return []byte(fmt.Sprintf("%s\n*Part %d of %d of [[%s|%s]]*\n",
+		n.parts[i].content, i+1, len(n.parts), path.Join(n.folder, "index"), path.Base(n.folder)))
+}
+
+// target returns the vault-relative path, without extension, of the page a link fragment lands on
+// Heading and block links go to the part holding the heading or block, other links to the index page
+func (n *splitNote) target(fragment string) string {
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+	if block, ok := strings.CutPrefix(fragment, "#^"); ok {
+		for _, part := range n.parts {
+			if strings.Contains(part.content, " ^"+block) {
+				return path.Join(n.folder, part.name)
+			}
+		}
+	} else if fragment != "" {
+		// Obsidian links to nested headings as #Heading#Subheading
+		segments := strings.Split(fragment, "#")
+		id := headingID(segments[len(segments)-1])
+		for _, part := range n.parts {
+			for _, heading := range part.headings {
+				if heading == id {
+					return path.Join(n.folder, part.name)
+				}
+			}
+		}
+	}
+	return path.Join(n.folder, "index")
+}
+
+// planSplits finds the notes larger than --max-note-size and works out their parts before any note is written,
+// so links to them can be rewritten
+func (c *converter) planSplits() error {
+	c.splitNotes = make(map[string]*splitNote)
+	return c.walkEligible(func(relPath string) error {
+		if !hasExt(relPath, ".md") {
+			return nil
+		}
+		src := filepath.Join(c.obsidianFolder, relPath)
+		info, err := c.vault.Stat(src)
+		if err != nil || info.Size() <= c.opts.maxNoteSize || c.tooLargeToTransform(info.Size()) {
+			return nil
+		}
+		// Binary files named .md are copied as they are
+		if binary, err := c.looksBinary(src); err != nil || binary {
+			return nil
+		}
+		content, err := c.vault.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read markdown file: %v", err)
+		}
+		note := splitMarkdown(content)
+		if note == nil {
+			c.console.warnf("%s: larger than --max-note-size but has no heading to split it at, published whole", src)
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		note.folder = strings.TrimSuffix(relPath, path.Ext(relPath))
+		c.splitNotes[relPath] = note
+		return nil
+	})
+}
+
+// checkNoteSize warns about a note larger than --max-note-size, or reports if it must be excluded
+func (c *converter) checkNoteSize(src string) (exclude bool, err error) {
+	if c.opts.maxNoteSize == 0 {
+		return false, nil
+	}
+	info, err := c.vault.Stat(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat markdown file: %v", err)
+	}
+	if info.Size() <= c.opts.maxNoteSize {
+		return false, nil
+	}
+
+	switch c.opts.oversizeNotes {
+	case oversizeExclude:
+		c.console.warnf("%s: %s of markdown, larger than --max-note-size %s; not published",
+			src, formatSize(info.Size()), formatSize(c.opts.maxNoteSize))
+		c.record(reportEntry{Source: src, Action: actionSkippedSize}, 0)
+		return true, nil
+	case oversizeWarn:
+		c.console.warnf("%s: %s of markdown, larger than --max-note-size %s; Quartz may be slow or run out of memory on it",
+			src, formatSize(info.Size()), formatSize(c.opts.maxNoteSize))
+	}
+	return false, nil
+}
+
+// writeSplitNote publishes a split note as its index page and a page per part
+func (c *converter) writeSplitNote(src, dest string, content []byte, note *splitNote) error {
+	folder := strings.TrimSuffix(dest, filepath.Ext(dest))
+	indexDest := filepath.Join(folder, "index.md")
+	c.console.infof("Split: %s into %d parts", src, len(note.parts))
+
+	c.collectTags(src, indexDest, content)
+	for i, part := range note.parts {
+		partDest := filepath.Join(folder, part.name+".md")
+		if err := c.writeMarkdownFile(src, partDest, c.transformMarkdown(src, note.page(i)), actionGenerated); err != nil {
+			return err
+		}
+	}
+	return c.writeMarkdownFile(src, indexDest, c.transformMarkdown(src, note.index()), actionTransformed)
+}
+
+// rewriteSplitLinks points links to split notes at their index page, or at the part holding the linked heading
+//   - [[Big Note]] → [[Big Note/index|Big Note]]
+//   - [[Big Note#Results]] → [[Big Note/03 Results#Results|Big Note]]
+//   - [[#Results]] in Big Note itself → [[Big Note/03 Results#Results|Results]]
+//
+// Code blocks and inline code are left untouched
+func (c *converter) rewriteSplitLinks(src string, content []byte) []byte {
+	if len(c.splitNotes) == 0 {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	self := path.Join(noteDir, filepath.Base(src))
+	find := func(target string, wiki bool) *splitNote {
+		if target == "" {
+			return c.splitNotes[self]
+		}
+		if wiki && !hasExt(target, ".md") {
+			target += ".md"
+		}
+		if resolved, ok := c.resolveLink(noteDir, target); ok {
+			return c.splitNotes[resolved]
+		}
+		return nil
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			target, fragment, alias := parts[2], parts[3], parts[4]
+			note := find(target, true)
+			if note == nil {
+				return match
+			}
+			if alias == "" {
+				alias = path.Base(target)
+				if target == "" {
+					alias = strings.TrimPrefix(fragment, "#")
+				}
+			}
+			return parts[1] + "[[" + note.target(fragment) + fragment + "|" + alias + "]]"
+		})
+
+		return noteMarkdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteMarkdownLinkRe.FindStringSubmatch(match)
+			link := fileLink{target: parts[3]}
+			note := find(link.decodedTarget(), false)
+			if note == nil {
+				return match
+			}
+			return parts[1] + "[" + parts[2] + "](" + relativeURL(noteDir, note.target(parts[4])+".md") + parts[4] + ")"
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitMarkdown(t *testing.T) {
+	if note := splitMarkdown([]byte("---\ntitle: Flat\n---\nNo heading, #tag\n")); note != nil {
+		t.Errorf("splitMarkdown() of a note without heading = %+v, want nil", note)
+	}
+
+	// The top level is the highest level used, and headings in code do not count
+	note := splitMarkdown([]byte("Intro\n\n## One\n\n```\n# Not a heading\n```\n\n### Detail\n\n## Two: the end?\n"))
+	if note == nil {
+		t.Fatal("splitMarkdown() = nil")
+	}
+	var names []string
+	for _, part := range note.parts {
+		names = append(names, part.name)
+	}
+	if got := strings.Join(names, ", "); got != "01 One, 02 Two the end" {
+		t.Errorf("parts = %s, want 01 One, 02 Two the end", got)
+	}
+	if note.intro != "Intro\n" {
+		t.Errorf("intro = %q, want %q", note.intro, "Intro\n")
+	}
+	if got := strings.Join(note.parts[0].headings, ","); got != "one,detail" {
+		t.Errorf("headings of part 1 = %s, want one,detail", got)
+	}
+}
+
+func TestPartName(t *testing.T) {
+	tests := map[string]string{
+		"Results":                   "Results",
+		"Q&A: what/why?":            "Q&A whatwhy",
+		"[[Linked]] #tag":           "Linked tag",
+		"   ":                       "Part",
+		strings.Repeat("word ", 20): strings.TrimSpace(strings.Repeat("word ", 12)),
+	}
+	for title, want := range tests {
+		if got := partName(title); got != want {
+			t.Errorf("partName(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestOversizeSplit(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Big.md": "---\ntitle: Big\n---\nIntro[^a]\n\n" +
+			"# Introduction\n\nText[^b]\n\n" +
+			"# Methods\n\n## Setup\n\nSetup\n\n" +
+			"# Results\n\n## Data Flow\n\nResults[^b] ^key\n\n" +
+			"# Appendix\n\nMore\n\n" +
+			"[^a]: Note a\n[^b]: Note b\n    continued\n",
+		"Home.md": "[[Big]], [[Big#Data Flow]], [[Big#^key|the key]], [m](Big.md#methods)\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-max-note-size", "120", "-oversize-notes", "split"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+
+	// Links go to the index, or to the part holding the heading or block
+	wantHome := "[[Big/index|Big]], [[Big/03 Results#data-flow|Big]], [[Big/03 Results#^key|the key]], [m](Big/02%20Methods.md#methods)\n"
+	if got := readContent(t, quartz, "Home.md"); got != wantHome {
+		t.Errorf("Home.md = %q, want %q", got, wantHome)
+	}
+
+	// The frontmatter stays on the index page, and each part keeps the footnotes it uses
+	wantIndex := "---\ntitle: Big\n---\nIntro[^a]\n\n[^a]: Note a\n\n## Parts\n\n" +
+		"1. [[Big/01 Introduction|Introduction]]\n2. [[Big/02 Methods|Methods]]\n" +
+		"3. [[Big/03 Results|Results]]\n4. [[Big/04 Appendix|Appendix]]\n"
+	if got := readContent(t, quartz, "Big/index.md"); got != wantIndex {
+		t.Errorf("Big/index.md =\n%s\nwant\n%s", got, wantIndex)
+	}
+	wantResults := "# Results\n\n## Data Flow\n\nResults[^b] ^key\n\n[^b]: Note b\n    continued\n\n*Part 3 of 4 of [[Big/index|Big]]*\n"
+	if got := readContent(t, quartz, "Big/03 Results.md"); got != wantResults {
+		t.Errorf("Big/03 Results.md =\n%s\nwant\n%s", got, wantResults)
+	}
+	if got := readContent(t, quartz, "Big/02 Methods.md"); strings.Contains(got, "[^") || strings.HasPrefix(got, "---") {
+		t.Errorf("Big/02 Methods.md has footnotes or frontmatter it does not use:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(quartz, "content", "Big.md")); err == nil {
+		t.Errorf("the split note is also published whole")
+	}
+}
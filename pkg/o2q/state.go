@@ -0,0 +1,484 @@
+package o2q
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateFileName is the name of the file of the Quartz folder where --incremental records what was published
+const stateFileName = ".obsidian-to-quartz-state.json"
+
+// stateVersion is the version of the state file format; a state file of another version is ignored
+// Version 2 added the identity of the vault and content folder, version 3 replaced the vault path with its id
+// and added the profile
+const stateVersion = 3
+
+// vaultIDFileName is the file of the .obsidian folder of the vault holding the id of the vault, written by the
+// first --incremental run, so the state file tells vaults apart without recording where they are
+const vaultIDFileName = "obsidian-to-quartz-id"
+
+// syncState is the content of the state file
+type syncState struct {
+	Version  int                   `json:"version"`
+	Identity stateIdentity         `json:"identity"`
+	Settings string                `json:"settings"` // Hash of the options and rules the files were published with
+	Files    map[string]stateEntry `json:"files"`    // By vault-relative source path, with forward slashes
+}
+
+// stateIdentity tells which vault was published to which content folder, so a state file is never reused for another
+type stateIdentity struct {
+	VaultID     string `json:"vault_id"`          // Id of the vault, as vaultID gives it
+	ContentDir  string `json:"content_dir"`       // Content folder relative to the Quartz folder, with forward slashes
+	Profile     string `json:"profile,omitempty"` // Name of the config file given with --config, without extension, but for the default name
+	ToolVersion string `json:"tool_version"`      // Version of the program that wrote the state file, for information
+}
+
+// matches checks if a state file was written for the same vault, content folder and profile; the tool version may differ
+func (id stateIdentity) matches(other stateIdentity) bool {
+	return id.VaultID == other.VaultID && id.ContentDir == other.ContentDir && id.Profile == other.Profile
+}
+
+// String describes an identity for messages
+func (id stateIdentity) String() string {
+	s := fmt.Sprintf("vault %s published to %s", id.VaultID, id.ContentDir)
+	if id.Profile != "" {
+		s += " with profile " + id.Profile
+	}
+	return s
+}
+
+// stateIdentityOf returns the identity of a run, recorded in the state file
+func (c *converter) stateIdentityOf() stateIdentity {
+	contentDir, err := filepath.Rel(c.quartzFolder, c.contentFolder)
+	if err != nil {
+		contentDir = c.contentFolder
+	}
+	var profile string
+	if c.opts.configPath != "" && filepath.Base(c.opts.configPath) != configFileName {
+		profile = filepath.Base(c.opts.configPath)
+		profile = strings.TrimSuffix(profile, filepath.Ext(profile))
+	}
+	return stateIdentity{VaultID: c.vaultID(), ContentDir: filepath.ToSlash(contentDir), Profile: profile, ToolVersion: buildVersion()}
+}
+
+// vaultID returns the id of the vault, read from its .obsidian folder, where it is written the first time
+// A vault without .obsidian folder, or an archive without id, is told apart by a hash of its resolved path instead,
+// which changes when it moves
+func (c *converter) vaultID() string {
+	idFile := filepath.Join(c.obsidianFolder, ".obsidian", vaultIDFileName)
+	if data, err := c.vault.ReadFile(idFile); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data))
+	}
+	if info, err := c.vault.Stat(filepath.Join(c.obsidianFolder, ".obsidian")); err == nil && info.IsDir() &&
+		c.vault.archive == nil && !c.opts.dryRun {
+		id, err := newVaultID()
+		if err == nil {
+			err = os.WriteFile(idFile, []byte(id+"\n"), 0644)
+		}
+		if err == nil {
+			c.console.progressf("Recorded the id of the vault in %s", idFile)
+			return id
+		}
+		c.console.warnf("failed to record the id of the vault, telling it apart by its path: %v", err)
+	}
+	vault, err := resolvedPath(c.obsidianFolder)
+	if err != nil {
+		vault = c.obsidianFolder
+	}
+	sum := sha256.Sum256([]byte(vault))
+	return "path-" + hex.EncodeToString(sum[:8])
+}
+
+// newVaultID returns a random version 4 UUID
+func newVaultID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// stateEntry describes a vault file as it was published by the last --incremental run
+type stateEntry struct {
+	Size    int64         `json:"size"`
+	ModTime time.Time     `json:"mtime"`
+	Hash    string        `json:"hash"` // SHA-256 of the source
+	Outputs []stateOutput `json:"outputs"`
+	Links   []string      `json:"links,omitempty"` // Names a note links to, as linkName gives them
+}
+
+// stateOutput is a file written for a source
+type stateOutput struct {
+	Path string `json:"path"` // Relative to the content folder, with forward slashes
+	Hash string `json:"hash"` // SHA-256 of the file as written
+}
+
+// incrementalState tracks the state of an --incremental run
+type incrementalState struct {
+	file     string
+	identity stateIdentity         // Identity of this run, written to the state file
+	settings string                // Hash of the options and rules of this run, written to the state file
+	full     bool                  // The settings changed since the last run, so every file is published again
+	previous map[string]stateEntry // From the state file of the last run
+	next     map[string]stateEntry // Files published or left unchanged by this run
+}
+
+// loadState reads the state file of the Quartz folder for --incremental
+// A missing, unreadable or outdated state file is warned about and the run publishes every file
+// A state file written for another vault or content folder is refused, as its files would be taken for sources
+// gone and deleted: --migrate-state takes it over for the new identity, as after moving the vault, and
+// --reset-state ignores it
+// When the settings differ from those of the last run, every file is published again, while the sources gone since
+// then still have their files deleted
+func loadState(log *consoleLogger, quartzFolder string, identity stateIdentity, settings string, migrate, reset bool) (*incrementalState, error) {
+	s := &incrementalState{
+		file:     filepath.Join(quartzFolder, stateFileName),
+		identity: identity,
+		settings: settings,
+		previous: map[string]stateEntry{},
+		next:     map[string]stateEntry{},
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		log.infof("No state file from a previous run, publishing every file")
+		return s, nil
+	}
+	var state syncState
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	switch {
+	case err != nil:
+		log.warnf("failed to read the state file, publishing every file: %v", err)
+	case state.Version != stateVersion:
+		log.warnf("the state file has version %d instead of %d, publishing every file", state.Version, stateVersion)
+	case reset:
+		log.infof("Ignoring the state file with --reset-state, publishing every file")
+	case !state.Identity.matches(identity) && !migrate:
+		return nil, refusedf("the state file %s was written for %s, not %s; pass --migrate-state if the vault "+
+			"or content folder moved or the config file was renamed, or --reset-state to publish every file",
+			s.file, state.Identity, identity)
+	default:
+		if !state.Identity.matches(identity) {
+			log.infof("Migrating the state file from %s", state.Identity)
+		}
+		if state.Files != nil {
+			s.previous = state.Files
+		}
+		if state.Settings != settings {
+			log.infof("The options or ignore rules changed since the last run, publishing every file")
+			s.full = true
+		}
+	}
+	return s, nil
+}
+
+// runOnlyOptions are the options changing how a run goes, but not what it publishes or how,
+// left out of the settings recorded in the state file
+var runOnlyOptions = map[string]bool{
+	"absolute-paths": true, "break-pins": true, "clean": true, "clean-keep": true, "config": true, "dry-run": true,
+	"every": true, "explain-filter": true, "fail-fast": true, "force-unlock": true, "hook-post": true,
+	"hook-timeout": true, "incremental": true, "jitter": true, "migrate-state": true, "note-depth": true,
+	"outside-links": true, "print-config": true, "progress": true, "prune": true, "quiet": true, "report-json": true,
+	"reset-state": true, "self-check": true, "since-git": true, "skip-space-check": true, "source": true,
+	"standalone": true, "verbose": true, "version": true, "wait": true, "watch": true, "write-ref": true, "yes": true,
+}
+
+// settingsHash returns a hash of the options, the ignore rules, the rules of the config file and the snippets of a run,
+// which decide what is published and how; --incremental publishes every file again when it changes
+func (c *converter) settingsHash() string {
+	h := sha256.New()
+	// The registry sets the options it binds to their defaults, so the values of the run are put back once bound
+	var opts options
+	registry := newOptionRegistry(&opts)
+	opts = c.opts
+	for _, o := range registry {
+		if !runOnlyOptions[o.name] {
+			fmt.Fprintf(h, "%s=%s\n", o.name, o.value)
+		}
+	}
+	var gitignoreRules []gitignoreRule
+	if c.gitignore != nil {
+		gitignoreRules = c.gitignore.rules
+	}
+	fmt.Fprintf(h, "%+v\n%+v\n%+v\n%+v\n%+v\n%+v\n%+v\n%s\n", c.excludePatterns, gitignoreRules, c.gitignoreKeeps,
+		c.folderMap, c.frontmatterRules, c.transformRules, c.snippets, pipelineStepNames(c.bodySteps))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashFile returns the SHA-256 of a file of the content folder, in hexadecimal
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashReader(file)
+}
+
+// hashSource returns the SHA-256 of a file of the vault, in hexadecimal
+func (c *converter) hashSource(src string) (string, error) {
+	file, err := c.vault.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashReader(file)
+}
+
+// hashReader returns the SHA-256 of what a reader holds, in hexadecimal
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unchangedSinceState checks if a file is left as it is with --incremental: same size, and same time or content
+// as in the state file, and its published files still there
+func (c *converter) unchangedSinceState(relPath, src string, info os.FileInfo) bool {
+	if c.state == nil || c.state.full {
+		return false
+	}
+	key := filepath.ToSlash(relPath)
+	prev, ok := c.state.previous[key]
+	if !ok || prev.Size != info.Size() {
+		return false
+	}
+	if !prev.ModTime.Equal(info.ModTime()) {
+		// Touched but maybe not modified, as by a sync tool
+		if hash, err := c.hashSource(src); err != nil || hash != prev.Hash {
+			return false
+		}
+		prev.ModTime = info.ModTime()
+	}
+	for _, out := range prev.Outputs {
+		if _, err := os.Stat(filepath.Join(c.contentFolder, filepath.FromSlash(out.Path))); err != nil {
+			return false
+		}
+	}
+	c.state.next[key] = prev
+	return true
+}
+
+// warnModifiedOutputs warns about the published files of a changed source that were modified in the content folder
+// since the last --incremental run, as they are about to be overwritten
+func (c *converter) warnModifiedOutputs(relPath, src string) {
+	if c.state == nil {
+		return
+	}
+	for _, out := range c.state.previous[filepath.ToSlash(relPath)].Outputs {
+		dest := filepath.Join(c.contentFolder, filepath.FromSlash(out.Path))
+		if hash, err := hashFile(dest); err == nil && hash != out.Hash {
+			c.console.warnf("%s: %s was modified in the content folder since the last run, overwriting it", src, c.paths.dest(dest))
+		}
+	}
+}
+
+// recordState adds a published source and the files written for it to the state file
+// A source for which nothing was written, such as a note left out by --filter, is left out
+func (c *converter) recordState(relPath, src string, info os.FileInfo) {
+	if c.state == nil || len(c.written[src]) == 0 {
+		return
+	}
+	hash, err := c.hashSource(src)
+	if err != nil {
+		return
+	}
+	entry := stateEntry{Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+	if hasExt(src, ".md") {
+		if content, err := c.vault.ReadFile(src); err == nil {
+			entry.Links = linkNames(content)
+		}
+	}
+	for _, dest := range c.written[src] {
+		rel, err := filepath.Rel(c.contentFolder, dest)
+		if err != nil {
+			return
+		}
+		outHash, err := hashFile(dest)
+		if err != nil {
+			return
+		}
+		entry.Outputs = append(entry.Outputs, stateOutput{Path: filepath.ToSlash(rel), Hash: outHash})
+	}
+	c.state.next[filepath.ToSlash(relPath)] = entry
+}
+
+// linkName returns the name a link target or a vault file is known by in the state file: its lowercased base name,
+// without .md for notes, as the plan indexes the files it publishes
+func linkName(target string) string {
+	name := strings.ToLower(path.Base(filepath.ToSlash(target)))
+	if hasExt(name, ".md") {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	return name
+}
+
+// linkNames returns the names of the files a note links to or embeds, sorted
+func linkNames(content []byte) []string {
+	seen := make(map[string]bool)
+	for _, parts := range noteWikiLinkRe.FindAllSubmatch(content, -1) {
+		if target := string(parts[2]); strings.TrimSpace(target) != "" {
+			seen[linkName(target)] = true
+		}
+	}
+	for _, parts := range markdownLinkRe.FindAllSubmatch(content, -1) {
+		target := strings.Trim(string(parts[3]), "<>")
+		if isExternalURL(target) {
+			continue
+		}
+		target, _, _ = strings.Cut(target, "#")
+		if decoded, err := url.PathUnescape(target); err == nil {
+			target = decoded
+		}
+		if target != "" {
+			seen[linkName(target)] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// planStaleDependents publishes again the unchanged notes of --incremental that link to a file published differently
+// since the last run: newly published, no longer published, moved or changed, as their links are rewritten depending
+// on it, such as links to its headings
+func (c *converter) planStaleDependents() {
+	if c.state == nil || c.state.full {
+		return
+	}
+	previous := make(map[string][]string)
+	for key := range c.state.previous {
+		previous[linkName(key)] = append(previous[linkName(key)], key)
+	}
+	changed := make(map[string]bool) // Names of the files published by the last run and changed since
+	for _, f := range c.plan.files {
+		if _, ok := c.state.previous[filepath.ToSlash(f.relPath)]; ok && f.action == planPublish {
+			changed[linkName(f.relPath)] = true
+		}
+	}
+	for i := range c.plan.files {
+		f := &c.plan.files[i]
+		if f.action != planUnchangedState {
+			continue
+		}
+		for _, name := range c.state.previous[filepath.ToSlash(f.relPath)].Links {
+			was := append([]string{}, previous[name]...)
+			var now []string
+			for _, file := range append(append([]string{}, c.plan.notesByName[name]...), c.plan.assetsByName[name]...) {
+				if !c.unpublishedNotes[file] {
+					now = append(now, file)
+				}
+			}
+			sort.Strings(was)
+			sort.Strings(now)
+			if !changed[name] && strings.Join(was, "\n") == strings.Join(now, "\n") {
+				continue
+			}
+			c.console.progressf("Republishing %s, as the files it links to as %s changed since the last run", f.src, name)
+			f.action = planPublish
+			if f.err = c.claimPlannedDest(f.relPath, f.src, f.dest); f.err != nil {
+				var r refusal
+				f.action, f.refused = actionError, errors.As(f.err, &r)
+			}
+			break
+		}
+	}
+}
+
+// removeVanishedSources deletes the published files of the sources of the last --incremental run that no longer exist
+// Sources still in the vault but no longer published, such as newly ignored ones, keep their files until a full sync,
+// or until the options or ignore rules change
+func (c *converter) removeVanishedSources() error {
+	var planned map[string]bool // Sources the run publishes or failed to, when every file is published again
+	if c.state.full {
+		planned = make(map[string]bool)
+		for _, f := range c.plan.files {
+			file := filepath.ToSlash(f.relPath)
+			if (f.action == planPublish || f.action == actionError) && !c.unpublishedNotes[file] {
+				planned[file] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(c.state.previous))
+	for key := range c.state.previous {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		prev := c.state.previous[key]
+		if _, ok := c.state.next[key]; ok {
+			continue
+		}
+		src := filepath.Join(c.obsidianFolder, filepath.FromSlash(key))
+		reason := "source gone since the last run"
+		if _, err := c.vault.Lstat(src); !os.IsNotExist(err) {
+			if planned == nil || planned[key] {
+				continue
+			}
+			reason = "no longer published since the options changed"
+		}
+		for _, out := range prev.Outputs {
+			dest := filepath.Join(c.contentFolder, filepath.FromSlash(out.Path))
+			if c.relocatedHere(dest) {
+				continue
+			}
+			if err := c.checkContained(dest); err != nil {
+				return fmt.Errorf("%s %w", dest, err)
+			}
+			if _, err := os.Stat(dest); os.IsNotExist(err) {
+				continue
+			}
+			if buried, err := c.buryNote(dest, reason); err != nil {
+				return err
+			} else if buried {
+				continue
+			}
+			if c.wouldDelete(dest, reason) {
+				continue
+			}
+			if err := os.Remove(dest); os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to delete %s: %v", dest, err)
+			}
+			c.record(reportEntry{Source: src, Destination: dest, Action: actionDeleted}, 0)
+		}
+	}
+	return nil
+}
+
+// writeState writes the state file atomically, so an interrupted write leaves the previous one
+func (c *converter) writeState() error {
+	data, err := json.MarshalIndent(syncState{Version: stateVersion, Identity: c.state.identity, Settings: c.state.settings, Files: c.state.next}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the state file: %v", err)
+	}
+	_, err = writeFileAtomic(c.state.file, 0644, func(w io.Writer) (int64, error) {
+		n, err := w.Write(append(data, '\n'))
+		return int64(n), err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write the state file: %v", err)
+	}
+	return nil
+}
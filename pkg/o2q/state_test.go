@@ -0,0 +1,246 @@
+package o2q
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestState writes a state file for identity, publishing Note.md, to a Quartz folder
+func writeTestState(t *testing.T, quartzFolder string, identity stateIdentity) {
+	t.Helper()
+	state := syncState{Version: stateVersion, Identity: identity, Files: map[string]stateEntry{
+		"Note.md": {Size: 5, Hash: "abc", Outputs: []stateOutput{{Path: "Note.md", Hash: "def"}}},
+	}}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(quartzFolder, stateFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadStateIdentity(t *testing.T) {
+	written := stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content", Profile: "blog", ToolVersion: "v1.0.0"}
+	tests := []struct {
+		name     string
+		identity stateIdentity
+		migrate  bool
+		reset    bool
+		refused  bool
+		previous int
+	}{
+		{"same identity", written, false, false, false, 1},
+		{"new tool version", stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content", Profile: "blog", ToolVersion: "v1.1.0"}, false, false, false, 1},
+		{"other vault", stateIdentity{VaultID: "path-9a1b", ContentDir: "content", Profile: "blog"}, false, false, true, 0},
+		{"other vault, migrated", stateIdentity{VaultID: "path-9a1b", ContentDir: "content", Profile: "blog"}, true, false, false, 1},
+		{"other vault, reset", stateIdentity{VaultID: "path-9a1b", ContentDir: "content", Profile: "blog"}, false, true, false, 0},
+		{"content dir renamed", stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content/notes", Profile: "blog"}, false, false, true, 0},
+		{"content dir renamed, migrated", stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content/notes", Profile: "blog"}, true, false, false, 1},
+		{"profile switched", stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content", Profile: "docs"}, false, false, true, 0},
+		{"no profile", stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content"}, false, false, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quartz := t.TempDir()
+			writeTestState(t, quartz, written)
+			state, err := loadState(console, quartz, tt.identity, "", tt.migrate, tt.reset)
+			if tt.refused {
+				var r refusal
+				if !errors.As(err, &r) {
+					t.Fatalf("loadState() error = %v, want a refusal", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadState() error = %v", err)
+			}
+			if len(state.previous) != tt.previous {
+				t.Errorf("loadState() kept %d files, want %d", len(state.previous), tt.previous)
+			}
+			if state.identity != tt.identity {
+				t.Errorf("loadState() identity = %+v, want %+v", state.identity, tt.identity)
+			}
+		})
+	}
+}
+
+func TestIncrementalVaultMove(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n", ".obsidian/app.json": "{}\n"})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-incremental"); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+	data, err := os.ReadFile(filepath.Join(quartz, stateFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), filepath.ToSlash(vault)) || strings.Contains(string(data), strings.ReplaceAll(vault, `\`, `\\`)) {
+		t.Errorf("the state file records the path of the vault:\n%s", data)
+	}
+
+	// The vault keeps its id in .obsidian, so the state file follows it
+	moved := filepath.Join(t.TempDir(), "Moved")
+	if err := os.Rename(vault, moved); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, moved, quartz, "-incremental"); code != exitNothingToDo {
+		t.Errorf("run from the moved vault exited with %d, want %d", code, exitNothingToDo)
+	}
+
+	// Another vault with the same files is refused
+	other := writeVault(t, map[string]string{"Note.md": "Text\n", ".obsidian/app.json": "{}\n"})
+	opts := testOptions(t, "-incremental")
+	sources := []vaultSource{{folder: other, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	if code := runSources(console, opts, config{}, sources, quartz, ""); code != exitRefused {
+		t.Errorf("run from another vault exited with %d, want %d", code, exitRefused)
+	}
+}
+
+func TestIncrementalProfileSwitch(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n", ".obsidian/app.json": "{}\n"})
+	quartz := t.TempDir()
+	blog, docs := filepath.Join(t.TempDir(), "blog.yaml"), filepath.Join(t.TempDir(), "docs.yaml")
+	for _, path := range []string{blog, docs} {
+		if err := os.WriteFile(path, []byte("strip-comments: true\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if code := runTestSync(t, vault, quartz, "-incremental", "-config", blog); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+	// The state file of one profile is not taken for another, whose prune would see every file as gone
+	opts := testOptions(t, "-incremental", "-config", docs)
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	if code := runSources(console, opts, config{}, sources, quartz, ""); code != exitRefused {
+		t.Errorf("run with another profile exited with %d, want %d", code, exitRefused)
+	}
+	if code := runTestSync(t, vault, quartz, "-incremental", "-config", docs, "-migrate-state"); code != exitNothingToDo {
+		t.Errorf("run with another profile and --migrate-state exited with %d, want %d", code, exitNothingToDo)
+	}
+	if code := runTestSync(t, vault, quartz, "-incremental", "-config", docs); code != exitNothingToDo {
+		t.Errorf("next run with the migrated profile exited with %d, want %d", code, exitNothingToDo)
+	}
+}
+
+func TestLoadStateOlderVersion(t *testing.T) {
+	quartz := t.TempDir()
+	data := []byte(`{"version": 1, "files": {"Note.md": {"size": 5}}}`)
+	if err := os.WriteFile(filepath.Join(quartz, stateFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A state file without identity is never reused: every file is published, and none deleted
+	state, err := loadState(console, quartz, stateIdentity{VaultID: "4f0c2a7e", ContentDir: "content"}, "", false, false)
+	if err != nil {
+		t.Fatalf("loadState() error = %v", err)
+	}
+	if len(state.previous) != 0 {
+		t.Errorf("loadState() kept %d files of a version 1 state file, want 0", len(state.previous))
+	}
+}
+
+func TestIncrementalSettingsChange(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Text `= this.file.name` here\n", "Drafts/Old.md": "Old\n"})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-incremental"); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+	if code := runTestSync(t, vault, quartz, "-incremental"); code != exitNothingToDo {
+		t.Fatalf("run with nothing changed exited with %d, want %d", code, exitNothingToDo)
+	}
+
+	// A new option changes how every note is published, though none of them changed
+	if code := runTestSync(t, vault, quartz, "-incremental", "-strip-dataview"); code != exitSuccess {
+		t.Fatalf("run with --strip-dataview exited with %d", code)
+	}
+	if note := readContent(t, quartz, "Note.md"); strings.Contains(note, "this.file.name") {
+		t.Errorf("with --strip-dataview, Note.md = %q, want the expression removed", note)
+	}
+	if code := runTestSync(t, vault, quartz, "-incremental", "-strip-dataview"); code != exitNothingToDo {
+		t.Errorf("second run with --strip-dataview exited with %d, want %d", code, exitNothingToDo)
+	}
+
+	// So does a new line of the ignore file, and the notes it leaves out are deleted
+	if err := os.WriteFile(filepath.Join(vault, ignoreFileName), []byte("Drafts/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, "-incremental", "-strip-dataview"); code != exitSuccess {
+		t.Errorf("run with a new ignore file exited with %d", code)
+	}
+	if got := contentFiles(t, quartz); got != "Note.md" {
+		t.Errorf("with Drafts/ ignored, published %s, want Note.md", got)
+	}
+}
+
+func TestIncrementalLinkDependents(t *testing.T) {
+	vault := writeVault(t, map[string]string{"A.md": "See [[B]].\n", "B.md": "B\n"})
+	quartz := t.TempDir()
+	args := []string{"-incremental", "-skip-unpublished", "-unpublished-links", "unlink", "-resolve-links"}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+
+	// B moves to a folder: the unchanged A links to its new path
+	if err := os.Mkdir(filepath.Join(vault, "Sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(vault, "B.md"), filepath.Join(vault, "Sub", "B.md")); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run with B moved exited with %d", code)
+	}
+	if note := readContent(t, quartz, "A.md"); !strings.Contains(note, "[[Sub/B|B]]") {
+		t.Errorf("with B moved, A.md = %q, want the link to its new path", note)
+	}
+
+	// B is no longer published: the unchanged A loses its link
+	if err := os.WriteFile(filepath.Join(vault, "Sub", "B.md"), []byte("---\ndraft: true\n---\nB\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run with B unpublished exited with %d", code)
+	}
+	if note := readContent(t, quartz, "A.md"); note != "See B.\n" {
+		t.Errorf("with B unpublished, A.md = %q, want the link to B turned into text", note)
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitNothingToDo {
+		t.Errorf("run with nothing changed exited with %d, want %d", code, exitNothingToDo)
+	}
+}
+
+func TestIncrementalChangedLinkTarget(t *testing.T) {
+	// Links to the headings of a split note point to the part holding them, which depends on the text of the note
+	big := "# First\n\n" + strings.Repeat("text ", 30) + "\n\n# Second\n\n" + strings.Repeat("more ", 30) + "\n"
+	vault := writeVault(t, map[string]string{"A.md": "See [[Big#Second]].\n", "Big.md": big})
+	quartz := t.TempDir()
+	args := []string{"-incremental", "-max-note-size", "120", "-oversize-notes", "split"}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+	if note := readContent(t, quartz, "A.md"); !strings.Contains(note, "02 Second") {
+		t.Fatalf("A.md = %q, want the link to the second part", note)
+	}
+
+	// A new section moves the heading to the third part: the unchanged A links to it
+	big = "# Intro\n\n" + strings.Repeat("intro ", 30) + "\n\n" + big
+	if err := os.WriteFile(filepath.Join(vault, "Big.md"), []byte(big), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run with Big changed exited with %d", code)
+	}
+	if note := readContent(t, quartz, "A.md"); !strings.Contains(note, "03 Second") {
+		t.Errorf("with Big changed, A.md = %q, want the link to the third part", note)
+	}
+}
@@ -0,0 +1,149 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// walkVault walks the Obsidian folder like filepath.Walk, handling symbolic links explicitly:
+//   - links to files are reported with the information of the file they point to, so its contents are published
+//   - links to folders are descended into with --follow-symlinks, and skipped with a notice otherwise
+//   - broken links are skipped with a warning
+//
+// Folders reached through a link are walked once, which also stops link loops
+func (c *converter) walkVault(fn filepath.WalkFunc) error {
+	info, err := c.vault.Lstat(c.obsidianFolder)
+	if err != nil {
+		return fn(c.obsidianFolder, nil, err)
+	}
+	visited := make(map[string]bool)
+	if info.Mode()&os.ModeSymlink != 0 {
+		// The vault itself may be a link
+		if info, err = c.vault.Stat(c.obsidianFolder); err != nil {
+			return fn(c.obsidianFolder, nil, err)
+		}
+	}
+	err = c.walkPath(c.obsidianFolder, info, visited, fn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkPath walks a file or folder of the vault, see walkVault
+func (c *converter) walkPath(path string, info os.FileInfo, visited map[string]bool, fn filepath.WalkFunc) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := c.vault.Stat(path)
+		if err != nil {
+			c.noticeOnce(path, func() { c.console.warnf("%s: broken symbolic link skipped", path) })
+			return nil
+		}
+		if target.IsDir() {
+			if !c.opts.followSymlinks {
+				c.noticeOnce(path, func() {
+					c.console.infof("Skipped: %s (symbolic link to a folder, use --follow-symlinks to include it)", path)
+				})
+				return nil
+			}
+			real, err := resolvedPath(path)
+			if err != nil || visited[real] {
+				c.noticeOnce(path, func() { c.console.warnf("%s: symbolic link to a folder already included, skipped", path) })
+				return nil
+			}
+		}
+		info = target
+	}
+
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	if c.opts.followSymlinks {
+		if real, err := resolvedPath(path); err == nil {
+			visited[real] = true
+		}
+	}
+
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+	entries, err := c.readFolder(path)
+	if err != nil {
+		if err := fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.name)
+		if entry.err != nil {
+			if err := fn(child, nil, entry.err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := c.walkPath(child, entry.info, visited, fn); err != nil {
+			if err != filepath.SkipDir {
+				return err
+			}
+			if !entry.info.IsDir() {
+				// Like filepath.Walk, SkipDir returned for a file skips the rest of its folder
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// noticeOnce prints a message about a path only the first time, as the vault is walked several times
+func (c *converter) noticeOnce(path string, print func()) {
+	if c.symlinkNotices == nil {
+		c.symlinkNotices = make(map[string]bool)
+	}
+	if !c.symlinkNotices[path] {
+		c.symlinkNotices[path] = true
+		print()
+	}
+}
+
+// folderEntry is a file or folder of a vault folder, as walkVault visits it
+type folderEntry struct {
+	name string
+	info os.FileInfo
+	err  error // Failure to read the information of the file
+}
+
+// readFolder reads the entries of a vault folder, or those kept by the listing of --watch when nothing changed in it
+// since the last sync
+func (c *converter) readFolder(path string) ([]folderEntry, error) {
+	if entries, ok := c.listing.entries(path); ok {
+		return entries, nil
+	}
+	dirEntries, err := c.vault.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	// Entries are visited in the order of their names in NFC form, as macOS stores names in NFD,
+	// so the plan, the log lines and the report list files in the same order on every machine
+	sort.Slice(dirEntries, func(i, j int) bool {
+		a, b := norm.NFC.String(dirEntries[i].Name()), norm.NFC.String(dirEntries[j].Name())
+		if a != b {
+			return a < b
+		}
+		return dirEntries[i].Name() < dirEntries[j].Name()
+	})
+	entries := make([]folderEntry, len(dirEntries))
+	complete := true
+	for i, entry := range dirEntries {
+		entries[i].name = entry.Name()
+		entries[i].info, entries[i].err = entry.Info()
+		complete = complete && entries[i].err == nil
+	}
+	if complete {
+		c.listing.keep(path, entries)
+	}
+	return entries, nil
+}
@@ -0,0 +1,399 @@
+package o2q
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tagPageMarker is the generated-by frontmatter value marking a page as generated by --emit-tag-pages or --generate-indexes
+// Only marked pages are ever overwritten or pruned
+const tagPageMarker = "obsidian-to-quartz"
+
+// headingLineRe matches an ATX heading
+var headingLineRe = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]|\r?$)`)
+
+// inlineTagRe matches an inline #tag, which must not be only digits
+var inlineTagRe = regexp.MustCompile(`(^|\s)#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+// taggedNote is a published note listed on tag pages
+type taggedNote struct {
+	link  string // Content-relative path without .md, used as wikilink target
+	title string
+	date  string // YYYY-MM-DD, empty if unknown
+}
+
+// normalizeTag returns the canonical form of a tag: no #, lowercase, dashes instead of spaces, no empty levels
+// #Project/Alpha, project/alpha and "Project / Alpha" are the same tag
+func normalizeTag(tag string) string {
+	var levels []string
+	for _, level := range strings.Split(strings.TrimPrefix(strings.TrimSpace(tag), "#"), "/") {
+		level = strings.Join(strings.Fields(strings.ToLower(level)), "-")
+		if level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return strings.Join(levels, "/")
+}
+
+// noteTags returns the normalized tags of a note, from the tags frontmatter key and inline #tags
+func noteTags(values map[string]interface{}, content []byte) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	add := func(tag string) {
+		if tag = normalizeTag(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	for _, key := range []string{"tags", "tag"} {
+		for _, item := range configList(values[key]) {
+			// A single string may hold several tags separated by commas or spaces
+			for _, tag := range strings.FieldsFunc(item, func(r rune) bool { return r == ',' || r == ' ' }) {
+				add(tag)
+			}
+		}
+	}
+
+	_, body, _ := splitFrontmatter(content)
+	mapOutsideCode(body, func(text string) string {
+		for _, m := range inlineTagRe.FindAllStringSubmatch(text, -1) {
+			add(m[2])
+		}
+		return text
+	})
+	return tags
+}
+
+// noteDate returns the date of a note as YYYY-MM-DD, from its date or created frontmatter, else its modification time
+func noteDate(values map[string]interface{}, vault *vaultFS, src string) string {
+	for _, key := range []string{"date", "created"} {
+		switch v := values[key].(type) {
+		case time.Time:
+			return v.Format("2006-01-02")
+		case string:
+			if checkFormat(v, formatDate, nil) == nil {
+				return v[:10]
+			}
+		}
+	}
+	if info, err := vault.Stat(src); err == nil {
+		return info.ModTime().Format("2006-01-02")
+	}
+	return ""
+}
+
+// collectTags records the tags of a published note for --emit-tag-pages
+func (c *converter) collectTags(src, dest string, content []byte) {
+	if c.opts.emitTagPages == "" {
+		return
+	}
+	// Inline tags are still collected from a note with invalid frontmatter
+	values, err := parseFrontmatter(content)
+	c.frontmatterFallback(src, err, "frontmatter tags")
+	tags := noteTags(values, content)
+	if len(tags) == 0 {
+		return
+	}
+
+	rel, err := filepath.Rel(c.contentFolder, dest)
+	if err != nil {
+		return
+	}
+	link := strings.TrimSuffix(filepath.ToSlash(rel), path.Ext(rel))
+	note := taggedNote{link: link, title: path.Base(link), date: noteDate(values, c.vault, src)}
+	if title := frontmatterString(values, "title"); title != "" {
+		note.title = title
+	}
+
+	if c.taggedNotes == nil {
+		c.taggedNotes = make(map[string][]taggedNote)
+	}
+	for _, tag := range tags {
+		c.taggedNotes[tag] = append(c.taggedNotes[tag], note)
+	}
+}
+
+// writeTagPages writes a page per tag and an overview page to the --emit-tag-pages folder,
+// then deletes the pages of tags no longer used
+//
+//	tags/index.md              overview of all tags
+//	tags/project/index.md      notes tagged #project, and its child tags
+//	tags/project/alpha/index.md
+func (c *converter) writeTagPages() error {
+	if c.opts.emitTagPages == "" {
+		return nil
+	}
+	folder := filepath.Join(c.contentFolder, c.opts.emitTagPages)
+	base := filepath.ToSlash(filepath.Clean(c.opts.emitTagPages))
+
+	// Parent tags get a page listing their children even when no note uses them directly
+	all := make(map[string]bool)
+	for tag := range c.taggedNotes {
+		for t := tag; t != "."; t = path.Dir(t) {
+			all[t] = true
+		}
+	}
+	children := make(map[string][]string)
+	var tags []string
+	for tag := range all {
+		tags = append(tags, tag)
+		children[path.Dir(tag)] = append(children[path.Dir(tag)], tag)
+	}
+	sort.Strings(tags)
+	for parent := range children {
+		sort.Strings(children[parent])
+	}
+
+	written := make(map[string]bool)
+	write := func(tag, title, body string) error {
+		file := filepath.Join(folder, filepath.FromSlash(tag), "index.md")
+		content := fmt.Sprintf("---\ntitle: %s\ngenerated-by: %s\n---\n\n%s\n",
+			strconv.Quote(title), tagPageMarker, strings.TrimRight(body, "\n"))
+		written[file] = true
+		return c.writeGeneratedPage(file, []byte(content), isTagPage, "tag page")
+	}
+	pageLink := func(tag string) string {
+		return "[[" + path.Join(base, tag, "index") + "|" + tag + "]]"
+	}
+
+	// Overview page, with nested tags indented under their parent
+	var overview strings.Builder
+	for _, tag := range tags {
+		indent := strings.Repeat("  ", strings.Count(tag, "/"))
+		fmt.Fprintf(&overview, "%s- %s%s\n", indent, pageLink(tag), noteCount(c.taggedNotes[tag]))
+	}
+	if err := write(".", "Tags", overview.String()); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		var b strings.Builder
+		if parent := path.Dir(tag); parent != "." {
+			fmt.Fprintf(&b, "Parent tag: %s\n\n", pageLink(parent))
+		}
+		if len(children[tag]) > 0 {
+			b.WriteString("## Tags\n\n")
+			for _, child := range children[tag] {
+				fmt.Fprintf(&b, "- %s%s\n", pageLink(child), noteCount(c.taggedNotes[child]))
+			}
+			b.WriteString("\n")
+		}
+
+		// Most recent notes first
+		notes := c.taggedNotes[tag]
+		sort.Slice(notes, func(i, j int) bool {
+			if notes[i].date != notes[j].date {
+				return notes[i].date > notes[j].date
+			}
+			return notes[i].link < notes[j].link
+		})
+		if len(notes) > 0 {
+			b.WriteString("## Notes\n\n")
+			for _, note := range notes {
+				fmt.Fprintf(&b, "- [[%s|%s]]", note.link, note.title)
+				if note.date != "" {
+					fmt.Fprintf(&b, " (%s)", note.date)
+				}
+				b.WriteString("\n")
+			}
+		}
+		if err := write(tag, "Tag: "+tag, b.String()); err != nil {
+			return err
+		}
+	}
+
+	return c.pruneTagPages(folder, written)
+}
+
+// noteCount returns the number of notes shown after a tag, or nothing for a tag only used through its children
+func noteCount(notes []taggedNote) string {
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d)", len(notes))
+}
+
+// writeGeneratedPage writes a generated page, unless a file that generated does not recognize is in the way
+func (c *converter) writeGeneratedPage(file string, content []byte, generated func([]byte) bool, what string) error {
+	if err := c.checkContained(file); err != nil {
+		return fmt.Errorf("%s %w", file, err)
+	}
+	existing, err := os.ReadFile(file)
+	if err == nil {
+		if !generated(existing) {
+			c.console.warnf("%s: not a generated %s, left untouched", file, what)
+			return nil
+		}
+		// Unchanged pages are not rewritten, so Quartz does not rebuild them
+		if bytes.Equal(existing, content) {
+			c.record(reportEntry{Destination: file, Action: actionGenerated}, 0)
+			return nil
+		}
+	}
+
+	if err := c.makeDir(filepath.Dir(file)); err != nil {
+		return fmt.Errorf("failed to create %s folder: %v", what, err)
+	}
+	_, err = writeFileAtomic(file, 0644, func(w io.Writer) (int64, error) {
+		n, err := w.Write(content)
+		return int64(n), err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", what, err)
+	}
+	c.record(reportEntry{Destination: file, Action: actionGenerated}, int64(len(content)))
+	return nil
+}
+
+// isTagPage checks if a file was generated by --emit-tag-pages
+func isTagPage(content []byte) bool {
+	values, err := parseFrontmatter(content)
+	return err == nil && frontmatterString(values, "generated-by") == tagPageMarker
+}
+
+// pruneTagPages deletes the generated tag pages not written by this run, and the folders left empty
+func (c *converter) pruneTagPages(folder string, written map[string]bool) error {
+	removed := 0
+	var dirs []string
+	err := filepath.Walk(folder, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		if written[p] || filepath.Ext(p) != ".md" {
+			return nil
+		}
+		if content, err := os.ReadFile(p); err != nil || !isTagPage(content) {
+			return nil
+		}
+		if err := c.checkContained(p); err != nil {
+			return fmt.Errorf("%s %w", p, err)
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		c.console.progressf("Deleted: %s (tag no longer used)", c.paths.dest(p))
+		removed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prune tag pages: %v", err)
+	}
+
+	// Deepest folders first; removing a folder that is not empty fails and is ignored
+	for i := len(dirs) - 1; i > 0; i-- {
+		os.Remove(dirs[i])
+	}
+	if removed > 0 {
+		c.console.infof("Removed %d pages of tags no longer used", removed)
+	}
+	return nil
+}
+
+// collectInlineTags merges the inline #tags of a note into its tags frontmatter key, for Quartz only reads tags from frontmatter
+// The tags are written as a YAML list, which also fixes tags given as a comma-separated string or under the tag key;
+// duplicates are dropped, comparing tags like Obsidian does, and nested tags keep their a/b form
+// With --strip-inline-tags, the inline tags are removed from the body, and lines holding nothing else are dropped
+func (c *converter) collectInlineTags(src string, content []byte) []byte {
+	if !c.opts.collectInlineTags {
+		return content
+	}
+	frontmatter, body, _ := splitFrontmatter(content)
+	lines, keys, ok := frontmatterKeys(frontmatter)
+	if !ok {
+		c.console.warnf("%s: frontmatter is not a block of keys, inline tags not collected", src)
+		return content
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	add := func(tag string) {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if key := normalizeTag(tag); key != "" && !seen[key] {
+			seen[key] = true
+			tags = append(tags, tag)
+		}
+	}
+	found, items := frontmatterListItems(keys, "tags", "tag")
+	for _, item := range items {
+		// A single string may hold several tags separated by commas or spaces
+		for _, tag := range strings.FieldsFunc(item, func(r rune) bool { return r == ',' || r == ' ' }) {
+			add(tag)
+		}
+	}
+	written := len(tags)
+
+	stripped := mapOutsideCode(body, func(text string) string {
+		// Headings are left alone: # Title #draft is a title, not a tagged line
+		if headingLineRe.MatchString(text) {
+			return text
+		}
+		for _, m := range inlineTagRe.FindAllStringSubmatch(text, -1) {
+			add(m[2])
+		}
+		if c.opts.stripInlineTags {
+			text = stripInlineTags(text)
+		}
+		return text
+	})
+	if c.opts.stripInlineTags {
+		content = append(content[:len(content)-len(body):len(content)-len(body)], dropEmptiedLines(body, stripped)...)
+	}
+
+	// A tags list with nothing to add is left as written
+	isList := len(found) == 1 && found[0].key.Value == "tags" && found[0].value.Kind == yaml.SequenceNode
+	if len(tags) == written && (len(found) == 0 || isList) {
+		return content
+	}
+	return writeFrontmatterList(content, lines, found, "tags", tags)
+}
+
+// stripInlineTags removes the inline #tags of a text with the space before them, or after them at the start of the text
+func stripInlineTags(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range inlineTagRe.FindAllStringSubmatchIndex(text, -1) {
+		// The space before a tag may already be gone with the tag before it
+		b.WriteString(text[min(last, m[0]):m[0]])
+		last = m[1]
+		if m[3] == m[2] {
+			// No space before the tag: drop the spaces after it instead
+			for last < len(text) && (text[last] == ' ' || text[last] == '\t') {
+				last++
+			}
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// dropEmptiedLines returns the stripped lines of a body, leaving out those that only held inline tags
+func dropEmptiedLines(original, stripped []byte) []byte {
+	before, after := splitLines(original), splitLines(stripped)
+	if len(before) != len(after) {
+		return stripped
+	}
+	var b bytes.Buffer
+	for i, line := range after {
+		if isBlankLine(line) && !isBlankLine(before[i]) {
+			continue
+		}
+		b.WriteString(line)
+	}
+	return b.Bytes()
+}
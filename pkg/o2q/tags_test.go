@@ -0,0 +1,98 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"#Project/Alpha":     "project/alpha",
+		"project/alpha":      "project/alpha",
+		"Project / Alpha":    "project/alpha",
+		"Reading List":       "reading-list",
+		"#a//b/":             "a/b",
+		"  #Été  ":           "été",
+		"#":                  "",
+		"Work/Team  Meeting": "work/team-meeting",
+	}
+	for tag, want := range tests {
+		if got := normalizeTag(tag); got != want {
+			t.Errorf("normalizeTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestNoteTags(t *testing.T) {
+	content := []byte("---\ntags: [Project/Alpha, reading]\ntag: \"draft, Reading\"\n---\n" +
+		"Inline #project/alpha and #Later, not #123 nor `#code`.\n\n```\n#fenced\n```\n")
+	values, err := parseFrontmatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"project/alpha", "reading", "draft", "later"}
+	if got := noteTags(values, content); !reflect.DeepEqual(got, want) {
+		t.Errorf("noteTags() = %q, want %q", got, want)
+	}
+}
+
+func TestEmitTagPages(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Alpha.md":         "---\ndate: 2024-03-01\ntags: [Project/Alpha]\n---\nAlpha\n",
+		"Plan.md":          "---\ndate: 2024-05-01\ntitle: The Plan\n---\nPlan #project/alpha\n",
+		"Old.md":           "---\ndate: 2023-01-01\n---\nOld #legacy\n",
+		"Private/Diary.md": "---\ndate: 2024-06-01\n---\nDiary #project/alpha #secret\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, "-emit-tag-pages", "tags", "-exclude", "Private/"); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+
+	// #Project/Alpha and #project/alpha are one tag, and excluded notes are never listed
+	wantOverview := "---\ntitle: \"Tags\"\ngenerated-by: obsidian-to-quartz\n---\n\n" +
+		"- [[tags/legacy/index|legacy]] (1)\n" +
+		"- [[tags/project/index|project]]\n" +
+		"  - [[tags/project/alpha/index|project/alpha]] (2)\n"
+	if got := readContent(t, quartz, "tags/index.md"); got != wantOverview {
+		t.Errorf("tags/index.md =\n%s\nwant\n%s", got, wantOverview)
+	}
+	wantAlpha := "---\ntitle: \"Tag: project/alpha\"\ngenerated-by: obsidian-to-quartz\n---\n\n" +
+		"Parent tag: [[tags/project/index|project]]\n\n" +
+		"## Notes\n\n" +
+		"- [[Plan|The Plan]] (2024-05-01)\n" +
+		"- [[Alpha|Alpha]] (2024-03-01)\n"
+	if got := readContent(t, quartz, "tags/project/alpha/index.md"); got != wantAlpha {
+		t.Errorf("tags/project/alpha/index.md =\n%s\nwant\n%s", got, wantAlpha)
+	}
+	wantProject := "---\ntitle: \"Tag: project\"\ngenerated-by: obsidian-to-quartz\n---\n\n" +
+		"## Tags\n\n- [[tags/project/alpha/index|project/alpha]] (2)\n"
+	if got := readContent(t, quartz, "tags/project/index.md"); got != wantProject {
+		t.Errorf("tags/project/index.md =\n%s\nwant\n%s", got, wantProject)
+	}
+	if _, err := os.Stat(filepath.Join(quartz, "content", "tags", "secret")); err == nil {
+		t.Errorf("a tag only used by an excluded note has a page")
+	}
+
+	// The page of a tag no note uses anymore is pruned, but not a page written by hand
+	handWritten := filepath.Join(quartz, "content", "tags", "about.md")
+	if err := os.WriteFile(handWritten, []byte("About tags\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(vault, "Old.md")); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, "-emit-tag-pages", "tags", "-exclude", "Private/"); code != exitSuccess {
+		t.Fatalf("second run exited with %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(quartz, "content", "tags", "legacy")); err == nil {
+		t.Errorf("the page of #legacy was not pruned")
+	}
+	if _, err := os.Stat(handWritten); err != nil {
+		t.Errorf("a hand-written page of the tags folder was deleted")
+	}
+	if got := readContent(t, quartz, "tags/project/alpha/index.md"); got != wantAlpha {
+		t.Errorf("tags/project/alpha/index.md changed between runs:\n%s", got)
+	}
+}
@@ -0,0 +1,118 @@
+package o2q
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Checkbox states custom task statuses are mapped to with --task-status
+const (
+	taskUnchecked = "unchecked"
+	taskChecked   = "checked"
+)
+
+// defaultTaskStatuses maps the common custom statuses of the Tasks plugin and themes such as Minimal;
+// statuses missing here are shown unchecked
+var defaultTaskStatuses = map[string]bool{
+	"-": true,  // Cancelled
+	"/": false, // In progress
+	">": false, // Forwarded
+	"<": false, // Scheduled
+	"!": false, // Important
+	"?": false, // Question
+}
+
+// taskLineRe matches a task of a list, in a quote or callout or not: its list marker, status character and text
+var taskLineRe = regexp.MustCompile(`^((?:[ \t]*>[ \t]?)*[ \t]*(?:[-*+]|\d{1,9}[.)])[ \t]+)\[([^\]])\](.*)$`)
+
+// taskMetadataRe matches a metadata field of the Tasks plugin with the spaces before it:
+// due, scheduled, start, created, done and cancelled dates, recurrence, priority, id, dependencies and on-completion
+var taskMetadataRe = regexp.MustCompile(`[ \t]*(?:` +
+	`(?:📅|⏳|⌛|🛫|➕|✅|❌)\x{FE0F}?[ \t]*\d{4}-\d{2}-\d{2}` +
+	`|🔁\x{FE0F}?(?:[ \t]+[^ \t📅⏳⌛🛫➕✅❌⏫🔼🔽🔺⏬🆔⛔🏁^]+)*` +
+	`|(?:⏫|🔼|🔽|🔺|⏬)\x{FE0F}?` +
+	`|🆔\x{FE0F}?[ \t]*[A-Za-z0-9_-]+` +
+	`|⛔\x{FE0F}?[ \t]*[A-Za-z0-9_-]+(?:[ \t]*,[ \t]*[A-Za-z0-9_-]+)*` +
+	`|🏁\x{FE0F}?[ \t]*[A-Za-z]+` +
+	`)`)
+
+// parseTaskStatuses reads --task-status entries of the form "status=checked" or "status=unchecked", such as -=checked,
+// added to the default mapping of the common custom statuses
+func parseTaskStatuses(entries []string) (map[string]bool, error) {
+	statuses := make(map[string]bool)
+	for status, checked := range defaultTaskStatuses {
+		statuses[status] = checked
+	}
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not of the form status=checked or status=unchecked", entry)
+		}
+		status, state := entry[:i], strings.ToLower(strings.TrimSpace(entry[i+1:]))
+		if utf8.RuneCountInString(status) != 1 || status == " " || status == "x" || status == "X" {
+			return nil, fmt.Errorf("%q: the status must be a single character other than a space or x", entry)
+		}
+		switch state {
+		case taskChecked:
+			statuses[status] = true
+		case taskUnchecked:
+			statuses[status] = false
+		default:
+			return nil, fmt.Errorf("%q: the status must map to %s or %s", entry, taskChecked, taskUnchecked)
+		}
+	}
+	return statuses, nil
+}
+
+// rewriteTasks rewrites the tasks of a note for Quartz, which only knows [ ] and [x]
+//   - --normalize-tasks: - [/] Call dentist → - [ ] Call dentist, - [-] Old idea → - [x] Old idea
+//   - --strip-task-metadata: - [ ] Call dentist 📅 2024-05-01 ⏫ 🔁 every week → - [ ] Call dentist
+//
+// Only list items with a checkbox are touched, including nested ones and those in callouts; code blocks are left alone
+func (c *converter) rewriteTasks(content []byte) []byte {
+	if !c.opts.normalizeTasks && !c.opts.stripTaskMetadata {
+		return content
+	}
+
+	_, body, _ := splitFrontmatter(content)
+	var out strings.Builder
+	out.Write(content[:len(content)-len(body)])
+	fence := ""
+	for _, line := range splitLines(body) {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			out.WriteString(line)
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			fence = marker
+			out.WriteString(line)
+			continue
+		}
+
+		text := strings.TrimRight(line, "\r\n")
+		eol := line[len(text):]
+		m := taskLineRe.FindStringSubmatch(text)
+		if m == nil {
+			out.WriteString(line)
+			continue
+		}
+		prefix, status, rest := m[1], m[2], m[3]
+		if c.opts.normalizeTasks && status != " " && status != "x" && status != "X" {
+			if c.taskStatuses[status] {
+				status = "x"
+			} else {
+				status = " "
+			}
+		}
+		if c.opts.stripTaskMetadata {
+			rest = strings.TrimRight(taskMetadataRe.ReplaceAllString(rest, ""), " \t")
+		}
+		out.WriteString(prefix + "[" + status + "]" + rest + eol)
+	}
+	return []byte(out.String())
+}
@@ -0,0 +1,100 @@
+package o2q
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Patterns used to derive a title from the first H1 of a note
+var (
+	h1Re             = regexp.MustCompile(`^ {0,3}#[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*\r?\n?$`)
+	titleWikiLinkRe  = regexp.MustCompile(`!?\[\[([^\]|#]*)(#[^\]|]*)?(?:\|([^\]]*))?\]\]`)
+	titleMdLinkRe    = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	titleCodeRe      = regexp.MustCompile("`+([^`]*)`+")
+	titleHTMLTagRe   = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	titleEmphasisRe  = regexp.MustCompile(`\*\*|__|~~|==|\*|\b_|_\b`)
+	titleBlockIDRe   = regexp.MustCompile(`\s\^[A-Za-z0-9-]+$`)
+	titleWhitespacRe = regexp.MustCompile(`\s+`)
+)
+
+// plainHeading flattens the markdown of a heading to plain text
+// Links become their text, and code, emphasis and HTML markers are dropped: "[[Go]] **tips**" gives "Go tips"
+func plainHeading(heading string) string {
+	text := titleCodeRe.ReplaceAllString(heading, "$1")
+	text = titleWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+		parts := titleWikiLinkRe.FindStringSubmatch(match)
+		if parts[1] == "" && parts[3] == "" {
+			return strings.TrimPrefix(parts[2], "#")
+		}
+		return fileLink{wiki: true, target: parts[1], text: parts[3]}.displayText()
+	})
+	text = titleMdLinkRe.ReplaceAllString(text, "$1")
+	text = titleHTMLTagRe.ReplaceAllString(text, "")
+	text = titleEmphasisRe.ReplaceAllString(text, "")
+	text = titleBlockIDRe.ReplaceAllString(text, "")
+	return strings.TrimSpace(titleWhitespacRe.ReplaceAllString(text, " "))
+}
+
+// firstH1 returns the index of the line holding the first H1 of a body, outside code, and its plain text
+func firstH1(lines []string) (int, string, bool) {
+	fence := ""
+	for i, line := range lines {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			fence = marker
+			continue
+		}
+		if m := h1Re.FindStringSubmatch(line); m != nil {
+			if title := plainHeading(m[1]); title != "" {
+				return i, title, true
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// addTitle gives a note without a title key a title, taken from its first H1 or else its file name
+// With --strip-h1, the H1 the title was taken from is removed, with the blank line after it, so the page does not show it twice
+// Notes that have a title key are left untouched, even if it is empty
+func (c *converter) addTitle(src string, content []byte) []byte {
+	if !c.opts.addTitle {
+		return content
+	}
+	values, err := parseFrontmatter(content)
+	if err != nil {
+		c.frontmatterFallback(src, err, "--add-title")
+		return content
+	}
+	if _, ok := values["title"]; ok {
+		return content
+	}
+
+	_, body, hasFrontmatter := splitFrontmatter(content)
+	head := string(content[:len(content)-len(body)])
+	title := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	lines := splitLines(body)
+	if i, heading, ok := firstH1(lines); ok {
+		title = heading
+		if c.opts.stripH1 {
+			end := i + 1
+			if end < len(lines) && isBlankLine(lines[end]) {
+				end++
+			}
+			body = []byte(strings.Join(lines[:i], "") + strings.Join(lines[end:], ""))
+		}
+	}
+
+	field := "title: " + yamlString(title) + "\n"
+	if !hasFrontmatter {
+		return []byte("---\n" + field + "---\n" + string(body))
+	}
+	// The title goes first, after the opening ---, and the rest of the frontmatter is kept as written
+	opening := len(splitLines([]byte(head))[0])
+	return []byte(head[:opening] + field + head[opening:] + string(body))
+}
@@ -0,0 +1,172 @@
+package o2q
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tombstonesFileName is the file of the Quartz folder recording when each stub of --tombstones was written
+const tombstonesFileName = ".obsidian-to-quartz-tombstones.json"
+
+// tombstonesVersion is the version of the tombstones file format; a file of another version is ignored
+const tombstonesVersion = 1
+
+// tombstonesState is the content of the tombstones file
+type tombstonesState struct {
+	Version    int                             `json:"version"`
+	Tombstones map[string]map[string]time.Time `json:"tombstones"` // When each stub was written, by content-relative path, by content folder
+}
+
+// loadTombstones reads the tombstones file of the Quartz folder for --tombstones
+// A missing or outdated file starts a new one: stubs written before it are left to --prune, as they are not notes
+func loadTombstones(log *consoleLogger, quartzFolder string) *tombstonesState {
+	state := &tombstonesState{Tombstones: map[string]map[string]time.Time{}}
+	data, err := os.ReadFile(filepath.Join(quartzFolder, tombstonesFileName))
+	if os.IsNotExist(err) {
+		return state
+	}
+	var saved tombstonesState
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	}
+	switch {
+	case err != nil:
+		log.warnf("Ignoring the tombstones file, it cannot be read: %v", err)
+	case saved.Version != tombstonesVersion:
+		log.warnf("Ignoring the tombstones file, it was written by another version of obsidian-to-quartz")
+	default:
+		if saved.Tombstones != nil {
+			state.Tombstones = saved.Tombstones
+		}
+	}
+	return state
+}
+
+// tombstonesOf returns the stubs of the content folder of the run, by content-relative path
+func (c *converter) tombstonesOf() map[string]time.Time {
+	key := path.Clean(filepath.ToSlash(c.opts.contentDir))
+	if c.tombstones.Tombstones[key] == nil {
+		c.tombstones.Tombstones[key] = make(map[string]time.Time)
+	}
+	return c.tombstones.Tombstones[key]
+}
+
+// tombstone returns the stub replacing a deleted note, pointing at the note it moved to when --redirects knows it
+//
+//	---
+//	title: "Roadmap"
+//	noindex: true
+//	tombstone: true
+//	generated-by: obsidian-to-quartz
+//	---
+//
+//	This page has moved to [Roadmap](../work/roadmap).
+func (c *converter) tombstone(rel string) []byte {
+	title := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\nnoindex: true\ntombstone: true\ngenerated-by: %s\n---\n\n", strconv.Quote(title), tagPageMarker)
+	url := path.Join(c.sitePrefix(), quartzSlug(rel))
+	if successor, ok := c.redirects.successor(url); ok {
+		fmt.Fprintf(&b, "This page has moved to [%s](%s).\n", path.Base(successor), relativeURL(path.Dir(url), successor))
+	} else {
+		b.WriteString("This page has moved or been removed.\n")
+	}
+	return []byte(b.String())
+}
+
+// isTombstone checks if a file was written by --tombstones
+// Both keys are checked, as a note of the vault may well say tombstone: true
+func isTombstone(content []byte) bool {
+	values, err := parseFrontmatter(content)
+	tombstone, _ := frontmatterBool(values, "tombstone")
+	return err == nil && tombstone && frontmatterString(values, "generated-by") == tagPageMarker
+}
+
+// liveTombstone checks if a file of the content folder is a stub of --tombstones still within its retention
+func (c *converter) liveTombstone(rel string) bool {
+	if c.tombstones == nil {
+		return false
+	}
+	_, ok := c.tombstonesOf()[rel]
+	return ok
+}
+
+// buryNote replaces a published note about to be deleted with a stub, with --tombstones, and reports whether it did
+// Other files, and notes with --dry-run, which lists the stub instead, are left to be deleted
+func (c *converter) buryNote(p, why string) (bool, error) {
+	if c.tombstones == nil || !hasExt(p, ".md") {
+		return false, nil
+	}
+	rel, err := filepath.Rel(c.contentFolder, p)
+	if err != nil {
+		return false, nil
+	}
+	rel = filepath.ToSlash(rel)
+	if c.opts.dryRun {
+		c.console.infof("  tombstone  %s (%s)", c.paths.dest(p), why)
+		return true, nil
+	}
+	if err := writeTextFile(p, c.tombstone(rel)); err != nil {
+		return false, fmt.Errorf("failed to replace %s with a tombstone: %v", p, err)
+	}
+	c.tombstonesOf()[rel] = c.report.StartedAt
+	c.record(reportEntry{Destination: p, Action: actionTombstoned}, 0)
+	return true, nil
+}
+
+// expireTombstones deletes the stubs of --tombstones older than its retention, and forgets the stubs that are gone
+// or were overwritten by a published note, so a stub never takes the place of real content
+func (c *converter) expireTombstones() error {
+	if c.tombstones == nil {
+		return nil
+	}
+	stubs := c.tombstonesOf()
+	rels := make([]string, 0, len(stubs))
+	for rel := range stubs {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	retention := time.Duration(c.opts.tombstones) * 24 * time.Hour
+	for _, rel := range rels {
+		p := filepath.Join(c.contentFolder, filepath.FromSlash(rel))
+		content, err := os.ReadFile(p)
+		if err != nil || !isTombstone(content) {
+			delete(stubs, rel)
+			continue
+		}
+		if c.report.StartedAt.Sub(stubs[rel]) < retention {
+			continue
+		}
+		if err := c.checkContained(p); err != nil {
+			return fmt.Errorf("%s %w", p, err)
+		}
+		if c.wouldDelete(p, "tombstone older than --tombstones") {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", p, err)
+		}
+		delete(stubs, rel)
+		c.record(reportEntry{Destination: p, Action: actionDeleted}, 0)
+	}
+	return nil
+}
+
+// writeTombstones saves the tombstones file
+func (c *converter) writeTombstones() error {
+	data, err := json.MarshalIndent(tombstonesState{Version: tombstonesVersion, Tombstones: c.tombstones.Tombstones}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the tombstones file: %v", err)
+	}
+	if err := writeTextFile(filepath.Join(c.quartzFolder, tombstonesFileName), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write the tombstones file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package o2q
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readTombstones returns the stubs recorded in the tombstones file of a Quartz folder, for the default content folder
+func readTombstones(t *testing.T, quartz string) map[string]time.Time {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(quartz, tombstonesFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state tombstonesState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	return state.Tombstones["content"]
+}
+
+func TestTombstones(t *testing.T) {
+	vault := writeVault(t, map[string]string{"index.md": "Home\n", "Old.md": "Old note\n", "old.png": "png"})
+	quartz := t.TempDir()
+	args := []string{"-prune", "-tombstones", "30"}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("first run exited with %d", code)
+	}
+
+	// The deleted note is replaced with a stub, the deleted image is deleted
+	for _, name := range []string{"Old.md", "old.png"} {
+		if err := os.Remove(filepath.Join(vault, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run after deleting the note exited with %d", code)
+	}
+	if stub := readContent(t, quartz, "Old.md"); !isTombstone([]byte(stub)) || !strings.Contains(stub, "moved or been removed") {
+		t.Errorf("Old.md = %q, want a tombstone", stub)
+	}
+	if _, err := os.Stat(filepath.Join(quartz, "content", "old.png")); !os.IsNotExist(err) {
+		t.Errorf("old.png kept, want it deleted")
+	}
+	if _, ok := readTombstones(t, quartz)["Old.md"]; !ok {
+		t.Errorf("Old.md not recorded in the tombstones file")
+	}
+
+	// A later run keeps the stub within its retention
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run within the retention exited with %d", code)
+	}
+	readContent(t, quartz, "Old.md")
+
+	// Past its retention, the stub is deleted
+	expired := tombstonesState{Version: tombstonesVersion, Tombstones: map[string]map[string]time.Time{
+		"content": {"Old.md": time.Now().Add(-31 * 24 * time.Hour)},
+	}}
+	data, err := json.Marshal(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(quartz, tombstonesFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run past the retention exited with %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(quartz, "content", "Old.md")); !os.IsNotExist(err) {
+		t.Errorf("Old.md kept past its retention")
+	}
+	if len(readTombstones(t, quartz)) != 0 {
+		t.Errorf("tombstones file = %v, want no stub", readTombstones(t, quartz))
+	}
+}
+
+func TestTombstoneReplacedByNote(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Note.md": "Text\n"})
+	quartz := t.TempDir()
+	args := []string{"-incremental", "-tombstones", "30"}
+	runTestSync(t, vault, quartz, args...)
+	if err := os.Remove(filepath.Join(vault, "Note.md")); err != nil {
+		t.Fatal(err)
+	}
+	runTestSync(t, vault, quartz, args...)
+	if stub := readContent(t, quartz, "Note.md"); !isTombstone([]byte(stub)) {
+		t.Fatalf("Note.md = %q, want a tombstone", stub)
+	}
+
+	// The note coming back takes the place of its stub, which is forgotten
+	if err := os.WriteFile(filepath.Join(vault, "Note.md"), []byte("Back\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run after restoring the note exited with %d", code)
+	}
+	if note := readContent(t, quartz, "Note.md"); note != "Back\n" {
+		t.Errorf("Note.md = %q, want the restored note", note)
+	}
+	if len(readTombstones(t, quartz)) != 0 {
+		t.Errorf("tombstones file = %v, want no stub", readTombstones(t, quartz))
+	}
+}
+
+func TestTombstoneSuccessor(t *testing.T) {
+	vault := writeVault(t, map[string]string{"index.md": "Home\n", "Projects/Roadmap.md": "Plans\n"})
+	quartz := t.TempDir()
+	args := []string{"-prune", "-tombstones", "30", "-redirects", "json"}
+	runTestSync(t, vault, quartz, args...)
+
+	// The stub of a moved note links to its new path
+	if code := runTestSync(t, vault, quartz, append(args, "-map", "Projects=>work")...); code != exitSuccess {
+		t.Fatalf("run moving the note exited with %d", code)
+	}
+	readContent(t, quartz, "work/Roadmap.md")
+	stub := readContent(t, quartz, "Projects/Roadmap.md")
+	if !isTombstone([]byte(stub)) || !strings.Contains(stub, "This page has moved to [Roadmap](../work/Roadmap).") {
+		t.Errorf("Projects/Roadmap.md = %q, want a tombstone linking to ../work/Roadmap", stub)
+	}
+}
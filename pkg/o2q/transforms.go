@@ -0,0 +1,342 @@
+package o2q
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// transformStep is a named step of the pipeline a note goes through
+// Steps run in the order of the pipeline, each one doing nothing unless its option is set
+type transformStep struct {
+	name string
+	run  func(c *converter, src string, content []byte) []byte
+}
+
+// frontmatterSteps edit the frontmatter of a note; a split note goes through them once, for all its pages
+var frontmatterSteps = []transformStep{
+	{"edit-frontmatter", (*converter).editFrontmatter},
+	{"add-title", (*converter).addTitle},
+	{"add-description", (*converter).addDescription},
+	{"collect-tags", (*converter).collectInlineTags},
+	{"redirect-aliases", (*converter).addRedirectAliases},
+	{"normalize-aliases", (*converter).normalizeAliases},
+}
+
+// snippetsStep adds the banner and footer to a note, between its frontmatter and body steps
+// Split notes and generated pages have none
+var snippetsStep = transformStep{"snippets", (*converter).addSnippets}
+
+// bodySteps transform the body of a note and its links, for notes and the pages generated from canvases and split notes
+var bodySteps = []transformStep{
+	// Remove dataview and query blocks, with --strip-dataview
+	{"strip-dataview", func(c *converter, src string, content []byte) []byte {
+		if !c.opts.stripDataview {
+			return content
+		}
+		return stripDataview(content, c.opts.dataviewPlaceholder)
+	}},
+	// Remove HTML comments from the body, with --strip-html-comments
+	{"strip-comments", func(c *converter, src string, content []byte) []byte {
+		if !c.opts.stripHTMLComments {
+			return content
+		}
+		_, body, _ := splitFrontmatter(content)
+		stripped, unclosed := stripHTMLComments(body, false)
+		if unclosed {
+			c.console.warnf("%s: HTML comment never closed, kept from <!-- to the end of the note", src)
+		}
+		return append(content[:len(content)-len(body):len(content)-len(body)], stripped...)
+	}},
+	// Apply --outside-links to links to notes left out of export-note
+	{"outside-links", (*converter).rewriteOutsideLinks},
+	// Replace embeds of notes that are excluded or missing
+	{"missing-embeds", (*converter).rewriteMissingEmbeds},
+	// Apply --unpublished-links to links to notes that are not published
+	{"unpublished-links", (*converter).rewriteUnpublishedLinks},
+	// Point wikilinks to notes at the vault path of the note Obsidian resolves them to, with --resolve-links
+	{"resolve-links", (*converter).resolveLinks},
+	// Point links to split notes at their index page or the part holding the linked heading
+	{"split-links", (*converter).rewriteSplitLinks},
+	// Strip block markers and rewrite block reference links
+	{"block-refs", (*converter).rewriteBlockRefs},
+	// Turn absolute links to the published site into wikilinks
+	{"site-urls", (*converter).rewriteSiteURLs},
+	// Turn obsidian://open links into wikilinks, and report the URLs that only work on this computer
+	{"local-urls", (*converter).rewriteLocalURLs},
+	// Map custom task statuses to a checkbox Quartz shows, and strip the metadata of the Tasks plugin
+	{"tasks", func(c *converter, src string, content []byte) []byte { return c.rewriteTasks(content) }},
+	// Rewrite callout types Quartz does not style, and their fold markers
+	{"callouts", (*converter).rewriteCallouts},
+	// Point heading links at the anchors Quartz gives headings
+	{"heading-links", func(c *converter, src string, content []byte) []byte { return c.rewriteHeadingLinks(content) }},
+	// Report links to files left out by their size or extension, and replace embeds of them
+	{"skipped-embeds", (*converter).rewriteSkippedEmbeds},
+	// Point links to drawings at their SVG export, reporting missing exports
+	{"excalidraw", (*converter).rewriteDrawings},
+	// Rewrite links to canvas files according to the canvas mode
+	{"canvas-links", func(c *converter, src string, content []byte) []byte {
+		return rewriteCanvasLinks(c.console, src, content, c.opts.canvas)
+	}},
+	// Rewrite links to HTML files moved to the static folder or wrapped in a page
+	{"html-links", (*converter).rewriteHTMLLinks},
+	// Rewrite PDF, audio and video embeds that Quartz would render as broken images
+	{"media-embeds", (*converter).rewriteMediaEmbeds},
+	// Rewrite sized image embeds that Quartz would show at full size with the size as caption
+	{"image-sizes", (*converter).rewriteImageSizes},
+	// Point links to files renamed by --sanitize-names at their new name
+	{"renamed-links", (*converter).rewriteRenamedLinks},
+	// Put link targets in the same Unicode form as the published names
+	{"normalize-links", func(c *converter, src string, content []byte) []byte { return c.normalizeLinks(content) }},
+	// Warn about constructs Quartz parses differently than Obsidian, fixing the safe ones with --fix
+	{"lint", (*converter).lintMarkdown},
+}
+
+// transformStepNames lists the steps of the pipeline in the order they run by default
+func transformStepNames() []string {
+	return pipelineStepNames(bodySteps)
+}
+
+// pipelineStepNames lists the steps of the pipeline in the order they run, with the body steps in the given order
+func pipelineStepNames(body []transformStep) []string {
+	var names []string
+	for _, step := range frontmatterSteps {
+		names = append(names, step.name)
+	}
+	names = append(names, snippetsStep.name)
+	for _, step := range body {
+		names = append(names, step.name)
+	}
+	return names
+}
+
+// linkSteps are the body steps writing or reporting links, which read the links as the earlier steps left them
+var linkSteps = []string{
+	"outside-links", "missing-embeds", "unpublished-links", "resolve-links", "split-links", "block-refs", "site-urls",
+	"local-urls", "heading-links", "skipped-embeds", "excalidraw", "canvas-links", "html-links", "media-embeds", "image-sizes",
+}
+
+// stepsBefore lists, for body steps, the steps that must run before them, which the order key cannot change:
+// the text stripped by strip-dataview and strip-comments is not read for links, renamed-links and normalize-links
+// rewrite the targets every link step wrote, heading-links the anchors of the links pointing at notes, and lint
+// checks the note as published
+var stepsBefore = func() map[string][]string {
+	before := map[string][]string{
+		"heading-links":   {"resolve-links", "split-links", "block-refs", "site-urls", "local-urls"},
+		"renamed-links":   linkSteps,
+		"normalize-links": append(append([]string{}, linkSteps...), "renamed-links"),
+	}
+	for _, name := range linkSteps {
+		before[name] = append(before[name], "strip-dataview", "strip-comments")
+	}
+	for _, step := range bodySteps {
+		if step.name != "lint" {
+			before["lint"] = append(before["lint"], step.name)
+		}
+	}
+	return before
+}()
+
+// orderBodySteps returns the body steps in the order of the order key of the config file
+// The steps listed run in the listed order, in the places they hold by default, while the others keep their place:
+// order: [callouts, tasks] runs callouts where tasks runs by default, and tasks where callouts does
+// Only body steps can be listed, once each, and an order running a step before one it relies on is an error
+func orderBodySteps(names []string) ([]transformStep, error) {
+	index := make(map[string]int)
+	var known []string
+	for i, step := range bodySteps {
+		index[step.name] = i
+		known = append(known, step.name)
+	}
+	var slots []int
+	for _, name := range names {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("order: %q is not a body step, use some of %s", name, strings.Join(known, ", "))
+		}
+		if slices.Contains(slots, i) {
+			return nil, fmt.Errorf("order: %s is listed twice", name)
+		}
+		slots = append(slots, i)
+	}
+	sorted := slices.Clone(slots)
+	slices.Sort(sorted)
+	steps := slices.Clone(bodySteps)
+	for i, slot := range sorted {
+		steps[slot] = bodySteps[slots[i]]
+	}
+
+	position := make(map[string]int)
+	for i, step := range steps {
+		position[step.name] = i
+	}
+	for _, step := range steps {
+		for _, earlier := range stepsBefore[step.name] {
+			if position[earlier] > position[step.name] {
+				return nil, fmt.Errorf("order: %s must run after %s, which it relies on", step.name, earlier)
+			}
+		}
+	}
+	return steps, nil
+}
+
+// transformRule turns off steps of the pipeline for the notes matching a pattern, from the rules key of the config file:
+//
+//	rules:
+//	  - match: "Reference/**"
+//	    disable: [strip-comments, collect-tags]
+//	  - match: Handmade/
+//	    only: [excalidraw]
+type transformRule struct {
+	match   []ignorePattern
+	disable map[string]bool
+	only    map[string]bool // Steps kept, the others being turned off; nil keeps them all
+}
+
+// parseTransformRules reads the rules key of the config file
+// Patterns use the syntax of the ignore file, and an unknown step name is an error
+func parseTransformRules(value interface{}) ([]transformRule, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		Match   interface{} `yaml:"match"`
+		Disable []string    `yaml:"disable"`
+		Only    []string    `yaml:"only"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("rules must be a list of rules with match and disable or only: %v", err)
+	}
+
+	known := make(map[string]bool)
+	for _, name := range transformStepNames() {
+		known[name] = true
+	}
+	steps := func(i int, names []string) (map[string]bool, error) {
+		set := make(map[string]bool)
+		for _, name := range names {
+			if !known[name] {
+				return nil, fmt.Errorf("rule %d: unknown transform step %q, use one of %s",
+					i+1, name, strings.Join(transformStepNames(), ", "))
+			}
+			set[name] = true
+		}
+		return set, nil
+	}
+
+	rules := make([]transformRule, len(raw))
+	for i, r := range raw {
+		patterns := configList(r.Match)
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("rule %d has no match pattern", i+1)
+		}
+		if len(r.Disable) == 0 && r.Only == nil {
+			return nil, fmt.Errorf("rule %d turns off no step, give disable or only", i+1)
+		}
+		rule := transformRule{}
+		if rule.match, err = compileIgnorePatterns(patterns, fmt.Sprintf("rule %d", i+1)); err != nil {
+			return nil, err
+		}
+		if rule.disable, err = steps(i, r.Disable); err != nil {
+			return nil, err
+		}
+		if r.Only != nil {
+			if rule.only, err = steps(i, r.Only); err != nil {
+				return nil, err
+			}
+		}
+		rules[i] = rule
+	}
+	return rules, nil
+}
+
+// matches checks if a rule applies to a vault file, matching the file or one of its folders as the ignore file does
+func (r transformRule) matches(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, p := range r.match {
+		if p.matches(relPath, false) {
+			return true
+		}
+		for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+			if p.matches(dir, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// disabledSteps returns the steps the rules turn off for a note; every matching rule applies, in order
+func (c *converter) disabledSteps(src string) map[string]bool {
+	if len(c.transformRules) == 0 {
+		return nil
+	}
+	relPath, err := filepath.Rel(c.obsidianFolder, src)
+	if err != nil {
+		return nil
+	}
+	disabled := make(map[string]bool)
+	for _, r := range c.transformRules {
+		if !r.matches(relPath) {
+			continue
+		}
+		for _, name := range transformStepNames() {
+			if r.disable[name] || (r.only != nil && !r.only[name]) {
+				disabled[name] = true
+			}
+		}
+	}
+	return disabled
+}
+
+// stepEnabled checks if the rules leave a step of the pipeline on for a note
+// Steps may call it, as it does not go through the list of steps as disabledSteps does
+func (c *converter) stepEnabled(src, name string) bool {
+	if len(c.transformRules) == 0 {
+		return true
+	}
+	relPath, err := filepath.Rel(c.obsidianFolder, src)
+	if err != nil {
+		return true
+	}
+	for _, r := range c.transformRules {
+		if r.matches(relPath) && (r.disable[name] || (r.only != nil && !r.only[name])) {
+			return false
+		}
+	}
+	return true
+}
+
+// runSteps runs the steps the rules leave on for a note, in order
+func (c *converter) runSteps(src string, content []byte, steps []transformStep) []byte {
+	disabled := c.disabledSteps(src)
+	for _, step := range steps {
+		if !disabled[step.name] {
+			content = step.run(c, src, content)
+		}
+	}
+	return content
+}
+
+// printSteps lists the steps a note goes through, with --verbose
+func (c *converter) printSteps(src string) {
+	if c.console.verbosity < verbosityVerbose {
+		return
+	}
+	disabled := c.disabledSteps(src)
+	var names []string
+	for _, name := range pipelineStepNames(c.bodySteps) {
+		if !disabled[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	c.console.progressf("Transforms of %s: %s", src, strings.Join(names, ", "))
+}
@@ -0,0 +1,85 @@
+package o2q
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrderBodySteps(t *testing.T) {
+	steps, err := orderBodySteps(nil)
+	if err != nil || len(steps) != len(bodySteps) {
+		t.Fatalf("orderBodySteps(nil) = %d steps, %v", len(steps), err)
+	}
+	for i := range steps {
+		if steps[i].name != bodySteps[i].name {
+			t.Fatalf("orderBodySteps(nil) runs %s at %d, want the default order", steps[i].name, i)
+		}
+	}
+
+	// The steps listed swap places, the others keep theirs
+	steps, err = orderBodySteps([]string{"callouts", "tasks"})
+	if err != nil {
+		t.Fatalf("orderBodySteps() error = %v", err)
+	}
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	if got := strings.Join(names, ","); !strings.Contains(got, "local-urls,callouts,tasks,heading-links") {
+		t.Errorf("orderBodySteps(callouts, tasks) = %s", got)
+	}
+
+	for order, want := range map[string]string{
+		"excalidraw,unknown":           `"unknown" is not a body step`,
+		"add-title":                    `"add-title" is not a body step`,
+		"snippets":                     `"snippets" is not a body step`,
+		"tasks,tasks":                  "tasks is listed twice",
+		"lint,callouts":                "lint must run after",
+		"renamed-links,resolve-links":  "renamed-links must run after resolve-links",
+		"resolve-links,strip-comments": "resolve-links must run after strip-comments",
+	} {
+		if _, err := orderBodySteps(strings.Split(order, ",")); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("orderBodySteps(%s) error = %v, want %q", order, err, want)
+		}
+	}
+}
+
+func TestOrderConfig(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Note.md": "Tasks:\n\n```dataview\nTASK FROM \"Projects\"\n```\n",
+	})
+	args := []string{"-strip-dataview", "-dataview-placeholder", "<!-- dataview -->", "-strip-html-comments"}
+
+	// By default the placeholder left by strip-dataview is a comment strip-comments removes
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz, args...); code != exitSuccess {
+		t.Fatalf("run exited with %d", code)
+	}
+	if got := readContent(t, quartz, "Note.md"); strings.Contains(got, "<!--") {
+		t.Errorf("Note.md = %q, want the placeholder removed", got)
+	}
+
+	// Run the other way around, the placeholder stays
+	path := writeConfig(t, "order: [strip-comments, strip-dataview]\n")
+	var opts options
+	cfg, err := loadConfig(console, path, newOptionRegistry(&opts), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = testOptions(t, append([]string{"-quiet", "-self-check"}, args...)...)
+	sources := []vaultSource{{folder: vault, sub: "."}}
+	if err := checkOptions(console, &opts, cfg, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	quartz = t.TempDir()
+	if code := runSources(console, opts, cfg, sources, quartz, ""); code != exitSuccess {
+		t.Fatalf("run with the order key exited with %d", code)
+	}
+	if got := readContent(t, quartz, "Note.md"); !strings.Contains(got, "<!-- dataview -->") {
+		t.Errorf("with strip-comments first, Note.md = %q, want the placeholder kept", got)
+	}
+
+	if _, err := loadConfig(console, writeConfig(t, "order: [lint, tasks]\n"), newOptionRegistry(&opts), nil, true); err == nil {
+		t.Error("loadConfig() of an order running lint early error = nil")
+	}
+}
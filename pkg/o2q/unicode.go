@@ -0,0 +1,122 @@
+package o2q
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Unicode normalization forms for destination names and link targets
+const (
+	unicodeNFC  = "nfc"  // Composed: é is one code point, as typed on most systems
+	unicodeNFD  = "nfd"  // Decomposed: é is e followed by a combining accent, as stored by macOS
+	unicodeNone = "none" // Names are published as they are stored in the vault
+)
+
+// markdownTargetRe matches the target of a markdown link or embed: [text](target) or [text](<target>)
+var markdownTargetRe = regexp.MustCompile(`(!?\[[^\]]*\]\()(<[^>\n]+>|[^)\s]+)`)
+
+// normalizeName returns a name or path in the form chosen with --normalize-unicode
+func (c *converter) normalizeName(name string) string {
+	switch c.opts.normalizeUnicode {
+	case unicodeNFC:
+		return norm.NFC.String(name)
+	case unicodeNFD:
+		return norm.NFD.String(name)
+	}
+	return name
+}
+
+// claimDest records that a vault file is published to dest
+// Two vault files whose names only differ in their Unicode form, that --map moves to the same place,
+// or of two sources sharing a subfolder are published to the same dest; the second one is refused
+func (c *converter) claimDest(src, dest string) error {
+	if c.destOwners == nil {
+		c.destOwners = make(map[string]string)
+	}
+	if owner, ok := c.destOwners[dest]; ok && owner != src {
+		if c.normalizeName(owner) == c.normalizeName(src) {
+			return fmt.Errorf("has the same name as %s once normalized to %s (the names are stored as %s and %s); not published, rename one of them",
+				c.paths.source(owner), strings.ToUpper(c.opts.normalizeUnicode), unicodeForm(src), unicodeForm(owner))
+		}
+		cause := ""
+		rel, err := filepath.Rel(c.obsidianFolder, owner)
+		srcRel, _ := filepath.Rel(c.obsidianFolder, src)
+		if err != nil || !filepath.IsLocal(rel) {
+			cause = " from another source"
+		} else if c.dated[filepath.ToSlash(rel)] || c.dated[filepath.ToSlash(srcRel)] {
+			cause = " by --date-folders"
+		} else if len(c.folderMap) > 0 {
+			cause = " by the folder map"
+		}
+		return fmt.Errorf("is published to the same path as %s%s; not published, rename one of them",
+			c.paths.source(owner), cause)
+	}
+	c.destOwners[dest] = src
+	return nil
+}
+
+// unicodeForm describes the Unicode form a name is stored in, to tell apart names that look the same
+func unicodeForm(name string) string {
+	switch {
+	case norm.NFC.IsNormalString(name) && norm.NFD.IsNormalString(name):
+		return "either form"
+	case norm.NFC.IsNormalString(name):
+		return "NFC"
+	case norm.NFD.IsNormalString(name):
+		return "NFD"
+	}
+	return "a mix of forms"
+}
+
+// normalizeLinks puts the targets of wikilinks and markdown links in the same Unicode form as the published names,
+// so a link typed as [[Ménage]] finds Ménage.md whatever the form of either
+// Code blocks and inline code are left untouched
+func (c *converter) normalizeLinks(content []byte) []byte {
+	if c.opts.normalizeUnicode == unicodeNone {
+		return content
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, c.normalizeName)
+		return markdownTargetRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := markdownTargetRe.FindStringSubmatch(match)
+			target := parts[2]
+			if isExternalURL(strings.Trim(target, "<>")) {
+				return match
+			}
+			if !strings.Contains(target, "%") {
+				return parts[1] + c.normalizeName(target)
+			}
+
+			// Percent-encoded targets are normalized decoded, then encoded again, fragment included
+			decoded, err := url.PathUnescape(target)
+			if err != nil || c.normalizeName(decoded) == decoded {
+				return match
+			}
+			file, fragment, found := strings.Cut(target, "#")
+			normalized := c.normalizeEncoded(file, "/")
+			if found {
+				normalized += "#" + c.normalizeEncoded(fragment, "#")
+			}
+			return parts[1] + normalized
+		})
+	})
+}
+
+// normalizeEncoded normalizes a percent-encoded text, encoding again each of its parts separated by sep
+func (c *converter) normalizeEncoded(text, sep string) string {
+	decoded, err := url.PathUnescape(text)
+	if err != nil {
+		return text
+	}
+	segments := strings.Split(c.normalizeName(decoded), sep)
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, sep)
+}
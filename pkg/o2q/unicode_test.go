@@ -0,0 +1,93 @@
+package o2q
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	menageNFC = "M\u00e9nage"  // Composed, as typed on most systems
+	menageNFD = "Me\u0301nage" // Decomposed, as stored by macOS
+)
+
+func TestNormalizeLinks(t *testing.T) {
+	content := "[[" + menageNFD + "]] [[" + menageNFD + "#Cafe\u0301|" + menageNFD + "]] " +
+		"[m](" + menageNFD + ".md) [m](Me%CC%81nage.md#caf%C3%A9) [m](<" + menageNFD + ".md>) " +
+		"[web](https://example.com/Me%CC%81nage) `[[" + menageNFD + "]]`\n"
+	tests := []struct {
+		form string
+		want string
+	}{
+		{unicodeNFC, "[[" + menageNFC + "]] [[" + menageNFC + "#Caf\u00e9|" + menageNFC + "]] " +
+			"[m](" + menageNFC + ".md) [m](M%C3%A9nage.md#caf%C3%A9) [m](<" + menageNFC + ".md>) " +
+			"[web](https://example.com/Me%CC%81nage) `[[" + menageNFD + "]]`\n"},
+		{unicodeNone, content},
+	}
+	for _, tt := range tests {
+		c := &converter{opts: options{normalizeUnicode: tt.form}}
+		if got := string(c.normalizeLinks([]byte(content))); got != tt.want {
+			t.Errorf("normalizeLinks() with %s = %q, want %q", tt.form, got, tt.want)
+		}
+	}
+
+	c := &converter{opts: options{normalizeUnicode: unicodeNFD}}
+	if got := string(c.normalizeLinks([]byte("[[" + menageNFC + "]]"))); got != "[["+menageNFD+"]]" {
+		t.Errorf("normalizeLinks() with nfd = %q, want %q", got, "[["+menageNFD+"]]")
+	}
+}
+
+func TestUnicodeForm(t *testing.T) {
+	for name, want := range map[string]string{
+		"Menage":                    "either form",
+		menageNFC:                   "NFC",
+		menageNFD:                   "NFD",
+		menageNFC + " " + menageNFD: "a mix of forms",
+	} {
+		if got := unicodeForm(name); got != want {
+			t.Errorf("unicodeForm(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		"Notes/" + menageNFD + ".md": "Chores\n",
+		"Home.md":                    "See [[" + menageNFC + "]] and [list](Notes/M%C3%A9nage.md).\n",
+	})
+	for _, form := range []string{unicodeNFC, unicodeNFD} {
+		quartz := t.TempDir()
+		if code := runTestSync(t, vault, quartz, "-normalize-unicode", form); code != exitSuccess {
+			t.Fatalf("%s: run exited with %d", form, code)
+		}
+		c := &converter{opts: options{normalizeUnicode: form}}
+		entries, err := os.ReadDir(filepath.Join(quartz, "content", "Notes"))
+		if err != nil || len(entries) != 1 || entries[0].Name() != c.normalizeName(menageNFC)+".md" {
+			t.Errorf("%s: published %v, %v, want the note named in %s", form, entries, err, form)
+		}
+		if home := readContent(t, quartz, "Home.md"); !strings.Contains(home, "[["+c.normalizeName(menageNFC)+"]]") {
+			t.Errorf("%s: Home.md = %q, want the link in %s", form, home, form)
+		}
+	}
+}
+
+// Names that only differ in their Unicode form are published to the same file: the second one is refused
+func TestUnicodeCollision(t *testing.T) {
+	vault := writeVault(t, map[string]string{
+		menageNFC + ".md": "Composed\n",
+		menageNFD + ".md": "Decomposed\n",
+	})
+	quartz := t.TempDir()
+	if code := runTestSync(t, vault, quartz); code == exitSuccess {
+		t.Errorf("run exited with %d, want the collision reported as an error", code)
+	}
+	entries, err := os.ReadDir(filepath.Join(quartz, "content"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("published %v, %v, want one note", entries, err)
+	}
+	// Vault files are walked in byte order, so the decomposed name, which sorts first, wins
+	if got := readContent(t, quartz, entries[0].Name()); got != "Decomposed\n" {
+		t.Errorf("published note = %q, want the first of the two", got)
+	}
+}
@@ -0,0 +1,230 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Handling of links to notes of the vault that are not published
+const (
+	unpublishedLinksKeep   = "keep"   // Leave the link, which Quartz shows as a dead link giving away the note title
+	unpublishedLinksUnlink = "unlink" // Replace the link with its text
+	unpublishedLinksRemove = "remove" // Remove the link and its text
+)
+
+// unpublishedLink is a link of a published note to a note of the vault that is not published, listed in the summary
+type unpublishedLink struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Target string `json:"target"` // Link target as written
+	Note   string `json:"note"`   // Vault path of the note linked to
+}
+
+// planUnpublishedLinks finds the notes of the vault the plan leaves out, whether ignored, filtered or unpublished,
+// then warns about every link of a published note to one of them, before anything is written
+// Links to notes missing from the vault are not reported, Quartz showing them as it shows notes not written yet
+func (c *converter) planUnpublishedLinks() error {
+	if c.exportFiles != nil {
+		return nil
+	}
+	published := make(map[string]bool)
+	var sources []plannedFile
+	for _, f := range c.plan.files {
+		if !hasExt(f.relPath, ".md") {
+			continue
+		}
+		file := filepath.ToSlash(f.relPath)
+		switch f.action {
+		case planPublish, planUnchangedGit, planUnchangedState:
+			published[file] = !c.unpublishedNotes[file]
+			sources = append(sources, f)
+		case actionError:
+			// Reported as an error already; the note was meant to be published
+			published[file] = true
+		}
+	}
+
+	c.vaultNotes = make(map[string]string)
+	c.vaultNotesByName = make(map[string][]string)
+	c.excludedNotes = make(map[string]bool)
+	err := c.walkVault(func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if src == c.obsidianFolder {
+			return nil
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		relPath, err := filepath.Rel(c.obsidianFolder, src)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		// Drawings are published as their SVG export, which --missing-drawings deals with
+		if info.IsDir() || !hasExt(relPath, ".md") || isInExcalidrawFolder(relPath) || hasExt(relPath, ".excalidraw.md") {
+			return nil
+		}
+		file := filepath.ToSlash(relPath)
+		c.vaultNotes[noteKey(file)] = file
+		c.vaultNotesByName[path.Base(noteKey(file))] = append(c.vaultNotesByName[path.Base(noteKey(file))], file)
+		if !published[file] {
+			c.excludedNotes[file] = true
+		}
+		return nil
+	})
+	if err != nil || len(c.excludedNotes) == 0 {
+		return err
+	}
+
+	for _, f := range sources {
+		if f.info != nil && c.tooLargeToTransform(f.info.Size()) {
+			continue
+		}
+		content, err := c.vault.ReadFile(f.src)
+		if err != nil {
+			return fmt.Errorf("failed to read markdown file: %v", err)
+		}
+		c.findUnpublishedLinks(f.src, content)
+	}
+	return nil
+}
+
+// findUnpublishedLinks warns about the links of a note to notes that are not published, with their line
+// Embeds are left to --missing-embeds, and code blocks and inline code are skipped
+func (c *converter) findUnpublishedLinks(src string, content []byte) {
+	noteDir := c.noteDir(src)
+	fence := ""
+	for i, line := range splitLines(content) {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker, _, ok := parseFence(line); ok {
+			fence = marker
+			continue
+		}
+		mapOutsideCodeSpans(line, func(text string) string {
+			for _, link := range noteLinks(text) {
+				if note, ok := c.unpublishedTarget(noteDir, link.decodedTarget()); ok {
+					c.report.UnpublishedLinks = append(c.report.UnpublishedLinks, unpublishedLink{
+						Source: c.paths.source(src),
+						Line:   i + 1,
+						Target: link.target,
+						Note:   note,
+					})
+					c.console.warnf("%s:%d: links to %s, which is not published", src, i+1, note)
+				}
+			}
+			return text
+		})
+	}
+}
+
+// noteLinks returns the wikilinks and markdown links of a piece of text that may point to a note
+// Embeds, links to a section of the same note and external URLs are left out
+func noteLinks(text string) []fileLink {
+	var links []fileLink
+	for _, parts := range noteWikiLinkRe.FindAllStringSubmatch(text, -1) {
+		if parts[1] == "" && parts[2] != "" && isNoteTarget(parts[2]) {
+			links = append(links, fileLink{wiki: true, target: parts[2], fragment: parts[3], text: parts[4]})
+		}
+	}
+	for _, parts := range markdownLinkRe.FindAllStringSubmatch(text, -1) {
+		link := fileLink{target: strings.Trim(parts[3], "<>"), angle: strings.HasPrefix(parts[3], "<"), text: parts[2]}
+		if parts[1] != "" || isExternalURL(link.target) {
+			continue
+		}
+		link.target, link.fragment, _ = strings.Cut(link.target, "#")
+		if link.target != "" && isNoteTarget(link.decodedTarget()) {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// unpublishedTarget returns the vault path of the note a link points to when that note is not published
+// Targets are matched as Obsidian does: relative to the note, from the vault root, then as the end of a path in any
+// folder, so [[Private Meeting Notes]] points to Work/Private Meeting Notes.md; a name is taken as unpublished only
+// when every note it matches is
+func (c *converter) unpublishedTarget(noteDir, target string) (string, bool) {
+	if len(c.excludedNotes) == 0 || target == "" {
+		return "", false
+	}
+	target = filepath.ToSlash(target)
+	if !hasExt(target, ".md") {
+		target += ".md"
+	}
+
+	for _, candidate := range []string{path.Join(noteDir, target), path.Clean(target)} {
+		if file, ok := c.vaultNotes[noteKey(candidate)]; ok {
+			return file, c.excludedNotes[file]
+		}
+	}
+
+	suffix := "/" + noteKey(path.Clean(target))
+	found := ""
+	for _, file := range c.vaultNotesByName[path.Base(suffix)] {
+		if !strings.HasSuffix("/"+noteKey(file), suffix) {
+			continue
+		}
+		if !c.excludedNotes[file] {
+			return "", false
+		}
+		if found == "" {
+			found = file
+		}
+	}
+	return found, found != ""
+}
+
+// rewriteUnpublishedLinks applies --unpublished-links to the links of a note to notes that are not published
+//   - unlink: [[Private Meeting Notes|the meeting]] → the meeting, [[Private Meeting Notes]] → Private Meeting Notes
+//   - remove: the link is removed with its text
+//
+// Embeds, code blocks and inline code are left untouched; the links were reported when the run was planned
+func (c *converter) rewriteUnpublishedLinks(src string, content []byte) []byte {
+	if c.opts.unpublishedLinks == unpublishedLinksKeep || len(c.excludedNotes) == 0 {
+		return content
+	}
+
+	noteDir := c.noteDir(src)
+	replace := func(match string, link fileLink) string {
+		if _, ok := c.unpublishedTarget(noteDir, link.decodedTarget()); !ok {
+			return match
+		}
+		if c.opts.unpublishedLinks == unpublishedLinksRemove {
+			return ""
+		}
+		return link.displayText()
+	}
+
+	return mapOutsideCode(content, func(text string) string {
+		text = noteWikiLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			parts := noteWikiLinkRe.FindStringSubmatch(match)
+			if parts[1] != "" || parts[2] == "" || !isNoteTarget(parts[2]) {
+				return match
+			}
+			return replace(match, fileLink{wiki: true, target: parts[2], fragment: parts[3], text: parts[4]})
+		})
+		return markdownLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+			links := noteLinks(match)
+			if len(links) != 1 {
+				return match
+			}
+			return replace(match, links[0])
+		})
+	})
+}
+
+// noteKey returns the form vault paths are compared in, as Obsidian does: lowercased, in Unicode NFC form
+func noteKey(file string) string {
+	return strings.ToLower(norm.NFC.String(file))
+}
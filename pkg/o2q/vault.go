@@ -0,0 +1,137 @@
+package o2q
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// toolFileNames are the files the tool reads or writes, never published wherever they are in the vault,
+// such as the ignore file of a vault kept inside another or the state file of a Quartz folder inside the vault
+var toolFileNames = map[string]bool{
+	configFileName:         true,
+	ignoreFileName:         true,
+	stateFileName:          true,
+	redirectsStateFileName: true,
+	pinsFileName:           true,
+	tombstonesFileName:     true,
+	lockFileName:           true,
+	vaultIDFileName:        true,
+}
+
+// isToolFile checks if a vault file is one of the files of the tool
+func isToolFile(relPath string) bool {
+	return toolFileNames[filepath.Base(relPath)]
+}
+
+// isNestedVault checks if a folder of the vault is the root of another vault, holding its own .obsidian folder,
+// which --skip-nested-vaults leaves out with everything in it
+func (c *converter) isNestedVault(dir string) bool {
+	if !c.opts.skipNestedVaults || dir == c.obsidianFolder {
+		return false
+	}
+	info, err := c.vault.Stat(filepath.Join(dir, ".obsidian"))
+	return err == nil && info.IsDir()
+}
+
+// indexFiles collects the vault-relative paths of all files that will be published
+func (c *converter) indexFiles() error {
+	return c.walkEligible(func(relPath string) error {
+		c.vaultFiles = append(c.vaultFiles, filepath.ToSlash(relPath))
+		return nil
+	})
+}
+
+// walkEligible calls fn with the relative path of every file that passes the folder, ignore and Excalidraw rules,
+// and is not a conflict copy or a temporary file
+// No file is read unless --filter needs it, so this is cheap enough to run before the conversion itself
+func (c *converter) walkEligible(fn func(relPath string) error) error {
+	return c.walkVault(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == c.obsidianFolder {
+			return nil
+		}
+		relPath, err := filepath.Rel(c.obsidianFolder, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if ignored, _ := c.ignored(relPath, info.IsDir()); ignored {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() && c.isNestedVault(path) {
+			return filepath.SkipDir
+		}
+		if isToolFile(relPath) && !info.IsDir() {
+			return nil
+		}
+		if isInExcalidrawFolder(relPath) && !info.IsDir() && !hasExt(path, ".svg") && !c.rendersDrawing(path) {
+			return nil
+		}
+		if !info.IsDir() && (c.filtered(relPath) || c.assetSkipped(relPath) != nil || c.conflictKind(info.Name()) != "") {
+			return nil
+		}
+		if !c.inExport(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return fn(relPath)
+		}
+		return nil
+	})
+}
+
+// noteDir returns the vault-relative folder of a note, using forward slashes
+func (c *converter) noteDir(src string) string {
+	relPath, err := filepath.Rel(c.obsidianFolder, src)
+	if err != nil {
+		return "."
+	}
+	return path.Dir(filepath.ToSlash(relPath))
+}
+
+// resolveLink finds the vault-relative path of the file a link points to
+func (c *converter) resolveLink(noteDir, target string) (string, bool) {
+	return resolveFile(c.vaultFiles, noteDir, target)
+}
+
+// resolveFile finds the file of files (relative paths with forward slashes) a link points to
+// The target is a decoded file path, see fileLink.decodedTarget
+// It is looked up relative to the note, then relative to the root, then by file name
+// Names are compared in Unicode NFC form, so a link typed on one system matches a file named on another
+func resolveFile(files []string, noteDir, target string) (string, bool) {
+	target = norm.NFC.String(filepath.ToSlash(target))
+
+	for _, candidate := range []string{path.Join(noteDir, target), path.Clean(target)} {
+		for _, file := range files {
+			if norm.NFC.String(file) == candidate {
+				return file, true
+			}
+		}
+	}
+
+	var found []string
+	for _, file := range files {
+		if norm.NFC.String(path.Base(file)) == path.Base(target) {
+			found = append(found, file)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,120 @@
+package o2q
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// vaultFS is the file system the vault is read from: its folder, or a zip archive of it read without unpacking it
+// The paths of a run start with root, the folder or archive given, as if the archive were a folder;
+// paths outside root, such as an --ignore-file kept elsewhere, are read from the disk
+// The destination side always uses the disk
+type vaultFS struct {
+	root    string
+	fsys    fs.FS
+	archive *zip.ReadCloser // Open archive; nil for a folder
+}
+
+// isVaultArchive checks if the vault is given as a zip archive rather than a folder
+func isVaultArchive(vault string) bool {
+	return hasExt(vault, ".zip")
+}
+
+// openVault opens the vault folder, or the zip archive of the vault
+// An archive whose files are all in a single folder, as when a vault folder is zipped, is read from that folder;
+// the __MACOSX folder the Finder adds to the archives it makes is not counted
+func openVault(root string) (*vaultFS, error) {
+	if !isVaultArchive(root) {
+		return &vaultFS{root: root, fsys: os.DirFS(root)}, nil
+	}
+	archive, err := zip.OpenReader(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vault archive: %v", err)
+	}
+	v := &vaultFS{root: root, fsys: archive, archive: archive}
+	entries, err := fs.ReadDir(archive, ".")
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("failed to read vault archive: %v", err)
+	}
+	var folders []fs.DirEntry
+	for _, entry := range entries {
+		if entry.Name() != "__MACOSX" {
+			folders = append(folders, entry)
+		}
+	}
+	if len(folders) == 1 && folders[0].IsDir() {
+		if v.fsys, err = fs.Sub(archive, folders[0].Name()); err != nil {
+			archive.Close()
+			return nil, fmt.Errorf("failed to read vault archive: %v", err)
+		}
+	}
+	return v, nil
+}
+
+// Close closes the archive of the vault, if any
+func (v *vaultFS) Close() error {
+	if v.archive == nil {
+		return nil
+	}
+	return v.archive.Close()
+}
+
+// name returns the name of a path of the run in the file system of the vault; ok is false for paths outside root
+func (v *vaultFS) name(p string) (string, bool) {
+	rel, err := filepath.Rel(v.root, p)
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return "", false
+	}
+	name := path.Clean(filepath.ToSlash(rel))
+	return name, fs.ValidPath(name)
+}
+
+// ReadFile reads a file of the vault
+func (v *vaultFS) ReadFile(p string) ([]byte, error) {
+	name, ok := v.name(p)
+	if !ok {
+		return os.ReadFile(p)
+	}
+	return fs.ReadFile(v.fsys, name)
+}
+
+// Open opens a file of the vault for reading
+func (v *vaultFS) Open(p string) (fs.File, error) {
+	name, ok := v.name(p)
+	if !ok {
+		return os.Open(p)
+	}
+	return v.fsys.Open(name)
+}
+
+// Stat returns the information of a file of the vault, following symbolic links
+func (v *vaultFS) Stat(p string) (fs.FileInfo, error) {
+	name, ok := v.name(p)
+	if !ok {
+		return os.Stat(p)
+	}
+	return fs.Stat(v.fsys, name)
+}
+
+// Lstat returns the information of a file of the vault, describing a symbolic link itself
+// io/fs has no Lstat, so a folder is read from the disk; an archive holds no links
+func (v *vaultFS) Lstat(p string) (fs.FileInfo, error) {
+	if v.archive == nil {
+		return os.Lstat(p)
+	}
+	return v.Stat(p)
+}
+
+// ReadDir lists a folder of the vault, sorted by name; the information of the entries describes symbolic links themselves
+func (v *vaultFS) ReadDir(p string) ([]fs.DirEntry, error) {
+	name, ok := v.name(p)
+	if !ok {
+		return os.ReadDir(p)
+	}
+	return fs.ReadDir(v.fsys, name)
+}
@@ -0,0 +1,145 @@
+package o2q
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// zipVault writes the files of a vault folder to a zip archive, under prefix, and returns the archive
+func zipVault(t *testing.T, vault, prefix string) string {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "Vault.zip")
+	out, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(out)
+	err = filepath.WalkDir(vault, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(vault, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		f, err := w.Create(prefix + filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "" {
+		// The Finder adds the resource forks of the files next to the zipped folder
+		if _, err := w.Create("__MACOSX/" + prefix + "._index.md"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// The fixture vault read from an archive is published as from its folder
+func TestFixtureArchive(t *testing.T) {
+	for name, prefix := range map[string]string{"zipped folder": "MyVault/", "files at the root": ""} {
+		t.Run(name, func(t *testing.T) {
+			archive := zipVault(t, fixtureVault, prefix)
+			quartz := t.TempDir()
+			if code := runTestSync(t, archive, quartz); code != exitSuccess {
+				t.Fatalf("sync of the fixture archive exited with %d", code)
+			}
+			compareTree(t, readTree(t, filepath.Join(quartz, "content")), readTree(t, fixtureExpected))
+		})
+	}
+}
+
+func TestVaultFS(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "vault")
+	outside := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(outside, []byte("Drafts/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := &vaultFS{root: root, fsys: fstest.MapFS{
+		"index.md":       {Data: []byte("Home\n")},
+		"Notes/B.md":     {Data: []byte("B\n")},
+		"Notes/A.md":     {Data: []byte("A\n")},
+		".obsidian/a.js": {Data: []byte("{}")},
+	}}
+
+	if data, err := v.ReadFile(filepath.Join(root, "Notes", "A.md")); err != nil || string(data) != "A\n" {
+		t.Errorf("ReadFile(Notes/A.md) = %q, %v", data, err)
+	}
+	entries, err := v.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := strings.Join(names, ","); got != ".obsidian,Notes,index.md" {
+		t.Errorf("ReadDir(root) = %s, want the entries sorted by name", got)
+	}
+	if info, err := v.Stat(filepath.Join(root, "Notes")); err != nil || !info.IsDir() {
+		t.Errorf("Stat(Notes) = %v, %v, want a folder", info, err)
+	}
+	f, err := v.Open(filepath.Join(root, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "Home\n" {
+		t.Errorf("Open(index.md) read %q, %v", data, err)
+	}
+	if _, err := v.ReadFile(filepath.Join(root, "Missing.md")); !os.IsNotExist(err) {
+		t.Errorf("ReadFile(Missing.md) error = %v, want a missing file", err)
+	}
+
+	// Paths outside the vault, such as an ignore file kept elsewhere, are read from the disk
+	if data, err := v.ReadFile(outside); err != nil || string(data) != "Drafts/\n" {
+		t.Errorf("ReadFile(%s) = %q, %v, want the file on disk", outside, data, err)
+	}
+}
+
+func TestVaultArchiveOptions(t *testing.T) {
+	archive := zipVault(t, writeVault(t, map[string]string{"index.md": "Home\n"}), "")
+	sources := []vaultSource{{folder: archive, sub: "."}}
+	for _, args := range [][]string{{"-since-git", "HEAD~1"}, {"-write-ref", "published"}, {"-watch"}, {"-link-mode", "hardlink"}} {
+		opts := testOptions(t, args...)
+		if err := checkOptions(console, &opts, config{}, sources, ""); err == nil || !strings.Contains(err.Error(), "not an archive") && !strings.Contains(err.Error(), "can only be copied") {
+			t.Errorf("checkOptions(%q) of an archive error = %v, want a refusal", args, err)
+		}
+	}
+
+	if _, err := parseSources([]string{archive + ":blog"}, false, ""); err != nil {
+		t.Errorf("parseSources() of an archive error = %v", err)
+	}
+	folder := filepath.Join(t.TempDir(), "Folder.zip")
+	if err := os.Mkdir(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{folder + ":blog", filepath.Join(t.TempDir(), "Missing.zip") + ":blog"} {
+		if _, err := parseSources([]string{spec}, false, ""); err == nil || !strings.Contains(err.Error(), "is not a zip archive") {
+			t.Errorf("parseSources(%q) error = %v, want no archive", spec, err)
+		}
+	}
+}
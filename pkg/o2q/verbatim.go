@@ -0,0 +1,61 @@
+package o2q
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// defaultMaxTransformSize is the size above which notes are copied as they are, as transforming them
+// takes several times their size in memory
+const defaultMaxTransformSize = 10 << 20
+
+// binarySniffSize is how much of a note is read to tell if it holds binary data
+const binarySniffSize = 8 << 10
+
+// looksBinary checks if a file has a NUL byte in its first 8KB, which text never has
+// A sync conflict or a backup plugin may give a binary file, such as an Excalidraw backup, a .md name
+func (c *converter) looksBinary(src string) (bool, error) {
+	file, err := c.vault.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	buf := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(buf[:n], 0) >= 0, nil
+}
+
+// tooLargeToTransform checks if a note of the given size is larger than --max-transform-size
+func (c *converter) tooLargeToTransform(size int64) bool {
+	return c.opts.maxTransformSize > 0 && size > c.opts.maxTransformSize
+}
+
+// copyUntransformed copies a note as it is when it holds binary data or is larger than --max-transform-size,
+// with a warning, instead of reading it whole to transform it; returns false for the notes to transform
+func (c *converter) copyUntransformed(src, dest string) (bool, error) {
+	info, err := c.vault.Stat(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat markdown file: %v", err)
+	}
+	binary, err := c.looksBinary(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to read markdown file: %v", err)
+	}
+
+	switch {
+	case binary:
+		c.console.warnf("%s: holds binary data rather than text, copied as it is", src)
+		c.addReportNote(src, "binary data, copied as it is")
+	case c.tooLargeToTransform(info.Size()):
+		c.console.warnf("%s: %s of markdown, larger than --max-transform-size %s; copied as it is, without its links rewritten",
+			src, formatSize(info.Size()), formatSize(c.opts.maxTransformSize))
+		c.addReportNote(src, "larger than --max-transform-size, copied as it is")
+	default:
+		return false, nil
+	}
+	return true, c.copyFile(src, dest)
+}
@@ -0,0 +1,17 @@
+package o2q
+
+import "runtime/debug"
+
+// version is the release version passed to Main, set when building a release: go build -ldflags "-X main.version=v1.2.0"
+var version = ""
+
+// buildVersion returns the version of the program: the release version, or the module version of go install
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,259 @@
+package o2q
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long the vault must stay unchanged before --watch syncs, so that saving several files,
+// or an editor writing a file in several steps, starts a single sync
+const watchSettle = time.Second
+
+// watcher syncs a vault each time its files change, until it receives SIGINT or SIGTERM
+// Every sync is an --incremental run, so only the files that changed, and the notes linking to them, are published
+// again; changes made while a sync runs start another one when it finishes, and a failed sync does not stop the watch
+//
+// The first sync reads every folder of the vault, and the next ones only the folders where something changed,
+// taking the others from the listing: the plan still holds every file, as a note can change how others publish
+// (links resolved by name, shared names, tag pages, folder indexes), but unchanged files are left as they are
+// without reading the vault again. A lost event, as when many files change at once, reads every folder again
+type watcher struct {
+	console      *consoleLogger
+	folder       string // Vault folder
+	quartzFolder string // Changes inside it are the writes of the syncs themselves, when it is inside the vault
+	sync         func() bool
+	events       *fsnotify.Watcher
+	listing      *vaultListing
+	failures     int // Consecutive failed syncs
+}
+
+// vaultListing keeps the entries of the watched folders of a vault as the syncs of --watch read them, so that a sync
+// only reads again the folders where something changed since; the folders that are not watched, such as those
+// reached through a symbolic link, are read by every sync
+// The watcher adds folders while a sync reads the listing, but only forgets folders between syncs, so that a sync
+// never keeps entries read before a change it has not seen
+type vaultListing struct {
+	mu      sync.Mutex
+	watched map[string]bool          // Folders whose changes are reported
+	folders map[string][]folderEntry // Entries of the watched folders, as readFolder sorts them
+}
+
+// newVaultListing returns an empty listing, where every folder is read by the next sync
+func newVaultListing() *vaultListing {
+	return &vaultListing{watched: make(map[string]bool), folders: make(map[string][]folderEntry)}
+}
+
+// watch records that the changes of a folder are reported, so its entries can be kept
+func (l *vaultListing) watch(folder string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.watched[filepath.Clean(folder)] = true
+}
+
+// entries returns the entries of a folder kept since it was last read, if any
+func (l *vaultListing) entries(folder string) ([]folderEntry, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries, ok := l.folders[filepath.Clean(folder)]
+	return entries, ok
+}
+
+// keep records the entries of a watched folder just read
+func (l *vaultListing) keep(folder string, entries []folderEntry) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if folder = filepath.Clean(folder); l.watched[folder] {
+		l.folders[folder] = entries
+	}
+}
+
+// forget drops the entries of the folder holding a changed path, and those of the path and the folders inside it
+// when it is a folder, so the next sync reads them again
+func (l *vaultListing) forget(p string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p = filepath.Clean(p)
+	delete(l.folders, filepath.Dir(p))
+	for folder := range l.folders {
+		if folder == p || strings.HasPrefix(folder, p+string(filepath.Separator)) {
+			delete(l.folders, folder)
+		}
+	}
+}
+
+// newWatcher starts watching the folders of a vault
+func newWatcher(log *consoleLogger, folder, quartzFolder string, sync func() bool) (*watcher, error) {
+	events, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch the vault: %v", err)
+	}
+	w := &watcher{console: log, folder: filepath.Clean(folder), quartzFolder: filepath.Clean(quartzFolder), sync: sync, events: events,
+		listing: newVaultListing()}
+	if err := w.add(w.folder); err != nil {
+		events.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// add watches a folder and the folders inside it, as changes are only reported for the files of a watched folder
+func (w *watcher) add(root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.ignored(p, true) {
+			return filepath.SkipDir
+		}
+		if err := w.events.Add(p); err != nil {
+			return fmt.Errorf("failed to watch %s: %v", p, err)
+		}
+		w.listing.watch(p)
+		return nil
+	})
+}
+
+// ignored checks if a change leaves the site as it is: hidden folders, the Quartz folder and the temporary files
+// of editors, which change on every keystroke, are never published
+func (w *watcher) ignored(p string, dir bool) bool {
+	if rel, err := filepath.Rel(w.quartzFolder, p); err == nil && (rel == "." || filepath.IsLocal(rel)) {
+		return true
+	}
+	name := filepath.Base(p)
+	if dir {
+		return p != w.folder && strings.HasPrefix(name, ".")
+	}
+	for _, r := range conflictRules {
+		if r.kind == conflictTemporary && r.re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// run syncs immediately, then each time the vault changes, until stopped
+func (w *watcher) run() {
+	defer w.events.Close()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	var done chan bool // Receives the result of the running sync, nil when idle
+	start := func() {
+		done = make(chan bool, 1)
+		go func() {
+			done <- w.sync()
+		}()
+	}
+
+	settle := time.NewTimer(watchSettle)
+	settle.Stop()
+	changed := make(map[string]bool)
+	changes := func() {
+		// A sync is started once the running one finishes
+		if done == nil {
+			settle.Reset(watchSettle)
+		}
+	}
+
+	start()
+	stopping := false
+	for {
+		select {
+		case ok := <-done:
+			done = nil
+			w.finished(ok)
+			if stopping {
+				return
+			}
+			if len(changed) > 0 {
+				changes()
+				continue
+			}
+			w.console.infof("Watching %s for changes", w.folder)
+
+		case event, ok := <-w.events.Events:
+			if !ok {
+				return
+			}
+			info, err := os.Stat(event.Name)
+			dir := err == nil && info.IsDir()
+			if event.Op == fsnotify.Chmod || w.ignored(event.Name, dir) {
+				continue
+			}
+			if dir && event.Has(fsnotify.Create) {
+				// A new folder, which may already hold files when it was moved into the vault
+				if err := w.add(event.Name); err != nil {
+					w.console.warnf("%v", err)
+				}
+			}
+			w.console.progressf("Changed: %s", event.Name)
+			changed[event.Name] = true
+			changes()
+
+		case err, ok := <-w.events.Errors:
+			if !ok {
+				return
+			}
+			// Changes may have been lost, as when many files change at once, so the next sync reads every folder again
+			w.console.warnf("watching the vault: %v", err)
+			changed[w.folder] = true
+			changes()
+
+		case <-settle.C:
+			if done != nil {
+				continue
+			}
+			w.console.infof("Changes in the vault (paths changed: %d), syncing", len(changed))
+			for p := range changed {
+				w.listing.forget(p)
+			}
+			changed = make(map[string]bool)
+			start()
+
+		case sig := <-stop:
+			if done == nil {
+				w.console.infof("Received %v, stopping", sig)
+				return
+			}
+			// A second signal stops the process right away
+			if stopping {
+				w.console.infof("Received %v again, stopping now", sig)
+				releaseHeldLocks(w.console)
+				os.Exit(exitFailure)
+			}
+			w.console.infof("Received %v, stopping after the current sync", sig)
+			stopping = true
+		}
+	}
+}
+
+// finished keeps count of consecutive failures after a sync
+func (w *watcher) finished(ok bool) {
+	if !ok {
+		w.failures++
+		w.console.errorf("sync failed (consecutive failures: %d), retrying at the next change", w.failures)
+		return
+	}
+	if w.failures > 0 {
+		w.console.infof("Sync succeeded after %d failed syncs", w.failures)
+	}
+	w.failures = 0
+}
@@ -0,0 +1,70 @@
+package o2q
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultListing(t *testing.T) {
+	vault := writeVault(t, map[string]string{"Home.md": "Home\n", "Notes/A.md": "A\n"})
+	listing := newVaultListing()
+	listing.watch(vault)
+	listing.watch(filepath.Join(vault, "Notes"))
+	opts := testOptions(t, "-incremental")
+	sources := []vaultSource{{folder: vault, sub: ".", listing: listing}}
+	if err := checkOptions(console, &opts, config{}, sources, ""); err != nil {
+		t.Fatal(err)
+	}
+	log := &consoleLogger{verbosity: verbosityNormal, out: io.Discard, err: io.Discard}
+	quartz := t.TempDir()
+	sync := func() {
+		t.Helper()
+		if code := runSources(log, opts, config{}, sources, quartz, ""); code != exitSuccess && code != exitNothingToDo {
+			t.Fatalf("sync exited with %d", code)
+		}
+	}
+	sync()
+
+	// A folder is taken from the listing until a change in it is seen
+	if err := os.WriteFile(filepath.Join(vault, "Notes", "B.md"), []byte("B\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	if got := contentFiles(t, quartz); got != "Home.md, Notes/A.md" {
+		t.Errorf("before the change is seen, published %s", got)
+	}
+	listing.forget(filepath.Join(vault, "Notes", "B.md"))
+	sync()
+	if got := contentFiles(t, quartz); got != "Home.md, Notes/A.md, Notes/B.md" {
+		t.Errorf("after the change is seen, published %s", got)
+	}
+
+	// Forgetting a folder forgets the folders inside it
+	if err := os.WriteFile(filepath.Join(vault, "Notes", "C.md"), []byte("C\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	listing.forget(vault)
+	sync()
+	if got := contentFiles(t, quartz); got != "Home.md, Notes/A.md, Notes/B.md, Notes/C.md" {
+		t.Errorf("after the vault is forgotten, published %s", got)
+	}
+
+	// Folders that are not watched are read by every sync
+	if err := os.MkdirAll(filepath.Join(vault, "Other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vault, "Other", "D.md"), []byte("D\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	listing.forget(filepath.Join(vault, "Other"))
+	sync()
+	if err := os.WriteFile(filepath.Join(vault, "Other", "E.md"), []byte("E\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	if got := contentFiles(t, quartz); got != "Home.md, Notes/A.md, Notes/B.md, Notes/C.md, Other/D.md, Other/E.md" {
+		t.Errorf("with a folder not watched, published %s", got)
+	}
+}